@@ -0,0 +1,66 @@
+package tomato
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/lfq7413/tomato/export"
+	"github.com/lfq7413/tomato/orm"
+)
+
+// Backup 备份数据库中的全部数据到文件 path ，包括 _SCHEMA 、所有类（含系统类）与 _Join 表
+// 备份文件与数据库类型无关，可通过 Restore 恢复到 MongoDB 或 PostgreSQL 中，用于在数据库之间迁移数据
+func Backup(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = export.Backup(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Restore 从 Backup 生成的备份文件 path 中恢复数据到当前配置的数据库
+// 每个类的导入结果输出到日志中，有记录导入失败时返回错误
+func Restore(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	// 创建系统类与必要的索引
+	orm.TomatoDBController.PerformInitialization()
+
+	results, err := export.Restore(file, info.Size())
+	if err != nil {
+		return err
+	}
+	classNames := []string{}
+	for className := range results {
+		classNames = append(classNames, className)
+	}
+	sort.Strings(classNames)
+	failed := 0
+	for _, className := range classNames {
+		result := results[className]
+		log.Println("Restore", className+":", "imported", result.Imported, "failed", result.Failed)
+		for _, message := range result.Errors {
+			log.Println("Restore", className+":", message)
+		}
+		failed += result.Failed
+	}
+	if failed > 0 {
+		return fmt.Errorf("restore: %d records failed", failed)
+	}
+	return nil
+}
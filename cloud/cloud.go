@@ -13,6 +13,35 @@ func validateClassNameForTriggers(className string) error {
 	return nil
 }
 
+// ValidateTrigger 检测回调类型是否存在，以及是否可用于类 className
+// beforeLogin afterLogin 仅用于 _User ， afterLogout 仅用于 _Session
+func ValidateTrigger(triggerType, className string) error {
+	switch triggerType {
+	case TypeBeforeLogin, TypeAfterLogin:
+		if className != "_User" {
+			return errors.New("Only the _User class is allowed for the beforeLogin and afterLogin triggers.")
+		}
+		return nil
+	case TypeAfterLogout:
+		if className != "_Session" {
+			return errors.New("Only the _Session class is allowed for the afterLogout trigger.")
+		}
+		return nil
+	case TypeBeforeSaveFile, TypeAfterSaveFile, TypeBeforeDeleteFile:
+		if className != FileClassName {
+			return errors.New("Only the " + FileClassName + " class is allowed for the file triggers.")
+		}
+		return nil
+	}
+	if className == FileClassName {
+		return errors.New("Only file triggers are allowed for the " + FileClassName + " class.")
+	}
+	if _, ok := triggers[triggerType]; ok == false {
+		return errors.New("Invalid trigger name: " + triggerType)
+	}
+	return validateClassNameForTriggers(className)
+}
+
 // Define ...
 func Define(functionName string, handler FunctionHandler, validationHandler ValidatorHandler) {
 	AddFunction(functionName, handler, validationHandler)
@@ -83,6 +112,36 @@ func AfterFind(className string, handler TriggerHandler) error {
 	return nil
 }
 
+// BeforeLogin 用户登录前回调，包括用户名密码登录与第三方登录，返回错误时拒绝登录
+func BeforeLogin(handler TriggerHandler) {
+	AddTrigger(TypeBeforeLogin, "_User", handler)
+}
+
+// AfterLogin 用户登录后回调，此时 session 已创建
+func AfterLogin(handler TriggerHandler) {
+	AddTrigger(TypeAfterLogin, "_User", handler)
+}
+
+// AfterLogout 用户退出后回调，Object 为已删除的 _Session 对象
+func AfterLogout(handler TriggerHandler) {
+	AddTrigger(TypeAfterLogout, "_Session", handler)
+}
+
+// BeforeSaveFile 文件保存前回调，可以修改 request.File 进行重命名或者替换文件内容
+func BeforeSaveFile(handler TriggerHandler) {
+	AddTrigger(TypeBeforeSaveFile, FileClassName, handler)
+}
+
+// AfterSaveFile 文件保存后回调
+func AfterSaveFile(handler TriggerHandler) {
+	AddTrigger(TypeAfterSaveFile, FileClassName, handler)
+}
+
+// BeforeDeleteFile 文件删除前回调
+func BeforeDeleteFile(handler TriggerHandler) {
+	AddTrigger(TypeBeforeDeleteFile, FileClassName, handler)
+}
+
 // RemoveHook ...
 func RemoveHook(category, name, triggerType string) {
 	Unregister(category, name, triggerType)
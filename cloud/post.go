@@ -1,45 +1,27 @@
 package cloud
 
 import (
-	"bytes"
 	"encoding/json"
-	"io/ioutil"
-	"net/http"
+	"time"
 
-	"github.com/lfq7413/tomato/config"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
+	"github.com/lfq7413/tomato/webhook"
 )
 
-// post 请求网络接口
+// post 请求网络接口，请求使用 WebhookSigningKey 签名， timeout 为 0 时使用 WebhookTimeout
 // 接口返回格式如下：
 // {
 // 	"success":{},
 // 	"error":{},
 // }
-func post(params types.M, URL string) (r types.M, e types.M) {
+func post(params types.M, URL string, timeout time.Duration) (r types.M, e types.M) {
 	jsonParams, err := json.Marshal(params)
 	if err != nil {
 		return types.M{}, types.M{"code": -1, "message": "Malformed response"}
 	}
-	request, err := http.NewRequest("POST", URL, bytes.NewBuffer(jsonParams))
-	if err != nil {
-		return types.M{}, types.M{"code": -1, "message": "Malformed response"}
-	}
-
-	request.Header.Set("Content-Type", "application/json")
-	if config.TConfig.WebhookKey != "" {
-		request.Header.Add("X-Parse-Webhook-Key", config.TConfig.WebhookKey)
-	}
-
-	client := http.DefaultClient
-	response, err := client.Do(request)
-	if err != nil {
-		return types.M{}, types.M{"code": -1, "message": "Malformed response"}
-	}
-	defer response.Body.Close()
 
-	body, err := ioutil.ReadAll(response.Body)
+	body, err := webhook.Post(URL, jsonParams, timeout)
 	if err != nil {
 		return types.M{}, types.M{"code": -1, "message": "Malformed response"}
 	}
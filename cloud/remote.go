@@ -1,43 +1,82 @@
 package cloud
 
-import "github.com/lfq7413/tomato/types"
+import (
+	"encoding/base64"
+	"time"
+
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+	"github.com/lfq7413/tomato/webhook"
+)
 
 // RemoteDefine ...
 func RemoteDefine(functionName string, functionHandlerURL, validatorHandlerURL string) {
-	Define(functionName, GetFunctionHandler(functionHandlerURL), GetValidatorHandler(validatorHandlerURL))
+	Define(functionName, GetFunctionHandler(functionHandlerURL, 0), GetValidatorHandler(validatorHandlerURL, 0))
 }
 
 // RemoteBeforeSave ...
 func RemoteBeforeSave(className string, triggerHandlerURL string) error {
-	return BeforeSave(className, GetTriggerHandler(triggerHandlerURL))
+	return BeforeSave(className, GetTriggerHandler(triggerHandlerURL, 0))
 }
 
 // RemoteBeforeDelete ...
 func RemoteBeforeDelete(className string, triggerHandlerURL string) error {
-	return BeforeDelete(className, GetTriggerHandler(triggerHandlerURL))
+	return BeforeDelete(className, GetTriggerHandler(triggerHandlerURL, 0))
 }
 
 // RemoteAfterSave ...
 func RemoteAfterSave(className string, triggerHandlerURL string) error {
-	return AfterSave(className, GetTriggerHandler(triggerHandlerURL))
+	return AfterSave(className, GetTriggerHandler(triggerHandlerURL, 0))
 }
 
 // RemoteAfterDelete ...
 func RemoteAfterDelete(className string, triggerHandlerURL string) error {
-	return AfterDelete(className, GetTriggerHandler(triggerHandlerURL))
+	return AfterDelete(className, GetTriggerHandler(triggerHandlerURL, 0))
+}
+
+// RemoteBeforeLogin ...
+func RemoteBeforeLogin(triggerHandlerURL string) {
+	BeforeLogin(GetTriggerHandler(triggerHandlerURL, 0))
+}
+
+// RemoteAfterLogin ...
+func RemoteAfterLogin(triggerHandlerURL string) {
+	AfterLogin(GetTriggerHandler(triggerHandlerURL, 0))
+}
+
+// RemoteAfterLogout ...
+func RemoteAfterLogout(triggerHandlerURL string) {
+	AfterLogout(GetTriggerHandler(triggerHandlerURL, 0))
 }
 
-// GetFunctionHandler ...
-func GetFunctionHandler(url string) FunctionHandler {
+// RemoteBeforeSaveFile ...
+func RemoteBeforeSaveFile(triggerHandlerURL string) {
+	BeforeSaveFile(GetTriggerHandler(triggerHandlerURL, 0))
+}
+
+// RemoteAfterSaveFile ...
+func RemoteAfterSaveFile(triggerHandlerURL string) {
+	AfterSaveFile(GetTriggerHandler(triggerHandlerURL, 0))
+}
+
+// RemoteBeforeDeleteFile ...
+func RemoteBeforeDeleteFile(triggerHandlerURL string) {
+	BeforeDeleteFile(GetTriggerHandler(triggerHandlerURL, 0))
+}
+
+// GetFunctionHandler 生成调用远程函数的处理器， timeout 为请求超时时间，为 0 时使用 WebhookTimeout
+func GetFunctionHandler(url string, timeout time.Duration) FunctionHandler {
 	return func(request FunctionRequest, response Response) {
 		params := types.M{
 			"params":         request.Params,
 			"master":         request.Master,
 			"user":           request.User,
 			"installationID": request.InstallationID,
+			"ip":             request.IP,
 			"headers":        request.Headers,
+			"context":        request.Context,
 		}
-		result, err := post(params, url)
+		result, err := post(params, url, timeout)
 		if err != nil {
 			response.Error(err["code"].(int), err["message"].(string))
 			return
@@ -46,17 +85,19 @@ func GetFunctionHandler(url string) FunctionHandler {
 	}
 }
 
-// GetValidatorHandler ...
-func GetValidatorHandler(url string) ValidatorHandler {
+// GetValidatorHandler 生成调用远程校验函数的处理器， timeout 为请求超时时间，为 0 时使用 WebhookTimeout
+func GetValidatorHandler(url string, timeout time.Duration) ValidatorHandler {
 	return func(request FunctionRequest) bool {
 		params := types.M{
 			"params":         request.Params,
 			"master":         request.Master,
 			"user":           request.User,
 			"installationID": request.InstallationID,
+			"ip":             request.IP,
 			"headers":        request.Headers,
+			"context":        request.Context,
 		}
-		result, _ := post(params, url)
+		result, _ := post(params, url, timeout)
 		if v, ok := result["result"].(bool); ok {
 			return v
 		}
@@ -64,8 +105,10 @@ func GetValidatorHandler(url string) ValidatorHandler {
 	}
 }
 
-// GetTriggerHandler ...
-func GetTriggerHandler(url string) TriggerHandler {
+// GetTriggerHandler 生成调用远程回调的处理器， timeout 为请求超时时间，为 0 时使用 WebhookTimeout
+// afterSave afterDelete afterLogin afterLogout afterSaveFile 的返回值不影响请求结果，在后台发送，失败时按指数退避重试，全部失败后记录在 _WebhookDelivery 中
+// 文件回调的请求中包含 file 字段，beforeSaveFile 可以返回 {"name":"a.png","contentType":"image/png","base64":"xxx"} 修改文件
+func GetTriggerHandler(url string, timeout time.Duration) TriggerHandler {
 	return func(request TriggerRequest, response Response) {
 		params := types.M{
 			"triggerName":    request.TriggerName,
@@ -74,8 +117,29 @@ func GetTriggerHandler(url string) TriggerHandler {
 			"master":         request.Master,
 			"user":           request.User,
 			"installationID": request.InstallationID,
+			"ip":             request.IP,
+			"headers":        request.Headers,
+			"context":        request.Context,
+		}
+		className := utils.S(request.Object["className"])
+		if request.File != nil {
+			params["file"] = fileParams(request.TriggerName, request.File)
+			className = FileClassName
 		}
-		result, err := post(params, url)
+		if request.TriggerName == TypeAfterSave || request.TriggerName == TypeAfterDelete ||
+			request.TriggerName == TypeAfterLogin || request.TriggerName == TypeAfterLogout ||
+			request.TriggerName == TypeAfterSaveFile {
+			webhook.DeliverAsync(&webhook.Delivery{
+				URL:         url,
+				Payload:     params,
+				Timeout:     timeout,
+				ClassName:   className,
+				TriggerName: request.TriggerName,
+			})
+			response.Success(nil)
+			return
+		}
+		result, err := post(params, url, timeout)
 		if err != nil {
 			response.Error(err["code"].(int), err["message"].(string))
 			return
@@ -85,6 +149,48 @@ func GetTriggerHandler(url string) TriggerHandler {
 			delete(result, "updatedAt")
 			request.Object = result
 		}
+		if request.TriggerName == TypeBeforeSaveFile && request.File != nil {
+			if applyFileResult(request.File, result) == false {
+				response.Error(0, "Invalid file data.")
+				return
+			}
+		}
 		response.Success(nil)
 	}
 }
+
+// fileParams 文件回调中发送的文件信息，文件内容以 base64 编码
+// afterSaveFile 在后台发送，失败时会记录在 _WebhookDelivery 中，因此仅发送文件地址，不包含文件内容
+func fileParams(triggerName string, file *File) types.M {
+	params := types.M{
+		"name":        file.Name,
+		"contentType": file.ContentType,
+		"size":        file.Size,
+	}
+	if file.URL != "" {
+		params["url"] = file.URL
+	}
+	if file.Data != nil && triggerName != TypeAfterSaveFile {
+		params["base64"] = base64.StdEncoding.EncodeToString(file.Data)
+	}
+	return params
+}
+
+// applyFileResult 使用 beforeSaveFile 的返回结果修改文件，base64 无法解码时返回 false
+func applyFileResult(file *File, result types.M) bool {
+	if name := utils.S(result["name"]); name != "" {
+		file.Name = name
+	}
+	if contentType := utils.S(result["contentType"]); contentType != "" {
+		file.ContentType = contentType
+	}
+	if s, ok := result["base64"].(string); ok {
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return false
+		}
+		file.Data = data
+		file.Size = len(data)
+	}
+	return true
+}
@@ -1,7 +1,9 @@
 package cloud
 
 import (
+	"context"
 	"reflect"
+	"time"
 
 	"github.com/lfq7413/tomato/errs"
 	"github.com/lfq7413/tomato/types"
@@ -21,8 +23,23 @@ const (
 	TypeBeforeFind = "beforeFind"
 	// TypeAfterFind 查询后回调
 	TypeAfterFind = "afterFind"
+	// TypeBeforeLogin 登录前回调，仅用于 _User ，返回错误时拒绝登录
+	TypeBeforeLogin = "beforeLogin"
+	// TypeAfterLogin 登录后回调，仅用于 _User
+	TypeAfterLogin = "afterLogin"
+	// TypeAfterLogout 退出后回调，仅用于 _Session
+	TypeAfterLogout = "afterLogout"
+	// TypeBeforeSaveFile 文件保存前回调，可以修改文件名、类型与内容，返回错误时拒绝保存
+	TypeBeforeSaveFile = "beforeSaveFile"
+	// TypeAfterSaveFile 文件保存后回调
+	TypeAfterSaveFile = "afterSaveFile"
+	// TypeBeforeDeleteFile 文件删除前回调，返回错误时拒绝删除
+	TypeBeforeDeleteFile = "beforeDeleteFile"
 )
 
+// FileClassName 文件回调使用的类名
+const FileClassName = "@File"
+
 // TriggerRequest ...
 type TriggerRequest struct {
 	TriggerName    string
@@ -31,9 +48,23 @@ type TriggerRequest struct {
 	Query          types.M // beforeFind 时使用
 	Count          bool    // beforeFind 时使用
 	Objects        types.S // afterFind 时使用
+	File           *File   // 文件回调时使用
 	Master         bool
 	User           types.M
 	InstallationID string
+	IP             string
+	Headers        map[string]string
+	Context        types.M // 同一个请求的各个回调共享，可用于在 beforeSave 与 afterSave 之间传递数据
+}
+
+// File 文件回调中的文件信息，beforeSaveFile 中可以直接修改 Name ContentType Data
+// beforeDeleteFile 中仅包含 Name ，afterSaveFile 中 Name 为保存后的文件名，并包含 URL
+type File struct {
+	Name        string
+	ContentType string
+	Size        int
+	Data        []byte
+	URL         string
 }
 
 // FunctionRequest ...
@@ -42,16 +73,20 @@ type FunctionRequest struct {
 	Master         bool
 	User           types.M
 	InstallationID string
+	IP             string
 	Headers        map[string]string
+	Context        types.M
 	FunctionName   string
 }
 
 // JobRequest ...
+// Context 在任务被取消或者超过最长执行时间时关闭，任务应及时检查并退出
 type JobRequest struct {
 	Params  types.M
 	Headers map[string]string
 	JobName string
 	JobID   string
+	Context context.Context
 }
 
 // Response ...
@@ -76,19 +111,31 @@ var triggers map[string]map[string]TriggerHandler
 var functions map[string]FunctionHandler
 var validators map[string]ValidatorHandler
 var jobs map[string]JobHandler
+var jobTimeouts map[string]time.Duration
 
 func init() {
-	triggers = map[string]map[string]TriggerHandler{
-		TypeBeforeSave:   map[string]TriggerHandler{},
-		TypeAfterSave:    map[string]TriggerHandler{},
-		TypeBeforeDelete: map[string]TriggerHandler{},
-		TypeAfterDelete:  map[string]TriggerHandler{},
-		TypeBeforeFind:   map[string]TriggerHandler{},
-		TypeAfterFind:    map[string]TriggerHandler{},
-	}
+	triggers = newTriggers()
 	functions = map[string]FunctionHandler{}
 	validators = map[string]ValidatorHandler{}
 	jobs = map[string]JobHandler{}
+	jobTimeouts = map[string]time.Duration{}
+}
+
+func newTriggers() map[string]map[string]TriggerHandler {
+	return map[string]map[string]TriggerHandler{
+		TypeBeforeSave:       map[string]TriggerHandler{},
+		TypeAfterSave:        map[string]TriggerHandler{},
+		TypeBeforeDelete:     map[string]TriggerHandler{},
+		TypeAfterDelete:      map[string]TriggerHandler{},
+		TypeBeforeFind:       map[string]TriggerHandler{},
+		TypeAfterFind:        map[string]TriggerHandler{},
+		TypeBeforeLogin:      map[string]TriggerHandler{},
+		TypeAfterLogin:       map[string]TriggerHandler{},
+		TypeAfterLogout:      map[string]TriggerHandler{},
+		TypeBeforeSaveFile:   map[string]TriggerHandler{},
+		TypeAfterSaveFile:    map[string]TriggerHandler{},
+		TypeBeforeDeleteFile: map[string]TriggerHandler{},
+	}
 }
 
 // AddFunction 添加函数到列表
@@ -102,6 +149,11 @@ func AddJob(name string, handler JobHandler) {
 	jobs[name] = handler
 }
 
+// SetJobTimeout 设置任务的最长执行时间，超时后任务将被取消
+func SetJobTimeout(name string, timeout time.Duration) {
+	jobTimeouts[name] = timeout
+}
+
 // AddTrigger 添加回调函数
 func AddTrigger(triggerType string, className string, handler TriggerHandler) {
 	triggers[triggerType][className] = handler
@@ -142,12 +194,7 @@ func Unregister(category, name, triggerType string) {
 
 // UnregisterAll 删除所有注册的云代码
 func UnregisterAll() {
-	triggers = map[string]map[string]TriggerHandler{
-		TypeBeforeSave:   map[string]TriggerHandler{},
-		TypeAfterSave:    map[string]TriggerHandler{},
-		TypeBeforeDelete: map[string]TriggerHandler{},
-		TypeAfterDelete:  map[string]TriggerHandler{},
-	}
+	triggers = newTriggers()
 	functions = map[string]FunctionHandler{}
 	validators = map[string]ValidatorHandler{}
 	jobs = map[string]JobHandler{}
@@ -205,6 +252,14 @@ func GetJob(name string) JobHandler {
 	return nil
 }
 
+// GetJobTimeout 获取任务的最长执行时间，未设置时返回 0
+func GetJobTimeout(name string) time.Duration {
+	if jobTimeouts == nil {
+		return 0
+	}
+	return jobTimeouts[name]
+}
+
 // GetJobs 获取定时任务
 func GetJobs() map[string]JobHandler {
 	if jobs == nil {
@@ -279,6 +334,7 @@ type JobStatus interface {
 	SetSucceeded(message string)
 	SetFailed(message string)
 	SetMessage(message string)
+	SetProgress(progress float64)
 }
 
 // JobResponse ...
@@ -300,3 +356,8 @@ func (j JobResponse) Error(message string) {
 func (j JobResponse) Message(message string) {
 	j.JobStatus.SetMessage(message)
 }
+
+// Progress 报告任务进度，取值范围为 0-100
+func (j JobResponse) Progress(progress float64) {
+	j.JobStatus.SetProgress(progress)
+}
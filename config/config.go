@@ -47,10 +47,13 @@ type Config struct {
 	TencentAppID                     string   // 腾讯云存储 AppID ，仅在 FileAdapter=Tencent 时需要配置
 	TencentSecretID                  string   // 腾讯云存储 SecretID ，仅在 FileAdapter=Tencent 时需要配置
 	TencentSecretKey                 string   // 腾讯云存储 SecretKey ，仅在 FileAdapter=Tencent 时需要配置
-	PushAdapter                      string   // 推送模块，可选：FCM，默认为 tomato
+	PushAdapter                      string   // 推送模块，可选：FCM、APNs，默认为 tomato
 	PushChannel                      string   // 推送通道
 	PushBatchSize                    int      // 批量推送的大小
 	ScheduledPush                    bool     // 是否有推送调度器
+	ScheduledPushInterval            int      // 推送调度器检查定时推送的间隔，单位为秒，默认为 60
+	JobSchedulerInterval             int      // 后台任务调度器检查 _JobSchedule 的间隔，单位为秒，取值大于等于 0 ，默认为 10 ，0 表示不启用调度器
+	JobMaxRuntime                    int      // 后台任务的最长执行时间，单位为秒，取值大于等于 0 ，默认为 0 表示不限制，可通过 cloud.SetJobTimeout 为单个任务设置
 	LiveQueryClasses                 string   // LiveQuery 支持的 classe ，多个 class 使用 | 隔开，如： classeA|classeB|classeC
 	PublisherType                    string   // 发布者类型，可选：Redis ，默认使用自带的 EventEmitter
 	PublisherURL                     string   // 发布者地址， PublisherType=Redis 时必填
@@ -63,7 +66,12 @@ type Config struct {
 	RedisPassword                    string   // Redis 密码，选填
 	SchemaCacheTTL                   int      // Schema 缓存有效期，单位为秒。取值： -1 表示永不过期，0 表示使用 CacheAdapter 自身的有效期，或者大于 0 ，默认为 5 秒
 	EnableSingleSchemaCache          bool     // 是否允许缓存唯一一份 SchemaCache ，默认为 false 不允许
-	WebhookKey                       string   // 用于云代码鉴权
+	WebhookKey                       string   // 用于云代码鉴权，设置后远程回调请求将通过 X-Parse-Webhook-Key 请求头发送该值
+	WebhookSigningKey                string   // 远程回调的签名密钥，设置后请求将使用该值进行 HMAC-SHA256 签名，该值不会随请求发送，不能与 WebhookKey 相同
+	WebhookTimeout                   int      // 远程回调的超时时间，单位为毫秒，取值大于 0 ，默认为 30000 ，可在注册回调时通过 timeout 为单个回调设置
+	WebhookRetries                   int      // afterSave afterDelete 远程回调失败后的重试次数，取值大于等于 0 ，默认为 3 ，全部失败后记录在 _WebhookDelivery 中
+	WebhookRetryInterval             int      // 远程回调第一次重试前的等待时间，单位为毫秒，之后每次重试等待时间翻倍，取值大于 0 ，默认为 1000
+	WebhookSubscriptionCacheTTL      int      // 数据变更事件订阅的缓存有效期，单位为秒，过期后从数据库重新加载，以同步其他实例中的修改，取值大于等于 0 ，默认为 30 ，0 表示永不过期
 	EnableAccountLockout             bool     // 是否启用账户锁定规则，默认为 false 不启用
 	AccountLockoutThreshold          int      // 锁定账户需要的登录失败次数，取值范围： 1-999 ，默认为 3 次
 	AccountLockoutDuration           int      // 锁定账户时长，单位为分钟，取值范围： 1-99999 ，默认为 10 分钟
@@ -73,6 +81,7 @@ type Config struct {
 	DoNotAllowUsername               bool     // 是否启用密码中不允许包含用户名，默认为 false 不启用，密码中可包含用户名
 	MaxPasswordAge                   int      // 密码的最长使用时间，单位为天，取值大于等于 0 ，默认为 0 表示不设置最长使用时间
 	MaxPasswordHistory               int      // 最大密码历史个数，修改的密码不能与密码历史重复，取值范围： 0-20 ，默认为 0 表示不设置密码历史
+	PasswordHashCost                 int      // 密码哈希 bcrypt 计算强度，取值范围： 4-31 ，默认为 10
 	UserSensitiveFields              []string // 用户敏感字段，按需删除，多个字段使用 | 删除，如： email|password
 	AnalyticsAdapter                 string   // 分析模块，可选：InfluxDB，默认使用空的分析模块
 	InfluxDBURL                      string   // InfluxDB 地址，仅在 AnalyticsAdapter=InfluxDB 时需要配置
@@ -88,6 +97,20 @@ type Config struct {
 	PasswordResetSuccess             string   // 自定义页面地址，密码重置成功页面
 	ParseFrameURL                    string   // 自定义页面地址，用于呈现验证 Email 页面和密码重置页面
 	FCMServerKey                     string   // FCM Server Key
+	APNsKeyFile                      string   // APNs 鉴权使用的 .p8 私钥文件，与 APNsKeyID 、 APNsTeamID 一起使用
+	APNsKeyID                        string   // APNs .p8 私钥的 Key ID
+	APNsTeamID                       string   // APNs 开发者账号的 Team ID
+	APNsCertFile                     string   // APNs 推送证书文件， PEM 格式，未设置 APNsKeyFile 时使用证书鉴权
+	APNsCertKeyFile                  string   // APNs 推送证书的私钥文件， PEM 格式
+	APNsTopic                        string   // APNs 默认的 topic ，一般为应用的 bundle id ，设备未设置 appIdentifier 时使用
+	APNsProduction                   bool     // 是否使用 APNs 生产环境，默认为 false 使用开发环境
+	LoggerAdapter                    string   // 日志模块，可选：Beego、File，默认为 Beego 输出到控制台， File 以 JSON 格式逐行写入本地文件，支持日志查询
+	LogsFolder                       string   // 日志文件夹，仅在 LoggerAdapter=File 时需要配置，默认为 ./logs
+	LogMaxSize                       int      // 单个日志文件的最大大小，单位为 MB ，取值大于等于 0 ，默认为 100 ，0 表示不限制大小
+	LogMaxFiles                      int      // 保留的日志文件个数，取值大于等于 0 ，默认为 0 表示保留全部日志文件
+	SchemaFile                       string   // 类定义文件， JSON 格式与 GET /schemas 的返回值相同，设置后启动时将数据库中的 schema 同步为文件中的定义
+	SchemaDryRun                     bool     // 是否仅打印类定义文件与数据库的差异而不执行修改，默认为 false
+	SchemaStrict                     bool     // 是否拒绝删除仍有数据的字段，默认为 true 拒绝删除并停止启动
 }
 
 var (
@@ -138,6 +161,11 @@ func parseConfig() {
 	TConfig.MailUsername = beego.AppConfig.String("MailUsername")
 	TConfig.MailPassword = beego.AppConfig.String("MailPassword")
 	TConfig.WebhookKey = beego.AppConfig.String("WebhookKey")
+	TConfig.WebhookSigningKey = beego.AppConfig.String("WebhookSigningKey")
+	TConfig.WebhookTimeout = beego.AppConfig.DefaultInt("WebhookTimeout", 30000)
+	TConfig.WebhookRetries = beego.AppConfig.DefaultInt("WebhookRetries", 3)
+	TConfig.WebhookRetryInterval = beego.AppConfig.DefaultInt("WebhookRetryInterval", 1000)
+	TConfig.WebhookSubscriptionCacheTTL = beego.AppConfig.DefaultInt("WebhookSubscriptionCacheTTL", 30)
 
 	TConfig.EnableAccountLockout = beego.AppConfig.DefaultBool("EnableAccountLockout", false)
 	TConfig.AccountLockoutThreshold = beego.AppConfig.DefaultInt("AccountLockoutThreshold", 3)
@@ -172,6 +200,7 @@ func parseConfig() {
 	TConfig.DoNotAllowUsername = beego.AppConfig.DefaultBool("DoNotAllowUsername", false)
 	TConfig.MaxPasswordAge = beego.AppConfig.DefaultInt("MaxPasswordAge", 0)
 	TConfig.MaxPasswordHistory = beego.AppConfig.DefaultInt("MaxPasswordHistory", 0)
+	TConfig.PasswordHashCost = beego.AppConfig.DefaultInt("PasswordHashCost", 10)
 
 	for _, field := range strings.Split(beego.AppConfig.String("UserSensitiveFields"), "|") {
 		TConfig.UserSensitiveFields = append(TConfig.UserSensitiveFields, field)
@@ -192,8 +221,27 @@ func parseConfig() {
 	TConfig.PushChannel = beego.AppConfig.String("PushChannel")
 	TConfig.PushBatchSize = beego.AppConfig.DefaultInt("PushBatchSize", 0)
 	TConfig.ScheduledPush = beego.AppConfig.DefaultBool("ScheduledPush", false)
+	TConfig.ScheduledPushInterval = beego.AppConfig.DefaultInt("ScheduledPushInterval", 60)
+	TConfig.JobSchedulerInterval = beego.AppConfig.DefaultInt("JobSchedulerInterval", 10)
+	TConfig.JobMaxRuntime = beego.AppConfig.DefaultInt("JobMaxRuntime", 0)
 
 	TConfig.FCMServerKey = beego.AppConfig.String("FCMServerKey")
+	TConfig.APNsKeyFile = beego.AppConfig.String("APNsKeyFile")
+	TConfig.APNsKeyID = beego.AppConfig.String("APNsKeyID")
+	TConfig.APNsTeamID = beego.AppConfig.String("APNsTeamID")
+	TConfig.APNsCertFile = beego.AppConfig.String("APNsCertFile")
+	TConfig.APNsCertKeyFile = beego.AppConfig.String("APNsCertKeyFile")
+	TConfig.APNsTopic = beego.AppConfig.String("APNsTopic")
+	TConfig.APNsProduction = beego.AppConfig.DefaultBool("APNsProduction", false)
+
+	TConfig.LoggerAdapter = beego.AppConfig.DefaultString("LoggerAdapter", "Beego")
+	TConfig.LogsFolder = beego.AppConfig.DefaultString("LogsFolder", "./logs")
+	TConfig.LogMaxSize = beego.AppConfig.DefaultInt("LogMaxSize", 100)
+	TConfig.LogMaxFiles = beego.AppConfig.DefaultInt("LogMaxFiles", 0)
+
+	TConfig.SchemaFile = beego.AppConfig.String("SchemaFile")
+	TConfig.SchemaDryRun = beego.AppConfig.DefaultBool("SchemaDryRun", false)
+	TConfig.SchemaStrict = beego.AppConfig.DefaultBool("SchemaStrict", true)
 }
 
 // Validate 校验用户参数合法性
@@ -206,8 +254,12 @@ func Validate() {
 	validateSessionConfiguration()
 	validateAccountLockoutPolicy()
 	validatePasswordPolicy()
+	validatePasswordHashCost()
 	validateCacheConfiguration()
 	validateAnalyticsConfiguration()
+	validateLoggerConfiguration()
+	validateJobConfiguration()
+	validateWebhookConfiguration()
 }
 
 // validateApplicationConfiguration 校验应用相关参数
@@ -257,7 +309,18 @@ func validateFileConfiguration() {
 
 // validatePushConfiguration 校验推送相关参数
 func validatePushConfiguration() {
-	// TODO
+	if TConfig.ScheduledPush && TConfig.ScheduledPushInterval <= 0 {
+		log.Fatalln("ScheduledPushInterval must be a value greater than 0")
+	}
+	if TConfig.PushAdapter == "APNs" {
+		if TConfig.APNsKeyFile != "" {
+			if TConfig.APNsKeyID == "" || TConfig.APNsTeamID == "" {
+				log.Fatalln("APNsKeyID, APNsTeamID is required")
+			}
+		} else if TConfig.APNsCertFile == "" || TConfig.APNsCertKeyFile == "" {
+			log.Fatalln("APNsKeyFile or APNsCertFile, APNsCertKeyFile is required")
+		}
+	}
 }
 
 // validateMailConfiguration 校验发送邮箱相关参数
@@ -341,6 +404,13 @@ func validatePasswordPolicy() {
 	}
 }
 
+// validatePasswordHashCost 校验密码哈希计算强度
+func validatePasswordHashCost() {
+	if TConfig.PasswordHashCost < 4 || TConfig.PasswordHashCost > 31 {
+		log.Fatalln("PasswordHashCost must be an integer ranging 4 - 31")
+	}
+}
+
 // validateCacheConfiguration 校验缓存相关参数
 func validateCacheConfiguration() {
 	adapter := TConfig.CacheAdapter
@@ -382,6 +452,52 @@ func validateAnalyticsConfiguration() {
 	}
 }
 
+// validateLoggerConfiguration 校验日志模块相关参数
+func validateLoggerConfiguration() {
+	adapter := TConfig.LoggerAdapter
+	switch adapter {
+	case "", "Beego":
+	case "File":
+		if TConfig.LogMaxSize < 0 {
+			log.Fatalln("LogMaxSize must be a value greater than or equal to 0")
+		}
+		if TConfig.LogMaxFiles < 0 {
+			log.Fatalln("LogMaxFiles must be a value greater than or equal to 0")
+		}
+	default:
+		log.Fatalln("Unsupported LoggerAdapter")
+	}
+}
+
+// validateJobConfiguration 校验后台任务相关参数
+func validateJobConfiguration() {
+	if TConfig.JobSchedulerInterval < 0 {
+		log.Fatalln("JobSchedulerInterval must be a value greater than or equal to 0")
+	}
+	if TConfig.JobMaxRuntime < 0 {
+		log.Fatalln("JobMaxRuntime must be a value greater than or equal to 0")
+	}
+}
+
+// validateWebhookConfiguration 校验远程回调相关参数
+func validateWebhookConfiguration() {
+	if TConfig.WebhookTimeout <= 0 {
+		log.Fatalln("WebhookTimeout must be a value greater than 0")
+	}
+	if TConfig.WebhookRetries < 0 {
+		log.Fatalln("WebhookRetries must be a value greater than or equal to 0")
+	}
+	if TConfig.WebhookRetryInterval <= 0 {
+		log.Fatalln("WebhookRetryInterval must be a value greater than 0")
+	}
+	if TConfig.WebhookSubscriptionCacheTTL < 0 {
+		log.Fatalln("WebhookSubscriptionCacheTTL must be a value greater than or equal to 0")
+	}
+	if TConfig.WebhookSigningKey != "" && TConfig.WebhookSigningKey == TConfig.WebhookKey {
+		log.Fatalln("WebhookSigningKey must be different from WebhookKey")
+	}
+}
+
 // GenerateSessionExpiresAt 获取 Session 过期时间
 func GenerateSessionExpiresAt() time.Time {
 	expiresAt := time.Now().UTC()
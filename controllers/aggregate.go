@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"encoding/json"
+
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/rest"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+// AggregateController 处理 /aggregate 接口的请求
+type AggregateController struct {
+	ClassesController
+}
+
+// HandleFind 处理聚合查询请求
+// 传入 distinct 参数时，查询指定字段的不重复值，遵循当前用户的 ACL 与 CLP 限制，可同时传入 where 参数
+// 否则执行 pipeline 聚合查询，需要 master key
+// pipeline 为聚合阶段数组，支持 $match $group $project $sort $limit $skip $unwind
+// 可以通过 URL 参数传入 json 字符串，也可以在请求体中传入数组
+// @router /:className [get]
+func (a *AggregateController) HandleFind() {
+	if a.ClassName == "" {
+		a.ClassName = a.Ctx.Input.Param(":className")
+	}
+
+	if a.Query["distinct"] != "" || (a.JSONBody != nil && a.JSONBody["distinct"] != nil) {
+		a.handleDistinct()
+		return
+	}
+
+	if a.EnforceMasterKeyAccess() == false {
+		return
+	}
+
+	pipeline := types.S{}
+	if a.Query["pipeline"] != "" {
+		err := json.Unmarshal([]byte(a.Query["pipeline"]), &pipeline)
+		if err != nil {
+			a.HandleError(errs.E(errs.InvalidJSON, "pipeline should be valid json array"), 0)
+			return
+		}
+	} else if a.JSONBody != nil && a.JSONBody["pipeline"] != nil {
+		pipeline = utils.A(a.JSONBody["pipeline"])
+		if pipeline == nil {
+			a.HandleError(errs.E(errs.InvalidQuery, "pipeline should be an array"), 0)
+			return
+		}
+	}
+
+	results, err := orm.TomatoDBController.Aggregate(a.ClassName, pipeline)
+	if err != nil {
+		a.HandleError(err, 0)
+		return
+	}
+
+	a.Data["json"] = types.M{
+		"results": results,
+	}
+	a.ServeJSON()
+}
+
+// handleDistinct 处理 distinct 查询
+func (a *AggregateController) handleDistinct() {
+	var distinct string
+	if a.Query["distinct"] != "" {
+		distinct = a.Query["distinct"]
+	} else {
+		distinct = utils.S(a.JSONBody["distinct"])
+	}
+	if distinct == "" {
+		a.HandleError(errs.E(errs.InvalidQuery, "distinct should be a field name"), 0)
+		return
+	}
+
+	where := types.M{}
+	if a.Query["where"] != "" {
+		err := json.Unmarshal([]byte(a.Query["where"]), &where)
+		if err != nil {
+			a.HandleError(errs.E(errs.InvalidJSON, "where should be valid json"), 0)
+			return
+		}
+	} else if a.JSONBody != nil && a.JSONBody["where"] != nil {
+		where = utils.M(a.JSONBody["where"])
+	}
+
+	response, err := rest.Find(a.Auth, a.ClassName, where, types.M{"distinct": distinct}, a.Info.ClientSDK)
+	if err != nil {
+		a.HandleError(err, 0)
+		return
+	}
+	a.Data["json"] = types.M{
+		"results": response["results"],
+	}
+	a.ServeJSON()
+}
+
+// Get ...
+// @router / [get]
+func (a *AggregateController) Get() {
+	a.ClassesController.Get()
+}
+
+// Post ...
+// @router / [post]
+func (a *AggregateController) Post() {
+	a.ClassesController.Post()
+}
+
+// Delete ...
+// @router / [delete]
+func (a *AggregateController) Delete() {
+	a.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (a *AggregateController) Put() {
+	a.ClassesController.Put()
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/rest"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+// audienceFields _Audience 中允许通过接口设置的字段
+var audienceFields = []string{"name", "query"}
+
+// AudiencesController 处理 /push_audiences 接口的请求
+// 推送受众保存在 _Audience 中， query 为 _Installation 的查询条件，
+// 以 JSON 字符串的形式保存，返回时转换为对象
+type AudiencesController struct {
+	ClassesController
+}
+
+// Prepare ...
+func (a *AudiencesController) Prepare() {
+	a.ClassesController.Prepare()
+	if a.Ctx.ResponseWriter.Started == false {
+		a.EnforceMasterKeyAccess()
+	}
+}
+
+// HandleFind 处理查找推送受众请求
+// @router / [get]
+func (a *AudiencesController) HandleFind() {
+	where, options, err := a.findOptions()
+	if err != nil {
+		a.HandleError(err, 0)
+		return
+	}
+	response, err := rest.Find(a.Auth, "_Audience", where, options, a.Info.ClientSDK)
+	if err != nil {
+		a.HandleError(err, 0)
+		return
+	}
+	for _, v := range utils.A(response["results"]) {
+		if audience := utils.M(v); audience != nil {
+			parseAudienceQuery(audience)
+		}
+	}
+	a.Data["json"] = response
+	a.ServeJSON()
+}
+
+// HandleGet 处理获取指定推送受众请求
+// @router /:objectId [get]
+func (a *AudiencesController) HandleGet() {
+	objectID := a.Ctx.Input.Param(":objectId")
+	response, err := rest.Get(a.Auth, "_Audience", objectID, types.M{}, a.Info.ClientSDK)
+	if err != nil {
+		a.HandleError(err, 0)
+		return
+	}
+	results := utils.A(response["results"])
+	if len(results) == 0 {
+		a.HandleError(errs.E(errs.ObjectNotFound, "Object not found."), 0)
+		return
+	}
+	audience := utils.M(results[0])
+	parseAudienceQuery(audience)
+	a.Data["json"] = audience
+	a.ServeJSON()
+}
+
+// HandleCreate 处理创建推送受众请求，请求格式如下
+// {
+// 	"name":"iOS users",
+// 	"query":{"deviceType":"ios"}
+// }
+// query 也可以是 JSON 字符串
+// @router / [post]
+func (a *AudiencesController) HandleCreate() {
+	audience, err := a.audience()
+	if err != nil {
+		a.HandleError(err, 0)
+		return
+	}
+	if utils.S(audience["name"]) == "" {
+		a.HandleError(errs.E(errs.InvalidJSON, "name is required"), 0)
+		return
+	}
+	if audience["query"] == nil {
+		a.HandleError(errs.E(errs.InvalidJSON, "query is required"), 0)
+		return
+	}
+	// lockdown!
+	audience["ACL"] = types.M{}
+
+	result, err := rest.Create(a.Auth, "_Audience", audience, a.Info.ClientSDK)
+	if err != nil {
+		a.HandleError(err, 0)
+		return
+	}
+	a.Data["json"] = result["response"]
+	a.Ctx.Output.SetStatus(201)
+	a.Ctx.Output.Header("Location", utils.S(result["location"]))
+	a.ServeJSON()
+}
+
+// HandleUpdate 处理更新推送受众请求，请求格式与创建时相同
+// @router /:objectId [put]
+func (a *AudiencesController) HandleUpdate() {
+	objectID := a.Ctx.Input.Param(":objectId")
+	audience, err := a.audience()
+	if err != nil {
+		a.HandleError(err, 0)
+		return
+	}
+	if audience["name"] != nil && utils.S(audience["name"]) == "" {
+		a.HandleError(errs.E(errs.InvalidJSON, "name should be a non-empty string"), 0)
+		return
+	}
+
+	result, err := rest.Update(a.Auth, "_Audience", objectID, audience, a.Info.ClientSDK)
+	if err != nil {
+		a.HandleError(err, 0)
+		return
+	}
+	a.Data["json"] = result["response"]
+	a.ServeJSON()
+}
+
+// HandleDelete 处理删除推送受众请求
+// @router /:objectId [delete]
+func (a *AudiencesController) HandleDelete() {
+	objectID := a.Ctx.Input.Param(":objectId")
+	err := rest.Delete(a.Auth, "_Audience", objectID)
+	if err != nil {
+		a.HandleError(err, 0)
+		return
+	}
+	a.Data["json"] = types.M{}
+	a.ServeJSON()
+}
+
+// audience 获取请求中的推送受众信息，仅允许设置 name 与 query
+// query 使用与发送推送时相同的规则校验，并转换为 JSON 字符串
+func (a *AudiencesController) audience() (types.M, error) {
+	if a.JSONBody == nil {
+		return nil, errs.E(errs.InvalidJSON, "request body is empty")
+	}
+	audience := types.M{}
+	for k, v := range a.JSONBody {
+		valid := false
+		for _, field := range audienceFields {
+			if k == field {
+				valid = true
+				break
+			}
+		}
+		if valid == false {
+			return nil, errs.E(errs.InvalidKeyName, "Invalid field for audience: "+k)
+		}
+		audience[k] = v
+	}
+	if audience["name"] != nil {
+		if _, ok := audience["name"].(string); ok == false {
+			return nil, errs.E(errs.InvalidJSON, "name should be a string")
+		}
+	}
+
+	if audience["query"] != nil {
+		var query interface{}
+		if s, ok := audience["query"].(string); ok {
+			if err := json.Unmarshal([]byte(s), &query); err != nil {
+				return nil, errs.E(errs.InvalidJSON, "query should be valid json")
+			}
+		} else {
+			query = audience["query"]
+		}
+		where, err := getQueryCondition(types.M{"where": query})
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(where)
+		if err != nil {
+			return nil, errs.E(errs.InvalidJSON, "query should be valid json")
+		}
+		audience["query"] = string(b)
+	}
+	return audience, nil
+}
+
+// parseAudienceQuery 把推送受众中以字符串保存的 query 转换为对象
+func parseAudienceQuery(audience types.M) {
+	s, ok := audience["query"].(string)
+	if ok == false {
+		return
+	}
+	var query types.M
+	if err := json.Unmarshal([]byte(s), &query); err == nil {
+		audience["query"] = query
+	}
+}
+
+// audienceWhere 获取推送受众中保存的 _Installation 查询条件
+func audienceWhere(audienceID string) (types.M, error) {
+	response, err := rest.Get(rest.Master(), "_Audience", audienceID, types.M{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	results := utils.A(response["results"])
+	if len(results) == 0 {
+		return nil, errs.E(errs.PushMisconfigured, "Audience not found: "+audienceID)
+	}
+	audience := utils.M(results[0])
+	parseAudienceQuery(audience)
+	where := utils.M(audience["query"])
+	if where == nil {
+		return nil, errs.E(errs.PushMisconfigured, "Audience has an invalid query: "+audienceID)
+	}
+	return where, nil
+}
+
+// markAudienceUsed 记录推送受众的最后使用时间与使用次数
+func markAudienceUsed(audienceID string) error {
+	update := types.M{
+		"lastUsed": types.M{
+			"__type": "Date",
+			"iso":    utils.TimetoString(time.Now().UTC()),
+		},
+		"timesUsed": types.M{
+			"__op":   "Increment",
+			"amount": 1,
+		},
+	}
+	_, err := rest.Update(rest.Master(), "_Audience", audienceID, update, nil)
+	return err
+}
+
+// Delete ...
+// @router / [delete]
+func (a *AudiencesController) Delete() {
+	a.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (a *AudiencesController) Put() {
+	a.ClassesController.Put()
+}
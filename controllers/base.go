@@ -3,6 +3,7 @@ package controllers
 import (
 	"encoding/base64"
 	"encoding/json"
+	"net/http"
 	"strings"
 
 	"github.com/astaxie/beego"
@@ -166,8 +167,17 @@ func (b *BaseController) Prepare() {
 		b.InvalidRequest()
 		return
 	}
+	// 客户端可以通过 X-Parse-Cloud-Context 请求头设置云代码回调中的 Context ，必须为 JSON 对象
+	cloudContext := types.M{}
+	if header := b.Ctx.Input.Header("X-Parse-Cloud-Context"); header != "" {
+		err := json.Unmarshal([]byte(header), &cloudContext)
+		if err != nil || cloudContext == nil {
+			b.HandleError(errs.E(errs.InvalidJSON, "X-Parse-Cloud-Context must be a JSON object"), 0)
+			return
+		}
+	}
 	if info.MasterKey == config.TConfig.MasterKey {
-		b.Auth = &rest.Auth{InstallationID: info.InstallationID, IsMaster: true}
+		b.setAuth(&rest.Auth{InstallationID: info.InstallationID, IsMaster: true}, cloudContext)
 		return
 	}
 	var allow = false
@@ -188,7 +198,7 @@ func (b *BaseController) Prepare() {
 	}
 	// 生成当前会话用户权限信息
 	if info.SessionToken == "" {
-		b.Auth = &rest.Auth{InstallationID: info.InstallationID, IsMaster: false}
+		b.setAuth(&rest.Auth{InstallationID: info.InstallationID, IsMaster: false}, cloudContext)
 		return
 	}
 	var auth *rest.Auth
@@ -203,9 +213,42 @@ func (b *BaseController) Prepare() {
 		b.HandleError(err, 0)
 		return
 	}
+	b.setAuth(auth, cloudContext)
+}
+
+// setAuth 设置当前请求的用户权限，并写入客户端地址、请求头与 Context ，供云代码回调使用
+func (b *BaseController) setAuth(auth *rest.Auth, cloudContext types.M) {
+	auth.IP = b.Ctx.Input.IP()
+	auth.Headers = b.requestHeaders()
+	auth.Context = cloudContext
 	b.Auth = auth
 }
 
+// credentialHeaders 包含密钥与会话信息的请求头，不能传递给云代码
+// 远程回调会把请求头发送到第三方地址，投递失败时还会保存到数据库中
+var credentialHeaders = map[string]bool{
+	"X-Parse-Master-Key":     true,
+	"X-Parse-Session-Token":  true,
+	"X-Parse-Rest-Api-Key":   true,
+	"X-Parse-Javascript-Key": true,
+	"X-Parse-Client-Key":     true,
+	"X-Parse-Windows-Key":    true,
+	"Authorization":          true,
+	"Cookie":                 true,
+}
+
+// requestHeaders 返回当前请求中除密钥与会话信息以外的请求头
+func (b *BaseController) requestHeaders() map[string]string {
+	headers := map[string]string{}
+	for k := range b.Ctx.Request.Header {
+		if credentialHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		headers[k] = b.Ctx.Request.Header.Get(k)
+	}
+	return headers
+}
+
 func httpAuth(authorization string) map[string]string {
 	if authorization == "" {
 		return nil
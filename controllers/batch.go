@@ -1,26 +1,39 @@
 package controllers
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
-	"io"
-	"io/ioutil"
-	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
-	"github.com/astaxie/beego"
 	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/rest"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
 )
 
-// BatchController ...
+// BatchController 处理 /batch 接口的请求
 type BatchController struct {
 	ClassesController
 }
 
-// HandleBatch ...
+// batchPathPrefix 批量请求中的路径前缀，与 API 的命名空间一致
+const batchPathPrefix = "/v1/"
+
+// batchRequest 批量请求中的单个请求
+type batchRequest struct {
+	method    string
+	className string
+	objectID  string
+	query     url.Values
+	body      types.M
+}
+
+// HandleBatch 处理批量请求
+// 批量请求中的每个请求直接通过 rest 包执行，不再通过 HTTP 转发
+// transaction 为 true 时，所有请求在同一个事务中执行，任一请求失败则回滚整个事务，并返回该请求的错误
 // @router / [post]
 func (b *BatchController) HandleBatch() {
 	if b.JSONBody == nil {
@@ -33,140 +46,275 @@ func (b *BatchController) HandleBatch() {
 		return
 	}
 
-	headers := map[string]string{
-		"X-Parse-Application-Id": b.Info.AppID,
-	}
-	if b.Info.MasterKey != "" {
-		headers["X-Parse-Master-Key"] = b.Info.MasterKey
+	batchRequests := []*batchRequest{}
+	for _, v := range requests {
+		request, err := parseBatchRequest(v)
+		if err != nil {
+			b.HandleError(err, 0)
+			return
+		}
+		batchRequests = append(batchRequests, request)
 	}
-	if b.Info.ClientKey != "" {
-		headers["X-Parse-Client-Key"] = b.Info.ClientKey
+
+	if transaction, ok := b.JSONBody["transaction"].(bool); ok && transaction {
+		b.handleTransaction(batchRequests)
+		return
 	}
-	if b.Info.JavaScriptKey != "" {
-		headers["X-Parse-Javascript-Key"] = b.Info.JavaScriptKey
+
+	results := types.S{}
+	for _, request := range batchRequests {
+		response, err := b.handleRequest(requestAuth(b.Auth, nil), request)
+		if err != nil {
+			results = append(results, types.M{"error": errs.ErrorToMap(err)})
+			continue
+		}
+		results = append(results, types.M{"success": response})
 	}
-	if b.Info.DotNetKey != "" {
-		headers["X-Parse-Windows-Key"] = b.Info.DotNetKey
+	b.Data["json"] = results
+	b.ServeJSON()
+}
+
+// handleTransaction 在事务中执行批量请求
+// afterSave afterDelete 回调、 LiveQuery 通知与数据变更事件在事务提交之后执行，回滚时不会执行
+// 数据库适配器不支持事务时（例如 MongoDB ）返回错误，不执行任何请求
+func (b *BatchController) handleTransaction(requests []*batchRequest) {
+	db, err := orm.TomatoDBController.StartTransaction()
+	if err != nil {
+		b.HandleError(err, 0)
+		return
 	}
-	if b.Info.RestAPIKey != "" {
-		headers["X-Parse-REST-API-Key"] = b.Info.RestAPIKey
+
+	results := types.S{}
+	for _, request := range requests {
+		response, err := b.handleRequest(requestAuth(b.Auth, db), request)
+		if err != nil {
+			db.AbortTransaction()
+			b.HandleError(err, 0)
+			return
+		}
+		results = append(results, types.M{"success": response})
 	}
-	if b.Info.SessionToken != "" {
-		headers["X-Parse-Session-Token"] = b.Info.SessionToken
+
+	err = db.CommitTransaction()
+	if err != nil {
+		db.AbortTransaction()
+		b.HandleError(err, 0)
+		return
 	}
-	if b.Info.InstallationID != "" {
-		headers["X-Parse-Installation-Id"] = b.Info.InstallationID
+	b.Data["json"] = results
+	b.ServeJSON()
+}
+
+// requestAuth 为批量请求中的单个请求复制 Auth
+// 每个请求使用独立的 Context ，初始值为 X-Parse-Cloud-Context 中的数据，云代码回调写入的数据不会传递到其他请求
+// db 不为空时，请求在该 DBController 的事务中执行
+func requestAuth(auth *rest.Auth, db *orm.DBController) *rest.Auth {
+	a := *auth
+	a.Context = utils.CopyMap(auth.Context)
+	if a.Context == nil {
+		a.Context = types.M{}
 	}
-	if b.Info.ClientVersion != "" {
-		headers["X-Parse-Client-Version"] = b.Info.ClientVersion
+	if db != nil {
+		a.DB = db
 	}
-	if b.Ctx.Input.Header("Authorization") != "" {
-		headers["Authorization"] = b.Ctx.Input.Header("Authorization")
+	return &a
+}
+
+// parseBatchRequest 解析批量请求中的单个请求
+// path 可以是 /v1/classes/className/objectId 格式，也可以是包含 scheme 与 host 的完整地址
+func parseBatchRequest(v interface{}) (*batchRequest, error) {
+	request := utils.M(v)
+	if request == nil {
+		return nil, errs.E(errs.InvalidJSON, "Invalid request")
 	}
 
-	b.HandleRequest(requests, headers, b.Ctx.Input.Scheme())
-}
+	method := strings.ToUpper(utils.S(request["method"]))
+	switch method {
+	case "GET", "POST", "PUT", "DELETE":
+	default:
+		return nil, errs.E(errs.InvalidJSON, "Invalid method")
+	}
 
-// HandleRequest ...
-func (b *BatchController) HandleRequest(requests types.S, headers map[string]string, scheme string) {
-	methods := []string{}
-	paths := []string{}
-	bodys := []interface{}{}
-	results := types.S{}
+	path := utils.S(request["path"])
+	u, err := url.Parse(path)
+	if path == "" || err != nil || strings.HasPrefix(u.Path, batchPathPrefix) == false {
+		return nil, errs.E(errs.InvalidJSON, "Invalid path")
+	}
 
-	for _, v := range requests {
-		request := utils.M(v)
-		if request == nil {
-			b.HandleError(errs.E(errs.InvalidJSON, "Invalid request"), 0)
-			return
+	var body types.M
+	if request["body"] != nil {
+		body = utils.M(request["body"])
+		if body == nil {
+			return nil, errs.E(errs.InvalidJSON, "Invalid body")
 		}
+	}
 
-		method := utils.S(request["method"])
-		if method == "" {
-			b.HandleError(errs.E(errs.InvalidJSON, "Invalid method"), 0)
-			return
-		}
-		methods = append(methods, method)
+	r := &batchRequest{
+		method: method,
+		query:  u.Query(),
+		body:   body,
+	}
 
-		path := utils.S(request["path"])
-		if path == "" {
-			b.HandleError(errs.E(errs.InvalidJSON, "Invalid path"), 0)
-			return
+	parts := strings.Split(strings.Trim(u.Path[len(batchPathPrefix):], "/"), "/")
+	switch parts[0] {
+	case "classes":
+		parts = parts[1:]
+		if len(parts) == 0 || parts[0] == "" {
+			return nil, errs.E(errs.InvalidJSON, "Invalid path")
 		}
-		if strings.HasPrefix(path, "/") {
-			path = scheme + "://127.0.0.1:" + beego.AppConfig.String("httpport") + path
-		} else if strings.HasPrefix(path, scheme+"://") {
-			path = path[len(scheme+"://"):]
-			p := strings.Index(path, "/")
-			if p == -1 {
-				b.HandleError(errs.E(errs.InvalidJSON, "Invalid path"), 0)
-				return
-			}
-			path = path[p:]
-			path = scheme + "://127.0.0.1:" + beego.AppConfig.String("httpport") + path
-		} else {
-			b.HandleError(errs.E(errs.InvalidJSON, "Invalid path"), 0)
-			return
+		r.className = parts[0]
+	case "users":
+		r.className = "_User"
+	case "roles":
+		r.className = "_Role"
+	case "installations":
+		r.className = "_Installation"
+	case "sessions":
+		r.className = "_Session"
+	default:
+		return nil, errs.E(errs.InvalidJSON, "Unsupported path in batch request: "+path)
+	}
+	if len(parts) > 2 {
+		return nil, errs.E(errs.InvalidJSON, "Invalid path")
+	}
+	if len(parts) == 2 {
+		r.objectID = parts[1]
+		if r.objectID == "me" {
+			return nil, errs.E(errs.InvalidJSON, "Unsupported path in batch request: "+path)
 		}
-		paths = append(paths, path)
+	}
 
-		bodys = append(bodys, request["body"])
+	if r.objectID == "" && (method == "PUT" || method == "DELETE") {
+		return nil, errs.E(errs.InvalidJSON, "Invalid path")
 	}
-	for i := 0; i < len(requests); i++ {
-		r := request(methods[i], paths[i], headers, bodys[i])
-		results = append(results, r)
+	if r.objectID != "" && method == "POST" {
+		return nil, errs.E(errs.InvalidJSON, "Invalid path")
 	}
-	b.Data["json"] = results
-	b.ServeJSON()
+
+	return r, nil
 }
 
-func request(method, path string, headers map[string]string, body interface{}) types.M {
-	var requestBody io.Reader
-	if body == nil {
-		requestBody = nil
-	} else {
-		jsonParams, err := json.Marshal(body)
+// handleRequest 通过 rest 包执行单个请求，返回的结果与对应接口的返回结果一致
+func (b *BatchController) handleRequest(auth *rest.Auth, request *batchRequest) (interface{}, error) {
+	switch request.method {
+	case "POST":
+		if request.body == nil {
+			return nil, errs.E(errs.InvalidJSON, "request body is empty")
+		}
+		result, err := rest.Create(auth, request.className, request.body, b.Info.ClientSDK)
+		if err != nil {
+			return nil, err
+		}
+		return result["response"], nil
+
+	case "PUT":
+		if request.body == nil {
+			return nil, errs.E(errs.InvalidJSON, "request body is empty")
+		}
+		result, err := rest.Update(auth, request.className, request.objectID, request.body, b.Info.ClientSDK)
+		if err != nil {
+			return nil, err
+		}
+		return result["response"], nil
+
+	case "DELETE":
+		err := rest.Delete(auth, request.className, request.objectID)
 		if err != nil {
-			return types.M{"error": errs.ErrorMessageToMap(errs.InvalidJSON, "Invalid body")}
+			return nil, err
+		}
+		return types.M{}, nil
+
+	default:
+		params := types.M{}
+		for k := range request.query {
+			params[k] = request.query.Get(k)
+		}
+		for k, v := range request.body {
+			params[k] = v
+		}
+		if request.objectID != "" {
+			return b.handleGet(auth, request.className, request.objectID, params)
 		}
-		requestBody = bytes.NewBuffer(jsonParams)
+		return b.handleFind(auth, request.className, params)
 	}
+}
 
-	request, err := http.NewRequest(method, path, requestBody)
-	if err != nil {
-		return types.M{"error": errs.ErrorMessageToMap(errs.InvalidJSON, "Invalid request")}
+// handleGet 查询指定对象
+func (b *BatchController) handleGet(auth *rest.Auth, className, objectID string, params types.M) (interface{}, error) {
+	options := types.M{}
+	for k, v := range params {
+		switch k {
+		case "keys", "include":
+			options[k] = v
+		default:
+			return nil, errs.E(errs.InvalidQuery, "Invalid parameter for query: "+k)
+		}
 	}
 
-	if method == "POST" || method == "PUT" || method == "PATCH" {
-		request.Header.Set("Content-Type", "application/json")
+	response, err := rest.Get(auth, className, objectID, options, b.Info.ClientSDK)
+	if err != nil {
+		return nil, err
 	}
-	for header, value := range headers {
-		request.Header.Set(header, value)
+	results := utils.A(response["results"])
+	if results == nil || len(results) == 0 {
+		return nil, errs.E(errs.ObjectNotFound, "Object not found.")
 	}
 
-	client := http.DefaultClient
-	response, err := client.Do(request)
-	if err != nil {
-		return types.M{"error": errs.ErrorMessageToMap(errs.InvalidJSON, "Invalid request")}
+	result := utils.M(results[0])
+	if className == "_User" {
+		delete(result, "sessionToken")
+		if auth.User != nil && utils.S(result["objectId"]) == utils.S(auth.User["objectId"]) {
+			result["sessionToken"] = b.Info.SessionToken
+		}
 	}
-	defer response.Body.Close()
+	return result, nil
+}
 
-	responseBody, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return types.M{"error": errs.ErrorMessageToMap(errs.InvalidJSON, "Invalid request")}
+// handleFind 查找对象，参数与 /classes/:className 的 GET 请求一致
+func (b *BatchController) handleFind(auth *rest.Auth, className string, params types.M) (interface{}, error) {
+	options := types.M{"limit": 100}
+	where := types.M{}
+	for k, v := range params {
+		switch k {
+		case "skip", "limit":
+			if s, ok := v.(string); ok {
+				if i, err := strconv.Atoi(s); err == nil {
+					options[k] = i
+				}
+			} else if f, ok := v.(float64); ok {
+				options[k] = int(f)
+			}
+		case "count":
+			options["count"] = true
+		case "order", "keys", "include", "redirectClassNameForKey":
+			options[k] = v
+		case "where":
+			if s, ok := v.(string); ok {
+				err := json.Unmarshal([]byte(s), &where)
+				if err != nil {
+					return nil, errs.E(errs.InvalidJSON, "where should be valid json")
+				}
+			} else if w := utils.M(v); w != nil {
+				where = w
+			}
+		default:
+			return nil, errs.E(errs.InvalidQuery, "Invalid parameter for query: "+k)
+		}
 	}
 
-	var result types.M
-	err = json.Unmarshal(responseBody, &result)
+	response, err := rest.Find(auth, className, where, options, b.Info.ClientSDK)
 	if err != nil {
-		return types.M{"error": types.M{"error": string(responseBody)}}
+		return nil, err
 	}
-
-	if result["error"] != nil {
-		return types.M{"error": result}
+	if utils.HasResults(response) {
+		for _, v := range utils.A(response["results"]) {
+			result := utils.M(v)
+			if result["sessionToken"] != nil && b.Info.SessionToken != "" {
+				result["sessionToken"] = b.Info.SessionToken
+			}
+		}
 	}
-
-	return types.M{"success": result}
+	return response, nil
 }
 
 // Get ...
@@ -3,7 +3,9 @@ package controllers
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/lfq7413/tomato/errs"
 	"github.com/lfq7413/tomato/rest"
@@ -148,6 +150,85 @@ func (c *ClassesController) HandleFind() {
 		c.ClassName = c.Ctx.Input.Param(":className")
 	}
 
+	where, options, err := c.findOptions()
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+
+	// 请求 NDJSON 格式时，以流的形式返回结果
+	// 仅 Master 在未设置 limit 时返回全部结果，用于导出数据，其他请求仍使用默认的 limit
+	if strings.Contains(c.Ctx.Input.Header("Accept"), ndjsonContentType) {
+		if c.Auth.IsMaster && c.Query["limit"] == "" && (c.JSONBody == nil || c.JSONBody["limit"] == nil) {
+			delete(options, "limit")
+		}
+		c.streamFind(where, options)
+		return
+	}
+
+	response, err := rest.Find(c.Auth, c.ClassName, where, options, c.Info.ClientSDK)
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+	if utils.HasResults(response) {
+		results := utils.A(response["results"])
+		for _, v := range results {
+			result := utils.M(v)
+			if result["sessionToken"] != nil && c.Info.SessionToken != "" {
+				result["sessionToken"] = c.Info.SessionToken
+			}
+		}
+	}
+
+	c.Data["json"] = response
+	c.ServeJSON()
+}
+
+// ndjsonContentType 流式查询返回的数据格式，每行一个 JSON 对象
+const ndjsonContentType = "application/x-ndjson"
+
+// streamFindChunkSize 流式查询时每批处理的对象数量
+const streamFindChunkSize = 100
+
+// streamFind 以流的形式返回查询结果，每处理完一批对象即发送给客户端，适用于结果集很大的查询
+// 开始发送数据后出错时，已发送的数据无法撤回，将直接结束响应
+func (c *ClassesController) streamFind(where, options types.M) {
+	w := c.Ctx.ResponseWriter
+	encoder := json.NewEncoder(w)
+	err := rest.FindStream(c.Auth, c.ClassName, where, options, c.Info.ClientSDK, streamFindChunkSize, func(results types.S) error {
+		if w.Started == false {
+			c.Ctx.Output.Header("Content-Type", ndjsonContentType)
+		}
+		for _, v := range results {
+			result := utils.M(v)
+			if result != nil && result["sessionToken"] != nil && c.Info.SessionToken != "" {
+				result["sessionToken"] = c.Info.SessionToken
+			}
+			err := encoder.Encode(v)
+			if err != nil {
+				return err
+			}
+		}
+		if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if w.Started {
+		return
+	}
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+	// 没有符合条件的对象
+	c.Ctx.Output.Header("Content-Type", ndjsonContentType)
+	c.Ctx.Output.Body([]byte{})
+}
+
+// findOptions 获取查找请求中的查询条件与查询选项
+func (c *ClassesController) findOptions() (types.M, types.M, error) {
 	allowConstraints := map[string]bool{
 		"skip":                    true,
 		"limit":                   true,
@@ -157,17 +238,16 @@ func (c *ClassesController) HandleFind() {
 		"include":                 true,
 		"redirectClassNameForKey": true,
 		"where":                   true,
+		"cursor":                  true,
 	}
 	for k := range c.Query {
 		if allowConstraints[k] == false {
-			c.HandleError(errs.E(errs.InvalidQuery, "Invalid parameter for query: "+k), 0)
-			return
+			return nil, nil, errs.E(errs.InvalidQuery, "Invalid parameter for query: "+k)
 		}
 	}
 	for k := range c.JSONBody {
 		if allowConstraints[k] == false {
-			c.HandleError(errs.E(errs.InvalidQuery, "Invalid parameter for query: "+k), 0)
-			return
+			return nil, nil, errs.E(errs.InvalidQuery, "Invalid parameter for query: "+k)
 		}
 	}
 
@@ -229,34 +309,29 @@ func (c *ClassesController) HandleFind() {
 		options["redirectClassNameForKey"] = c.JSONBody["redirectClassNameForKey"]
 	}
 
+	// 设置 cursor 时使用游标分页，值为空时查询第一页
+	if cursor, ok := c.Query["cursor"]; ok {
+		options["cursor"] = cursor
+	} else if c.JSONBody != nil {
+		if cursor, ok := c.JSONBody["cursor"]; ok {
+			if cursor == nil {
+				cursor = ""
+			}
+			options["cursor"] = cursor
+		}
+	}
+
 	where := types.M{}
 	if c.Query["where"] != "" {
 		err := json.Unmarshal([]byte(c.Query["where"]), &where)
 		if err != nil {
-			c.HandleError(errs.E(errs.InvalidJSON, "where should be valid json"), 0)
-			return
+			return nil, nil, errs.E(errs.InvalidJSON, "where should be valid json")
 		}
 	} else if c.JSONBody != nil && c.JSONBody["where"] != nil {
 		where = utils.M(c.JSONBody["where"])
 	}
 
-	response, err := rest.Find(c.Auth, c.ClassName, where, options, c.Info.ClientSDK)
-	if err != nil {
-		c.HandleError(err, 0)
-		return
-	}
-	if utils.HasResults(response) {
-		results := utils.A(response["results"])
-		for _, v := range results {
-			result := utils.M(v)
-			if result["sessionToken"] != nil && c.Info.SessionToken != "" {
-				result["sessionToken"] = c.Info.SessionToken
-			}
-		}
-	}
-
-	c.Data["json"] = response
-	c.ServeJSON()
+	return where, options, nil
 }
 
 // HandleDelete 处理删除指定对象请求
@@ -2,26 +2,177 @@ package controllers
 
 import (
 	"github.com/lfq7413/tomato/cloud"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/job"
+	"github.com/lfq7413/tomato/rest"
 	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
 )
 
-// CloudCodeController ...
+// CloudCodeController 处理 /cloud_code 接口的请求
 type CloudCodeController struct {
 	ClassesController
 }
 
-// HandleGet ...
+// Prepare ...
+func (c *CloudCodeController) Prepare() {
+	c.ClassesController.Prepare()
+	if c.Ctx.ResponseWriter.Started == false {
+		c.EnforceMasterKeyAccess()
+	}
+}
+
+// HandleGet 获取所有的任务调度
 // @router /jobs [get]
 func (c *CloudCodeController) HandleGet() {
-	jobs := cloud.GetJobs()
+	response, err := rest.Find(rest.Master(), "_JobSchedule", types.M{}, types.M{}, nil)
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+	results := utils.A(response["results"])
+	if results == nil {
+		results = types.S{}
+	}
+	c.Data["json"] = results
+	c.ServeJSON()
+}
+
+// HandleGetJobsData 获取所有已注册的任务，以及已被调度的任务
+// @router /jobs/data [get]
+func (c *CloudCodeController) HandleGetJobsData() {
+	response, err := rest.Find(rest.Master(), "_JobSchedule", types.M{}, types.M{}, nil)
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
 	jobNames := []string{}
-	for n := range jobs {
+	for n := range cloud.GetJobs() {
 		jobNames = append(jobNames, n)
 	}
-	c.Data["json"] = types.M{"jobName": jobNames}
+	inUse := []string{}
+	for _, v := range utils.A(response["results"]) {
+		if schedule := utils.M(v); schedule != nil {
+			inUse = append(inUse, utils.S(schedule["jobName"]))
+		}
+	}
+	c.Data["json"] = types.M{
+		"jobs":   jobNames,
+		"in_use": inUse,
+	}
 	c.ServeJSON()
 }
 
+// HandleCreateJob 创建任务调度，请求格式如下
+// {
+// 	"job_schedule":{
+// 		"jobName":"cleanup",
+// 		"params":{},
+// 		"cron":"0 3 * * *",
+// 		"timeZone":"Asia/Shanghai",
+// 		"startAfter":"2017-03-13T00:00:00.000Z"
+// 	}
+// }
+// @router /jobs [post]
+func (c *CloudCodeController) HandleCreateJob() {
+	schedule, err := c.jobSchedule()
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+	nextRunAt, err := job.NextRunAt(schedule)
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+	schedule["nextRunAt"] = nextRunAt
+	// lockdown!
+	schedule["ACL"] = types.M{}
+
+	result, err := rest.Create(rest.Master(), "_JobSchedule", schedule, c.Info.ClientSDK)
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+	c.Data["json"] = result["response"]
+	c.ServeJSON()
+}
+
+// HandleUpdateJob 更新任务调度，请求格式与创建时相同，重新计算下一次执行时间
+// @router /jobs/:objectId [put]
+func (c *CloudCodeController) HandleUpdateJob() {
+	objectID := c.Ctx.Input.Param(":objectId")
+	update, err := c.jobSchedule()
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+
+	response, err := rest.Get(rest.Master(), "_JobSchedule", objectID, types.M{}, nil)
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+	results := utils.A(response["results"])
+	if len(results) == 0 {
+		c.HandleError(errs.E(errs.ObjectNotFound, "Object not found."), 0)
+		return
+	}
+	schedule := utils.M(results[0])
+	for k, v := range update {
+		schedule[k] = v
+	}
+	nextRunAt, err := job.NextRunAt(schedule)
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+	update["nextRunAt"] = nextRunAt
+
+	result, err := rest.Update(rest.Master(), "_JobSchedule", objectID, update, c.Info.ClientSDK)
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+	c.Data["json"] = result["response"]
+	c.ServeJSON()
+}
+
+// HandleDeleteJob 删除任务调度
+// @router /jobs/:objectId [delete]
+func (c *CloudCodeController) HandleDeleteJob() {
+	objectID := c.Ctx.Input.Param(":objectId")
+	err := rest.Delete(rest.Master(), "_JobSchedule", objectID)
+	if err != nil {
+		c.HandleError(err, 0)
+		return
+	}
+	c.Data["json"] = types.M{}
+	c.ServeJSON()
+}
+
+// jobSchedule 获取请求中的任务调度信息，仅允许设置 job.ScheduleFields 中的字段
+func (c *CloudCodeController) jobSchedule() (types.M, error) {
+	if c.JSONBody == nil || utils.M(c.JSONBody["job_schedule"]) == nil {
+		return nil, errs.E(errs.InvalidJSON, "job_schedule is required")
+	}
+	schedule := types.M{}
+	for k, v := range utils.M(c.JSONBody["job_schedule"]) {
+		valid := false
+		for _, field := range job.ScheduleFields {
+			if k == field {
+				valid = true
+				break
+			}
+		}
+		if valid == false {
+			return nil, errs.E(errs.InvalidKeyName, "Invalid field for job schedule: "+k)
+		}
+		schedule[k] = v
+	}
+	return schedule, nil
+}
+
 // Get ...
 // @router / [get]
 func (c *CloudCodeController) Get() {
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/export"
+)
+
+// ExportController 处理 /export 接口的请求
+type ExportController struct {
+	ClassesController
+}
+
+// HandleExport 导出类中的全部数据，需要 master key
+// format 为导出格式，可选 ndjson csv ，默认为 ndjson
+// relation 为 Relation 字段名，设置时仅导出该字段的 _Join 表记录
+// 数据分批读取并以流的形式返回，导出过程中出错时，已发送的数据无法撤回，将直接结束响应
+// @router /:className [get]
+func (e *ExportController) HandleExport() {
+	if e.EnforceMasterKeyAccess() == false {
+		return
+	}
+	className := e.Ctx.Input.Param(":className")
+	format := e.Query["format"]
+	if format == "" {
+		format = export.FormatNDJSON
+	}
+	if format != export.FormatNDJSON && format != export.FormatCSV {
+		e.HandleError(errs.E(errs.InvalidQuery, "invalid export format: "+format), 0)
+		return
+	}
+
+	e.Ctx.Output.Header("Content-Type", export.ContentType(format))
+	e.Ctx.Output.Header("Content-Disposition", `attachment; filename="`+className+`.`+format+`"`)
+	err := export.ExportClass(className, format, e.Query["relation"], e.Ctx.ResponseWriter)
+	if e.Ctx.ResponseWriter.Started {
+		return
+	}
+	if err != nil {
+		// 尚未发送数据时，返回错误信息
+		e.Ctx.ResponseWriter.Header().Del("Content-Disposition")
+		e.HandleError(err, 0)
+		return
+	}
+	// 类中没有数据
+	e.Ctx.Output.Body([]byte{})
+}
+
+// Get ...
+// @router / [get]
+func (e *ExportController) Get() {
+	e.ClassesController.Get()
+}
+
+// Post ...
+// @router / [post]
+func (e *ExportController) Post() {
+	e.ClassesController.Post()
+}
+
+// Delete ...
+// @router / [delete]
+func (e *ExportController) Delete() {
+	e.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (e *ExportController) Put() {
+	e.ClassesController.Put()
+}
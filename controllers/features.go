@@ -17,6 +17,8 @@ func (f *FeaturesController) HandleGet() {
 		return
 	}
 
+	// 仅 File 日志模块支持日志查询
+	queryableLogs := config.TConfig.LoggerAdapter == "File"
 	features := types.M{
 		"globalConfig": types.M{
 			"create": true,
@@ -30,21 +32,24 @@ func (f *FeaturesController) HandleGet() {
 			"update": true,
 			"delete": true,
 		},
+		"aggregate": types.M{
+			"pipeline": true,
+		},
 		"cloudCode": types.M{
 			"jobs": true,
 		},
 		"logs": types.M{
-			"level": true,
-			"size":  true,
-			"order": true,
-			"until": true,
-			"from":  true,
+			"level": queryableLogs,
+			"size":  queryableLogs,
+			"order": queryableLogs,
+			"until": queryableLogs,
+			"from":  queryableLogs,
 		},
 		"push": types.M{
 			"immediatePush":  config.TConfig.PushAdapter != "",
 			"scheduledPush":  config.TConfig.ScheduledPush,
 			"storedPushData": config.TConfig.PushAdapter != "",
-			"pushAudiences":  false,
+			"pushAudiences":  true,
 		},
 		"schemas": types.M{
 			"addField":                  true,
@@ -52,7 +57,7 @@ func (f *FeaturesController) HandleGet() {
 			"addClass":                  true,
 			"removeClass":               true,
 			"clearAllDataFromClass":     true,
-			"exportClass":               false,
+			"exportClass":               true,
 			"editClassLevelPermissions": true,
 			"editPointerPermissions":    true,
 		},
@@ -4,8 +4,11 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/astaxie/beego"
+	"github.com/lfq7413/tomato/cloud"
 	"github.com/lfq7413/tomato/errs"
 	"github.com/lfq7413/tomato/files"
+	"github.com/lfq7413/tomato/rest"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
 )
@@ -61,17 +64,49 @@ func (f *FilesController) HandleCreate() {
 		f.HandleError(errs.E(errs.FileSaveError, "Invalid file upload."), 0)
 		return
 	}
-	if len(filename) > 128 {
-		f.HandleError(errs.E(errs.InvalidFileName, "Filename too long."), 0)
+	err := validateFilename(filename)
+	if err != nil {
+		f.HandleError(err, 0)
 		return
 	}
-	if utils.IsFileName(filename) == false {
-		f.HandleError(errs.E(errs.InvalidFileName, "Filename contains invalid characters."), 0)
+	contentType := f.Ctx.Input.Header("Content-type")
+
+	// 保存前回调，可以重命名文件或者替换文件内容
+	file := &cloud.File{
+		Name:        filename,
+		ContentType: contentType,
+		Size:        len(data),
+		Data:        data,
+	}
+	err = rest.MaybeRunFileTrigger(cloud.TypeBeforeSaveFile, f.Auth, file)
+	if err != nil {
+		f.HandleError(err, 0)
 		return
 	}
-	contentType := f.Ctx.Input.Header("Content-type")
-	result := files.CreateFile(filename, data, contentType)
+	if len(file.Data) == 0 {
+		f.HandleError(errs.E(errs.FileSaveError, "Invalid file upload."), 0)
+		return
+	}
+	// 回调替换的文件内容同样不能超过上传文件大小的限制
+	if int64(len(file.Data)) > beego.BConfig.MaxMemory {
+		f.HandleError(errs.E(errs.FileTooLarge, "File size exceeds the maximum upload size."), 0)
+		return
+	}
+	if file.Name != filename {
+		err = validateFilename(file.Name)
+		if err != nil {
+			f.HandleError(err, 0)
+			return
+		}
+	}
+
+	result := files.CreateFile(file.Name, file.Data, file.ContentType)
 	if result != nil && result["url"] != "" {
+		// 保存后回调，不影响保存结果
+		file.Name = result["name"]
+		file.URL = result["url"]
+		rest.MaybeRunFileTrigger(cloud.TypeAfterSaveFile, f.Auth, file)
+
 		f.Ctx.Output.SetStatus(201)
 		f.Ctx.Output.Header("location", result["url"])
 		f.Data["json"] = result
@@ -88,7 +123,13 @@ func (f *FilesController) HandleDelete() {
 		return
 	}
 	filename := f.Ctx.Input.Param(":filename")
-	err := files.DeleteFile(filename)
+	// 删除前回调，返回错误时拒绝删除
+	err := rest.MaybeRunFileTrigger(cloud.TypeBeforeDeleteFile, f.Auth, &cloud.File{Name: filename})
+	if err != nil {
+		f.HandleError(err, 0)
+		return
+	}
+	err = files.DeleteFile(filename)
 	if err != nil {
 		f.HandleError(errs.E(errs.FileDeleteError, "Could not delete file."), 0)
 		return
@@ -97,6 +138,17 @@ func (f *FilesController) HandleDelete() {
 	f.ServeJSON()
 }
 
+// validateFilename 校验上传的文件名
+func validateFilename(filename string) error {
+	if len(filename) > 128 {
+		return errs.E(errs.InvalidFileName, "Filename too long.")
+	}
+	if utils.IsFileName(filename) == false {
+		return errs.E(errs.InvalidFileName, "Filename contains invalid characters.")
+	}
+	return nil
+}
+
 // Get ...
 // @router / [get]
 func (f *FilesController) Get() {
@@ -38,21 +38,21 @@ func (f *FunctionsController) HandleCloudFunction() {
 		params[k] = v
 	}
 
-	headers := map[string]string{}
-	for k := range f.Ctx.Request.Header {
-		headers[k] = f.Ctx.Request.Header.Get(k)
-	}
-
 	request := cloud.FunctionRequest{
 		Params:         params,
 		Master:         false,
 		InstallationID: f.Info.InstallationID,
+		IP:             f.Ctx.Input.IP(),
 		FunctionName:   functionName,
-		Headers:        headers,
+		Headers:        f.requestHeaders(),
+		Context:        types.M{},
 	}
 	if f.Auth != nil {
 		request.Master = f.Auth.IsMaster
 		request.User = f.Auth.User
+		if f.Auth.Context != nil {
+			request.Context = f.Auth.Context
+		}
 	}
 
 	if theValidator != nil {
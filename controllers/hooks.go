@@ -3,8 +3,10 @@ package controllers
 import (
 	"github.com/lfq7413/tomato/errs"
 	"github.com/lfq7413/tomato/hooks"
+	"github.com/lfq7413/tomato/rest"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
+	"github.com/lfq7413/tomato/webhook"
 )
 
 // HooksController ...
@@ -83,6 +85,9 @@ func (h *HooksController) HandleUpdateFunction() {
 			"functionName": functionName,
 			"url":          h.JSONBody["url"],
 		}
+		if h.JSONBody["timeout"] != nil {
+			hook["timeout"] = h.JSONBody["timeout"]
+		}
 		result, err = hooks.UpdateHook(hook)
 	}
 	if err != nil {
@@ -159,6 +164,9 @@ func (h *HooksController) HandleUpdateTrigger() {
 			"triggerName": triggerName,
 			"url":         h.JSONBody["url"],
 		}
+		if h.JSONBody["timeout"] != nil {
+			hook["timeout"] = h.JSONBody["timeout"]
+		}
 		result, err = hooks.UpdateHook(hook)
 	}
 	if err != nil {
@@ -169,6 +177,99 @@ func (h *HooksController) HandleUpdateTrigger() {
 	h.ServeJSON()
 }
 
+// HandleGetDeliveries 查询投递失败的远程回调，支持 where limit skip order 等查询参数，默认按创建时间倒序排列
+// @router /deliveries [get]
+func (h *HooksController) HandleGetDeliveries() {
+	where, options, err := h.findOptions()
+	if err != nil {
+		h.HandleError(err, 0)
+		return
+	}
+	if options["order"] == nil {
+		options["order"] = "-createdAt"
+	}
+	response, err := rest.Find(rest.Master(), "_WebhookDelivery", where, options, h.Info.ClientSDK)
+	if err != nil {
+		h.HandleError(err, 0)
+		return
+	}
+	h.Data["json"] = response
+	h.ServeJSON()
+}
+
+// HandleReplayDelivery 重新发送投递失败的远程回调，返回更新后的投递记录
+// @router /deliveries/:objectId/replay [post]
+func (h *HooksController) HandleReplayDelivery() {
+	objectID := h.Ctx.Input.Param(":objectId")
+	result, err := webhook.Replay(objectID)
+	if err != nil {
+		h.HandleError(err, 0)
+		return
+	}
+	h.Data["json"] = result
+	h.ServeJSON()
+}
+
+// HandleGetAllSubscriptions 获取全部数据变更事件订阅
+// @router /subscriptions [get]
+func (h *HooksController) HandleGetAllSubscriptions() {
+	results, err := webhook.GetSubscriptions()
+	if err != nil {
+		h.HandleError(err, 0)
+		return
+	}
+	h.Data["json"] = types.M{"results": results}
+	h.ServeJSON()
+}
+
+// HandleGetSubscription ...
+// @router /subscriptions/:objectId [get]
+func (h *HooksController) HandleGetSubscription() {
+	result, err := webhook.GetSubscription(h.Ctx.Input.Param(":objectId"))
+	if err != nil {
+		h.HandleError(err, 0)
+		return
+	}
+	h.Data["json"] = result
+	h.ServeJSON()
+}
+
+// HandleCreateSubscription 创建数据变更事件订阅，请求格式参考 webhook.CreateSubscription
+// @router /subscriptions [post]
+func (h *HooksController) HandleCreateSubscription() {
+	result, err := webhook.CreateSubscription(h.JSONBody)
+	if err != nil {
+		h.HandleError(err, 0)
+		return
+	}
+	h.Data["json"] = result
+	h.ServeJSON()
+}
+
+// HandleUpdateSubscription ...
+// @router /subscriptions/:objectId [put]
+func (h *HooksController) HandleUpdateSubscription() {
+	result, err := webhook.UpdateSubscription(h.Ctx.Input.Param(":objectId"), h.JSONBody)
+	if err != nil {
+		h.HandleError(err, 0)
+		return
+	}
+	h.Data["json"] = result
+	h.ServeJSON()
+}
+
+// HandleDeleteSubscription ...
+// @router /subscriptions/:objectId [delete]
+func (h *HooksController) HandleDeleteSubscription() {
+	err := webhook.DeleteSubscription(h.Ctx.Input.Param(":objectId"))
+	if err != nil {
+		h.HandleError(err, 0)
+		return
+	}
+	h.Data["json"] = types.M{}
+	h.ServeJSON()
+}
+
 // Get ...
 // @router / [get]
 func (h *HooksController) Get() {
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lfq7413/tomato/cloud"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/export"
+	"github.com/lfq7413/tomato/job"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+// ImportController 处理 /import 接口的请求
+type ImportController struct {
+	ClassesController
+}
+
+// HandleImport 导入 /export 接口导出的数据，需要 master key
+// 请求体为导出的文件内容， Content-Type 为 application/x-ndjson 或 text/csv ，也可以通过 format 参数指定格式
+// relation 为 Relation 字段名，导入 CSV 格式的 _Join 表记录时需要设置
+// 导入在后台执行，进度与结果保存在 _JobStatus 中，通过 X-Parse-Job-Status-Id 返回任务状态 id ，
+// 可通过 /jobs/status/:objectId 查询
+// @router /:className [post]
+func (i *ImportController) HandleImport() {
+	if i.EnforceMasterKeyAccess() == false {
+		return
+	}
+	className := i.Ctx.Input.Param(":className")
+	format := i.Query["format"]
+	if format == "" {
+		if strings.Contains(i.Ctx.Input.Header("Content-type"), "csv") {
+			format = export.FormatCSV
+		} else {
+			format = export.FormatNDJSON
+		}
+	}
+	if format != export.FormatNDJSON && format != export.FormatCSV {
+		i.HandleError(errs.E(errs.InvalidQuery, "invalid import format: "+format), 0)
+		return
+	}
+	data := i.Ctx.Input.RequestBody
+	if len(data) == 0 {
+		i.HandleError(errs.E(errs.InvalidJSON, "request body is empty"), 0)
+		return
+	}
+	relation := i.Query["relation"]
+
+	statusParams := types.M{
+		"className": className,
+		"format":    format,
+		"relation":  relation,
+	}
+	status := job.RunHandler("importClass", func(request cloud.JobRequest, response cloud.JobResponse) {
+		result, err := export.ImportClass(className, format, relation, data, response.JobStatus)
+		if err != nil {
+			response.Error(err.Error())
+			return
+		}
+		message := "imported " + strconv.Itoa(result.Imported) + ", failed " + strconv.Itoa(result.Failed)
+		if len(result.Errors) > 0 {
+			message += "; " + strings.Join(result.Errors, "; ")
+		}
+		response.Success(message)
+	}, statusParams, "api")
+
+	i.Ctx.Output.Header("X-Parse-Job-Status-Id", utils.S(status["objectId"]))
+	i.Data["json"] = types.M{}
+	i.ServeJSON()
+}
+
+// Get ...
+// @router / [get]
+func (i *ImportController) Get() {
+	i.ClassesController.Get()
+}
+
+// Post ...
+// @router / [post]
+func (i *ImportController) Post() {
+	i.ClassesController.Post()
+}
+
+// Delete ...
+// @router / [delete]
+func (i *ImportController) Delete() {
+	i.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (i *ImportController) Put() {
+	i.ClassesController.Put()
+}
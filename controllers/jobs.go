@@ -1,9 +1,9 @@
 package controllers
 
 import (
-	"github.com/lfq7413/tomato/cloud"
 	"github.com/lfq7413/tomato/errs"
 	"github.com/lfq7413/tomato/job"
+	"github.com/lfq7413/tomato/rest"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
 )
@@ -34,14 +34,6 @@ func (j *JobsController) HandlePost() {
 }
 
 func (j *JobsController) runJob(jobName string) {
-	jobFunction := cloud.GetJob(jobName)
-	if jobFunction == nil {
-		j.Data["json"] = errs.ErrorMessageToMap(errs.ScriptFailed, "Invalid job.")
-		j.ServeJSON()
-		return
-	}
-	jobHandler := job.NewjobStatus()
-
 	if j.JSONBody == nil {
 		j.JSONBody = types.M{}
 	}
@@ -59,24 +51,55 @@ func (j *JobsController) runJob(jobName string) {
 		headers[k] = j.Ctx.Request.Header.Get(k)
 	}
 
-	request := cloud.JobRequest{
-		Params:  params,
-		JobName: jobName,
-		Headers: headers,
-	}
-	response := cloud.JobResponse{
-		JobStatus: jobHandler,
+	jobStatus, err := job.Run(jobName, params, j.JSONBody, headers, "api")
+	if err != nil {
+		j.Data["json"] = errs.ErrorToMap(err)
+		j.ServeJSON()
+		return
 	}
-	jobStatus := jobHandler.SetRunning(jobName, j.JSONBody)
-	request.JobID = utils.S(jobStatus["objectId"])
-
-	go jobFunction(request, response)
 
 	j.Ctx.Output.Header("X-Parse-Job-Status-Id", utils.S(jobStatus["objectId"]))
 	j.Data["json"] = types.M{}
 	j.ServeJSON()
 }
 
+// HandleGetStatus 查询任务状态
+// @router /status/:objectId [get]
+func (j *JobsController) HandleGetStatus() {
+	if j.EnforceMasterKeyAccess() == false {
+		return
+	}
+	objectID := j.Ctx.Input.Param(":objectId")
+	response, err := rest.Get(rest.Master(), "_JobStatus", objectID, types.M{}, nil)
+	if err != nil {
+		j.HandleError(err, 0)
+		return
+	}
+	results := utils.A(response["results"])
+	if len(results) == 0 {
+		j.HandleError(errs.E(errs.ObjectNotFound, "Object not found."), 0)
+		return
+	}
+	j.Data["json"] = results[0]
+	j.ServeJSON()
+}
+
+// HandleCancel 取消正在执行的任务，任务通过 JobRequest.Context 接收取消通知
+// @router /status/:objectId/cancel [post]
+func (j *JobsController) HandleCancel() {
+	if j.EnforceMasterKeyAccess() == false {
+		return
+	}
+	objectID := j.Ctx.Input.Param(":objectId")
+	err := job.Cancel(objectID)
+	if err != nil {
+		j.HandleError(err, 0)
+		return
+	}
+	j.Data["json"] = types.M{}
+	j.ServeJSON()
+}
+
 // Get ...
 // @router / [get]
 func (j *JobsController) Get() {
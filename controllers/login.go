@@ -3,6 +3,7 @@ package controllers
 import (
 	"time"
 
+	"github.com/lfq7413/tomato/cloud"
 	"github.com/lfq7413/tomato/config"
 	"github.com/lfq7413/tomato/errs"
 	"github.com/lfq7413/tomato/files"
@@ -67,8 +68,8 @@ func (l *LoginController) HandleLogIn() {
 		return
 	}
 
-	// TODO 换用高强度的加密方式
-	correct := utils.Compare(password, utils.S(user["password"]))
+	hashedPassword := utils.S(user["password"])
+	correct := utils.Compare(password, hashedPassword)
 	accountLockoutPolicy := rest.NewAccountLockout(utils.S(user["username"]))
 	err = accountLockoutPolicy.HandleLoginAttempt(correct)
 	if err != nil {
@@ -80,6 +81,11 @@ func (l *LoginController) HandleLogIn() {
 		return
 	}
 
+	// 旧版 sha256 哈希或计算强度已变更的哈希，在登录成功后使用当前配置重新计算
+	if utils.NeedsRehash(hashedPassword, config.TConfig.PasswordHashCost) {
+		rehashPassword(user, password)
+	}
+
 	// 检测密码是否过期
 	if config.TConfig.PasswordPolicy && config.TConfig.MaxPasswordAge > 0 {
 		if changedAt, ok := user["_password_changed_at"].(time.Time); ok {
@@ -97,6 +103,13 @@ func (l *LoginController) HandleLogIn() {
 		}
 	}
 
+	// 登录前回调，返回错误时拒绝登录
+	err = rest.MaybeRunLoginTrigger(cloud.TypeBeforeLogin, l.Auth, user)
+	if err != nil {
+		l.HandleError(err, 0)
+		return
+	}
+
 	token := "r:" + utils.CreateToken()
 	user["sessionToken"] = token
 	delete(user, "password")
@@ -151,11 +164,25 @@ func (l *LoginController) HandleLogIn() {
 		return
 	}
 
+	// 登录后回调，不影响登录结果
+	rest.MaybeRunLoginTrigger(cloud.TypeAfterLogin, l.Auth, user)
+
 	l.Data["json"] = user
 	l.ServeJSON()
 
 }
 
+// rehashPassword 使用当前配置重新计算密码哈希并保存，失败时不影响本次登录
+func rehashPassword(user types.M, password string) {
+	hashedPassword, err := utils.Hash(password, config.TConfig.PasswordHashCost)
+	if err != nil {
+		return
+	}
+	query := types.M{"objectId": user["objectId"]}
+	update := types.M{"_hashed_password": hashedPassword}
+	orm.TomatoDBController.Update("_User", query, update, types.M{}, false)
+}
+
 // Post ...
 // @router / [post]
 func (l *LoginController) Post() {
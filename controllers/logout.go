@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"github.com/lfq7413/tomato/cloud"
 	"github.com/lfq7413/tomato/rest"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
@@ -32,6 +33,8 @@ func (l *LogoutController) HandleLogOut() {
 				l.HandleError(err, 0)
 				return
 			}
+			// 退出后回调，不影响退出结果
+			rest.MaybeRunLoginTrigger(cloud.TypeAfterLogout, l.Auth, obj)
 		}
 	}
 	l.Data["json"] = types.M{}
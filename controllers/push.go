@@ -35,21 +35,38 @@ func (p *PushController) HandlePost() {
 		p.HandleError(err, 0)
 		return
 	}
+	if audienceID := utils.S(p.JSONBody["audience_id"]); audienceID != "" {
+		// 推送已发送，记录使用时间失败时不影响结果
+		markAudienceUsed(audienceID)
+	}
 	p.Data["json"] = types.M{"result": true}
 	p.ServeJSON()
 }
 
 // getQueryCondition 获取查询条件
+// 查询条件可以通过 where 、 channels 或者 audience_id 设定，三者只能设定其一
 func getQueryCondition(body types.M) (types.M, error) {
 	hasWhere := (body["where"] != nil)
 	hasChannels := (body["channels"] != nil)
+	hasAudience := (body["audience_id"] != nil)
 
 	var where types.M
-	if hasWhere && hasChannels {
+	if hasAudience && (hasWhere || hasChannels) {
+		return nil, errs.E(errs.PushMisconfigured, "audience_id can not be set at the same time with channels or query.")
+	} else if hasWhere && hasChannels {
 		// 查询与频道不能同时设定
 		return nil, errs.E(errs.PushMisconfigured, "Channels and query can not be set at the same time.")
+	} else if hasAudience {
+		audienceID, ok := body["audience_id"].(string)
+		if ok == false || audienceID == "" {
+			return nil, errs.E(errs.PushMisconfigured, "audience_id should be a string.")
+		}
+		return audienceWhere(audienceID)
 	} else if hasWhere {
 		where = utils.M(body["where"])
+		if where == nil {
+			return nil, errs.E(errs.PushMisconfigured, "where should be an object.")
+		}
 	} else if hasChannels {
 		channels := types.M{
 			"$in": body["channels"],
@@ -58,7 +75,7 @@ func getQueryCondition(body types.M) (types.M, error) {
 			"channels": channels,
 		}
 	} else {
-		return nil, errs.E(errs.PushMisconfigured, `Sending a push requires either "channels" or a "where" query.`)
+		return nil, errs.E(errs.PushMisconfigured, `Sending a push requires either "channels", a "where" query or an "audience_id".`)
 	}
 
 	return where, nil
@@ -81,7 +81,7 @@ func (s *SchemasController) HandleCreate() {
 	}
 
 	schema := orm.TomatoDBController.LoadSchema(types.M{"clearCache": true})
-	result, err := schema.AddClassIfNotExists(className, utils.M(data["fields"]), utils.M(data["classLevelPermissions"]))
+	result, err := schema.AddClassIfNotExists(className, utils.M(data["fields"]), utils.M(data["classLevelPermissions"]), utils.M(data["indexes"]))
 	if err != nil {
 		s.HandleError(err, 0)
 		return
@@ -116,7 +116,7 @@ func (s *SchemasController) HandleUpdate() {
 	}
 
 	schema := orm.TomatoDBController.LoadSchema(types.M{"clearCache": true})
-	result, err := schema.UpdateClass(className, submittedFields, utils.M(data["classLevelPermissions"]))
+	result, err := schema.UpdateClass(className, submittedFields, utils.M(data["classLevelPermissions"]), utils.M(data["indexes"]))
 	if err != nil {
 		s.HandleError(err, 0)
 		return
@@ -0,0 +1,203 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/lfq7413/tomato/config"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/logger"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+// backupVersion 备份文件格式的版本
+const backupVersion = 1
+
+const (
+	manifestFile = "manifest.json"
+	schemaFile   = "schema.json"
+	classesDir   = "classes/"
+)
+
+// Backup 备份数据库中的全部数据到 w ，格式为 zip ，包含以下文件：
+// manifest.json 备份信息，包括格式版本、数据库类型、备份时间与类列表
+// schema.json 所有类的定义，格式与 GET /schemas 的返回值相同，不包含 _Hooks 等 schema 固定的系统类
+// classes/<className>.ndjson 类中的全部对象，以及 Relation 字段的 _Join 表记录，格式与 ExportClass 相同
+// 备份文件与数据库类型无关，可以恢复到 MongoDB 或 PostgreSQL 中
+func Backup(w io.Writer) error {
+	schemas, err := orm.TomatoDBController.LoadSchema(nil).GetAllClasses(types.M{"clearCache": true})
+	if err != nil {
+		return err
+	}
+
+	definitions := types.S{}
+	classNames := []string{}
+	for _, schema := range schemas {
+		className := utils.S(schema["className"])
+		if className == "" || orm.IsVolatileClass(className) {
+			continue
+		}
+		definitions = append(definitions, schema)
+		classNames = append(classNames, className)
+	}
+	sort.Strings(classNames)
+	classNames = append(classNames, orm.VolatileClassNames()...)
+
+	archive := zip.NewWriter(w)
+	err = writeJSONFile(archive, manifestFile, types.M{
+		"version":      backupVersion,
+		"databaseType": config.TConfig.DatabaseType,
+		"createdAt":    utils.TimetoString(time.Now().UTC()),
+		"classes":      classNames,
+	})
+	if err != nil {
+		return err
+	}
+	err = writeJSONFile(archive, schemaFile, types.M{"results": definitions})
+	if err != nil {
+		return err
+	}
+
+	for _, className := range classNames {
+		file, err := archive.Create(classesDir + className + ".ndjson")
+		if err != nil {
+			return err
+		}
+		err = ExportClass(className, FormatNDJSON, "", file)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Restore 从 Backup 生成的备份文件中恢复数据
+// 先通过 PlanSchemaMigrations 与 ApplySchemaMigrations 创建类与字段，再逐个导入类中的数据，
+// 数据库中已有的字段不会被删除，已存在的对象不会被覆盖，导入失败的记录在返回结果中
+func Restore(r io.ReaderAt, size int64) (map[string]*ImportResult, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var manifest types.M
+	data, err := readFile(files, manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, errs.E(errs.InvalidJSON, "invalid "+manifestFile)
+	}
+	if version, _ := manifest["version"].(float64); int(version) != backupVersion {
+		return nil, errs.E(errs.InvalidJSON, "unsupported backup version")
+	}
+
+	data, err = readFile(files, schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	definitions, err := orm.ParseSchemaDefinitions(data)
+	if err != nil {
+		return nil, err
+	}
+	dropUnsupportedIndexOptions(definitions, config.TConfig.DatabaseType)
+	db := orm.TomatoDBController
+	migrations, err := db.PlanSchemaMigrations(definitions, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, migration := range migrations {
+		// 恢复数据时仅添加类、字段与索引，不删除数据库中已有的字段与索引
+		migration.DeletedFields = []string{}
+		deletedIndexes := []string{}
+		for _, name := range migration.DeletedIndexes {
+			if migration.AddedIndexes[name] != nil {
+				deletedIndexes = append(deletedIndexes, name)
+			}
+		}
+		migration.DeletedIndexes = deletedIndexes
+	}
+	err = db.ApplySchemaMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[string]*ImportResult{}
+	for _, v := range utils.A(manifest["classes"]) {
+		className := utils.S(v)
+		name := classesDir + className + ".ndjson"
+		if files[name] == nil {
+			continue
+		}
+		data, err := readFile(files, name)
+		if err != nil {
+			return results, err
+		}
+		result, err := ImportClass(className, FormatNDJSON, "", data, nil)
+		if err != nil {
+			return results, err
+		}
+		results[className] = result
+	}
+	return results, nil
+}
+
+// unsupportedIndexOptions 各数据库不支持的索引选项
+var unsupportedIndexOptions = map[string][]string{
+	"PostgreSQL": []string{"expireAfterSeconds"},
+}
+
+// dropUnsupportedIndexOptions 删除 databaseType 不支持的索引选项，并输出警告日志
+// 如 MongoDB 中的 TTL 索引恢复到 PostgreSQL 时，作为普通索引创建，过期数据不会被自动删除
+func dropUnsupportedIndexOptions(definitions []types.M, databaseType string) {
+	options := unsupportedIndexOptions[databaseType]
+	if len(options) == 0 {
+		return
+	}
+	for _, definition := range definitions {
+		for name, v := range utils.M(definition["indexes"]) {
+			index := utils.M(v)
+			if index == nil {
+				continue
+			}
+			for _, option := range options {
+				if _, ok := index[option]; ok {
+					delete(index, option)
+					logger.Warn("Restore", utils.S(definition["className"])+":", "dropped option", option, "of index", name, "which is not supported by", databaseType)
+				}
+			}
+		}
+	}
+}
+
+func writeJSONFile(archive *zip.Writer, name string, object interface{}) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(file).Encode(object)
+}
+
+func readFile(files map[string]*zip.File, name string) ([]byte, error) {
+	file := files[name]
+	if file == nil {
+		return nil, errs.E(errs.InvalidJSON, name+" not found in backup")
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
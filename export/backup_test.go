@@ -0,0 +1,102 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/storage/mongo"
+	"github.com/lfq7413/tomato/test"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+func Test_BackupRestore(t *testing.T) {
+	initEnv()
+	fields := types.M{
+		"name":    types.M{"type": "String"},
+		"players": types.M{"type": "Relation", "targetClass": "_User"},
+	}
+	_, err := orm.TomatoDBController.LoadSchema(nil).AddClassIfNotExists("Team", fields, nil, nil)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	orm.TomatoDBController.Create("Team", types.M{"objectId": "01", "name": "a"}, nil)
+	orm.TomatoDBController.Create("Team", types.M{"objectId": "02", "name": "b"}, nil)
+	orm.TomatoDBController.AddJoinRecord("Team", "players", "01", "u1")
+	orm.TomatoDBController.AddJoinRecord("Team", "players", "02", "u2")
+
+	buf := &bytes.Buffer{}
+	err = Backup(buf)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	orm.TomatoDBController.DeleteEverything()
+	initEnv()
+
+	results, err := Restore(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	if results["Team"] == nil || results["Team"].Imported != 4 || results["Team"].Failed != 0 {
+		t.Error("expect:", 4, "result:", results["Team"])
+	}
+
+	schema, err := loadSchema("Team")
+	if err != nil || reflect.DeepEqual(fields["players"], utils.M(schema["fields"])["players"]) == false {
+		t.Error("expect:", fields["players"], "result:", schema, err)
+	}
+	objects, _ := orm.TomatoDBController.Find("Team", types.M{}, types.M{"sort": []string{"objectId"}})
+	names := []string{}
+	for _, v := range objects {
+		names = append(names, utils.S(utils.M(v)["name"]))
+	}
+	if reflect.DeepEqual([]string{"a", "b"}, names) == false {
+		t.Error("expect:", []string{"a", "b"}, "result:", names)
+	}
+	records, _ := orm.TomatoDBController.FindJoinRecords("Team", "players", nil, 10)
+	related := []string{}
+	for _, record := range records {
+		related = append(related, utils.S(record["relatedId"]))
+	}
+	if reflect.DeepEqual([]string{"u1", "u2"}, related) == false {
+		t.Error("expect:", []string{"u1", "u2"}, "result:", related)
+	}
+	orm.TomatoDBController.DeleteEverything()
+}
+
+func Test_dropUnsupportedIndexOptions(t *testing.T) {
+	data := []struct {
+		databaseType string
+		expect       types.M
+	}{
+		{
+			databaseType: "MongoDB",
+			expect:       types.M{"fields": types.S{"createdAt"}, "expireAfterSeconds": 3600.0},
+		},
+		{
+			databaseType: "PostgreSQL",
+			expect:       types.M{"fields": types.S{"createdAt"}},
+		},
+	}
+	for _, v := range data {
+		definitions := []types.M{
+			types.M{
+				"className": "Session",
+				"indexes": types.M{
+					"ttl": types.M{"fields": types.S{"createdAt"}, "expireAfterSeconds": 3600.0},
+				},
+			},
+		}
+		dropUnsupportedIndexOptions(definitions, v.databaseType)
+		result := utils.M(definitions[0]["indexes"])["ttl"]
+		if reflect.DeepEqual(v.expect, result) == false {
+			t.Error(v.databaseType, "expect:", v.expect, "result:", result)
+		}
+	}
+}
+
+func initEnv() {
+	orm.InitOrm(mongo.NewMongoAdapter("tomato", test.OpenMongoDBForTest()))
+}
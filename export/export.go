@@ -0,0 +1,260 @@
+// Package export 导出与导入类中的数据，支持 NDJSON 与 CSV 格式
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+const (
+	// FormatNDJSON 每行一个 JSON 对象
+	FormatNDJSON = "ndjson"
+	// FormatCSV 第一行为字段名，之后每行一个对象
+	FormatCSV = "csv"
+)
+
+// batchSize 每次从数据库中读取的对象个数
+const batchSize = 1000
+
+// ContentType 返回导出格式对应的 Content-Type
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// ExportClass 以 format 格式导出类 className 中的全部对象，按 objectId 顺序分批读取并写入 w
+// NDJSON 格式中，对象之后为 Relation 字段的 _Join 表记录，格式为 {"__join":"users","owningId":"xxx","relatedId":"yyy"}
+// CSV 格式中，relation 不为空时仅导出该 Relation 字段的 _Join 表记录，列为 owningId 与 relatedId
+// File 字段仅导出文件引用 {"__type":"File","name":"xxx"} ，不包含文件内容
+// w 实现了 http.Flusher 时，每写入一批数据立即发送
+func ExportClass(className, format, relation string, w io.Writer) error {
+	schema, err := loadSchema(className)
+	if err != nil {
+		return err
+	}
+	if relation != "" {
+		if utils.S(utils.M(utils.M(schema["fields"])[relation])["type"]) != "Relation" {
+			return errs.E(errs.InvalidKeyName, "Field "+relation+" is not a Relation.")
+		}
+	}
+
+	switch format {
+	case FormatNDJSON, "":
+		return exportNDJSON(className, schema, relation, w)
+	case FormatCSV:
+		return exportCSV(className, schema, relation, w)
+	default:
+		return errs.E(errs.InvalidQuery, "invalid export format: "+format)
+	}
+}
+
+func exportNDJSON(className string, schema types.M, relation string, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	flush := func() { flushWriter(w) }
+	if relation == "" {
+		err := eachObject(className, flush, func(object types.M) error {
+			return encoder.Encode(object)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, key := range relationFields(schema, relation) {
+		err := eachJoinRecord(className, key, flush, func(record types.M) error {
+			return encoder.Encode(types.M{
+				"__join":    key,
+				"owningId":  record["owningId"],
+				"relatedId": record["relatedId"],
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportCSV(className string, schema types.M, relation string, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	flush := func() {
+		writer.Flush()
+		flushWriter(w)
+	}
+	if relation != "" {
+		err := writer.Write([]string{"owningId", "relatedId"})
+		if err != nil {
+			return err
+		}
+		err = eachJoinRecord(className, relation, flush, func(record types.M) error {
+			return writer.Write([]string{utils.S(record["owningId"]), utils.S(record["relatedId"])})
+		})
+		if err != nil {
+			return err
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+
+	columns := csvColumns(schema)
+	err := writer.Write(columns)
+	if err != nil {
+		return err
+	}
+	err = eachObject(className, flush, func(object types.M) error {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			value, err := encodeCSVValue(object[column])
+			if err != nil {
+				return err
+			}
+			row[i] = value
+		}
+		return writer.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// eachObject 按 objectId 顺序分批读取对象，使用 objectId 作为分页条件，避免 skip 过大时的性能问题
+func eachObject(className string, flush func(), fn func(object types.M) error) error {
+	lastID := ""
+	for {
+		query := types.M{}
+		if lastID != "" {
+			query["objectId"] = types.M{"$gt": lastID}
+		}
+		options := types.M{
+			"sort":  []string{"objectId"},
+			"limit": batchSize,
+		}
+		results, err := orm.TomatoDBController.Find(className, query, options)
+		if err != nil {
+			return err
+		}
+		for _, v := range results {
+			object := utils.M(v)
+			if object == nil {
+				continue
+			}
+			err = fn(object)
+			if err != nil {
+				return err
+			}
+			lastID = utils.S(object["objectId"])
+		}
+		flush()
+		if len(results) < batchSize {
+			return nil
+		}
+	}
+}
+
+// eachJoinRecord 按 owningId 、 relatedId 顺序分批读取 Relation 字段 key 的 _Join 表记录，使用上一批的最后一条记录作为分页条件
+func eachJoinRecord(className, key string, flush func(), fn func(record types.M) error) error {
+	var last types.M
+	for {
+		records, err := orm.TomatoDBController.FindJoinRecords(className, key, last, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			err = fn(record)
+			if err != nil {
+				return err
+			}
+			last = record
+		}
+		flush()
+		if len(records) < batchSize {
+			return nil
+		}
+	}
+}
+
+// flushWriter w 实现了 http.Flusher 时，发送已写入的数据
+func flushWriter(w io.Writer) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// loadSchema 获取类的 schema ，包括 _Hooks 等 schema 固定的系统类，类不存在时返回错误
+func loadSchema(className string) (types.M, error) {
+	if orm.ClassNameIsValid(className) == false && orm.IsVolatileClass(className) == false {
+		return nil, errs.E(errs.InvalidClassName, orm.InvalidClassNameMessage(className))
+	}
+	schema, err := orm.TomatoDBController.LoadSchema(nil).GetOneSchema(className, true, types.M{"clearCache": true})
+	if err != nil {
+		return nil, err
+	}
+	if len(schema) == 0 {
+		return nil, errs.E(errs.InvalidClassName, "Class "+className+" does not exist.")
+	}
+	return schema, nil
+}
+
+// relationFields 返回需要导出 _Join 表的 Relation 字段，relation 不为空时仅返回该字段
+func relationFields(schema types.M, relation string) []string {
+	if relation != "" {
+		return []string{relation}
+	}
+	keys := []string{}
+	for key, v := range utils.M(schema["fields"]) {
+		if utils.S(utils.M(v)["type"]) == "Relation" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// csvColumns CSV 中的列，默认字段在前，其他字段按名称排序， Relation 字段不在对象中保存，不导出
+func csvColumns(schema types.M) []string {
+	fields := utils.M(schema["fields"])
+	columns := []string{"objectId", "createdAt", "updatedAt", "ACL"}
+	others := []string{}
+	for key, v := range fields {
+		if key == "objectId" || key == "createdAt" || key == "updatedAt" || key == "ACL" {
+			continue
+		}
+		if utils.S(utils.M(v)["type"]) == "Relation" {
+			continue
+		}
+		others = append(others, key)
+	}
+	sort.Strings(others)
+	return append(columns, others...)
+}
+
+// encodeCSVValue 转换字段值为 CSV 中的字符串
+// 字符串直接写入， Date 类型写入 iso 时间，其他类型写入 JSON ，字段不存在时为空字符串
+func encodeCSVValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	if object := utils.M(value); object != nil && utils.S(object["__type"]) == "Date" {
+		return utils.S(object["iso"]), nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
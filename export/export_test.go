@@ -0,0 +1,221 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+func Test_ExportImport(t *testing.T) {
+	fields := types.M{
+		"name":    types.M{"type": "String"},
+		"score":   types.M{"type": "Number"},
+		"players": types.M{"type": "Relation", "targetClass": "_User"},
+	}
+	data := []struct {
+		format   string
+		relation string
+		imported int
+	}{
+		{format: FormatNDJSON, relation: "", imported: 5},
+		{format: FormatCSV, relation: "", imported: 2},
+		{format: FormatCSV, relation: "players", imported: 3},
+	}
+	for _, v := range data {
+		initEnv()
+		orm.TomatoDBController.LoadSchema(nil).AddClassIfNotExists("Team", fields, nil, nil)
+		orm.TomatoDBController.Create("Team", types.M{"objectId": "01", "name": "a", "score": 1.0}, nil)
+		orm.TomatoDBController.Create("Team", types.M{"objectId": "02", "name": "b", "score": 2.0}, nil)
+		orm.TomatoDBController.AddJoinRecord("Team", "players", "01", "u2")
+		orm.TomatoDBController.AddJoinRecord("Team", "players", "01", "u1")
+		orm.TomatoDBController.AddJoinRecord("Team", "players", "02", "u1")
+
+		buf := &bytes.Buffer{}
+		err := ExportClass("Team", v.format, v.relation, buf)
+		if err != nil {
+			t.Error(v.format, v.relation, "expect:", nil, "result:", err)
+		}
+		orm.TomatoDBController.DeleteEverything()
+		initEnv()
+		orm.TomatoDBController.LoadSchema(nil).AddClassIfNotExists("Team", fields, nil, nil)
+
+		result, err := ImportClass("Team", v.format, v.relation, buf.Bytes(), nil)
+		if err != nil || result.Imported != v.imported || result.Failed != 0 {
+			t.Error(v.format, v.relation, "expect:", v.imported, "result:", result, err)
+		}
+		if v.relation == "" {
+			objects, _ := orm.TomatoDBController.Find("Team", types.M{}, types.M{"sort": []string{"objectId"}})
+			names := []string{}
+			for _, object := range objects {
+				names = append(names, utils.S(utils.M(object)["name"])+":"+utils.S(utils.M(object)["objectId"]))
+			}
+			if reflect.DeepEqual([]string{"a:01", "b:02"}, names) == false {
+				t.Error(v.format, v.relation, "expect:", []string{"a:01", "b:02"}, "result:", names)
+			}
+		}
+		if v.format == FormatNDJSON || v.relation != "" {
+			records, _ := orm.TomatoDBController.FindJoinRecords("Team", "players", nil, 10)
+			related := []string{}
+			for _, record := range records {
+				related = append(related, utils.S(record["owningId"])+":"+utils.S(record["relatedId"]))
+			}
+			expect := []string{"01:u1", "01:u2", "02:u1"}
+			if reflect.DeepEqual(expect, related) == false {
+				t.Error(v.format, v.relation, "expect:", expect, "result:", related)
+			}
+		}
+		orm.TomatoDBController.DeleteEverything()
+	}
+}
+
+func Test_FindJoinRecords(t *testing.T) {
+	initEnv()
+	orm.TomatoDBController.AddJoinRecord("Team", "players", "01", "u2")
+	orm.TomatoDBController.AddJoinRecord("Team", "players", "02", "u1")
+	orm.TomatoDBController.AddJoinRecord("Team", "players", "01", "u1")
+	result := []string{}
+	var last types.M
+	for i := 0; i < 3; i++ {
+		records, err := orm.TomatoDBController.FindJoinRecords("Team", "players", last, 2)
+		if err != nil {
+			t.Error("expect:", nil, "result:", err)
+		}
+		for _, record := range records {
+			result = append(result, utils.S(record["owningId"])+":"+utils.S(record["relatedId"]))
+			last = record
+		}
+		if len(records) < 2 {
+			break
+		}
+	}
+	expect := []string{"01:u1", "01:u2", "02:u1"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	orm.TomatoDBController.DeleteEverything()
+}
+
+func Test_csvColumns(t *testing.T) {
+	schema := types.M{
+		"className": "GameScore",
+		"fields": types.M{
+			"objectId":  types.M{"type": "String"},
+			"createdAt": types.M{"type": "Date"},
+			"updatedAt": types.M{"type": "Date"},
+			"ACL":       types.M{"type": "ACL"},
+			"score":     types.M{"type": "Number"},
+			"name":      types.M{"type": "String"},
+			"players":   types.M{"type": "Relation", "targetClass": "_User"},
+		},
+	}
+	result := csvColumns(schema)
+	expect := []string{"objectId", "createdAt", "updatedAt", "ACL", "name", "score"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func Test_relationFields(t *testing.T) {
+	schema := types.M{
+		"fields": types.M{
+			"name":    types.M{"type": "String"},
+			"players": types.M{"type": "Relation", "targetClass": "_User"},
+			"admins":  types.M{"type": "Relation", "targetClass": "_User"},
+		},
+	}
+	result := relationFields(schema, "")
+	expect := []string{"admins", "players"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	result = relationFields(schema, "players")
+	expect = []string{"players"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func Test_encodeCSVValue(t *testing.T) {
+	data := []struct {
+		value  interface{}
+		expect string
+	}{
+		{value: nil, expect: ""},
+		{value: "hello", expect: "hello"},
+		{value: 10.5, expect: "10.5"},
+		{value: true, expect: "true"},
+		{value: types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"}, expect: "2006-01-02T15:04:05.000Z"},
+		{value: types.M{"__type": "Pointer", "className": "_User", "objectId": "1001"}, expect: `{"__type":"Pointer","className":"_User","objectId":"1001"}`},
+		{value: types.S{"a", 1.0}, expect: `["a",1]`},
+	}
+	for i, v := range data {
+		result, err := encodeCSVValue(v.value)
+		if err != nil || result != v.expect {
+			t.Error(i, "expect:", v.expect, "result:", result, err)
+		}
+	}
+}
+
+func Test_decodeCSVValue(t *testing.T) {
+	data := []struct {
+		column    string
+		fieldType types.M
+		value     string
+		expect    interface{}
+		expectErr error
+	}{
+		{column: "createdAt", fieldType: types.M{"type": "Date"}, value: "2006-01-02T15:04:05.000Z", expect: "2006-01-02T15:04:05.000Z"},
+		{column: "name", fieldType: types.M{"type": "String"}, value: "10", expect: "10"},
+		{column: "score", fieldType: types.M{"type": "Number"}, value: "10.5", expect: 10.5},
+		{column: "score", fieldType: types.M{"type": "Number"}, value: "abc", expectErr: errs.E(errs.IncorrectType, "invalid Number for score: abc")},
+		{column: "cheatMode", fieldType: types.M{"type": "Boolean"}, value: "false", expect: false},
+		{column: "playedAt", fieldType: types.M{"type": "Date"}, value: "2006-01-02T15:04:05.000Z", expect: types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"}},
+		{column: "tags", fieldType: types.M{"type": "Array"}, value: `["a","b"]`, expect: []interface{}{"a", "b"}},
+		{column: "tags", fieldType: types.M{"type": "Array"}, value: `[a`, expectErr: errs.E(errs.InvalidJSON, "invalid JSON for tags")},
+		{column: "other", fieldType: nil, value: "hello", expect: "hello"},
+		{column: "other", fieldType: nil, value: "1", expect: 1.0},
+	}
+	for i, v := range data {
+		result, err := decodeCSVValue(v.column, v.fieldType, v.value)
+		if reflect.DeepEqual(v.expectErr, err) == false || reflect.DeepEqual(v.expect, result) == false {
+			t.Error(i, "expect:", v.expect, v.expectErr, "result:", result, err)
+		}
+	}
+}
+
+type progressRecorder struct {
+	progress []float64
+}
+
+func (p *progressRecorder) SetProgress(progress float64) {
+	p.progress = append(p.progress, progress)
+}
+
+func Test_progressTracker(t *testing.T) {
+	p := &progressRecorder{}
+	tracker := newProgressTracker(p, 2500)
+	for i := 1; i <= 2500; i++ {
+		tracker.report(i)
+	}
+	expect := []float64{40, 80, 100}
+	if reflect.DeepEqual(expect, p.progress) == false {
+		t.Error("expect:", expect, "result:", p.progress)
+	}
+	/************************************************************/
+	// 距上次更新超过 progressInterval 时，未满 batchSize 也更新进度
+	p = &progressRecorder{}
+	tracker = newProgressTracker(p, 2500)
+	tracker.reportedAt = time.Now().Add(-progressInterval)
+	tracker.report(10)
+	tracker.report(11)
+	expect = []float64{0.4}
+	if reflect.DeepEqual(expect, p.progress) == false {
+		t.Error("expect:", expect, "result:", p.progress)
+	}
+}
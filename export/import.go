@@ -0,0 +1,290 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+// maxImportErrors ImportResult 中最多保存的错误信息个数
+const maxImportErrors = 10
+
+// ProgressReporter 接收导入进度，取值范围为 0-100 ， job.JobStatus 实现了该接口
+type ProgressReporter interface {
+	SetProgress(progress float64)
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Imported int      // 成功导入的记录数
+	Failed   int      // 导入失败的记录数
+	Errors   []string // 前 maxImportErrors 条导入失败的原因
+}
+
+func (r *ImportResult) addError(line int, err error) {
+	r.Failed++
+	if len(r.Errors) < maxImportErrors {
+		r.Errors = append(r.Errors, "line "+strconv.Itoa(line)+": "+err.Error())
+	}
+}
+
+// ImportClass 导入 ExportClass 导出的数据到类 className
+// 对象通过 orm.DBController 的 ValidateObject 与 Create 写入，遵循 schema 、 ACL 与唯一索引的限制，
+// objectId 已存在的对象导入失败，不会覆盖已有数据
+// CSV 格式中 relation 不为空时，导入该 Relation 字段的 _Join 表记录
+// progress 不为空时，每处理一批记录更新一次进度
+// 单条记录导入失败时继续导入，格式错误时返回错误
+func ImportClass(className, format, relation string, data []byte, progress ProgressReporter) (*ImportResult, error) {
+	if orm.ClassNameIsValid(className) == false && orm.IsVolatileClass(className) == false {
+		return nil, errs.E(errs.InvalidClassName, orm.InvalidClassNameMessage(className))
+	}
+	switch format {
+	case FormatNDJSON, "":
+		return importNDJSON(className, data, progress)
+	case FormatCSV:
+		return importCSV(className, relation, data, progress)
+	default:
+		return nil, errs.E(errs.InvalidQuery, "invalid import format: "+format)
+	}
+}
+
+func importNDJSON(className string, data []byte, progress ProgressReporter) (*ImportResult, error) {
+	result := &ImportResult{Errors: []string{}}
+	total := bytes.Count(data, []byte("\n")) + 1
+	tracker := newProgressTracker(progress, total)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record types.M
+		err := json.Unmarshal([]byte(text), &record)
+		if err != nil {
+			return result, errs.E(errs.InvalidJSON, "line "+strconv.Itoa(line)+": invalid JSON")
+		}
+		if key := utils.S(record["__join"]); key != "" {
+			err = importJoinRecord(className, key, record)
+		} else {
+			err = importObject(className, record)
+		}
+		if err != nil {
+			result.addError(line, err)
+		} else {
+			result.Imported++
+		}
+		tracker.report(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+	tracker.report(total)
+	return result, nil
+}
+
+func importCSV(className, relation string, data []byte, progress ProgressReporter) (*ImportResult, error) {
+	result := &ImportResult{Errors: []string{}}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, errs.E(errs.InvalidJSON, "invalid CSV: "+err.Error())
+	}
+	if len(rows) == 0 {
+		return result, nil
+	}
+	columns := rows[0]
+	tracker := newProgressTracker(progress, len(rows))
+
+	var fields types.M
+	if relation == "" {
+		schema, err := orm.TomatoDBController.LoadSchema(nil).GetOneSchema(className, false, types.M{"clearCache": true})
+		if err != nil {
+			return nil, err
+		}
+		fields = utils.M(schema["fields"])
+	}
+
+	for i, row := range rows[1:] {
+		line := i + 2
+		record := types.M{}
+		var err error
+		for j, column := range columns {
+			if j >= len(row) || row[j] == "" {
+				continue
+			}
+			if relation != "" {
+				record[column] = row[j]
+				continue
+			}
+			record[column], err = decodeCSVValue(column, utils.M(fields[column]), row[j])
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			if relation != "" {
+				err = importJoinRecord(className, relation, record)
+			} else {
+				err = importObject(className, record)
+			}
+		}
+		if err != nil {
+			result.addError(line, err)
+		} else {
+			result.Imported++
+		}
+		tracker.report(line)
+	}
+	return result, nil
+}
+
+// importObject 校验并创建对象
+// _User 中导出的 password 为密码哈希，导入时写回 _hashed_password
+// Relation 字段仅用于在 schema 中添加字段，关联关系通过 _Join 表记录导入
+func importObject(className string, object types.M) error {
+	db := orm.TomatoDBController
+	if orm.IsVolatileClass(className) {
+		return importVolatileObject(className, object)
+	}
+	if className == "_User" {
+		if password, ok := object["password"]; ok {
+			object["_hashed_password"] = password
+			delete(object, "password")
+		}
+	}
+
+	// 以 _ 开头的内部字段，以及默认字段不需要校验
+	validation := types.M{}
+	for key, value := range object {
+		if strings.HasPrefix(key, "_") || key == "objectId" || key == "createdAt" || key == "updatedAt" {
+			continue
+		}
+		validation[key] = value
+	}
+	err := db.ValidateObject(className, validation, nil, types.M{})
+	if err != nil {
+		return err
+	}
+
+	for key, value := range object {
+		if utils.S(utils.M(value)["__type"]) == "Relation" {
+			delete(object, key)
+		}
+	}
+	return db.Create(className, object, types.M{})
+}
+
+// importVolatileObject 导入 _Hooks 等 schema 固定的系统类中的对象
+// 与 hooks 、 /config 中的写入方式相同，以 objectId 为条件进行 upsert
+func importVolatileObject(className string, object types.M) error {
+	objectID := utils.S(object["objectId"])
+	if objectID == "" {
+		return errs.E(errs.MissingObjectID, "objectId is required.")
+	}
+	update := types.M{}
+	for key, value := range object {
+		if key == "objectId" {
+			continue
+		}
+		if iso, ok := value.(string); ok && (key == "createdAt" || key == "updatedAt") {
+			value = types.M{"__type": "Date", "iso": iso}
+		}
+		update[key] = value
+	}
+	if len(update) == 0 {
+		return nil
+	}
+	_, err := orm.TomatoDBController.Update(className, types.M{"objectId": objectID}, update, types.M{"upsert": true}, false)
+	return err
+}
+
+// importJoinRecord 导入 Relation 字段 key 的 _Join 表记录
+func importJoinRecord(className, key string, record types.M) error {
+	owningID := utils.S(record["owningId"])
+	relatedID := utils.S(record["relatedId"])
+	if owningID == "" || relatedID == "" {
+		return errs.E(errs.InvalidJSON, "owningId and relatedId are required for join records")
+	}
+	schema, err := orm.TomatoDBController.LoadSchema(nil).GetOneSchema(className, false, nil)
+	if err != nil {
+		return err
+	}
+	if utils.S(utils.M(utils.M(schema["fields"])[key])["type"]) != "Relation" {
+		return errs.E(errs.InvalidKeyName, "Field "+key+" is not a Relation.")
+	}
+	return orm.TomatoDBController.AddJoinRecord(className, key, owningID, relatedID)
+}
+
+// decodeCSVValue 按照字段类型转换 CSV 中的字符串，与 encodeCSVValue 对应
+// 默认字段与 String 类型直接使用字符串，不在 schema 中的字段尝试按 JSON 解析
+func decodeCSVValue(column string, fieldType types.M, s string) (interface{}, error) {
+	if column == "objectId" || column == "createdAt" || column == "updatedAt" {
+		return s, nil
+	}
+	switch utils.S(fieldType["type"]) {
+	case "String":
+		return s, nil
+	case "Date":
+		return types.M{"__type": "Date", "iso": s}, nil
+	case "Number":
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, errs.E(errs.IncorrectType, "invalid Number for "+column+": "+s)
+		}
+		return n, nil
+	case "Boolean":
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, errs.E(errs.IncorrectType, "invalid Boolean for "+column+": "+s)
+		}
+		return b, nil
+	}
+	var value interface{}
+	err := json.Unmarshal([]byte(s), &value)
+	if err != nil {
+		if fieldType == nil {
+			return s, nil
+		}
+		return nil, errs.E(errs.InvalidJSON, "invalid JSON for "+column)
+	}
+	return value, nil
+}
+
+// progressInterval 两次更新进度的最长间隔，处理较慢时也按时更新进度
+var progressInterval = 10 * time.Second
+
+// progressTracker 每处理 batchSize 条记录、距上次更新超过 progressInterval ，以及处理完成时更新进度
+type progressTracker struct {
+	progress   ProgressReporter
+	total      int
+	reportedAt time.Time
+}
+
+func newProgressTracker(progress ProgressReporter, total int) *progressTracker {
+	return &progressTracker{
+		progress:   progress,
+		total:      total,
+		reportedAt: time.Now(),
+	}
+}
+
+func (p *progressTracker) report(current int) {
+	if p.progress == nil || p.total == 0 {
+		return
+	}
+	if current%batchSize == 0 || current >= p.total || time.Since(p.reportedAt) >= progressInterval {
+		p.progress.SetProgress(float64(current) * 100 / float64(p.total))
+		p.reportedAt = time.Now()
+	}
+}
@@ -1,6 +1,8 @@
 package hooks
 
 import (
+	"time"
+
 	"github.com/lfq7413/tomato/cloud"
 	"github.com/lfq7413/tomato/errs"
 	"github.com/lfq7413/tomato/orm"
@@ -114,10 +116,19 @@ func saveHook(hook types.M) (types.M, error) {
 }
 
 func addHookToTriggers(hook types.M) {
+	timeout := hookTimeout(hook)
 	if hook["className"] != nil {
-		cloud.AddTrigger(utils.S(hook["triggerName"]), utils.S(hook["className"]), cloud.GetTriggerHandler(utils.S(hook["url"])))
+		cloud.AddTrigger(utils.S(hook["triggerName"]), utils.S(hook["className"]), cloud.GetTriggerHandler(utils.S(hook["url"]), timeout))
 	}
-	cloud.AddFunction(utils.S(hook["functionName"]), cloud.GetFunctionHandler(utils.S(hook["url"])), nil)
+	cloud.AddFunction(utils.S(hook["functionName"]), cloud.GetFunctionHandler(utils.S(hook["url"]), timeout), nil)
+}
+
+// hookTimeout 回调的请求超时时间， timeout 单位为毫秒，未设置时返回 0 ，使用 WebhookTimeout
+func hookTimeout(hook types.M) time.Duration {
+	if timeout, ok := hook["timeout"].(float64); ok && timeout > 0 {
+		return time.Duration(timeout) * time.Millisecond
+	}
+	return 0
 }
 
 func addHook(hook types.M) (types.M, error) {
@@ -133,6 +144,10 @@ func createOrUpdateHook(aHook types.M) (types.M, error) {
 			"url":          aHook["url"],
 		}
 	} else if aHook != nil && aHook["className"] != nil && aHook["url"] != nil && aHook["triggerName"] != nil {
+		err := cloud.ValidateTrigger(utils.S(aHook["triggerName"]), utils.S(aHook["className"]))
+		if err != nil {
+			return nil, errs.E(errs.WebhookError, err.Error())
+		}
 		hook = types.M{
 			"className":   aHook["className"],
 			"triggerName": aHook["triggerName"],
@@ -141,6 +156,13 @@ func createOrUpdateHook(aHook types.M) (types.M, error) {
 	} else {
 		return nil, errs.E(errs.WebhookError, "invalid hook declaration")
 	}
+	// timeout 为回调的请求超时时间，单位为毫秒
+	if timeout, ok := aHook["timeout"]; ok {
+		if t, ok := timeout.(float64); ok == false || t < 0 {
+			return nil, errs.E(errs.WebhookError, "invalid hook timeout")
+		}
+		hook["timeout"] = timeout
+	}
 
 	return addHook(hook)
 }
@@ -170,13 +192,22 @@ func UpdateHook(aHook types.M) (types.M, error) {
 		if result == nil {
 			return nil, errs.E(errs.WebhookError, "no function named: "+utils.S(aHook["functionName"])+" is defined")
 		}
+		keepTimeout(aHook, result)
 		return createOrUpdateHook(aHook)
 	} else if aHook["className"] != nil && aHook["triggerName"] != nil {
 		result, _ := GetTrigger(utils.S(aHook["className"]), utils.S(aHook["triggerName"]))
 		if result == nil {
 			return nil, errs.E(errs.WebhookError, "class "+utils.S(aHook["className"])+" does not exist")
 		}
+		keepTimeout(aHook, result)
 		return createOrUpdateHook(aHook)
 	}
 	return nil, errs.E(errs.WebhookError, "invalid hook declaration")
 }
+
+// keepTimeout 更新回调时未设置 timeout ，则沿用原有的设置
+func keepTimeout(aHook, oldHook types.M) {
+	if _, ok := aHook["timeout"]; ok == false && oldHook["timeout"] != nil {
+		aHook["timeout"] = oldHook["timeout"]
+	}
+}
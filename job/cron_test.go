@@ -0,0 +1,86 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseCron(t *testing.T) {
+	var expr string
+	var err error
+	/************************************************************/
+	for _, expr = range []string{"* * * * *", "*/15 0-6 1,15 jan-mar mon-fri", "@daily", "0 0 * * 7", "5/10 * * * *"} {
+		_, err = parseCron(expr)
+		if err != nil {
+			t.Error("expect:", nil, "result:", expr, err)
+		}
+	}
+	/************************************************************/
+	for _, expr = range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		_, err = parseCron(expr)
+		if err == nil {
+			t.Error("expect:", "error", "result:", expr)
+		}
+	}
+}
+
+func Test_cronNext(t *testing.T) {
+	var expr string
+	var from, result, expect time.Time
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	/************************************************************/
+	expr = "*/15 * * * *"
+	from = time.Date(2017, 3, 13, 10, 7, 30, 0, time.UTC)
+	expect = time.Date(2017, 3, 13, 10, 15, 0, 0, time.UTC)
+	result = mustParseCron(t, expr).next(from)
+	if result.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	expr = "0 3 * * *"
+	from = time.Date(2017, 3, 13, 3, 0, 0, 0, time.UTC)
+	expect = time.Date(2017, 3, 14, 3, 0, 0, 0, time.UTC)
+	result = mustParseCron(t, expr).next(from)
+	if result.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	expr = "0 3 * * *"
+	from = time.Date(2017, 3, 13, 0, 0, 0, 0, time.UTC).In(shanghai)
+	expect = time.Date(2017, 3, 13, 19, 0, 0, 0, time.UTC)
+	result = mustParseCron(t, expr).next(from)
+	if result.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	expr = "30 9 * * mon"
+	from = time.Date(2017, 3, 14, 0, 0, 0, 0, time.UTC)
+	expect = time.Date(2017, 3, 20, 9, 30, 0, 0, time.UTC)
+	result = mustParseCron(t, expr).next(from)
+	if result.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	expr = "0 0 13 * 5"
+	from = time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	expect = time.Date(2017, 3, 3, 0, 0, 0, 0, time.UTC)
+	result = mustParseCron(t, expr).next(from)
+	if result.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	expr = "0 0 30 2 *"
+	from = time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	result = mustParseCron(t, expr).next(from)
+	if result.IsZero() == false {
+		t.Error("expect:", time.Time{}, "result:", result)
+	}
+}
+
+func mustParseCron(t *testing.T, expr string) *cronSchedule {
+	s, err := parseCron(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,200 @@
+package job
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lfq7413/tomato/cloud"
+	"github.com/lfq7413/tomato/config"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+// cancelCheckInterval 更新心跳与检查其他实例取消请求的间隔
+var cancelCheckInterval = 5 * time.Second
+
+// jobLeaseDuration 任务心跳的有效期，超过该时间未更新心跳时，认为执行任务的实例已经停止
+const jobLeaseDuration = time.Minute
+
+// runningJobs 当前实例中正在执行的任务
+var runningJobs = struct {
+	sync.Mutex
+	cancels map[string]context.CancelFunc
+}{cancels: map[string]context.CancelFunc{}}
+
+// Run 在后台执行 cloud 中注册的任务，返回任务状态
+// params 为传递给任务的参数， statusParams 为记录在 _JobStatus 中的参数
+// source 为任务的触发来源
+func Run(jobName string, params, statusParams types.M, headers map[string]string, source string) (types.M, error) {
+	jobFunction := cloud.GetJob(jobName)
+	if jobFunction == nil {
+		return nil, errs.E(errs.ScriptFailed, "Invalid job.")
+	}
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	return start(jobName, jobFunction, params, statusParams, headers, source, jobTimeout(jobName)), nil
+}
+
+// RunHandler 在后台执行内置的任务，例如数据导入，返回任务状态
+// 与 cloud 中注册的任务一样定时更新心跳并检查取消请求，不限制执行时间
+func RunHandler(jobName string, handler cloud.JobHandler, statusParams types.M, source string) types.M {
+	return start(jobName, handler, types.M{}, statusParams, map[string]string{}, source, 0)
+}
+
+// start 设置任务状态为 running ，并在后台执行任务
+func start(jobName string, jobFunction cloud.JobHandler, params, statusParams types.M, headers map[string]string, source string, timeout time.Duration) types.M {
+	jobHandler := NewjobStatus()
+	jobHandler.timeout = timeout
+	jobStatus := jobHandler.setRunning(jobName, statusParams, source)
+	request := cloud.JobRequest{
+		Params:  params,
+		JobName: jobName,
+		Headers: headers,
+		JobID:   utils.S(jobStatus["objectId"]),
+	}
+	go jobHandler.run(jobFunction, request, timeout)
+	return jobStatus
+}
+
+// jobTimeout 返回任务的最长执行时间，优先使用 cloud.SetJobTimeout 设置的时间
+func jobTimeout(jobName string) time.Duration {
+	if timeout := cloud.GetJobTimeout(jobName); timeout > 0 {
+		return timeout
+	}
+	return time.Duration(config.TConfig.JobMaxRuntime) * time.Second
+}
+
+// run 执行任务，并在任务被取消或者超时时设置任务状态
+// 执行期间每隔 cancelCheckInterval 更新一次心跳，并检查其他实例的取消请求
+func (j *JobStatus) run(jobFunction cloud.JobHandler, request cloud.JobRequest, timeout time.Duration) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	request.Context = ctx
+
+	runningJobs.Lock()
+	runningJobs.cancels[j.objectID] = cancel
+	runningJobs.Unlock()
+	defer func() {
+		runningJobs.Lock()
+		delete(runningJobs.cancels, j.objectID)
+		runningJobs.Unlock()
+		cancel()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		jobFunction(request, cloud.JobResponse{JobStatus: j})
+	}()
+
+	ticker := time.NewTicker(cancelCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				j.setFinalStatus("failed", "Job exceeded the maximum runtime of "+strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64)+"s")
+			} else {
+				j.setFinalStatus("cancelled", "Job was cancelled")
+			}
+			return
+		case <-ticker.C:
+			j.heartbeat()
+			if j.cancelRequested() {
+				cancel()
+			}
+		}
+	}
+}
+
+// Cancel 取消正在执行的任务
+// 任务在当前实例中执行时直接取消，否则把任务状态设置为 cancelling ，由执行任务的实例取消
+func Cancel(objectID string) error {
+	runningJobs.Lock()
+	cancel, ok := runningJobs.cancels[objectID]
+	runningJobs.Unlock()
+	if ok {
+		cancel()
+		return nil
+	}
+
+	where := types.M{
+		"objectId": objectID,
+		"status":   "running",
+	}
+	_, err := orm.TomatoDBController.Update(jobStatusCollection, where, types.M{"status": "cancelling"}, types.M{}, false)
+	if err != nil {
+		return errs.E(errs.ObjectNotFound, "Job is not running.")
+	}
+	return nil
+}
+
+// failStaleJobs 把执行实例已经停止的任务设置为 failed
+// 执行任务的实例退出后，任务会一直处于 running 或者 cancelling 状态，通过 staleJobMessage 检查租约是否失效，
+// 更新时以读取到的 heartbeatAt 为条件，任务在此期间更新了心跳时不做修改
+func failStaleJobs(now time.Time) {
+	db := orm.TomatoDBController
+	where := types.M{"status": types.M{"$in": types.S{"running", "cancelling"}}}
+	results, err := db.Find(jobStatusCollection, where, types.M{})
+	if err != nil {
+		return
+	}
+	for _, v := range results {
+		status := utils.M(v)
+		message := staleJobMessage(status, now)
+		if message == "" {
+			continue
+		}
+		where := types.M{
+			"objectId": status["objectId"],
+			"status":   types.M{"$in": types.S{"running", "cancelling"}},
+		}
+		if heartbeatAt, err := parseStatusTime(status["heartbeatAt"]); err == nil {
+			where["heartbeatAt"] = utils.TimetoString(heartbeatAt)
+		} else {
+			where["heartbeatAt"] = types.M{"$exists": false}
+		}
+		update := types.M{
+			"status":     "failed",
+			"finishedAt": utils.TimetoString(now),
+			"message":    message,
+		}
+		db.Update(jobStatusCollection, where, update, types.M{}, false)
+	}
+}
+
+// staleJobMessage 检查任务的租约是否失效，返回任务失败的原因，租约有效时返回空字符串
+// heartbeatAt 超过 jobLeaseDuration 未更新，或者超过 deadlineAt 一个检查间隔后任务仍未结束时，租约失效
+// 没有 heartbeatAt 的任务无法判断执行实例的状态，仅检查 deadlineAt
+func staleJobMessage(status types.M, now time.Time) string {
+	if status == nil {
+		return ""
+	}
+	if t, err := parseStatusTime(status["heartbeatAt"]); err == nil && now.Sub(t) > jobLeaseDuration {
+		return "Job was abandoned by the instance running it"
+	}
+	if t, err := parseStatusTime(status["deadlineAt"]); err == nil && now.Sub(t) > cancelCheckInterval {
+		return "Job exceeded the maximum runtime"
+	}
+	return ""
+}
+
+// parseStatusTime 解析 _JobStatus 中保存的时间， MongoDB 中 ISO 8601 格式的字符串会被读取为 Date 类型
+func parseStatusTime(value interface{}) (time.Time, error) {
+	if date := utils.M(value); date != nil {
+		value = date["iso"]
+	}
+	return utils.StringtoTime(utils.S(value))
+}
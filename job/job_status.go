@@ -12,9 +12,12 @@ const jobStatusCollection = "_JobStatus"
 
 // JobStatus ...
 type JobStatus struct {
-	objectID string
-	status   types.M
-	db       *orm.DBController
+	objectID  string
+	status    types.M
+	db        *orm.DBController
+	startedAt time.Time
+	// timeout 任务的最长执行时间，为 0 时不限制
+	timeout time.Duration
 }
 
 // NewjobStatus ...
@@ -27,25 +30,55 @@ func NewjobStatus() *JobStatus {
 }
 
 // SetRunning ...
+// 未通过 Run 执行的任务不会自动更新心跳，需要在 jobLeaseDuration 内调用 SetProgress 或者 SetMessage ，
+// 否则会被认为执行任务的实例已经停止，任务状态被设置为 failed
 func (j *JobStatus) SetRunning(jobName string, params types.M) types.M {
+	return j.setRunning(jobName, params, "api")
+}
+
+// setRunning source 为任务的触发来源， api 表示通过接口触发， schedule 表示由任务调度器触发
+func (j *JobStatus) setRunning(jobName string, params types.M, source string) types.M {
 	now := time.Now().UTC()
+	j.startedAt = now
 	j.status = types.M{
-		"objectId":  j.objectID,
-		"jobName":   jobName,
-		"params":    params,
-		"status":    "running",
-		"source":    "api",
-		"createdAt": utils.TimetoString(now),
+		"objectId":    j.objectID,
+		"jobName":     jobName,
+		"params":      params,
+		"status":      "running",
+		"source":      source,
+		"createdAt":   utils.TimetoString(now),
+		"heartbeatAt": utils.TimetoString(now),
 		// lockdown!
 		"ACL": types.M{},
 	}
+	if j.timeout > 0 {
+		j.status["deadlineAt"] = utils.TimetoString(now.Add(j.timeout))
+	}
 	j.db.Create(jobStatusCollection, j.status, types.M{})
 	return j.status
 }
 
-// SetMessage ...
+// SetMessage 更新任务信息，同时更新心跳
 func (j *JobStatus) SetMessage(message string) {
-	j.db.Update(jobStatusCollection, types.M{"objectId": j.objectID}, types.M{"message": message}, types.M{}, false)
+	update := types.M{
+		"message":     message,
+		"heartbeatAt": utils.TimetoString(time.Now().UTC()),
+	}
+	j.db.Update(jobStatusCollection, types.M{"objectId": j.objectID}, update, types.M{}, false)
+}
+
+// SetProgress 更新任务进度，取值范围为 0-100 ，同时更新心跳
+func (j *JobStatus) SetProgress(progress float64) {
+	if progress < 0 {
+		progress = 0
+	} else if progress > 100 {
+		progress = 100
+	}
+	update := types.M{
+		"progress":    progress,
+		"heartbeatAt": utils.TimetoString(time.Now().UTC()),
+	}
+	j.db.Update(jobStatusCollection, types.M{"objectId": j.objectID}, update, types.M{}, false)
 }
 
 // SetSucceeded ...
@@ -58,7 +91,8 @@ func (j *JobStatus) SetFailed(message string) {
 	j.setFinalStatus("failed", message)
 }
 
-// setFinalStatus ...
+// setFinalStatus 设置任务的最终状态，并记录任务的执行时长，单位为毫秒
+// 仅能修改正在执行的任务，已结束的任务再次调用时不做处理
 func (j *JobStatus) setFinalStatus(status, message string) {
 	finishedAt := time.Now().UTC()
 	update := types.M{
@@ -66,5 +100,30 @@ func (j *JobStatus) setFinalStatus(status, message string) {
 		"finishedAt": utils.TimetoString(finishedAt),
 		"message":    message,
 	}
-	j.db.Update(jobStatusCollection, types.M{"objectId": j.objectID}, update, types.M{}, false)
+	if j.startedAt.IsZero() == false {
+		update["duration"] = int64(finishedAt.Sub(j.startedAt) / time.Millisecond)
+	}
+	where := types.M{
+		"objectId": j.objectID,
+		"status":   types.M{"$in": types.S{"running", "cancelling"}},
+	}
+	j.db.Update(jobStatusCollection, where, update, types.M{}, false)
+}
+
+// heartbeat 更新心跳，表示执行任务的实例仍在运行
+func (j *JobStatus) heartbeat() {
+	where := types.M{
+		"objectId": j.objectID,
+		"status":   types.M{"$in": types.S{"running", "cancelling"}},
+	}
+	j.db.Update(jobStatusCollection, where, types.M{"heartbeatAt": utils.TimetoString(time.Now().UTC())}, types.M{}, false)
+}
+
+// cancelRequested 检查任务是否被其他实例请求取消
+func (j *JobStatus) cancelRequested() bool {
+	results, err := j.db.Find(jobStatusCollection, types.M{"objectId": j.objectID}, types.M{})
+	if err != nil || len(results) == 0 {
+		return false
+	}
+	return utils.S(utils.M(results[0])["status"]) == "cancelling"
 }
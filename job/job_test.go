@@ -0,0 +1,228 @@
+package job
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lfq7413/tomato/cloud"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/storage/mongo"
+	"github.com/lfq7413/tomato/test"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+func Test_Run(t *testing.T) {
+	var status types.M
+	var err error
+	initEnv()
+	defer orm.TomatoDBController.DeleteEverything()
+	cloud.Job("succeed", func(request cloud.JobRequest, response cloud.JobResponse) {
+		response.Progress(50)
+		response.Success("done " + utils.S(request.Params["key"]))
+	})
+	defer cloud.RemoveJob("succeed")
+	/************************************************************/
+	status, err = Run("succeed", types.M{"key": "hello"}, types.M{"key": "hello"}, nil, "api")
+	if err != nil || status["status"] != "running" || status["heartbeatAt"] == nil || status["deadlineAt"] != nil {
+		t.Error("expect:", "running", "result:", status, err)
+	}
+	status = waitForStatus(utils.S(status["objectId"]), "succeeded")
+	if status["message"] != "done hello" || status["progress"] != 50.0 {
+		t.Error("expect:", "done hello", "result:", status)
+	}
+	/************************************************************/
+	status = RunHandler("builtin", func(request cloud.JobRequest, response cloud.JobResponse) {
+		response.Success("done " + request.JobName)
+	}, types.M{"key": "hello"}, "api")
+	if status["status"] != "running" || status["deadlineAt"] != nil {
+		t.Error("expect:", "running", "result:", status)
+	}
+	status = waitForStatus(utils.S(status["objectId"]), "succeeded")
+	if status["message"] != "done builtin" {
+		t.Error("expect:", "done builtin", "result:", status)
+	}
+	/************************************************************/
+	_, err = Run("other", types.M{}, types.M{}, nil, "api")
+	if reflect.DeepEqual(errs.E(errs.ScriptFailed, "Invalid job."), err) == false {
+		t.Error("expect:", "Invalid job.", "result:", err)
+	}
+}
+
+func Test_Run_timeout(t *testing.T) {
+	initEnv()
+	defer orm.TomatoDBController.DeleteEverything()
+	cloud.Job("slow", func(request cloud.JobRequest, response cloud.JobResponse) {
+		<-request.Context.Done()
+	})
+	cloud.SetJobTimeout("slow", 50*time.Millisecond)
+	defer cloud.RemoveJob("slow")
+
+	status, err := Run("slow", types.M{}, types.M{}, nil, "api")
+	if err != nil || status["deadlineAt"] == nil {
+		t.Error("expect:", "deadlineAt", "result:", status, err)
+	}
+	status = waitForStatus(utils.S(status["objectId"]), "failed")
+	if status["message"] != "Job exceeded the maximum runtime of 0.05s" {
+		t.Error("expect:", "Job exceeded the maximum runtime of 0.05s", "result:", status)
+	}
+}
+
+func Test_Cancel(t *testing.T) {
+	var status types.M
+	var err error
+	initEnv()
+	defer orm.TomatoDBController.DeleteEverything()
+	defer func(interval time.Duration) {
+		cancelCheckInterval = interval
+	}(cancelCheckInterval)
+	cancelCheckInterval = 10 * time.Millisecond
+	cloud.Job("wait", func(request cloud.JobRequest, response cloud.JobResponse) {
+		<-request.Context.Done()
+	})
+	defer cloud.RemoveJob("wait")
+	/************************************************************/
+	// 任务在当前实例中执行
+	status, _ = Run("wait", types.M{}, types.M{}, nil, "api")
+	err = Cancel(utils.S(status["objectId"]))
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	status = waitForStatus(utils.S(status["objectId"]), "cancelled")
+	if status["message"] != "Job was cancelled" {
+		t.Error("expect:", "Job was cancelled", "result:", status)
+	}
+	/************************************************************/
+	// 其他实例请求取消
+	status, _ = Run("wait", types.M{}, types.M{}, nil, "api")
+	orm.TomatoDBController.Update(jobStatusCollection, types.M{"objectId": status["objectId"]}, types.M{"status": "cancelling"}, types.M{}, false)
+	status = waitForStatus(utils.S(status["objectId"]), "cancelled")
+	if status["status"] != "cancelled" {
+		t.Error("expect:", "cancelled", "result:", status)
+	}
+	/************************************************************/
+	// 任务在其他实例中执行
+	jobHandler := NewjobStatus()
+	status = jobHandler.SetRunning("remote", types.M{})
+	err = Cancel(utils.S(status["objectId"]))
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	status = getStatus(utils.S(status["objectId"]))
+	if status["status"] != "cancelling" {
+		t.Error("expect:", "cancelling", "result:", status)
+	}
+	/************************************************************/
+	jobHandler.SetFailed("stopped")
+	err = Cancel(utils.S(status["objectId"]))
+	if reflect.DeepEqual(errs.E(errs.ObjectNotFound, "Job is not running."), err) == false {
+		t.Error("expect:", "Job is not running.", "result:", err)
+	}
+}
+
+func Test_SetProgress(t *testing.T) {
+	initEnv()
+	defer orm.TomatoDBController.DeleteEverything()
+	jobHandler := NewjobStatus()
+	jobHandler.SetRunning("job", types.M{})
+	data := []struct {
+		progress float64
+		expect   float64
+	}{
+		{progress: 30, expect: 30},
+		{progress: 150, expect: 100},
+		{progress: -5, expect: 0},
+	}
+	for _, v := range data {
+		jobHandler.SetProgress(v.progress)
+		status := getStatus(jobHandler.objectID)
+		if status["progress"] != v.expect {
+			t.Error(v.progress, "expect:", v.expect, "result:", status["progress"])
+		}
+	}
+}
+
+func Test_failStaleJobs(t *testing.T) {
+	initEnv()
+	defer orm.TomatoDBController.DeleteEverything()
+	now := time.Now().UTC()
+	running := NewjobStatus()
+	running.SetRunning("job", types.M{})
+	abandoned := NewjobStatus()
+	abandoned.SetRunning("job", types.M{})
+	orm.TomatoDBController.Update(jobStatusCollection, types.M{"objectId": abandoned.objectID}, types.M{
+		"status":      "cancelling",
+		"heartbeatAt": utils.TimetoString(now.Add(-2 * jobLeaseDuration)),
+	}, types.M{}, false)
+
+	failStaleJobs(now)
+	status := getStatus(running.objectID)
+	if status["status"] != "running" {
+		t.Error("expect:", "running", "result:", status)
+	}
+	status = getStatus(abandoned.objectID)
+	if status["status"] != "failed" || status["message"] != "Job was abandoned by the instance running it" {
+		t.Error("expect:", "failed", "result:", status)
+	}
+}
+
+func Test_staleJobMessage(t *testing.T) {
+	now := time.Date(2017, 3, 13, 10, 0, 0, 0, time.UTC)
+	data := []struct {
+		status types.M
+		expect string
+	}{
+		{
+			status: types.M{"heartbeatAt": "2017-03-13T09:59:50.000Z"},
+			expect: "",
+		},
+		{
+			status: types.M{"heartbeatAt": "2017-03-13T09:50:00.000Z"},
+			expect: "Job was abandoned by the instance running it",
+		},
+		{
+			status: types.M{"heartbeatAt": "2017-03-13T09:59:50.000Z", "deadlineAt": "2017-03-13T09:59:58.000Z"},
+			expect: "",
+		},
+		{
+			status: types.M{"heartbeatAt": "2017-03-13T09:59:50.000Z", "deadlineAt": "2017-03-13T09:59:00.000Z"},
+			expect: "Job exceeded the maximum runtime",
+		},
+		{
+			status: types.M{},
+			expect: "",
+		},
+	}
+	for i, v := range data {
+		if result := staleJobMessage(v.status, now); result != v.expect {
+			t.Error(i, "expect:", v.expect, "result:", result)
+		}
+	}
+}
+
+func getStatus(objectID string) types.M {
+	results, err := orm.TomatoDBController.Find(jobStatusCollection, types.M{"objectId": objectID}, types.M{})
+	if err != nil || len(results) == 0 {
+		return nil
+	}
+	return utils.M(results[0])
+}
+
+// waitForStatus 等待任务进入 status 状态，最多等待 1 秒
+func waitForStatus(objectID, status string) types.M {
+	var result types.M
+	for i := 0; i < 100; i++ {
+		result = getStatus(objectID)
+		if utils.S(result["status"]) == status {
+			return result
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return result
+}
+
+func initEnv() {
+	orm.InitOrm(mongo.NewMongoAdapter("tomato", test.OpenMongoDBForTest()))
+}
@@ -0,0 +1,248 @@
+package job
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/lfq7413/tomato/cloud"
+	"github.com/lfq7413/tomato/config"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+const jobScheduleCollection = "_JobSchedule"
+
+// ScheduleFields _JobSchedule 中允许通过接口设置的字段
+var ScheduleFields = []string{"jobName", "description", "params", "cron", "repeatMinutes", "startAfter", "timeZone"}
+
+// RunScheduler 启动后台任务调度器
+// 调度器定时检查 _JobSchedule 中的任务，执行时间到达时调用 cloud 中注册的任务
+// 执行前以 nextRunAt 为条件更新下一次执行时间，相当于在数据库中获取该次执行的租约，
+// 多个 tomato 实例同时运行调度器时，每次执行仅会被一个实例触发
+// 调度器同时检查 _JobStatus 中执行实例已经停止的任务，并设置为 failed
+func RunScheduler() {
+	if config.TConfig.JobSchedulerInterval <= 0 {
+		return
+	}
+	interval := time.Duration(config.TConfig.JobSchedulerInterval) * time.Second
+	go func() {
+		for {
+			now := time.Now().UTC()
+			runScheduledJobs(now)
+			failStaleJobs(now)
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// runScheduledJobs 执行所有已到达执行时间的任务
+func runScheduledJobs(now time.Time) {
+	results, err := orm.TomatoDBController.Find(jobScheduleCollection, types.M{}, types.M{})
+	if err != nil {
+		return
+	}
+	for _, v := range results {
+		if schedule := utils.M(v); schedule != nil {
+			runSchedule(schedule, now)
+		}
+	}
+}
+
+// runSchedule 检查任务的执行时间，到达时获取租约并执行任务
+func runSchedule(schedule types.M, now time.Time) {
+	db := orm.TomatoDBController
+	objectID := utils.S(schedule["objectId"])
+	nextRunAt := utils.S(schedule["nextRunAt"])
+
+	if nextRunAt == "" {
+		// 只执行一次的任务已经执行过
+		if utils.S(schedule["lastRunAt"]) != "" {
+			return
+		}
+		// 未通过接口创建的任务，在此处计算首次执行时间
+		next, err := firstRun(schedule, now)
+		if err != nil {
+			return
+		}
+		where := types.M{"objectId": objectID, "nextRunAt": types.M{"$exists": false}}
+		db.Update(jobScheduleCollection, where, types.M{"nextRunAt": utils.TimetoString(next)}, types.M{}, false)
+		return
+	}
+
+	t, err := utils.StringtoTime(nextRunAt)
+	if err != nil || t.After(now) {
+		return
+	}
+
+	update := types.M{"lastRunAt": utils.TimetoString(now)}
+	if following := followingRun(schedule, t, now); following.IsZero() {
+		update["nextRunAt"] = types.M{"__op": "Delete"}
+	} else {
+		update["nextRunAt"] = utils.TimetoString(following)
+	}
+	where := types.M{"objectId": objectID, "nextRunAt": nextRunAt}
+	_, err = db.Update(jobScheduleCollection, where, update, types.M{}, false)
+	if err != nil {
+		// 其他实例已经获取了该次执行
+		return
+	}
+
+	runScheduledJob(schedule)
+}
+
+// runScheduledJob 在后台执行任务，执行记录保存在 _JobStatus 中
+func runScheduledJob(schedule types.M) {
+	jobName := utils.S(schedule["jobName"])
+	var params types.M = utils.M(schedule["params"])
+	if params == nil {
+		params = types.M{}
+	}
+
+	_, err := Run(jobName, params, params, nil, "schedule")
+	if err != nil {
+		// 任务已不存在时，同样记录一次失败的执行
+		jobHandler := NewjobStatus()
+		jobHandler.setRunning(jobName, params, "schedule")
+		jobHandler.SetFailed(errs.GetErrorMessage(err))
+	}
+}
+
+// NextRunAt 校验任务调度信息，返回首次执行时间
+// cron 与 repeatMinutes 都未设置时，任务仅在 startAfter 之后执行一次
+func NextRunAt(schedule types.M) (string, error) {
+	jobName := utils.S(schedule["jobName"])
+	if jobName == "" {
+		return "", errs.E(errs.InvalidJSON, "jobName is required")
+	}
+	if cloud.GetJob(jobName) == nil {
+		return "", errs.E(errs.ScriptFailed, "Cannot Schedule a job that is not deployed")
+	}
+	if schedule["params"] != nil && utils.M(schedule["params"]) == nil {
+		return "", errs.E(errs.InvalidJSON, "params should be an object")
+	}
+	if utils.S(schedule["cron"]) != "" && repeatMinutes(schedule) > 0 {
+		return "", errs.E(errs.InvalidJSON, "cron and repeatMinutes cannot be set at the same time")
+	}
+	if schedule["repeatMinutes"] != nil && repeatMinutes(schedule) < 1 {
+		return "", errs.E(errs.InvalidJSON, "repeatMinutes should be a number greater than or equal to 1")
+	}
+	if schedule["repeatMinutes"] != nil && repeatInterval(schedule) <= 0 {
+		return "", errs.E(errs.InvalidJSON, "repeatMinutes is too large")
+	}
+
+	next, err := firstRun(schedule, time.Now().UTC())
+	if err != nil {
+		return "", errs.E(errs.InvalidJSON, err.Error())
+	}
+	return utils.TimetoString(next), nil
+}
+
+// firstRun 返回任务的首次执行时间
+func firstRun(schedule types.M, now time.Time) (time.Time, error) {
+	start := now
+	if s := utils.S(schedule["startAfter"]); s != "" {
+		t, err := parseScheduleTime(s)
+		if err != nil {
+			return time.Time{}, errors.New("startAfter should be an ISO 8601 time")
+		}
+		if t.After(now) {
+			start = t
+		}
+	}
+
+	expr := utils.S(schedule["cron"])
+	if expr == "" {
+		return start, nil
+	}
+	cron, err := parseCron(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	location, err := scheduleLocation(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := cron.next(start.In(location).Add(-time.Nanosecond))
+	if next.IsZero() {
+		return time.Time{}, errors.New("cron expression never matches: " + expr)
+	}
+	return next.UTC(), nil
+}
+
+// followingRun 返回本次执行之后，晚于 now 的下一次执行时间
+// 调度器停止期间错过的执行不会补充执行，没有下一次执行时返回零值
+func followingRun(schedule types.M, prev, now time.Time) time.Time {
+	if expr := utils.S(schedule["cron"]); expr != "" {
+		cron, err := parseCron(expr)
+		if err != nil {
+			return time.Time{}
+		}
+		location, err := scheduleLocation(schedule)
+		if err != nil {
+			return time.Time{}
+		}
+		next := cron.next(now.In(location))
+		if next.IsZero() {
+			return next
+		}
+		return next.UTC()
+	}
+
+	if interval := repeatInterval(schedule); interval > 0 {
+		n := now.Sub(prev)/interval + 1
+		return prev.Add(n * interval)
+	}
+	return time.Time{}
+}
+
+// maxRepeatMinutes repeatMinutes 的最大值，超过时转换为 time.Duration 会溢出
+const maxRepeatMinutes = float64(math.MaxInt64 / int64(time.Minute))
+
+// repeatInterval 返回 repeatMinutes 对应的执行间隔，未设置、小于 1 分钟或者超出范围时返回 0
+func repeatInterval(schedule types.M) time.Duration {
+	minutes := repeatMinutes(schedule)
+	if minutes < 1 || minutes > maxRepeatMinutes {
+		return 0
+	}
+	return time.Duration(minutes * float64(time.Minute))
+}
+
+func repeatMinutes(schedule types.M) float64 {
+	switch v := schedule["repeatMinutes"].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return 0
+}
+
+// scheduleLocation 返回计算 cron 表达式使用的时区，默认为 UTC
+func scheduleLocation(schedule types.M) (*time.Location, error) {
+	timeZone := utils.S(schedule["timeZone"])
+	if timeZone == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, errors.New("invalid timeZone: " + timeZone)
+	}
+	return location, nil
+}
+
+// parseScheduleTime 解析 ISO 8601 格式的时间
+func parseScheduleTime(s string) (time.Time, error) {
+	if t, err := utils.StringtoTime(s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
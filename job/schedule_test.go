@@ -0,0 +1,109 @@
+package job
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lfq7413/tomato/cloud"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/types"
+)
+
+func Test_firstRun(t *testing.T) {
+	var schedule types.M
+	var now, result, expect time.Time
+	var err error
+	now = time.Date(2017, 3, 13, 10, 7, 30, 0, time.UTC)
+	/************************************************************/
+	schedule = types.M{"jobName": "job"}
+	result, err = firstRun(schedule, now)
+	if err != nil || result.Equal(now) == false {
+		t.Error("expect:", now, "result:", result, err)
+	}
+	/************************************************************/
+	schedule = types.M{"jobName": "job", "repeatMinutes": 10, "startAfter": "2017-03-14T00:00:00.000Z"}
+	expect = time.Date(2017, 3, 14, 0, 0, 0, 0, time.UTC)
+	result, err = firstRun(schedule, now)
+	if err != nil || result.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	schedule = types.M{"jobName": "job", "cron": "0 3 * * *", "timeZone": "Asia/Shanghai"}
+	expect = time.Date(2017, 3, 13, 19, 0, 0, 0, time.UTC)
+	result, err = firstRun(schedule, now)
+	if err != nil || result.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	schedule = types.M{"jobName": "job", "cron": "0 3 * * *", "timeZone": "Mars/Olympus"}
+	_, err = firstRun(schedule, now)
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
+
+func Test_followingRun(t *testing.T) {
+	var schedule types.M
+	var prev, now, result, expect time.Time
+	/************************************************************/
+	schedule = types.M{"jobName": "job"}
+	prev = time.Date(2017, 3, 13, 10, 0, 0, 0, time.UTC)
+	now = time.Date(2017, 3, 13, 10, 0, 5, 0, time.UTC)
+	result = followingRun(schedule, prev, now)
+	if result.IsZero() == false {
+		t.Error("expect:", time.Time{}, "result:", result)
+	}
+	/************************************************************/
+	schedule = types.M{"jobName": "job", "repeatMinutes": 10.0}
+	now = time.Date(2017, 3, 13, 10, 35, 0, 0, time.UTC)
+	expect = time.Date(2017, 3, 13, 10, 40, 0, 0, time.UTC)
+	result = followingRun(schedule, prev, now)
+	if result.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	// 执行间隔小于 1 分钟或者超出范围时没有下一次执行
+	for _, minutes := range []float64{1e-12, 0.5, 1e300} {
+		schedule = types.M{"jobName": "job", "repeatMinutes": minutes}
+		result = followingRun(schedule, prev, now)
+		if result.IsZero() == false {
+			t.Error(minutes, "expect:", time.Time{}, "result:", result)
+		}
+	}
+	/************************************************************/
+	schedule = types.M{"jobName": "job", "cron": "0 * * * *"}
+	expect = time.Date(2017, 3, 13, 11, 0, 0, 0, time.UTC)
+	result = followingRun(schedule, prev, now)
+	if result.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func Test_NextRunAt(t *testing.T) {
+	cloud.Job("job", func(request cloud.JobRequest, response cloud.JobResponse) {})
+	defer cloud.RemoveJob("job")
+	data := []struct {
+		schedule types.M
+		expect   error
+	}{
+		{
+			schedule: types.M{"jobName": "job", "repeatMinutes": 1.0},
+			expect:   nil,
+		},
+		{
+			schedule: types.M{"jobName": "job", "repeatMinutes": 1e-12},
+			expect:   errs.E(errs.InvalidJSON, "repeatMinutes should be a number greater than or equal to 1"),
+		},
+		{
+			schedule: types.M{"jobName": "job", "repeatMinutes": 1e300},
+			expect:   errs.E(errs.InvalidJSON, "repeatMinutes is too large"),
+		},
+	}
+	for _, v := range data {
+		_, err := NextRunAt(v.schedule)
+		if reflect.DeepEqual(v.expect, err) == false {
+			t.Error(v.schedule, "expect:", v.expect, "result:", err)
+		}
+	}
+}
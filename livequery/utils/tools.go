@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"unicode"
 
 	"github.com/lfq7413/tomato/livequery/t"
 )
@@ -239,6 +240,14 @@ func matchesKeyConstraints(object t.M, key string, constraints interface{}) bool
 			if compareBox(compareTo, object[key]) == false {
 				return false
 			}
+		case "$text":
+			if compareText(compareTo, object[key]) == false {
+				return false
+			}
+		case "$geoIntersects":
+			if compareGeoIntersects(compareTo, object[key]) == false {
+				return false
+			}
 		case "$options":
 		case "$maxDistance":
 		case "$select":
@@ -331,6 +340,59 @@ func compareBox(compareTo, point interface{}) bool {
 		geoPoint["longitude"] < northEast["longitude"]
 }
 
+// compareGeoIntersects 判断多边形 polygon 是否包含 $point 指定的点
+// 多边形的坐标格式为 [latitude, longitude] ，按照平面坐标使用射线法判断，点在边上时视为包含
+func compareGeoIntersects(geoIntersects, polygon interface{}) bool {
+	c, ok := geoIntersects.(map[string]interface{})
+	if ok == false {
+		return false
+	}
+	point, ok := c["$point"].(map[string]interface{})
+	if ok == false {
+		return false
+	}
+	x, ok1 := point["latitude"].(float64)
+	y, ok2 := point["longitude"].(float64)
+	if ok1 == false || ok2 == false {
+		return false
+	}
+	p, ok := polygon.(map[string]interface{})
+	if ok == false || p["__type"] != "Polygon" {
+		return false
+	}
+	coordinates, ok := p["coordinates"].([]interface{})
+	if ok == false || len(coordinates) < 3 {
+		return false
+	}
+	vertices := [][2]float64{}
+	for _, v := range coordinates {
+		coordinate, ok := v.([]interface{})
+		if ok == false || len(coordinate) != 2 {
+			return false
+		}
+		lat, ok1 := coordinate[0].(float64)
+		lng, ok2 := coordinate[1].(float64)
+		if ok1 == false || ok2 == false {
+			return false
+		}
+		vertices = append(vertices, [2]float64{lat, lng})
+	}
+
+	inside := false
+	for i, j := 0, len(vertices)-1; i < len(vertices); j, i = i, i+1 {
+		xi, yi := vertices[i][0], vertices[i][1]
+		xj, yj := vertices[j][0], vertices[j][1]
+		// 点在边上
+		if (x-xi)*(yj-yi) == (xj-xi)*(y-yi) && math.Min(xi, xj) <= x && x <= math.Max(xi, xj) && math.Min(yi, yj) <= y && y <= math.Max(yi, yj) {
+			return true
+		}
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
 // compareGeoPoint 比较两点是否相邻
 func compareGeoPoint(p1, p2, maxDistance interface{}) bool {
 	if v1, ok := p1.(map[string]interface{}); ok {
@@ -381,6 +443,129 @@ func compareRegexp(exp, object interface{}) bool {
 	return false
 }
 
+// compareText 判断 object 是否符合全文检索条件
+// 数据库中的全文检索会进行词干提取、忽略停用词，此处仅按照单词完整匹配，是近似的结果：
+// 以空格分隔的词中有一个匹配即可，包含短语时必须包含所有短语，以 - 开头的词或短语表示排除
+// 默认不区分大小写， $diacriticSensitive 不起作用
+func compareText(text, object interface{}) bool {
+	o, ok := object.(string)
+	if ok == false {
+		return false
+	}
+	constraint, ok := text.(map[string]interface{})
+	if ok == false {
+		return false
+	}
+	search, ok := constraint["$search"].(map[string]interface{})
+	if ok == false {
+		return false
+	}
+	term, ok := search["$term"].(string)
+	if ok == false {
+		return false
+	}
+	if caseSensitive, _ := search["$caseSensitive"].(bool); caseSensitive == false {
+		o = strings.ToLower(o)
+		term = strings.ToLower(term)
+	}
+
+	words := textWords(o)
+	terms := []string{}
+	phrases := [][]string{}
+	negations := [][]string{}
+	for _, item := range splitTextTerm(term) {
+		itemWords := textWords(item)
+		if len(itemWords) == 0 {
+			continue
+		}
+		if strings.HasPrefix(item, "-") {
+			negations = append(negations, itemWords)
+		} else if strings.HasPrefix(item, `"`) {
+			phrases = append(phrases, itemWords)
+		} else {
+			terms = append(terms, itemWords...)
+		}
+	}
+
+	for _, negation := range negations {
+		if containsPhrase(words, negation) {
+			return false
+		}
+	}
+	if len(phrases) > 0 {
+		for _, phrase := range phrases {
+			if containsPhrase(words, phrase) == false {
+				return false
+			}
+		}
+		return true
+	}
+	for _, term := range terms {
+		if containsPhrase(words, []string{term}) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitTextTerm 按照空格拆分检索词，双引号中的短语作为一项，并保留双引号与 - 前缀
+func splitTextTerm(term string) []string {
+	items := []string{}
+	for {
+		term = strings.TrimLeftFunc(term, unicode.IsSpace)
+		if term == "" {
+			return items
+		}
+		start := 0
+		if strings.HasPrefix(term, "-") {
+			start = 1
+		}
+		var end int
+		if strings.HasPrefix(term[start:], `"`) {
+			end = strings.Index(term[start+1:], `"`)
+			if end < 0 {
+				end = len(term)
+			} else {
+				end = start + end + 2
+			}
+		} else {
+			end = strings.IndexFunc(term, unicode.IsSpace)
+			if end < 0 {
+				end = len(term)
+			}
+		}
+		items = append(items, term[:end])
+		term = term[end:]
+	}
+}
+
+// textWords 把字符串拆分为单词
+func textWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r) == false && unicode.IsNumber(r) == false
+	})
+}
+
+// containsPhrase 判断 words 中是否包含连续的 phrase
+func containsPhrase(words, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		matched := true
+		for j, word := range phrase {
+			if words[i+j] != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
 // inSlice 判断 slice 中是否存在指定的对象
 // s 必须为 []interface{} 类型
 // o 要查找的对象
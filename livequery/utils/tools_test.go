@@ -920,6 +920,108 @@ func Test_compareRegexp(t *testing.T) {
 	}
 }
 
+func Test_compareGeoIntersects(t *testing.T) {
+	point := func(latitude, longitude float64) interface{} {
+		return map[string]interface{}{
+			"$point": map[string]interface{}{
+				"__type":    "GeoPoint",
+				"latitude":  latitude,
+				"longitude": longitude,
+			},
+		}
+	}
+	polygon := map[string]interface{}{
+		"__type": "Polygon",
+		"coordinates": []interface{}{
+			[]interface{}{0.0, 0.0},
+			[]interface{}{0.0, 10.0},
+			[]interface{}{10.0, 10.0},
+			[]interface{}{10.0, 0.0},
+			[]interface{}{0.0, 0.0},
+		},
+	}
+	data := []struct {
+		geoIntersects interface{}
+		polygon       interface{}
+		expect        bool
+	}{
+		{geoIntersects: point(5, 5), polygon: polygon, expect: true},
+		{geoIntersects: point(0, 5), polygon: polygon, expect: true},
+		{geoIntersects: point(10, 10), polygon: polygon, expect: true},
+		{geoIntersects: point(11, 5), polygon: polygon, expect: false},
+		{geoIntersects: point(5, -1), polygon: polygon, expect: false},
+		{geoIntersects: "hello", polygon: polygon, expect: false},
+		{geoIntersects: point(5, 5), polygon: map[string]interface{}{"__type": "GeoPoint", "latitude": 5.0, "longitude": 5.0}, expect: false},
+		{
+			geoIntersects: point(1, 8),
+			polygon: map[string]interface{}{
+				"__type": "Polygon",
+				"coordinates": []interface{}{
+					[]interface{}{0.0, 0.0},
+					[]interface{}{0.0, 10.0},
+					[]interface{}{10.0, 0.0},
+				},
+			},
+			expect: true,
+		},
+		{
+			geoIntersects: point(8, 8),
+			polygon: map[string]interface{}{
+				"__type": "Polygon",
+				"coordinates": []interface{}{
+					[]interface{}{0.0, 0.0},
+					[]interface{}{0.0, 10.0},
+					[]interface{}{10.0, 0.0},
+				},
+			},
+			expect: false,
+		},
+	}
+	for i, v := range data {
+		result := compareGeoIntersects(v.geoIntersects, v.polygon)
+		if result != v.expect {
+			t.Error(i, "expect:", v.expect, "result:", result)
+		}
+	}
+}
+
+func Test_compareText(t *testing.T) {
+	search := func(term string, caseSensitive bool) interface{} {
+		return map[string]interface{}{
+			"$search": map[string]interface{}{
+				"$term":          term,
+				"$caseSensitive": caseSensitive,
+			},
+		}
+	}
+	data := []struct {
+		text   interface{}
+		object interface{}
+		expect bool
+	}{
+		{text: search("coffee", false), object: 1024, expect: false},
+		{text: "coffee", object: "coffee", expect: false},
+		{text: search("coffee", false), object: "I like Coffee.", expect: true},
+		{text: search("coffee", true), object: "I like Coffee.", expect: false},
+		{text: search("coff", false), object: "I like coffee", expect: false},
+		{text: search("tea coffee", false), object: "I like coffee", expect: true},
+		{text: search(`"like coffee"`, false), object: "I like coffee", expect: true},
+		{text: search(`"coffee like"`, false), object: "I like coffee", expect: false},
+		{text: search(`tea "like coffee" "i like"`, false), object: "I like coffee", expect: true},
+		{text: search(`tea "like coffee" "like tea"`, false), object: "I like coffee", expect: false},
+		{text: search("coffee -like", false), object: "I like coffee", expect: false},
+		{text: search(`coffee -"like tea"`, false), object: "I like coffee", expect: true},
+		{text: search("-tea", false), object: "I like coffee", expect: false},
+	}
+
+	for _, d := range data {
+		result := compareText(d.text, d.object)
+		if reflect.DeepEqual(d.expect, result) == false {
+			t.Error("text:", d.text, "object:", d.object, "expect:", d.expect, "result:", result)
+		}
+	}
+}
+
 func Test_inSlice(t *testing.T) {
 	data := []struct {
 		s      interface{}
@@ -50,6 +50,6 @@ func generateFmtStr(n int) string {
 	return strings.Repeat("%v ", n)
 }
 
-func (l *beegoLogger) query(options types.M) (types.M, error) {
+func (l *beegoLogger) query(options types.M) (types.S, error) {
 	return nil, errs.E(errs.PushMisconfigured, "Querying logs is not supported with this adapter")
 }
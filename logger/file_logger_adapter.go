@@ -0,0 +1,285 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+const (
+	logFilePrefix = "tomato-"
+	logFileSuffix = ".log"
+	logDateLayout = "2006-01-02"
+)
+
+// fileLogger 把日志以 JSON 格式逐行写入本地文件
+// 日志文件按日期切分，文件名格式为 tomato-2006-01-02.log ，
+// 超过 maxSize 时切分为 tomato-2006-01-02-1.log 、 tomato-2006-01-02-2.log 等
+type fileLogger struct {
+	mu       sync.Mutex
+	folder   string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	date     string
+	seq      int
+	size     int64
+}
+
+// logFile 日志文件信息
+type logFile struct {
+	name string
+	date string
+	seq  int
+}
+
+func newFileLogger(folder string, maxSize int64, maxFiles int) *fileLogger {
+	return &fileLogger{
+		folder:   folder,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+}
+
+func (l *fileLogger) log(level string, args ...interface{}) {
+	now := time.Now().UTC()
+	entry := types.M{
+		"level":     level,
+		"message":   strings.TrimSuffix(fmt.Sprintln(args...), "\n"),
+		"timestamp": utils.TimetoString(now),
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	date := now.Format(logDateLayout)
+	if l.file == nil || l.date != date || (l.maxSize > 0 && l.size >= l.maxSize) {
+		if err := l.rotate(date); err != nil {
+			return
+		}
+	}
+	n, _ := l.file.Write(line)
+	l.size += int64(n)
+}
+
+// rotate 切换到新的日志文件
+func (l *fileLogger) rotate(date string) error {
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	err := os.MkdirAll(l.folder, 0755)
+	if err != nil {
+		return err
+	}
+
+	if l.date != date {
+		// 重启之后继续写入当天最后一个日志文件
+		l.date = date
+		l.seq = 0
+		for _, f := range l.logFiles() {
+			if f.date == date && f.seq > l.seq {
+				l.seq = f.seq
+			}
+		}
+	} else {
+		l.seq++
+	}
+
+	for {
+		file, err := os.OpenFile(filepath.Join(l.folder, logFileName(l.date, l.seq)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if l.maxSize > 0 && info.Size() >= l.maxSize {
+			file.Close()
+			l.seq++
+			continue
+		}
+		l.file = file
+		l.size = info.Size()
+		break
+	}
+
+	l.removeOldFiles()
+	return nil
+}
+
+// removeOldFiles 删除超出 maxFiles 个数的旧日志文件
+func (l *fileLogger) removeOldFiles() {
+	if l.maxFiles <= 0 {
+		return
+	}
+	files := l.logFiles()
+	for i := 0; i < len(files)-l.maxFiles; i++ {
+		os.Remove(filepath.Join(l.folder, files[i].name))
+	}
+}
+
+// logFiles 返回日志文件夹中的所有日志文件，按写入的先后顺序排列
+func (l *fileLogger) logFiles() []logFile {
+	infos, err := ioutil.ReadDir(l.folder)
+	if err != nil {
+		return []logFile{}
+	}
+	files := []logFile{}
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if f, ok := parseLogFileName(info.Name()); ok {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].date != files[j].date {
+			return files[i].date < files[j].date
+		}
+		return files[i].seq < files[j].seq
+	})
+	return files
+}
+
+// query 查询日志，options 中的参数如下
+// from 与 until 为日志的时间范围， level 为日志级别， size 为返回的日志条数，
+// order 为排序方式，可选： asc 、 desc
+func (l *fileLogger) query(options types.M) (types.S, error) {
+	from, _ := options["from"].(time.Time)
+	until, _ := options["until"].(time.Time)
+	level := utils.S(options["level"])
+	order := utils.S(options["order"])
+	size, _ := options["size"].(int)
+
+	l.mu.Lock()
+	files := l.logFiles()
+	l.mu.Unlock()
+
+	type logEntry struct {
+		entry types.M
+		time  time.Time
+	}
+	entries := []logEntry{}
+	fromDate := from.UTC().Format(logDateLayout)
+	untilDate := until.UTC().Format(logDateLayout)
+	for _, f := range files {
+		if f.date < fromDate || f.date > untilDate {
+			continue
+		}
+		err := readLogFile(filepath.Join(l.folder, f.name), func(entry types.M) {
+			if level != "" && utils.S(entry["level"]) != level {
+				return
+			}
+			t, err := utils.StringtoTime(utils.S(entry["timestamp"]))
+			if err != nil || t.Before(from) || t.After(until) {
+				return
+			}
+			entries = append(entries, logEntry{entry: entry, time: t})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 同一时间的日志保持写入顺序
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].time.Before(entries[j].time)
+	})
+	if order != "asc" {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+
+	results := types.S{}
+	for _, e := range entries {
+		if size > 0 && len(results) >= size {
+			break
+		}
+		results = append(results, e.entry)
+	}
+	return results, nil
+}
+
+// readLogFile 逐行读取日志文件，无法解析的行将被忽略
+func readLogFile(name string, handle func(entry types.M)) error {
+	file, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var entry types.M
+			if json.Unmarshal(line, &entry) == nil && entry != nil {
+				handle(entry)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func logFileName(date string, seq int) string {
+	if seq == 0 {
+		return logFilePrefix + date + logFileSuffix
+	}
+	return logFilePrefix + date + "-" + strconv.Itoa(seq) + logFileSuffix
+}
+
+func parseLogFileName(name string) (logFile, bool) {
+	if strings.HasPrefix(name, logFilePrefix) == false || strings.HasSuffix(name, logFileSuffix) == false {
+		return logFile{}, false
+	}
+	s := name[len(logFilePrefix) : len(name)-len(logFileSuffix)]
+	if len(s) < len(logDateLayout) {
+		return logFile{}, false
+	}
+	date := s[:len(logDateLayout)]
+	if _, err := time.Parse(logDateLayout, date); err != nil {
+		return logFile{}, false
+	}
+	f := logFile{name: name, date: date}
+	if s = s[len(logDateLayout):]; s != "" {
+		if strings.HasPrefix(s, "-") == false {
+			return logFile{}, false
+		}
+		seq, err := strconv.Atoi(s[1:])
+		if err != nil || seq <= 0 {
+			return logFile{}, false
+		}
+		f.seq = seq
+	}
+	return f, true
+}
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+func Test_fileLogger(t *testing.T) {
+	folder, err := ioutil.TempDir("", "tomato-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	var l *fileLogger
+	var options types.M
+	var results types.S
+	var messages []string
+	var expect []string
+	/************************************************************/
+	l = newFileLogger(folder, 0, 0)
+	l.log("info", "hello", 1)
+	l.log("error", "world")
+	l.log("info", "tomato")
+	options = types.M{
+		"from":  time.Now().UTC().Add(-time.Minute),
+		"until": time.Now().UTC().Add(time.Minute),
+		"size":  10,
+		"order": "desc",
+		"level": "info",
+	}
+	results, err = l.query(options)
+	messages = []string{}
+	for _, v := range results {
+		messages = append(messages, utils.S(utils.M(v)["message"]))
+	}
+	expect = []string{"tomato", "hello 1"}
+	if err != nil || reflect.DeepEqual(expect, messages) != true {
+		t.Error("expect:", expect, "result:", messages, err)
+	}
+	/************************************************************/
+	options["order"] = "asc"
+	options["size"] = 1
+	results, err = l.query(options)
+	messages = []string{}
+	for _, v := range results {
+		messages = append(messages, utils.S(utils.M(v)["message"]))
+	}
+	expect = []string{"hello 1"}
+	if err != nil || reflect.DeepEqual(expect, messages) != true {
+		t.Error("expect:", expect, "result:", messages, err)
+	}
+	/************************************************************/
+	options["level"] = "error"
+	options["until"] = time.Now().UTC().Add(-30 * time.Second)
+	results, err = l.query(options)
+	if err != nil || len(results) != 0 {
+		t.Error("expect:", 0, "result:", len(results), err)
+	}
+	/************************************************************/
+	l = newFileLogger(folder, 10, 2)
+	l.log("info", "a")
+	l.log("info", "b")
+	l.log("info", "c")
+	files := l.logFiles()
+	date := time.Now().UTC().Format(logDateLayout)
+	expect = []string{logFileName(date, 2), logFileName(date, 3)}
+	names := []string{}
+	for _, f := range files {
+		names = append(names, f.name)
+	}
+	if reflect.DeepEqual(expect, names) != true {
+		t.Error("expect:", expect, "result:", names)
+	}
+	if _, err := os.Stat(filepath.Join(folder, logFileName(date, 0))); os.IsNotExist(err) == false {
+		t.Error("expect:", "removed", "result:", err)
+	}
+}
+
+func Test_parseLogFileName(t *testing.T) {
+	var name string
+	var f logFile
+	var ok bool
+	/************************************************************/
+	name = "tomato-2017-03-13.log"
+	f, ok = parseLogFileName(name)
+	if ok == false || f.date != "2017-03-13" || f.seq != 0 {
+		t.Error("expect:", name, "result:", f, ok)
+	}
+	/************************************************************/
+	name = "tomato-2017-03-13-12.log"
+	f, ok = parseLogFileName(name)
+	if ok == false || f.date != "2017-03-13" || f.seq != 12 {
+		t.Error("expect:", name, "result:", f, ok)
+	}
+	/************************************************************/
+	name = "project.log"
+	_, ok = parseLogFileName(name)
+	if ok {
+		t.Error("expect:", false, "result:", ok)
+	}
+}
@@ -1,6 +1,14 @@
 package logger
 
-import "github.com/lfq7413/tomato/types"
+import (
+	"strconv"
+	"time"
+
+	"github.com/lfq7413/tomato/config"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
 
 const logStringTruncateLength = 1000
 const truncationMarker = "... (truncated)"
@@ -8,7 +16,12 @@ const truncationMarker = "... (truncated)"
 var adapter loggerAdapter
 
 func init() {
-	adapter = newBeegoLogger()
+	a := config.TConfig.LoggerAdapter
+	if a == "File" {
+		adapter = newFileLogger(config.TConfig.LogsFolder, int64(config.TConfig.LogMaxSize)*1024*1024, config.TConfig.LogMaxFiles)
+	} else {
+		adapter = newBeegoLogger()
+	}
 }
 
 // Log ...
@@ -54,17 +67,73 @@ func TruncateLogMessage(msg string) string {
 	return msg
 }
 
-func parseOptions(options map[string]string) types.M {
-	// TODO
-	return types.M{}
+// parseOptions 解析日志查询参数
+// from 默认为 7 天前， until 默认为当前时间， size 默认为 10 ， order 默认为 desc ， level 默认为 info
+func parseOptions(options map[string]string) (types.M, error) {
+	until := time.Now().UTC()
+	if options["until"] != "" {
+		t, err := parseLogTime(options["until"])
+		if err != nil {
+			return nil, errs.E(errs.InvalidQuery, "Invalid date for until: "+options["until"])
+		}
+		until = t
+	}
+	from := time.Now().UTC().Add(-7 * 24 * time.Hour)
+	if options["from"] != "" {
+		t, err := parseLogTime(options["from"])
+		if err != nil {
+			return nil, errs.E(errs.InvalidQuery, "Invalid date for from: "+options["from"])
+		}
+		from = t
+	}
+
+	size := 10
+	if n, err := strconv.Atoi(options["size"]); err == nil && n > 0 {
+		size = n
+	}
+	order := "desc"
+	if options["order"] == "asc" {
+		order = "asc"
+	}
+	level := "info"
+	if options["level"] != "" {
+		level = options["level"]
+	}
+
+	return types.M{
+		"from":  from,
+		"until": until,
+		"size":  size,
+		"order": order,
+		"level": level,
+	}, nil
 }
 
-// GetLogs ...
-func GetLogs(options map[string]string) (types.M, error) {
-	return adapter.query(parseOptions(options))
+// parseLogTime 解析查询参数中的时间，支持 ISO 8601 格式与以毫秒为单位的 Unix 时间
+func parseLogTime(s string) (time.Time, error) {
+	if t, err := utils.StringtoTime(s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.UTC(), nil
+	}
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
+}
+
+// GetLogs 查询日志，返回的日志按时间排序
+func GetLogs(options map[string]string) (types.S, error) {
+	queryOptions, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.query(queryOptions)
 }
 
 type loggerAdapter interface {
 	log(level string, args ...interface{})
-	query(options types.M) (types.M, error)
+	query(options types.M) (types.S, error)
 }
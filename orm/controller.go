@@ -41,11 +41,75 @@ func init() {
 
 // DBController 数据库操作类
 type DBController struct {
+	// transaction 不为空时，表示在事务中执行，所有数据库操作都使用该适配器
+	transaction storage.Adapter
+	// afterCommit 事务提交成功之后需要执行的操作，例如删后回调、 LiveQuery 通知等
+	afterCommit []func()
+}
+
+// adapter 返回当前使用的数据库适配器
+func (d *DBController) adapter() storage.Adapter {
+	if d.transaction != nil {
+		return d.transaction
+	}
+	return Adapter
+}
+
+// StartTransaction 开启事务，返回在事务中执行操作的 DBController
+func (d *DBController) StartTransaction() (*DBController, error) {
+	if d.transaction != nil {
+		return nil, errs.E(errs.InternalServerError, "Transaction has already been started.")
+	}
+	transaction, err := Adapter.StartTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return &DBController{transaction: transaction}, nil
+}
+
+// CommitTransaction 提交事务，提交成功之后按顺序执行 RunAfterCommit 中加入的操作
+func (d *DBController) CommitTransaction() error {
+	if d.transaction == nil {
+		return errs.E(errs.InternalServerError, "Transaction has not been started.")
+	}
+	err := d.transaction.CommitTransaction()
+	if err != nil {
+		return err
+	}
+	afterCommit := d.afterCommit
+	d.afterCommit = nil
+	for _, f := range afterCommit {
+		f()
+	}
+	return nil
+}
+
+// RunAfterCommit 执行写操作之后的通知类操作
+// 在事务中时加入队列，事务提交成功之后才执行，回滚时丢弃，避免通知已经回滚的数据；不在事务中时立即执行
+func (d *DBController) RunAfterCommit(f func()) {
+	if d.transaction == nil {
+		f()
+		return
+	}
+	d.afterCommit = append(d.afterCommit, f)
+}
+
+// AbortTransaction 回滚事务
+// 事务中可能修改了 schema ，回滚之后需要清除 schema 缓存
+func (d *DBController) AbortTransaction() error {
+	if d.transaction == nil {
+		return errs.E(errs.InternalServerError, "Transaction has not been started.")
+	}
+	d.afterCommit = nil
+	err := d.transaction.AbortTransaction()
+	schemaCache.Clear()
+	schemaPromise = nil
+	return err
 }
 
 // CollectionExists 检测表是否存在
 func (d *DBController) CollectionExists(className string) bool {
-	return Adapter.ClassExists(className)
+	return d.adapter().ClassExists(className)
 }
 
 // PurgeCollection 清除类
@@ -55,7 +119,7 @@ func (d *DBController) PurgeCollection(className string) error {
 	if err != nil {
 		return err
 	}
-	return Adapter.DeleteObjectsByQuery(className, sch, types.M{})
+	return d.adapter().DeleteObjectsByQuery(className, sch, types.M{})
 }
 
 // Find 从指定表中查询数据，查询到的数据放入 list 中
@@ -65,6 +129,125 @@ func (d *DBController) Find(className string, query, options types.M) (types.S,
 	if options == nil {
 		options = types.M{}
 	}
+	f, err := d.prepareFind(className, query, options)
+	if err != nil {
+		return nil, err
+	}
+	if f.query == nil {
+		// 如果需要计算 count ，则默认返回  0
+		if options["count"] != nil {
+			return types.S{0}, nil
+		}
+		return types.S{}, nil
+	}
+
+	// 获取 count
+	if options["count"] != nil {
+		if f.classExists == false {
+			return types.S{0}, nil
+		}
+		count, err := d.adapter().Count(className, f.schema, f.query)
+		if err != nil {
+			return nil, err
+		}
+		return types.S{count}, nil
+	}
+
+	// 获取字段的不重复值
+	if distinct, ok := options["distinct"].(string); ok {
+		if f.classExists == false {
+			return types.S{}, nil
+		}
+		return d.adapter().Distinct(className, f.schema, f.query, distinct)
+	}
+
+	if f.classExists == false {
+		return types.S{}, nil
+	}
+
+	// 执行查询操作
+	objects, err := d.adapter().Find(className, f.schema, f.query, options)
+	if err != nil {
+		return nil, err
+	}
+	results := types.S{}
+	for _, object := range objects {
+		object = untransformObjectACL(object)
+		result := filterSensitiveData(f.isMaster, f.aclGroup, className, object)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// FindIterator 查询对象，以迭代器的形式返回结果，用于读取大量数据，不支持 count 与 distinct 选项
+// 返回的对象已经处理过 ACL 与敏感字段，与 Find 的结果相同，使用结束后需要调用 Close
+func (d *DBController) FindIterator(className string, query, options types.M) (*ObjectIterator, error) {
+	if options == nil {
+		options = types.M{}
+	}
+	if options["count"] != nil || options["distinct"] != nil {
+		return nil, errs.E(errs.InvalidQuery, "Cannot use count or distinct with iterator.")
+	}
+	f, err := d.prepareFind(className, query, options)
+	if err != nil {
+		return nil, err
+	}
+	if f.query == nil || f.classExists == false {
+		return &ObjectIterator{}, nil
+	}
+	iter, err := d.adapter().FindIterator(className, f.schema, f.query, options)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectIterator{
+		iter:      iter,
+		className: className,
+		isMaster:  f.isMaster,
+		aclGroup:  f.aclGroup,
+	}, nil
+}
+
+// ObjectIterator 查询结果的迭代器
+type ObjectIterator struct {
+	iter      storage.Iterator
+	className string
+	isMaster  bool
+	aclGroup  []string
+}
+
+// Next 读取下一个对象，没有更多数据时返回 nil, nil
+func (i *ObjectIterator) Next() (types.M, error) {
+	if i.iter == nil {
+		return nil, nil
+	}
+	object, err := i.iter.Next()
+	if err != nil || object == nil {
+		return nil, err
+	}
+	object = untransformObjectACL(object)
+	return filterSensitiveData(i.isMaster, i.aclGroup, i.className, object), nil
+}
+
+// Close 释放数据库游标
+func (i *ObjectIterator) Close() error {
+	if i.iter == nil {
+		return nil
+	}
+	return i.iter.Close()
+}
+
+// findContext 查询前的准备结果
+type findContext struct {
+	// query 处理权限后的查询条件，为 nil 时表示没有符合条件的对象
+	query       types.M
+	schema      types.M
+	classExists bool
+	isMaster    bool
+	aclGroup    []string
+}
+
+// prepareFind 校验查询权限与查询选项，组装最终的查询条件，供 Find 与 FindIterator 使用
+func (d *DBController) prepareFind(className string, query, options types.M) (*findContext, error) {
 	if query == nil {
 		query = types.M{}
 	}
@@ -124,6 +307,15 @@ func (d *DBController) Find(className string, query, options types.M) (types.S,
 				key = "updatedAt"
 			}
 
+			// $score 为全文检索的相关度，仅在包含 $text 的查询中使用
+			if key == "$score" {
+				if hasTextConstraint(query) == false {
+					return nil, errs.E(errs.InvalidQuery, "Cannot sort by $score without a $text constraint")
+				}
+				keys[i] = prefix + key
+				continue
+			}
+
 			if match, _ := regexp.MatchString(`^authData\.([a-zA-Z0-9_]+)\.id$`, key); match {
 				return nil, errs.E(errs.InvalidKeyName, "Cannot sort by "+key)
 			}
@@ -137,6 +329,21 @@ func (d *DBController) Find(className string, query, options types.M) (types.S,
 		options["sort"] = keys
 	}
 
+	if keys, ok := options["keys"].([]string); ok {
+		for _, key := range keys {
+			if key == "$score" && hasTextConstraint(query) == false {
+				return nil, errs.E(errs.InvalidQuery, "Cannot select $score without a $text constraint")
+			}
+		}
+	}
+
+	if distinct, ok := options["distinct"]; ok {
+		err := validateDistinctField(className, distinct, isMaster)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// 校验当前用户是否能对表进行 find 或者 get 操作
 	if isMaster == false {
 		err := schema.validatePermission(className, aclGroup, op)
@@ -157,11 +364,7 @@ func (d *DBController) Find(className string, query, options types.M) (types.S,
 		if op == "get" {
 			return nil, errs.E(errs.ObjectNotFound, "Object not found.")
 		}
-		// 如果需要计算 count ，则默认返回  0
-		if options["count"] != nil {
-			return types.S{0}, nil
-		}
-		return types.S{}, nil
+		return &findContext{}, nil
 	}
 
 	// 组装 acl 查询条件，查找可被当前用户访问的对象
@@ -174,32 +377,43 @@ func (d *DBController) Find(className string, query, options types.M) (types.S,
 		return nil, err
 	}
 
-	// 获取 count
-	if options["count"] != nil {
-		if classExists == false {
-			return types.S{0}, nil
-		}
-		count, err := Adapter.Count(className, parseFormatSchema, query)
-		if err != nil {
-			return nil, err
-		}
-		return types.S{count}, nil
+	return &findContext{
+		query:       query,
+		schema:      parseFormatSchema,
+		classExists: classExists,
+		isMaster:    isMaster,
+		aclGroup:    aclGroup,
+	}, nil
+}
+
+// Aggregate 对指定表执行聚合查询，仅供 Master 使用，不校验 ACL 与 CLP
+// pipeline 中支持的阶段包括：$match、$group、$project、$sort、$limit、$skip、$unwind
+func (d *DBController) Aggregate(className string, pipeline types.S) (types.S, error) {
+	pipeline, err := transformPipeline(pipeline)
+	if err != nil {
+		return nil, err
 	}
 
-	if classExists == false {
+	schema := d.LoadSchema(nil)
+	parseFormatSchema, err := schema.GetOneSchema(className, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(parseFormatSchema) == 0 {
 		return types.S{}, nil
 	}
 
-	// 执行查询操作
-	objects, err := Adapter.Find(className, parseFormatSchema, query, options)
+	objects, err := d.adapter().Aggregate(className, parseFormatSchema, pipeline)
 	if err != nil {
 		return nil, err
 	}
 	results := types.S{}
 	for _, object := range objects {
 		object = untransformObjectACL(object)
-		result := filterSensitiveData(isMaster, aclGroup, className, object)
-		results = append(results, result)
+		if className == "_User" {
+			delete(object, "_hashed_password")
+		}
+		results = append(results, object)
 	}
 	return results, nil
 }
@@ -254,7 +468,7 @@ func (d *DBController) Destroy(className string, query types.M, options types.M)
 		parseFormatSchema["fields"] = types.M{}
 	}
 
-	err = Adapter.DeleteObjectsByQuery(className, parseFormatSchema, query)
+	err = d.adapter().DeleteObjectsByQuery(className, parseFormatSchema, query)
 	if err != nil {
 		// 排除 _Session，避免在修改密码时因为没有 Session 失败
 		if className == "_Session" && errs.GetErrorCode(err) == errs.ObjectNotFound {
@@ -374,20 +588,20 @@ func (d *DBController) Update(className string, query, update, options types.M,
 	transformAuthData(className, update, sch)
 	var result types.M
 	if many {
-		err := Adapter.UpdateObjectsByQuery(className, sch, query, update)
+		err := d.adapter().UpdateObjectsByQuery(className, sch, query, update)
 		if err != nil {
 			return nil, err
 		}
 		result = types.M{}
 	} else if upsert {
-		err := Adapter.UpsertOneObject(className, sch, query, update)
+		err := d.adapter().UpsertOneObject(className, sch, query, update)
 		if err != nil {
 			return nil, err
 		}
 		result = types.M{}
 	} else {
 		var err error
-		result, err = Adapter.FindOneAndUpdate(className, sch, query, update)
+		result, err = d.adapter().FindOneAndUpdate(className, sch, query, update)
 		if err != nil {
 			return nil, err
 		}
@@ -504,7 +718,7 @@ func (d *DBController) Create(className string, object, options types.M) error {
 	flattenUpdateOperatorsForCreate(object)
 
 	// 无需调用 sanitizeDatabaseResult
-	err = Adapter.CreateObject(className, convertSchemaToAdapterSchema(sch), object)
+	err = d.adapter().CreateObject(className, convertSchemaToAdapterSchema(sch), object)
 	if err != nil {
 		return err
 	}
@@ -640,7 +854,7 @@ func (d *DBController) addRelation(key, fromClassName, fromID, toID string) erro
 		"owningId":  fromID,
 	}
 	className := "_Join:" + key + ":" + fromClassName
-	return Adapter.UpsertOneObject(className, relationSchema, doc, doc)
+	return d.adapter().UpsertOneObject(className, relationSchema, doc, doc)
 }
 
 // removeRelation 把对象 id 从 _Join 表中删除，表名为 _Join:key:fromClassName
@@ -650,7 +864,7 @@ func (d *DBController) removeRelation(key, fromClassName, fromID, toID string) e
 		"owningId":  fromID,
 	}
 	className := "_Join:" + key + ":" + fromClassName
-	err := Adapter.DeleteObjectsByQuery(className, relationSchema, doc)
+	err := d.adapter().DeleteObjectsByQuery(className, relationSchema, doc)
 	if err != nil {
 		if errs.GetErrorCode(err) == errs.ObjectNotFound {
 			return nil
@@ -660,6 +874,28 @@ func (d *DBController) removeRelation(key, fromClassName, fromID, toID string) e
 	return nil
 }
 
+// FindJoinRecords 查询 Relation 字段 key 的 _Join 表记录，按 owningId 、 relatedId 排序，用于导出数据
+// _Join 表中没有 objectId ，以 owningId 、 relatedId 作为分页条件，返回 after 之后的记录， after 为 nil 时从头开始
+func (d *DBController) FindJoinRecords(className, key string, after types.M, limit int) ([]types.M, error) {
+	query := types.M{}
+	if after != nil {
+		query["$or"] = types.S{
+			types.M{"owningId": types.M{"$gt": after["owningId"]}},
+			types.M{"owningId": after["owningId"], "relatedId": types.M{"$gt": after["relatedId"]}},
+		}
+	}
+	options := types.M{
+		"sort":  []string{"owningId", "relatedId"},
+		"limit": limit,
+	}
+	return d.adapter().Find(joinTableName(className, key), relationSchema, query, options)
+}
+
+// AddJoinRecord 向 Relation 字段 key 的 _Join 表添加记录，用于导入数据
+func (d *DBController) AddJoinRecord(className, key, owningID, relatedID string) error {
+	return d.addRelation(key, className, owningID, relatedID)
+}
+
 // ValidateObject 校验对象是否合法
 func (d *DBController) ValidateObject(className string, object, query, options types.M) error {
 	schema := d.LoadSchema(nil)
@@ -697,6 +933,10 @@ func (d *DBController) LoadSchema(options types.M) *Schema {
 	if options == nil {
 		options = types.M{"clearCache": false}
 	}
+	if d.transaction != nil {
+		// 事务中的 schema 修改需要在事务中执行，不使用全局的 schemaPromise
+		return Load(d.transaction, schemaCache, options)
+	}
 	if c, ok := options["clearCache"].(bool); ok && c {
 		schemaPromise = Load(Adapter, schemaCache, options)
 		return schemaPromise
@@ -711,7 +951,7 @@ func (d *DBController) LoadSchema(options types.M) *Schema {
 func (d *DBController) DeleteEverything() {
 	schemaCache.Clear()
 	schemaPromise = nil
-	Adapter.DeleteAllClasses()
+	d.adapter().DeleteAllClasses()
 }
 
 // RedirectClassNameForKey 返回指定类的字段所对应的类型
@@ -823,7 +1063,7 @@ func (d *DBController) reduceRelationKeys(className string, query types.M) types
 // relatedIds 从 Join 表中查询 ids ，表名：_Join:key:className
 func (d *DBController) relatedIds(className, key, owningID string) types.S {
 	ids := types.S{}
-	results, err := Adapter.Find(joinTableName(className, key), relationSchema, types.M{"owningId": owningID}, types.M{})
+	results, err := d.adapter().Find(joinTableName(className, key), relationSchema, types.M{"owningId": owningID}, types.M{})
 	if err != nil {
 		return ids
 	}
@@ -1117,7 +1357,7 @@ func (d *DBController) owningIds(className, key string, relatedIds types.S) type
 			"$in": relatedIds,
 		},
 	}
-	results, err := Adapter.Find(joinTableName(className, key), relationSchema, query, types.M{})
+	results, err := d.adapter().Find(joinTableName(className, key), relationSchema, query, types.M{})
 	if err != nil {
 		return ids
 	}
@@ -1184,7 +1424,7 @@ func (d *DBController) DeleteSchema(className string) error {
 
 	exist := d.CollectionExists(className)
 	if exist {
-		count, err := Adapter.Count(className, types.M{"fields": types.M{}}, types.M{})
+		count, err := d.adapter().Count(className, types.M{"fields": types.M{}}, types.M{})
 		if err != nil {
 			return err
 		}
@@ -1193,7 +1433,7 @@ func (d *DBController) DeleteSchema(className string) error {
 		}
 	}
 
-	result, err := Adapter.DeleteClass(className)
+	result, err := d.adapter().DeleteClass(className)
 	if err != nil {
 		return err
 	}
@@ -1202,7 +1442,7 @@ func (d *DBController) DeleteSchema(className string) error {
 			for fieldName, v := range fields {
 				if fieldType := utils.M(v); fieldType != nil {
 					if utils.S(fieldType["type"]) == "Relation" {
-						_, err = Adapter.DeleteClass(joinTableName(className, fieldName))
+						_, err = d.adapter().DeleteClass(joinTableName(className, fieldName))
 						if err != nil {
 							return err
 						}
@@ -1310,10 +1550,10 @@ func (d *DBController) PerformInitialization() {
 
 	d.LoadSchema(nil).EnforceClassExists("_User")
 	d.LoadSchema(nil).EnforceClassExists("_Role")
-	Adapter.EnsureUniqueness("_User", requiredUserFields, []string{"username"})
-	Adapter.EnsureUniqueness("_User", requiredUserFields, []string{"email"})
-	Adapter.EnsureUniqueness("_Role", requiredRoleFields, []string{"name"})
-	Adapter.PerformInitialization(types.M{"VolatileClassesSchemas": volatileClassesSchemas()})
+	d.adapter().EnsureUniqueness("_User", requiredUserFields, []string{"username"})
+	d.adapter().EnsureUniqueness("_User", requiredUserFields, []string{"email"})
+	d.adapter().EnsureUniqueness("_Role", requiredRoleFields, []string{"name"})
+	d.adapter().PerformInitialization(types.M{"VolatileClassesSchemas": volatileClassesSchemas()})
 }
 
 func addWriteACL(query types.M, acl []string) types.M {
@@ -1483,6 +1723,24 @@ func validateQuery(query types.M) error {
 	return nil
 }
 
+// hasTextConstraint 查询条件中是否包含 $text
+func hasTextConstraint(query types.M) bool {
+	for key, value := range query {
+		if key == "$or" || key == "$and" {
+			for _, subQuery := range utils.A(value) {
+				if hasTextConstraint(utils.M(subQuery)) {
+					return true
+				}
+			}
+			continue
+		}
+		if constraint := utils.M(value); constraint != nil && constraint["$text"] != nil {
+			return true
+		}
+	}
+	return false
+}
+
 // transformObjectACL 转换对象中的 ACL 字段
 // {
 // 	"ACL":{
@@ -1610,6 +1868,258 @@ func untransformObjectACL(output types.M) types.M {
 	return output
 }
 
+var aggregateStages = map[string]bool{
+	"$match":   true,
+	"$group":   true,
+	"$project": true,
+	"$sort":    true,
+	"$limit":   true,
+	"$skip":    true,
+	"$unwind":  true,
+}
+
+// transformPipeline 校验并规范化聚合查询的 pipeline
+// 阶段名可省略前缀 $ ，$group 中可使用 objectId 代替 _id
+// $sort 可以是 {"a":1} 、 "a,-b" 或者 [{"a":1},{"b":-1}] ，统一转换为 []string{"a", "-b"} ，map 格式只能包含一个字段
+// $unwind 可以是 "$a" 或者 {"path":"$a"} ，统一转换为 "$a"
+// $limit 与 $skip 统一转换为 int
+func transformPipeline(pipeline types.S) (types.S, error) {
+	if pipeline == nil {
+		return types.S{}, nil
+	}
+	result := types.S{}
+	for _, s := range pipeline {
+		stage := utils.M(s)
+		if stage == nil || len(stage) != 1 {
+			return nil, errs.E(errs.InvalidQuery, "Invalid aggregate stage, each stage should contain only one key.")
+		}
+		for key, value := range stage {
+			if strings.HasPrefix(key, "$") == false {
+				key = "$" + key
+			}
+			if aggregateStages[key] == false {
+				return nil, errs.E(errs.InvalidQuery, "Invalid aggregate stage: "+key)
+			}
+			switch key {
+			case "$match":
+				match := utils.M(value)
+				if match == nil {
+					return nil, errs.E(errs.InvalidQuery, "Invalid aggregate $match, use an object value.")
+				}
+				err := validateQuery(match)
+				if err != nil {
+					return nil, err
+				}
+				value = types.M(match)
+
+			case "$group":
+				group := utils.M(value)
+				if group == nil {
+					return nil, errs.E(errs.InvalidQuery, "Invalid aggregate $group, use an object value.")
+				}
+				if id, ok := group["objectId"]; ok {
+					if _, ok := group["_id"]; ok {
+						return nil, errs.E(errs.InvalidQuery, "Invalid aggregate $group, objectId and _id cannot be used together.")
+					}
+					group["_id"] = id
+					delete(group, "objectId")
+				}
+				if _, ok := group["_id"]; ok == false {
+					return nil, errs.E(errs.InvalidQuery, "Invalid aggregate $group, _id is required.")
+				}
+				for field, accumulator := range group {
+					if field == "_id" {
+						err := validateAggregateExpression(accumulator)
+						if err != nil {
+							return nil, err
+						}
+						continue
+					}
+					if fieldNameIsValid(field) == false {
+						return nil, errs.E(errs.InvalidKeyName, "Invalid field name: "+field)
+					}
+					acc := utils.M(accumulator)
+					if acc == nil || len(acc) != 1 {
+						return nil, errs.E(errs.InvalidQuery, "Invalid aggregate $group accumulator: "+field)
+					}
+					err := validateAggregateExpression(acc)
+					if err != nil {
+						return nil, err
+					}
+				}
+				value = types.M(group)
+
+			case "$project":
+				project := utils.M(value)
+				if project == nil {
+					return nil, errs.E(errs.InvalidQuery, "Invalid aggregate $project, use an object value.")
+				}
+				for field, v := range project {
+					if field != "_id" && fieldNameIsValid(field) == false {
+						return nil, errs.E(errs.InvalidKeyName, "Invalid field name: "+field)
+					}
+					err := validateAggregateExpression(v)
+					if err != nil {
+						return nil, err
+					}
+				}
+				value = types.M(project)
+
+			case "$sort":
+				keys := []string{}
+				if str, ok := value.(string); ok {
+					for _, k := range strings.Split(str, ",") {
+						if k = strings.TrimSpace(k); k != "" {
+							keys = append(keys, k)
+						}
+					}
+				} else if m := utils.M(value); m != nil {
+					// map 无法保留字段顺序，多个字段时需要使用字符串或者数组格式
+					if len(m) > 1 {
+						return nil, errs.E(errs.InvalidQuery, `Invalid aggregate $sort, use "a,-b" or [{"a":1},{"b":-1}] to sort by multiple fields.`)
+					}
+					keys = append(keys, aggregateSortKeys(m)...)
+				} else if items := utils.A(value); items != nil {
+					for _, item := range items {
+						if k, ok := item.(string); ok {
+							keys = append(keys, strings.TrimSpace(k))
+						} else if m := utils.M(item); m != nil && len(m) == 1 {
+							keys = append(keys, aggregateSortKeys(m)...)
+						} else {
+							return nil, errs.E(errs.InvalidQuery, "Invalid aggregate $sort.")
+						}
+					}
+				}
+				if len(keys) == 0 {
+					return nil, errs.E(errs.InvalidQuery, "Invalid aggregate $sort.")
+				}
+				for _, k := range keys {
+					if fieldNameIsValid(strings.TrimPrefix(k, "-")) == false {
+						return nil, errs.E(errs.InvalidKeyName, "Invalid field name: "+k)
+					}
+				}
+				value = keys
+
+			case "$limit", "$skip":
+				var n int
+				if f, ok := value.(float64); ok {
+					n = int(f)
+				} else if i, ok := value.(int); ok {
+					n = i
+				} else {
+					return nil, errs.E(errs.InvalidQuery, "Invalid aggregate "+key+", use a number value.")
+				}
+				if n < 0 {
+					return nil, errs.E(errs.InvalidQuery, "Invalid aggregate "+key+", use a positive number.")
+				}
+				value = n
+
+			case "$unwind":
+				var path string
+				if str, ok := value.(string); ok {
+					path = str
+				} else if unwind := utils.M(value); unwind != nil {
+					path = utils.S(unwind["path"])
+				}
+				if strings.HasPrefix(path, "$") == false || fieldNameIsValid(path[1:]) == false {
+					return nil, errs.E(errs.InvalidQuery, `Invalid aggregate $unwind, use a field path like "$field".`)
+				}
+				value = path
+			}
+			result = append(result, types.M{key: value})
+		}
+	}
+	return result, nil
+}
+
+// aggregateSortKeys 把 {"a":-1} 转换为 []string{"-a"}
+func aggregateSortKeys(m map[string]interface{}) []string {
+	keys := []string{}
+	for k, v := range m {
+		if n, ok := v.(float64); ok && n < 0 {
+			keys = append(keys, "-"+k)
+		} else if n, ok := v.(int); ok && n < 0 {
+			keys = append(keys, "-"+k)
+		} else {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+var aggregateOperatorRegex = regexp.MustCompile(`^\$[A-Za-z]+$`)
+
+// validateAggregateExpression 校验聚合表达式中的字段路径与对象的 key
+// 以 $ 开头的字符串为字段路径，每一段都需要是合法的字段名，对象的 key 为操作符或者合法的字段名
+func validateAggregateExpression(expression interface{}) error {
+	switch v := expression.(type) {
+	case string:
+		if strings.HasPrefix(v, "$") == false {
+			return nil
+		}
+		for _, key := range strings.Split(v[1:], ".") {
+			if fieldNameIsValid(key) == false {
+				return errs.E(errs.InvalidKeyName, "Invalid field path: "+v)
+			}
+		}
+	case map[string]interface{}, types.M:
+		for key, value := range utils.M(v) {
+			if strings.HasPrefix(key, "$") {
+				if aggregateOperatorRegex.MatchString(key) == false {
+					return errs.E(errs.InvalidQuery, "Invalid aggregate operator: "+key)
+				}
+			} else if fieldNameIsValid(key) == false {
+				return errs.E(errs.InvalidKeyName, "Invalid field name: "+key)
+			}
+			err := validateAggregateExpression(value)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}, types.S:
+		for _, value := range utils.A(v) {
+			err := validateAggregateExpression(value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateDistinctField 校验 distinct 查询的字段，字段路径的每一段都需要合法，不允许查询 _User 表中的敏感字段
+func validateDistinctField(className string, distinct interface{}, isMaster bool) error {
+	fieldName, ok := distinct.(string)
+	if ok == false || fieldName == "" {
+		return errs.E(errs.InvalidQuery, "distinct should be a field name")
+	}
+	path := strings.Split(fieldName, ".")
+	for _, key := range path {
+		if fieldNameIsValid(key) == false {
+			return errs.E(errs.InvalidKeyName, "Invalid field name: "+fieldName)
+		}
+	}
+	rootFieldName := path[0]
+	if className == "_User" {
+		if rootFieldName == "password" || rootFieldName == "sessionToken" {
+			return errs.E(errs.OperationForbidden, "Cannot use distinct on "+fieldName)
+		}
+		if isMaster {
+			return nil
+		}
+		// distinct 返回的是所有用户的字段值，非 Master 不能查询第三方登录数据与敏感字段
+		if rootFieldName == "authData" {
+			return errs.E(errs.OperationForbidden, "Cannot use distinct on "+fieldName)
+		}
+		for _, field := range config.TConfig.UserSensitiveFields {
+			if rootFieldName == field {
+				return errs.E(errs.OperationForbidden, "Cannot use distinct on "+fieldName)
+			}
+		}
+	}
+	return nil
+}
+
 // transformAuthData 转换第三方登录数据
 // {
 // 	"authData": {
@@ -7,10 +7,75 @@ import (
 
 	"github.com/lfq7413/tomato/cache"
 	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/storage"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
 )
 
+func Test_RunAfterCommit(t *testing.T) {
+	var db *DBController
+	var err error
+	var calls []string
+	/*************************************************/
+	initEnv()
+	calls = []string{}
+	TomatoDBController.RunAfterCommit(func() { calls = append(calls, "a") })
+	if reflect.DeepEqual([]string{"a"}, calls) == false {
+		t.Error("expect:", []string{"a"}, "result:", calls)
+	}
+	/*************************************************/
+	// MongoDB 适配器不支持事务
+	initEnv()
+	_, err = TomatoDBController.StartTransaction()
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+	/*************************************************/
+	initEnv()
+	Adapter = &transactionAdapter{Adapter}
+	calls = []string{}
+	db, err = TomatoDBController.StartTransaction()
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	db.RunAfterCommit(func() { calls = append(calls, "a") })
+	db.RunAfterCommit(func() { calls = append(calls, "b") })
+	if len(calls) != 0 {
+		t.Error("expect:", []string{}, "result:", calls)
+	}
+	err = db.CommitTransaction()
+	if err != nil || reflect.DeepEqual([]string{"a", "b"}, calls) == false {
+		t.Error("expect:", []string{"a", "b"}, "result:", calls, err)
+	}
+	/*************************************************/
+	initEnv()
+	Adapter = &transactionAdapter{Adapter}
+	calls = []string{}
+	db, _ = TomatoDBController.StartTransaction()
+	db.RunAfterCommit(func() { calls = append(calls, "a") })
+	db.AbortTransaction()
+	if len(calls) != 0 {
+		t.Error("expect:", []string{}, "result:", calls)
+	}
+}
+
+// transactionAdapter 在测试中模拟支持事务的适配器
+type transactionAdapter struct {
+	storage.Adapter
+}
+
+func (a *transactionAdapter) StartTransaction() (storage.Adapter, error) {
+	return a, nil
+}
+
+func (a *transactionAdapter) CommitTransaction() error {
+	return nil
+}
+
+func (a *transactionAdapter) AbortTransaction() error {
+	return nil
+}
+
 func Test_CollectionExists(t *testing.T) {
 	initEnv()
 	var object types.M
@@ -5549,6 +5614,199 @@ func Test_untransformObjectACL(t *testing.T) {
 	}
 }
 
+func Test_transformPipeline(t *testing.T) {
+	var pipeline types.S
+	var result types.S
+	var err error
+	var expect types.S
+	/*************************************************/
+	pipeline = nil
+	result, err = transformPipeline(pipeline)
+	expect = types.S{}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"match": types.M{"key": "hello"}},
+		types.M{"group": types.M{"objectId": "$key", "total": types.M{"$sum": "$score"}}},
+		types.M{"sort": types.S{types.M{"total": -1.0}, types.M{"objectId": 1.0}}},
+		types.M{"skip": 1.0},
+		types.M{"limit": 10.0},
+	}
+	result, err = transformPipeline(pipeline)
+	expect = types.S{
+		types.M{"$match": types.M{"key": "hello"}},
+		types.M{"$group": types.M{"_id": "$key", "total": types.M{"$sum": "$score"}}},
+		types.M{"$sort": []string{"-total", "objectId"}},
+		types.M{"$skip": 1},
+		types.M{"$limit": 10},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"$project": types.M{"key": 1.0}},
+		types.M{"$sort": "key,-createdAt"},
+		types.M{"$unwind": types.M{"path": "$tags"}},
+	}
+	result, err = transformPipeline(pipeline)
+	expect = types.S{
+		types.M{"$project": types.M{"key": 1.0}},
+		types.M{"$sort": []string{"key", "-createdAt"}},
+		types.M{"$unwind": "$tags"},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"$out": "other"},
+	}
+	_, err = transformPipeline(pipeline)
+	if reflect.DeepEqual(errs.E(errs.InvalidQuery, "Invalid aggregate stage: $out"), err) == false {
+		t.Error("expect:", "Invalid aggregate stage: $out", "result:", err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"$match": types.M{"key": "hello"}, "$limit": 1.0},
+	}
+	_, err = transformPipeline(pipeline)
+	if reflect.DeepEqual(errs.E(errs.InvalidQuery, "Invalid aggregate stage, each stage should contain only one key."), err) == false {
+		t.Error("expect:", "Invalid aggregate stage", "result:", err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"$group": types.M{"total": types.M{"$sum": 1.0}}},
+	}
+	_, err = transformPipeline(pipeline)
+	if reflect.DeepEqual(errs.E(errs.InvalidQuery, "Invalid aggregate $group, _id is required."), err) == false {
+		t.Error("expect:", "_id is required", "result:", err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"$group": types.M{"_id": types.M{"k', 1) --": "$key"}, "total": types.M{"$sum": 1.0}}},
+	}
+	_, err = transformPipeline(pipeline)
+	if reflect.DeepEqual(errs.E(errs.InvalidKeyName, "Invalid field name: k', 1) --"), err) == false {
+		t.Error("expect:", "Invalid field name", "result:", err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"$group": types.M{"_id": "$key", "total": types.M{"$max": `$score") FROM "_User" --`}}},
+	}
+	_, err = transformPipeline(pipeline)
+	if reflect.DeepEqual(errs.E(errs.InvalidKeyName, `Invalid field path: $score") FROM "_User" --`), err) == false {
+		t.Error("expect:", "Invalid field path", "result:", err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"$group": types.M{"_id": types.M{"key": "$key.sub"}, "total": types.M{"$sum": "$score"}}},
+		types.M{"$project": types.M{"_id": 0.0, "total": 1.0}},
+	}
+	result, err = transformPipeline(pipeline)
+	expect = types.S{
+		types.M{"$group": types.M{"_id": types.M{"key": "$key.sub"}, "total": types.M{"$sum": "$score"}}},
+		types.M{"$project": types.M{"_id": 0.0, "total": 1.0}},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"$sort": types.M{"total": -1.0, "objectId": 1.0}},
+	}
+	_, err = transformPipeline(pipeline)
+	if reflect.DeepEqual(errs.E(errs.InvalidQuery, `Invalid aggregate $sort, use "a,-b" or [{"a":1},{"b":-1}] to sort by multiple fields.`), err) == false {
+		t.Error("expect:", "Invalid aggregate $sort", "result:", err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"$sort": types.M{"total": -1.0}},
+	}
+	result, err = transformPipeline(pipeline)
+	expect = types.S{
+		types.M{"$sort": []string{"-total"}},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	pipeline = types.S{
+		types.M{"$limit": -1.0},
+	}
+	_, err = transformPipeline(pipeline)
+	if reflect.DeepEqual(errs.E(errs.InvalidQuery, "Invalid aggregate $limit, use a positive number."), err) == false {
+		t.Error("expect:", "use a positive number", "result:", err)
+	}
+}
+
+func Test_validateDistinctField(t *testing.T) {
+	var err error
+	var expect error
+	/*************************************************/
+	err = validateDistinctField("post", "key", false)
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	err = validateDistinctField("post", "key.sub", false)
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	err = validateDistinctField("post", "key.sub}',{a", false)
+	expect = errs.E(errs.InvalidKeyName, "Invalid field name: key.sub}',{a")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	err = validateDistinctField("post", 1.0, false)
+	expect = errs.E(errs.InvalidQuery, "distinct should be a field name")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	err = validateDistinctField("post", "_rperm", true)
+	expect = errs.E(errs.InvalidKeyName, "Invalid field name: _rperm")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	err = validateDistinctField("_User", "sessionToken", true)
+	expect = errs.E(errs.OperationForbidden, "Cannot use distinct on sessionToken")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	err = validateDistinctField("_User", "authData.facebook", false)
+	expect = errs.E(errs.OperationForbidden, "Cannot use distinct on authData.facebook")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	err = validateDistinctField("_User", "email", false)
+	expect = errs.E(errs.OperationForbidden, "Cannot use distinct on email")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	err = validateDistinctField("_User", "email", true)
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	err = validateDistinctField("_User", "authData.facebook", true)
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+}
+
 func Test_transformAuthData(t *testing.T) {
 	var className string
 	var object types.M
@@ -2,8 +2,10 @@ package orm
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/lfq7413/tomato/cache"
 	"github.com/lfq7413/tomato/errs"
@@ -16,9 +18,9 @@ import (
 var clpValidKeys = []string{"find", "count", "get", "create", "update", "delete", "addField", "readUserFields", "writeUserFields"}
 
 // SystemClasses 系统表
-var SystemClasses = []string{"_User", "_Installation", "_Role", "_Session", "_Product", "_PushStatus", "_JobStatus"}
+var SystemClasses = []string{"_User", "_Installation", "_Role", "_Session", "_Product", "_PushStatus", "_JobStatus", "_JobSchedule", "_Audience", "_WebhookDelivery", "_WebhookSubscription"}
 
-var volatileClasses = []string{"_JobStatus", "_PushStatus", "_Hooks", "_GlobalConfig"}
+var volatileClasses = []string{"_JobStatus", "_JobSchedule", "_PushStatus", "_Hooks", "_GlobalConfig", "_Audience", "_WebhookDelivery", "_WebhookSubscription"}
 
 // DefaultColumns 所有类的默认字段，以及系统类的默认字段
 var DefaultColumns = map[string]types.M{
@@ -73,34 +75,75 @@ var DefaultColumns = map[string]types.M{
 		"subtitle":          types.M{"type": "String"},
 	},
 	"_PushStatus": types.M{
-		"pushTime":      types.M{"type": "String"},
-		"source":        types.M{"type": "String"}, // rest or webui
-		"query":         types.M{"type": "String"}, // the stringified JSON query
-		"payload":       types.M{"type": "String"}, // the stringified payload,
-		"title":         types.M{"type": "String"},
-		"expiry":        types.M{"type": "Number"},
-		"status":        types.M{"type": "String"},
-		"numSent":       types.M{"type": "Number"},
-		"numFailed":     types.M{"type": "Number"},
-		"pushHash":      types.M{"type": "String"},
-		"errorMessage":  types.M{"type": "Object"},
-		"sentPerType":   types.M{"type": "Object"},
-		"failedPerType": types.M{"type": "Object"},
-		"count":         types.M{"type": "Number"},
+		"pushTime":        types.M{"type": "String"},
+		"source":          types.M{"type": "String"}, // rest or webui
+		"query":           types.M{"type": "String"}, // the stringified JSON query
+		"payload":         types.M{"type": "String"}, // the stringified payload,
+		"title":           types.M{"type": "String"},
+		"expiry":          types.M{"type": "Number"},
+		"status":          types.M{"type": "String"},
+		"numSent":         types.M{"type": "Number"},
+		"numFailed":       types.M{"type": "Number"},
+		"pushHash":        types.M{"type": "String"},
+		"errorMessage":    types.M{"type": "Object"},
+		"sentPerType":     types.M{"type": "Object"},
+		"failedPerType":   types.M{"type": "Object"},
+		"count":           types.M{"type": "Number"},
+		"localTimeCursor": types.M{"type": "String"}, // the UTC time up to which a local time push has been sent
 	},
 	"_JobStatus": types.M{
-		"jobName":    types.M{"type": "String"},
-		"source":     types.M{"type": "String"},
-		"status":     types.M{"type": "String"},
-		"message":    types.M{"type": "String"},
-		"params":     types.M{"type": "Object"}, // params received when calling the job
-		"finishedAt": types.M{"type": "Date"},
+		"jobName":     types.M{"type": "String"},
+		"source":      types.M{"type": "String"},
+		"status":      types.M{"type": "String"},
+		"message":     types.M{"type": "String"},
+		"params":      types.M{"type": "Object"}, // params received when calling the job
+		"finishedAt":  types.M{"type": "Date"},
+		"progress":    types.M{"type": "Number"}, // 0-100, reported by the job
+		"duration":    types.M{"type": "Number"}, // milliseconds between start and finish
+		"heartbeatAt": types.M{"type": "String"}, // refreshed by the instance running the job
+		"deadlineAt":  types.M{"type": "String"}, // the job fails after this time, empty if the job has no maximum runtime
+	},
+	"_JobSchedule": types.M{
+		"jobName":       types.M{"type": "String"},
+		"description":   types.M{"type": "String"},
+		"params":        types.M{"type": "Object"}, // params passed to the job on every run
+		"cron":          types.M{"type": "String"}, // cron expression, e.g. "0 3 * * *"
+		"repeatMinutes": types.M{"type": "Number"}, // interval between runs when cron is not set
+		"startAfter":    types.M{"type": "String"}, // the job does not run before this time
+		"timeZone":      types.M{"type": "String"}, // time zone used to evaluate cron, UTC by default
+		"nextRunAt":     types.M{"type": "String"}, // the next occurrence, claimed by exactly one instance
+		"lastRunAt":     types.M{"type": "String"},
+	},
+	"_Audience": types.M{
+		"name":      types.M{"type": "String"},
+		"query":     types.M{"type": "String"}, // stringified JSON of the _Installation query
+		"lastUsed":  types.M{"type": "Date"},
+		"timesUsed": types.M{"type": "Number"},
+	},
+	"_WebhookDelivery": types.M{
+		"url":          types.M{"type": "String"},
+		"payload":      types.M{"type": "String"}, // the stringified JSON body
+		"timeout":      types.M{"type": "Number"}, // milliseconds, 0 means WebhookTimeout
+		"functionName": types.M{"type": "String"},
+		"className":    types.M{"type": "String"},
+		"triggerName":  types.M{"type": "String"},
+		"status":       types.M{"type": "String"}, // failed or succeeded
+		"attempts":     types.M{"type": "Number"},
+		"error":        types.M{"type": "String"}, // the last delivery error
+	},
+	"_WebhookSubscription": types.M{
+		"url":        types.M{"type": "String"},
+		"classNames": types.M{"type": "Array"},
+		"events":     types.M{"type": "Array"},  // create update delete
+		"where":      types.M{"type": "String"}, // the stringified JSON query
+		"timeout":    types.M{"type": "Number"}, // milliseconds, 0 means WebhookTimeout
 	},
 	"_Hooks": types.M{
 		"functionName": types.M{"type": "String"},
 		"className":    types.M{"type": "String"},
 		"triggerName":  types.M{"type": "String"},
 		"url":          types.M{"type": "String"},
+		"timeout":      types.M{"type": "Number"}, // milliseconds, 0 means WebhookTimeout
 	},
 	"_GlobalConfig": types.M{
 		"objectId": types.M{"type": "String"},
@@ -126,7 +169,8 @@ type Schema struct {
 }
 
 // AddClassIfNotExists 添加类定义，包含默认的字段
-func (s *Schema) AddClassIfNotExists(className string, fields types.M, classLevelPermissions types.M) (types.M, error) {
+// indexes 为需要创建的索引，格式参考 buildIndex
+func (s *Schema) AddClassIfNotExists(className string, fields types.M, classLevelPermissions types.M, indexes types.M) (types.M, error) {
 	err := s.validateNewClass(className, fields, classLevelPermissions)
 	if err != nil {
 		return nil, err
@@ -137,13 +181,29 @@ func (s *Schema) AddClassIfNotExists(className string, fields types.M, classLeve
 		"fields":                fields,
 		"classLevelPermissions": classLevelPermissions,
 	}
-	result, err := s.dbAdapter.CreateClass(className, convertSchemaToAdapterSchema(schema))
+	insertedIndexes, _, err := s.validateIndexes(className, indexes, types.M{}, utils.M(injectDefaultSchema(schema)["fields"]))
+	if err != nil {
+		return nil, err
+	}
+
+	adapterSchema := convertSchemaToAdapterSchema(schema)
+	result, err := s.dbAdapter.CreateClass(className, adapterSchema)
 	if err != nil {
 		if errs.GetErrorCode(err) == errs.DuplicateValue {
 			return nil, errs.E(errs.InvalidClassName, "Class "+className+" already exists.")
 		}
 		return nil, err
 	}
+	if len(insertedIndexes) > 0 {
+		err = s.dbAdapter.CreateIndexes(className, adapterSchema, insertedIndexes)
+		if err != nil {
+			// 索引创建失败时，删除已创建的类
+			s.dbAdapter.DeleteClass(className)
+			s.cache.Clear()
+			return nil, err
+		}
+		result["indexes"] = insertedIndexes
+	}
 	result = convertAdapterSchemaToParseSchema(result)
 	s.cache.Clear()
 
@@ -151,7 +211,8 @@ func (s *Schema) AddClassIfNotExists(className string, fields types.M, classLeve
 }
 
 // UpdateClass 更新类
-func (s *Schema) UpdateClass(className string, submittedFields types.M, classLevelPermissions types.M) (types.M, error) {
+// submittedIndexes 中为需要添加的索引，以及需要删除的索引 {"name":{"__op":"Delete"}}
+func (s *Schema) UpdateClass(className string, submittedFields types.M, classLevelPermissions types.M, submittedIndexes types.M) (types.M, error) {
 	schema, err := s.GetOneSchema(className, false, nil)
 	if err != nil {
 		return nil, err
@@ -177,6 +238,16 @@ func (s *Schema) UpdateClass(className string, submittedFields types.M, classLev
 		}
 		op := utils.S(field["__op"])
 		if existingFields[name] != nil && op != "Delete" {
+			// 字段已存在时，仅允许修改校验规则，不能修改字段类型
+			// 类型相同且不包含校验规则时，清除字段已有的校验规则
+			existingField := utils.M(existingFields[name])
+			if dbTypeMatchesObjectType(existingField, field) && (hasFieldRules(field) || hasFieldRules(existingField)) {
+				err = validateFieldRules(name, field)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
 			// 字段已存在，不能更新
 			return nil, errs.E(errs.ClassNotEmpty, "Field "+name+" exists, cannot update.")
 		}
@@ -201,10 +272,20 @@ func (s *Schema) UpdateClass(className string, submittedFields types.M, classLev
 	if err != nil {
 		return nil, err
 	}
+	existingIndexes := utils.M(schema["indexes"])
+	if existingIndexes == nil {
+		existingIndexes = types.M{}
+	}
+	newFields := utils.M(injectDefaultSchema(types.M{"className": className, "fields": newSchema})["fields"])
+	insertedIndexes, deletedIndexes, err := s.validateIndexes(className, submittedIndexes, existingIndexes, newFields)
+	if err != nil {
+		return nil, err
+	}
 
-	// 删除指定字段，并统计需要插入的字段
+	// 删除指定字段，并统计需要插入的字段与需要修改校验规则的字段
 	deletedFields := []string{}
 	insertedFields := []string{}
+	updatedFields := []string{}
 	for name, v := range submittedFields {
 		field := utils.M(v)
 		if field == nil {
@@ -213,6 +294,8 @@ func (s *Schema) UpdateClass(className string, submittedFields types.M, classLev
 		op := utils.S(field["__op"])
 		if op == "Delete" {
 			deletedFields = append(deletedFields, name)
+		} else if existingFields[name] != nil {
+			updatedFields = append(updatedFields, name)
 		} else {
 			insertedFields = append(insertedFields, name)
 		}
@@ -237,21 +320,109 @@ func (s *Schema) UpdateClass(className string, submittedFields types.M, classLev
 		}
 	}
 
+	// 修改字段的校验规则
+	if len(updatedFields) > 0 {
+		for _, fieldName := range updatedFields {
+			err := s.dbAdapter.UpdateFieldOptions(className, fieldName, utils.M(submittedFields[fieldName]))
+			if err != nil {
+				return nil, err
+			}
+		}
+		s.reloadData(types.M{"clearCache": true})
+	}
+
 	// 设置 CLP
 	err = s.setPermissions(className, classLevelPermissions, newSchema)
 	if err != nil {
 		return nil, err
 	}
 
+	// 删除与创建索引
+	indexes, err := s.setIndexes(className, insertedIndexes, deletedIndexes)
+	if err != nil {
+		return nil, err
+	}
+
 	s.dataMutex.Lock()
 	defer s.dataMutex.Unlock()
 	s.permsMutex.Lock()
 	defer s.permsMutex.Unlock()
-	return types.M{
+	result := types.M{
 		"className":             className,
 		"fields":                utils.DeepCopy(s.data[className]),
 		"classLevelPermissions": utils.DeepCopy(s.perms[className]),
-	}, nil
+	}
+	if len(indexes) > 0 {
+		result["indexes"] = types.M(indexes)
+	}
+	return result, nil
+}
+
+// validateIndexes 校验需要添加与删除的索引，返回转换格式后需要添加的索引，以及需要删除的索引名称
+// existingIndexes 为 _SCHEMA 中保存的索引，数据库中存在但未保存的索引，例如 _id_ ，同样不能重复创建，且不能删除
+func (s *Schema) validateIndexes(className string, submittedIndexes, existingIndexes, fields types.M) (types.M, []string, error) {
+	insertedIndexes := types.M{}
+	deletedIndexes := []string{}
+	if len(submittedIndexes) == 0 {
+		return insertedIndexes, deletedIndexes, nil
+	}
+
+	var databaseIndexes types.M
+	for name, v := range submittedIndexes {
+		if op := utils.M(v); op != nil && utils.S(op["__op"]) == "Delete" {
+			if existingIndexes[name] == nil {
+				return nil, nil, errs.E(errs.InvalidQuery, "Index "+name+" does not exist, cannot delete.")
+			}
+			deletedIndexes = append(deletedIndexes, name)
+			continue
+		}
+
+		if existingIndexes[name] == nil && databaseIndexes == nil {
+			indexes, err := s.dbAdapter.GetIndexes(className)
+			if err != nil {
+				return nil, nil, err
+			}
+			databaseIndexes = indexes
+		}
+		if existingIndexes[name] != nil || databaseIndexes[name] != nil {
+			return nil, nil, errs.E(errs.InvalidQuery, "Index "+name+" exists, cannot update.")
+		}
+		index, err := buildIndex(name, v, fields)
+		if err != nil {
+			return nil, nil, err
+		}
+		insertedIndexes[name] = index
+	}
+	return insertedIndexes, deletedIndexes, nil
+}
+
+// setIndexes 删除与创建索引，返回更新后 _SCHEMA 中保存的索引
+func (s *Schema) setIndexes(className string, insertedIndexes types.M, deletedIndexes []string) (types.M, error) {
+	if len(insertedIndexes) > 0 || len(deletedIndexes) > 0 {
+		schema, err := s.GetOneSchema(className, false, types.M{"clearCache": true})
+		if err != nil {
+			return nil, err
+		}
+		if len(deletedIndexes) > 0 {
+			err = s.dbAdapter.DropIndexes(className, deletedIndexes)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(insertedIndexes) > 0 {
+			err = s.dbAdapter.CreateIndexes(className, convertSchemaToAdapterSchema(schema), insertedIndexes)
+			if err != nil {
+				return nil, err
+			}
+		}
+		s.cache.Clear()
+	}
+
+	schema, err := s.GetOneSchema(className, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	return utils.M(schema["indexes"]), nil
 }
 
 // deleteField 从类定义中删除指定的字段
@@ -288,6 +459,31 @@ func (s *Schema) deleteFields(fieldNames []string, className string) error {
 		}
 	}
 
+	// 删除使用了这些字段的索引
+	if indexes := utils.M(schema["indexes"]); len(indexes) > 0 {
+		deletedIndexes := []string{}
+		for name, index := range indexes {
+			for _, fieldName := range indexFieldNames(utils.M(index)) {
+				if fieldNameInList(fieldName, fieldNames) {
+					deletedIndexes = append(deletedIndexes, name)
+					break
+				}
+			}
+		}
+		if len(deletedIndexes) > 0 {
+			err = s.dbAdapter.DropIndexes(className, deletedIndexes)
+			if err != nil {
+				return err
+			}
+			indexes = utils.CopyMap(indexes)
+			for _, name := range deletedIndexes {
+				delete(indexes, name)
+			}
+			schema = utils.CopyMap(schema)
+			schema["indexes"] = indexes
+		}
+	}
+
 	err = s.dbAdapter.DeleteFields(className, schema, fieldNames)
 	if err != nil {
 		return err
@@ -447,7 +643,7 @@ func (s *Schema) EnforceClassExists(className string) error {
 	s.dataMutex.Unlock()
 
 	// 添加不存在的类定义
-	_, err := s.AddClassIfNotExists(className, nil, nil)
+	_, err := s.AddClassIfNotExists(className, nil, nil, nil)
 	if err != nil {
 
 	}
@@ -497,6 +693,10 @@ func (s *Schema) validateSchemaData(className string, fields types.M, classLevel
 		if err != nil {
 			return err
 		}
+		err = validateFieldRules(fieldName, utils.M(v))
+		if err != nil {
+			return err
+		}
 	}
 
 	if DefaultColumns[className] != nil {
@@ -830,13 +1030,17 @@ func getObjectType(obj interface{}) (types.M, error) {
 				if object["latitude"] != nil && object["longitude"] != nil {
 					return types.M{"type": "GeoPoint"}, nil
 				}
+			case "Polygon":
+				if object["coordinates"] != nil {
+					return types.M{"type": "Polygon"}, nil
+				}
 			case "Bytes":
 				if object["base64"] != nil {
 					return types.M{"type": "Bytes"}, nil
 				}
 			}
-			// 当 __type 的值不在以上 6 种类型之中时，为无效类型
-			// 当 __type 的值在以上 6 种类型之中，但是不符合详细规则时，为无效的类型
+			// 当 __type 的值不在以上 7 种类型之中时，为无效类型
+			// 当 __type 的值在以上 7 种类型之中，但是不符合详细规则时，为无效的类型
 			return nil, errs.E(errs.IncorrectType, "This is not a valid "+t)
 		}
 		if object["$ne"] != nil {
@@ -886,6 +1090,23 @@ func ClassNameIsValid(className string) bool {
 		fieldNameIsValid(className) // 类名与字段名的规则相同
 }
 
+// IsVolatileClass 是否为 schema 固定、不需要通过 _SCHEMA 管理的系统类，如 _Hooks 、 _GlobalConfig
+func IsVolatileClass(className string) bool {
+	for _, name := range volatileClasses {
+		if name == className {
+			return true
+		}
+	}
+	return false
+}
+
+// VolatileClassNames 返回所有 schema 固定的系统类
+func VolatileClassNames() []string {
+	names := make([]string, len(volatileClasses))
+	copy(names, volatileClasses)
+	return names
+}
+
 // InvalidClassNameMessage ...
 func InvalidClassNameMessage(className string) string {
 	return "Invalid classname: " + className + ", classnames can only have alphanumeric characters and _, and must start with an alpha character "
@@ -925,6 +1146,123 @@ func fieldNameIsValidForClass(fieldName string, className string) bool {
 	return true
 }
 
+var indexNameRegex = `^[A-Za-z][A-Za-z0-9_]*$`
+
+// buildIndex 校验索引定义，并转换为保存在 _SCHEMA 中的格式，格式如下
+// {
+// 	"fields":["name","-createdAt"],
+// 	"unique":true,
+// 	"sparse":true,
+// 	"expireAfterSeconds":3600
+// }
+// fields 中字段的顺序即为复合索引中字段的顺序，以 - 开头表示倒序，以 $text: 开头表示文本索引
+// unique 唯一索引， sparse 稀疏索引，仅索引存在该字段的对象
+// expireAfterSeconds 仅用于单个 Date 字段，对象在该字段的时间之后指定秒数过期并删除
+func buildIndex(name string, value interface{}, fields types.M) (types.M, error) {
+	if b, _ := regexp.MatchString(indexNameRegex, name); b == false {
+		return nil, errs.E(errs.InvalidQuery, "invalid index name: "+name)
+	}
+	definition := utils.M(value)
+	if definition == nil {
+		return nil, errs.E(errs.InvalidJSON, "index "+name+" should be an object")
+	}
+	for key := range definition {
+		if key != "fields" && key != "unique" && key != "sparse" && key != "expireAfterSeconds" {
+			return nil, errs.E(errs.InvalidJSON, "invalid option for index "+name+": "+key)
+		}
+	}
+
+	keys := utils.A(definition["fields"])
+	if len(keys) == 0 {
+		return nil, errs.E(errs.InvalidJSON, "fields of index "+name+" should be a non-empty array")
+	}
+	index := types.M{}
+	indexKeys := types.S{}
+	hasText := false
+	for _, k := range keys {
+		key, ok := k.(string)
+		if ok == false {
+			return nil, errs.E(errs.InvalidJSON, "fields of index "+name+" should be an array of strings")
+		}
+		fieldName := indexKeyFieldName(key)
+		isText := strings.HasPrefix(key, "$text:")
+		if isText {
+			hasText = true
+		}
+		fieldType := utils.M(fields[fieldName])
+		if fieldType == nil {
+			return nil, errs.E(errs.InvalidQuery, "Field "+fieldName+" does not exist, cannot add index.")
+		}
+		if utils.S(fieldType["type"]) == "Relation" {
+			return nil, errs.E(errs.InvalidQuery, "Field "+fieldName+" is a Relation, cannot add index.")
+		}
+		if isText && utils.S(fieldType["type"]) != "String" {
+			return nil, errs.E(errs.InvalidQuery, "Field "+fieldName+" is not a String, cannot add text index.")
+		}
+		indexKeys = append(indexKeys, key)
+	}
+	index["fields"] = indexKeys
+
+	for _, option := range []string{"unique", "sparse"} {
+		if v, ok := definition[option]; ok {
+			b, ok := v.(bool)
+			if ok == false {
+				return nil, errs.E(errs.InvalidJSON, option+" of index "+name+" should be a boolean")
+			}
+			if b {
+				index[option] = true
+			}
+		}
+	}
+
+	if v, ok := definition["expireAfterSeconds"]; ok {
+		var seconds float64
+		switch n := v.(type) {
+		case float64:
+			seconds = n
+		case int:
+			seconds = float64(n)
+		default:
+			return nil, errs.E(errs.InvalidJSON, "expireAfterSeconds of index "+name+" should be a number")
+		}
+		if seconds < 1 || seconds != float64(int64(seconds)) {
+			return nil, errs.E(errs.InvalidJSON, "expireAfterSeconds of index "+name+" should be a positive integer")
+		}
+		if len(keys) != 1 || hasText || utils.S(utils.M(fields[indexKeyFieldName(utils.S(keys[0]))])["type"]) != "Date" {
+			return nil, errs.E(errs.InvalidQuery, "expireAfterSeconds of index "+name+" can only be used on a single Date field")
+		}
+		index["expireAfterSeconds"] = seconds
+	}
+
+	return index, nil
+}
+
+// indexKeyFieldName 获取索引中的字段名，去掉表示倒序的 - 与表示文本索引的 $text:
+func indexKeyFieldName(key string) string {
+	if strings.HasPrefix(key, "$text:") {
+		return key[len("$text:"):]
+	}
+	return strings.TrimPrefix(key, "-")
+}
+
+// indexFieldNames 获取索引中的所有字段名
+func indexFieldNames(index types.M) []string {
+	fieldNames := []string{}
+	for _, key := range utils.A(index["fields"]) {
+		fieldNames = append(fieldNames, indexKeyFieldName(utils.S(key)))
+	}
+	return fieldNames
+}
+
+func fieldNameInList(fieldName string, fieldNames []string) bool {
+	for _, name := range fieldNames {
+		if name == fieldName {
+			return true
+		}
+	}
+	return false
+}
+
 var validNonRelationOrPointerTypes = map[string]bool{
 	"Number":   true,
 	"String":   true,
@@ -933,6 +1271,7 @@ var validNonRelationOrPointerTypes = map[string]bool{
 	"Object":   true,
 	"Array":    true,
 	"GeoPoint": true,
+	"Polygon":  true,
 	"File":     true,
 }
 
@@ -971,6 +1310,233 @@ func fieldTypeIsInvalid(t types.M) error {
 	return nil
 }
 
+// fieldRuleKeys 字段定义中可以设置的校验规则
+// required 必须字段， defaultValue 创建对象时的默认值，
+// min 、 max 用于 Number 字段， minLength 、 maxLength 用于 String 与 Array 字段，
+// pattern 用于 String 字段， enum 为 String 与 Number 字段允许的取值
+var fieldRuleKeys = []string{"required", "defaultValue", "min", "max", "minLength", "maxLength", "pattern", "enum"}
+
+// hasFieldRules 字段定义中是否包含校验规则
+func hasFieldRules(t types.M) bool {
+	for _, key := range fieldRuleKeys {
+		if _, ok := t[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateFieldRules 检测字段定义中的校验规则是否合法，默认值需要满足字段类型与其他规则
+func validateFieldRules(fieldName string, t types.M) error {
+	fieldType := utils.S(t["type"])
+	ruleTypes := map[string][]string{
+		"min":       {"Number"},
+		"max":       {"Number"},
+		"minLength": {"String", "Array"},
+		"maxLength": {"String", "Array"},
+		"pattern":   {"String"},
+		"enum":      {"String", "Number"},
+	}
+	for rule, fieldTypes := range ruleTypes {
+		if _, ok := t[rule]; ok == false {
+			continue
+		}
+		valid := false
+		for _, v := range fieldTypes {
+			if v == fieldType {
+				valid = true
+				break
+			}
+		}
+		if valid == false {
+			return errs.E(errs.IncorrectType, rule+" cannot be used on "+fieldType+" field "+fieldName)
+		}
+	}
+
+	if v, ok := t["required"]; ok {
+		if _, ok := v.(bool); ok == false {
+			return errs.E(errs.InvalidJSON, "required of field "+fieldName+" should be a boolean")
+		}
+	}
+	for _, rule := range []string{"min", "max"} {
+		if v, ok := t[rule]; ok {
+			if _, ok := toFloat64(v); ok == false {
+				return errs.E(errs.InvalidJSON, rule+" of field "+fieldName+" should be a number")
+			}
+		}
+	}
+	if min, ok := toFloat64(t["min"]); ok {
+		if max, ok := toFloat64(t["max"]); ok && min > max {
+			return errs.E(errs.InvalidJSON, "min of field "+fieldName+" should not be greater than max")
+		}
+	}
+	for _, rule := range []string{"minLength", "maxLength"} {
+		if v, ok := t[rule]; ok {
+			if n, ok := toFloat64(v); ok == false || n < 0 || n != float64(int64(n)) {
+				return errs.E(errs.InvalidJSON, rule+" of field "+fieldName+" should be a non-negative integer")
+			}
+		}
+	}
+	if minLength, ok := toFloat64(t["minLength"]); ok {
+		if maxLength, ok := toFloat64(t["maxLength"]); ok && minLength > maxLength {
+			return errs.E(errs.InvalidJSON, "minLength of field "+fieldName+" should not be greater than maxLength")
+		}
+	}
+	if v, ok := t["pattern"]; ok {
+		pattern, ok := v.(string)
+		if ok == false {
+			return errs.E(errs.InvalidJSON, "pattern of field "+fieldName+" should be a string")
+		}
+		if _, err := compileFieldPattern(pattern); err != nil {
+			return errs.E(errs.InvalidJSON, "pattern of field "+fieldName+" is not a valid regular expression")
+		}
+	}
+	if v, ok := t["enum"]; ok {
+		values := utils.A(v)
+		if len(values) == 0 {
+			return errs.E(errs.InvalidJSON, "enum of field "+fieldName+" should be a non-empty array")
+		}
+		for _, value := range values {
+			valid := false
+			switch value.(type) {
+			case string:
+				valid = fieldType == "String"
+			case float64, int:
+				valid = fieldType == "Number"
+			}
+			if valid == false {
+				return errs.E(errs.IncorrectType, "enum of field "+fieldName+" should only contain "+fieldType+" values")
+			}
+		}
+	}
+
+	if v, ok := t["defaultValue"]; ok && v != nil {
+		if fieldType == "Relation" {
+			return errs.E(errs.IncorrectType, "Relation field "+fieldName+" cannot have a defaultValue")
+		}
+		valueType, err := getType(v)
+		if err != nil {
+			return err
+		}
+		if valueType == nil || dbTypeMatchesObjectType(types.M{"type": fieldType, "targetClass": t["targetClass"]}, valueType) == false {
+			got := "unknown"
+			if valueType != nil {
+				got = typeToString(valueType)
+			}
+			return errs.E(errs.IncorrectType, "schema mismatch for "+fieldName+" default value; expected "+typeToString(t)+" but got "+got)
+		}
+		err = ValidateFieldValue(fieldName, t, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateFieldValue 校验写入的字段值是否满足字段定义中的规则
+// 字段值为 nil 或者为 __op 操作时不进行校验，必须字段与默认值由调用方处理
+func ValidateFieldValue(fieldName string, fieldType types.M, value interface{}) error {
+	if value == nil || fieldType == nil {
+		return nil
+	}
+	if op := utils.M(value); op != nil && op["__op"] != nil {
+		return nil
+	}
+
+	if enum := utils.A(fieldType["enum"]); len(enum) > 0 {
+		valid := false
+		for _, e := range enum {
+			if n, ok := toFloat64(e); ok {
+				if v, ok := toFloat64(value); ok && v == n {
+					valid = true
+					break
+				}
+			} else if e == value {
+				valid = true
+				break
+			}
+		}
+		if valid == false {
+			return errs.E(errs.ValidationError, fieldName+" should be one of the allowed values.")
+		}
+	}
+
+	if n, ok := toFloat64(value); ok {
+		if min, ok := toFloat64(fieldType["min"]); ok && n < min {
+			return errs.E(errs.ValidationError, fieldName+" should be greater than or equal to "+strconv.FormatFloat(min, 'f', -1, 64)+".")
+		}
+		if max, ok := toFloat64(fieldType["max"]); ok && n > max {
+			return errs.E(errs.ValidationError, fieldName+" should be less than or equal to "+strconv.FormatFloat(max, 'f', -1, 64)+".")
+		}
+	}
+
+	length := -1
+	if s, ok := value.(string); ok {
+		length = utf8.RuneCountInString(s)
+	} else if a := utils.A(value); a != nil {
+		length = len(a)
+	}
+	if length >= 0 {
+		if minLength, ok := toFloat64(fieldType["minLength"]); ok && float64(length) < minLength {
+			return errs.E(errs.ValidationError, fieldName+" should have a length of at least "+strconv.FormatFloat(minLength, 'f', -1, 64)+".")
+		}
+		if maxLength, ok := toFloat64(fieldType["maxLength"]); ok && float64(length) > maxLength {
+			return errs.E(errs.ValidationError, fieldName+" should have a length of at most "+strconv.FormatFloat(maxLength, 'f', -1, 64)+".")
+		}
+	}
+
+	if pattern, ok := fieldType["pattern"].(string); ok {
+		if s, ok := value.(string); ok {
+			if re, err := compileFieldPattern(pattern); err != nil || re.MatchString(s) == false {
+				return errs.E(errs.ValidationError, fieldName+" does not match the pattern "+pattern+".")
+			}
+		}
+	}
+	return nil
+}
+
+// fieldPatterns 字段校验规则中 pattern 编译后的正则表达式，写入数据时不再重复编译
+var fieldPatterns = struct {
+	sync.RWMutex
+	m map[string]*regexp.Regexp
+}{m: map[string]*regexp.Regexp{}}
+
+// compileFieldPattern 编译 pattern ，并缓存编译结果
+func compileFieldPattern(pattern string) (*regexp.Regexp, error) {
+	fieldPatterns.RLock()
+	re := fieldPatterns.m[pattern]
+	fieldPatterns.RUnlock()
+	if re != nil {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	fieldPatterns.Lock()
+	fieldPatterns.m[pattern] = re
+	fieldPatterns.Unlock()
+	return re, nil
+}
+
+// FieldIsRequired 字段定义中是否设置了 required
+func FieldIsRequired(fieldType types.M) bool {
+	required, _ := fieldType["required"].(bool)
+	return required
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
 // validateCLP 校验类级别权限
 // 正常的 perms 格式如下
 // {
@@ -1171,8 +1737,32 @@ func volatileClassesSchemas() []types.M {
 		"classLevelPermissions": types.M{},
 	}
 	jobStatusSchema := convertSchemaToAdapterSchema(s)
+	s = types.M{
+		"className":             "_JobSchedule",
+		"fields":                types.M{},
+		"classLevelPermissions": types.M{},
+	}
+	jobScheduleSchema := convertSchemaToAdapterSchema(s)
+	s = types.M{
+		"className":             "_Audience",
+		"fields":                types.M{},
+		"classLevelPermissions": types.M{},
+	}
+	audienceSchema := convertSchemaToAdapterSchema(s)
+	s = types.M{
+		"className":             "_WebhookDelivery",
+		"fields":                types.M{},
+		"classLevelPermissions": types.M{},
+	}
+	webhookDeliverySchema := convertSchemaToAdapterSchema(s)
+	s = types.M{
+		"className":             "_WebhookSubscription",
+		"fields":                types.M{},
+		"classLevelPermissions": types.M{},
+	}
+	webhookSubscriptionSchema := convertSchemaToAdapterSchema(s)
 
-	results = []types.M{hooksSchema, jobStatusSchema, pushStatusSchema, globalConfigSchema}
+	results = []types.M{hooksSchema, jobStatusSchema, jobScheduleSchema, pushStatusSchema, globalConfigSchema, audienceSchema, webhookDeliverySchema, webhookSubscriptionSchema}
 	return results
 }
 
@@ -1203,6 +1793,9 @@ func injectDefaultSchema(schema types.M) types.M {
 	newSchema["fields"] = newfields
 	newSchema["className"] = schema["className"]
 	newSchema["classLevelPermissions"] = schema["classLevelPermissions"]
+	if indexes := utils.M(schema["indexes"]); len(indexes) > 0 {
+		newSchema["indexes"] = types.M(indexes)
+	}
 
 	return newSchema
 }
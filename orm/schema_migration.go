@@ -0,0 +1,337 @@
+package orm
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+// SchemaMigration 类定义与数据库中 schema 的差异
+type SchemaMigration struct {
+	ClassName             string
+	CreateClass           bool     // 类不存在，需要创建
+	AddedFields           types.M  // 需要添加的字段
+	UpdatedFields         types.M  // 需要修改校验规则的字段
+	DeletedFields         []string // 需要删除的字段
+	ClassLevelPermissions types.M  // 需要更新的 CLP ，为 nil 时不更新
+	AddedIndexes          types.M  // 需要添加的索引
+	DeletedIndexes        []string // 需要删除的索引，定义发生变化的索引会先删除再添加
+}
+
+// IsEmpty 类定义与数据库中 schema 一致时返回 true
+func (m *SchemaMigration) IsEmpty() bool {
+	return m.CreateClass == false &&
+		len(m.AddedFields) == 0 &&
+		len(m.UpdatedFields) == 0 &&
+		len(m.DeletedFields) == 0 &&
+		m.ClassLevelPermissions == nil &&
+		len(m.AddedIndexes) == 0 &&
+		len(m.DeletedIndexes) == 0
+}
+
+// Diff 以文本形式列出需要进行的修改，每行一项，例如：
+// + GameScore.score: Number
+// - GameScore.cheatMode
+func (m *SchemaMigration) Diff() []string {
+	lines := []string{}
+	if m.CreateClass {
+		lines = append(lines, "+ class "+m.ClassName)
+	}
+	for _, name := range sortedKeys(m.AddedFields) {
+		lines = append(lines, "+ "+m.ClassName+"."+name+": "+typeToString(utils.M(m.AddedFields[name])))
+	}
+	for _, name := range sortedKeys(m.UpdatedFields) {
+		fieldType := utils.M(m.UpdatedFields[name])
+		if hasFieldRules(fieldType) {
+			lines = append(lines, "~ "+m.ClassName+"."+name+": "+typeToString(fieldType)+" (rules)")
+		} else {
+			lines = append(lines, "~ "+m.ClassName+"."+name+": "+typeToString(fieldType)+" (clear rules)")
+		}
+	}
+	for _, name := range m.DeletedFields {
+		lines = append(lines, "- "+m.ClassName+"."+name)
+	}
+	if m.ClassLevelPermissions != nil {
+		lines = append(lines, "~ "+m.ClassName+" classLevelPermissions")
+	}
+	for _, name := range m.DeletedIndexes {
+		lines = append(lines, "- "+m.ClassName+" index "+name)
+	}
+	for _, name := range sortedKeys(m.AddedIndexes) {
+		lines = append(lines, "+ "+m.ClassName+" index "+name)
+	}
+	return lines
+}
+
+// ParseSchemaDefinitions 解析类定义文件，格式与 GET /schemas 的返回值相同：
+// {"results": [{"className": "GameScore", "fields": {...}, "classLevelPermissions": {...}, "indexes": {...}}]}
+// 也可以直接使用类定义的数组
+func ParseSchemaDefinitions(data []byte) ([]types.M, error) {
+	var object interface{}
+	err := json.Unmarshal(data, &object)
+	if err != nil {
+		return nil, errs.E(errs.InvalidJSON, "invalid schema definitions: "+err.Error())
+	}
+	list := utils.A(object)
+	if list == nil {
+		if m := utils.M(object); m != nil {
+			list = utils.A(m["results"])
+		}
+	}
+	if list == nil {
+		return nil, errs.E(errs.InvalidJSON, "schema definitions should be an array or an object with results")
+	}
+
+	definitions := []types.M{}
+	classNames := map[string]bool{}
+	for _, v := range list {
+		definition := utils.M(v)
+		if definition == nil {
+			return nil, errs.E(errs.InvalidJSON, "schema definition should be an object")
+		}
+		className := utils.S(definition["className"])
+		if className == "" {
+			return nil, errs.E(errs.MissingRequiredFieldError, "schema definition needs a class name.")
+		}
+		if classNames[className] {
+			return nil, errs.E(errs.InvalidClassName, "Class "+className+" is defined more than once.")
+		}
+		classNames[className] = true
+		definitions = append(definitions, definition)
+	}
+	return definitions, nil
+}
+
+// PlanSchemaMigrations 对比类定义与数据库中的 schema ，返回需要进行的修改
+// 未出现在类定义中的类不做处理，类定义中未出现的字段将被删除，默认字段除外
+// 类定义中包含 classLevelPermissions 或 indexes 时，才会同步 CLP 与索引
+// strict 为 true 时，需要删除的字段中仍有数据时返回错误
+func (d *DBController) PlanSchemaMigrations(definitions []types.M, strict bool) ([]*SchemaMigration, error) {
+	schemaController := d.LoadSchema(types.M{"clearCache": true})
+	migrations := []*SchemaMigration{}
+	for _, definition := range definitions {
+		className := utils.S(definition["className"])
+		existing, err := schemaController.GetOneSchema(className, false, types.M{"clearCache": true})
+		if err != nil {
+			return nil, err
+		}
+		migration, err := diffSchema(className, existing, definition)
+		if err != nil {
+			return nil, err
+		}
+		if strict && len(migration.DeletedFields) > 0 {
+			err = d.enforceFieldsEmpty(className, existing, migration.DeletedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if migration.IsEmpty() == false {
+			migrations = append(migrations, migration)
+		}
+	}
+	return migrations, nil
+}
+
+// ApplySchemaMigrations 通过 Schema 执行 PlanSchemaMigrations 返回的修改
+func (d *DBController) ApplySchemaMigrations(migrations []*SchemaMigration) error {
+	for _, migration := range migrations {
+		schemaController := d.LoadSchema(types.M{"clearCache": true})
+		if migration.CreateClass {
+			_, err := schemaController.AddClassIfNotExists(migration.ClassName, migration.AddedFields, migration.ClassLevelPermissions, migration.AddedIndexes)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		fields := types.M{}
+		for name, v := range migration.AddedFields {
+			fields[name] = v
+		}
+		for name, v := range migration.UpdatedFields {
+			fields[name] = v
+		}
+		for _, name := range migration.DeletedFields {
+			fields[name] = types.M{"__op": "Delete"}
+		}
+		indexes := types.M{}
+		for _, name := range migration.DeletedIndexes {
+			indexes[name] = types.M{"__op": "Delete"}
+		}
+		_, err := schemaController.UpdateClass(migration.ClassName, fields, migration.ClassLevelPermissions, indexes)
+		if err != nil {
+			return err
+		}
+
+		// 删除与添加同名索引需要分两次进行
+		if len(migration.AddedIndexes) > 0 {
+			schemaController = d.LoadSchema(types.M{"clearCache": true})
+			_, err = schemaController.UpdateClass(migration.ClassName, types.M{}, nil, migration.AddedIndexes)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// enforceFieldsEmpty 检测需要删除的字段中是否仍有数据
+func (d *DBController) enforceFieldsEmpty(className string, schema types.M, fieldNames []string) error {
+	fields := utils.M(schema["fields"])
+	for _, fieldName := range fieldNames {
+		var count int
+		var err error
+		fieldType := utils.M(fields[fieldName])
+		if utils.S(fieldType["type"]) == "Relation" {
+			joinClassName := joinTableName(className, fieldName)
+			count, err = d.adapter().Count(joinClassName, relationSchema, types.M{})
+		} else {
+			query := types.M{fieldName: types.M{"$exists": true}}
+			count, err = d.adapter().Count(className, convertSchemaToAdapterSchema(schema), query)
+		}
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errs.E(errs.ClassNotEmpty, "Field "+fieldName+" of class "+className+" contains data in "+strconv.Itoa(count)+" objects, cannot delete.")
+		}
+	}
+	return nil
+}
+
+// diffSchema 对比类定义 definition 与数据库中的 schema existing
+// existing 为空时表示类不存在
+func diffSchema(className string, existing, definition types.M) (*SchemaMigration, error) {
+	if ClassNameIsValid(className) == false {
+		return nil, errs.E(errs.InvalidClassName, InvalidClassNameMessage(className))
+	}
+	migration := &SchemaMigration{
+		ClassName:     className,
+		AddedFields:   types.M{},
+		UpdatedFields: types.M{},
+		DeletedFields: []string{},
+		AddedIndexes:  types.M{},
+	}
+	definedFields := utils.M(definition["fields"])
+	if definedFields == nil {
+		definedFields = types.M{}
+	}
+	definedCLP := utils.M(definition["classLevelPermissions"])
+	definedIndexes := utils.M(definition["indexes"])
+
+	if len(existing) == 0 {
+		migration.CreateClass = true
+		for name, v := range definedFields {
+			if isDefaultField(className, name) == false {
+				migration.AddedFields[name] = v
+			}
+		}
+		migration.ClassLevelPermissions = definedCLP
+		for name, v := range definedIndexes {
+			migration.AddedIndexes[name] = v
+		}
+		return migration, nil
+	}
+
+	existingFields := utils.M(existing["fields"])
+	if existingFields == nil {
+		existingFields = types.M{}
+	}
+	for name, v := range definedFields {
+		if isDefaultField(className, name) {
+			continue
+		}
+		fieldType := utils.M(v)
+		if fieldType == nil {
+			return nil, errs.E(errs.InvalidJSON, "invalid JSON")
+		}
+		existingType := utils.M(existingFields[name])
+		if existingType == nil {
+			migration.AddedFields[name] = v
+			continue
+		}
+		if dbTypeMatchesObjectType(existingType, fieldType) == false {
+			return nil, errs.E(errs.IncorrectType, "schema mismatch for "+className+"."+name+"; expected "+typeToString(existingType)+" but got "+typeToString(fieldType))
+		}
+		// 类定义中删除了校验规则时，同样需要更新，清除已有的校验规则
+		if (hasFieldRules(fieldType) || hasFieldRules(existingType)) && sameDefinition(existingType, fieldType) == false {
+			migration.UpdatedFields[name] = v
+		}
+	}
+	for _, name := range sortedKeys(existingFields) {
+		if isDefaultField(className, name) == false && definedFields[name] == nil {
+			migration.DeletedFields = append(migration.DeletedFields, name)
+		}
+	}
+
+	if definedCLP != nil && sameDefinition(existing["classLevelPermissions"], definedCLP) == false {
+		migration.ClassLevelPermissions = definedCLP
+	}
+
+	if definedIndexes != nil {
+		existingIndexes := utils.M(existing["indexes"])
+		if existingIndexes == nil {
+			existingIndexes = types.M{}
+		}
+		fields := utils.M(injectDefaultSchema(types.M{"className": className, "fields": definedFields})["fields"])
+		for _, name := range sortedKeys(existingIndexes) {
+			v, ok := definedIndexes[name]
+			if ok == false {
+				migration.DeletedIndexes = append(migration.DeletedIndexes, name)
+				continue
+			}
+			index, err := buildIndex(name, v, fields)
+			if err != nil {
+				return nil, err
+			}
+			if sameDefinition(existingIndexes[name], index) == false {
+				migration.DeletedIndexes = append(migration.DeletedIndexes, name)
+				migration.AddedIndexes[name] = v
+			}
+		}
+		for name, v := range definedIndexes {
+			if existingIndexes[name] == nil {
+				migration.AddedIndexes[name] = v
+			}
+		}
+	}
+
+	return migration, nil
+}
+
+// isDefaultField 是否为类的默认字段，默认字段不可添加或删除
+func isDefaultField(className, fieldName string) bool {
+	if DefaultColumns["_Default"][fieldName] != nil {
+		return true
+	}
+	if columns := DefaultColumns[className]; columns != nil && columns[fieldName] != nil {
+		return true
+	}
+	return false
+}
+
+// sameDefinition 比较两个定义是否相同，数据库中读出的数组与数字类型可能与类定义中的不同，因此比较 JSON 编码后的结果
+func sameDefinition(a, b interface{}) bool {
+	aJSON, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bJSON, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(aJSON) == string(bJSON)
+}
+
+func sortedKeys(m types.M) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
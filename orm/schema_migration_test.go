@@ -0,0 +1,219 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/types"
+)
+
+func Test_ParseSchemaDefinitions(t *testing.T) {
+	var data string
+	var result []types.M
+	var err error
+	var expect []types.M
+	var expectErr error
+	/************************************************************/
+	data = `{"results":[{"className":"GameScore","fields":{"score":{"type":"Number"}}}]}`
+	result, err = ParseSchemaDefinitions([]byte(data))
+	expect = []types.M{
+		types.M{"className": "GameScore", "fields": map[string]interface{}{"score": map[string]interface{}{"type": "Number"}}},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	data = `[{"className":"GameScore"},{"className":"Player"}]`
+	result, err = ParseSchemaDefinitions([]byte(data))
+	expect = []types.M{
+		types.M{"className": "GameScore"},
+		types.M{"className": "Player"},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	data = `[{"className":"GameScore"},{"className":"GameScore"}]`
+	result, err = ParseSchemaDefinitions([]byte(data))
+	expectErr = errs.E(errs.InvalidClassName, "Class GameScore is defined more than once.")
+	if reflect.DeepEqual(expectErr, err) == false || result != nil {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	data = `[{"fields":{}}]`
+	result, err = ParseSchemaDefinitions([]byte(data))
+	expectErr = errs.E(errs.MissingRequiredFieldError, "schema definition needs a class name.")
+	if reflect.DeepEqual(expectErr, err) == false || result != nil {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	data = `{"className":"GameScore"}`
+	result, err = ParseSchemaDefinitions([]byte(data))
+	expectErr = errs.E(errs.InvalidJSON, "schema definitions should be an array or an object with results")
+	if reflect.DeepEqual(expectErr, err) == false || result != nil {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+}
+
+func Test_diffSchema(t *testing.T) {
+	var existing types.M
+	var definition types.M
+	var result *SchemaMigration
+	var err error
+	var expect *SchemaMigration
+	var expectErr error
+	/************************************************************/
+	existing = types.M{}
+	definition = types.M{
+		"className": "GameScore",
+		"fields": types.M{
+			"objectId": types.M{"type": "String"},
+			"score":    types.M{"type": "Number"},
+		},
+		"classLevelPermissions": types.M{"find": types.M{"*": true}},
+		"indexes":               types.M{"score": types.M{"fields": types.S{"score"}}},
+	}
+	result, err = diffSchema("GameScore", existing, definition)
+	expect = &SchemaMigration{
+		ClassName:             "GameScore",
+		CreateClass:           true,
+		AddedFields:           types.M{"score": types.M{"type": "Number"}},
+		UpdatedFields:         types.M{},
+		DeletedFields:         []string{},
+		ClassLevelPermissions: types.M{"find": types.M{"*": true}},
+		AddedIndexes:          types.M{"score": types.M{"fields": types.S{"score"}}},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	existing = types.M{
+		"className": "GameScore",
+		"fields": types.M{
+			"objectId":  types.M{"type": "String"},
+			"createdAt": types.M{"type": "Date"},
+			"updatedAt": types.M{"type": "Date"},
+			"ACL":       types.M{"type": "ACL"},
+			"score":     types.M{"type": "Number"},
+			"name":      types.M{"type": "String"},
+			"cheatMode": types.M{"type": "Boolean"},
+		},
+		"classLevelPermissions": types.M{"find": map[string]interface{}{"*": true}},
+		"indexes": types.M{
+			"name":  types.M{"fields": []interface{}{"name"}},
+			"score": types.M{"fields": []interface{}{"score"}},
+			"old":   types.M{"fields": []interface{}{"cheatMode"}},
+		},
+	}
+	definition = types.M{
+		"className": "GameScore",
+		"fields": types.M{
+			"score":  types.M{"type": "Number"},
+			"name":   types.M{"type": "String", "required": true},
+			"player": types.M{"type": "Pointer", "targetClass": "_User"},
+		},
+		"classLevelPermissions": types.M{"find": types.M{"*": true}},
+		"indexes": types.M{
+			"name":   types.M{"fields": types.S{"name"}},
+			"score":  types.M{"fields": types.S{"-score"}},
+			"player": types.M{"fields": types.S{"player"}},
+		},
+	}
+	result, err = diffSchema("GameScore", existing, definition)
+	expect = &SchemaMigration{
+		ClassName:     "GameScore",
+		AddedFields:   types.M{"player": types.M{"type": "Pointer", "targetClass": "_User"}},
+		UpdatedFields: types.M{"name": types.M{"type": "String", "required": true}},
+		DeletedFields: []string{"cheatMode"},
+		AddedIndexes: types.M{
+			"score":  types.M{"fields": types.S{"-score"}},
+			"player": types.M{"fields": types.S{"player"}},
+		},
+		DeletedIndexes: []string{"old", "score"},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	definition = types.M{
+		"className": "GameScore",
+		"fields": types.M{
+			"score":     types.M{"type": "Number"},
+			"name":      types.M{"type": "String"},
+			"cheatMode": types.M{"type": "Boolean"},
+		},
+	}
+	result, err = diffSchema("GameScore", existing, definition)
+	if err != nil || result.IsEmpty() == false {
+		t.Error("expect:", "empty migration", "result:", result, err)
+	}
+	/************************************************************/
+	// 类定义中删除了校验规则
+	existing["fields"].(types.M)["name"] = types.M{"type": "String", "required": true}
+	definition = types.M{
+		"className": "GameScore",
+		"fields": types.M{
+			"score":     types.M{"type": "Number"},
+			"name":      types.M{"type": "String"},
+			"cheatMode": types.M{"type": "Boolean"},
+		},
+	}
+	result, err = diffSchema("GameScore", existing, definition)
+	expect = &SchemaMigration{
+		ClassName:     "GameScore",
+		AddedFields:   types.M{},
+		UpdatedFields: types.M{"name": types.M{"type": "String"}},
+		DeletedFields: []string{},
+		AddedIndexes:  types.M{},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	if reflect.DeepEqual([]string{"~ GameScore.name: String (clear rules)"}, result.Diff()) == false {
+		t.Error("expect:", "~ GameScore.name: String (clear rules)", "result:", result.Diff())
+	}
+	existing["fields"].(types.M)["name"] = types.M{"type": "String"}
+	/************************************************************/
+	definition = types.M{
+		"className": "GameScore",
+		"fields": types.M{
+			"score": types.M{"type": "String"},
+		},
+	}
+	result, err = diffSchema("GameScore", existing, definition)
+	expectErr = errs.E(errs.IncorrectType, "schema mismatch for GameScore.score; expected Number but got String")
+	if reflect.DeepEqual(expectErr, err) == false || result != nil {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	result, err = diffSchema("@GameScore", types.M{}, types.M{})
+	expectErr = errs.E(errs.InvalidClassName, InvalidClassNameMessage("@GameScore"))
+	if reflect.DeepEqual(expectErr, err) == false || result != nil {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+}
+
+func Test_SchemaMigration_Diff(t *testing.T) {
+	migration := &SchemaMigration{
+		ClassName:             "GameScore",
+		AddedFields:           types.M{"player": types.M{"type": "Pointer", "targetClass": "_User"}},
+		UpdatedFields:         types.M{"name": types.M{"type": "String", "required": true}},
+		DeletedFields:         []string{"cheatMode"},
+		ClassLevelPermissions: types.M{},
+		AddedIndexes:          types.M{"player": types.M{"fields": types.S{"player"}}},
+		DeletedIndexes:        []string{"old"},
+	}
+	result := migration.Diff()
+	expect := []string{
+		"+ GameScore.player: Pointer<_User>",
+		"~ GameScore.name: String (rules)",
+		"- GameScore.cheatMode",
+		"~ GameScore classLevelPermissions",
+		"- GameScore index old",
+		"+ GameScore index player",
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
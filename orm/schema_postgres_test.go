@@ -30,7 +30,7 @@ func TestPostgres_AddClassIfNotExists(t *testing.T) {
 		"key": types.M{"type": "String"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.AddClassIfNotExists(className, fields, classLevelPermissions)
+	result, err = schama.AddClassIfNotExists(className, fields, classLevelPermissions, nil)
 	expect = types.M{
 		"className": className,
 		"fields": types.M{
@@ -66,7 +66,7 @@ func TestPostgres_AddClassIfNotExists(t *testing.T) {
 		"key": types.M{"type": "String"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.AddClassIfNotExists(className, fields, classLevelPermissions)
+	result, err = schama.AddClassIfNotExists(className, fields, classLevelPermissions, nil)
 	expect = errs.E(errs.InvalidClassName, "Class "+className+" already exists.")
 	if err == nil || reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", result, err)
@@ -88,7 +88,7 @@ func TestPostgres_UpdateClass(t *testing.T) {
 	className = "user"
 	submittedFields = nil
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = errs.E(errs.InvalidClassName, "Class "+className+" does not exist.")
 	if err == nil || reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", result, err)
@@ -107,7 +107,7 @@ func TestPostgres_UpdateClass(t *testing.T) {
 		"key": types.M{"type": "String"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = errs.E(errs.ClassNotEmpty, "Field key exists, cannot update.")
 	if err == nil || reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", result, err)
@@ -126,7 +126,7 @@ func TestPostgres_UpdateClass(t *testing.T) {
 		"key1": types.M{"__op": "Delete"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = errs.E(errs.ClassNotEmpty, "Field key1 does not exist, cannot delete.")
 	if err == nil || reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", result, err)
@@ -145,7 +145,7 @@ func TestPostgres_UpdateClass(t *testing.T) {
 		"key1": types.M{"type": "String"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = types.M{
 		"className": className,
 		"fields": types.M{
@@ -183,7 +183,7 @@ func TestPostgres_UpdateClass(t *testing.T) {
 		"key":  types.M{"__op": "Delete"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = types.M{
 		"className": className,
 		"fields": types.M{
@@ -222,7 +222,7 @@ func TestPostgres_UpdateClass(t *testing.T) {
 		"key2": types.M{"__op": "Delete"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = types.M{
 		"className": className,
 		"fields": types.M{
@@ -265,7 +265,7 @@ func TestPostgres_UpdateClass(t *testing.T) {
 		"update": types.M{"*": true},
 		"delete": types.M{"*": true},
 	}
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = types.M{
 		"className": className,
 		"fields": types.M{
@@ -1405,36 +1405,92 @@ func TestPostgres_reloadData(t *testing.T) {
 			"authData":      types.M{"type": "Object"},
 		},
 		"_PushStatus": types.M{
+			"objectId":        types.M{"type": "String"},
+			"updatedAt":       types.M{"type": "Date"},
+			"createdAt":       types.M{"type": "Date"},
+			"ACL":             types.M{"type": "ACL"},
+			"pushTime":        types.M{"type": "String"},
+			"source":          types.M{"type": "String"},
+			"query":           types.M{"type": "String"},
+			"payload":         types.M{"type": "String"},
+			"title":           types.M{"type": "String"},
+			"expiry":          types.M{"type": "Number"},
+			"status":          types.M{"type": "String"},
+			"numSent":         types.M{"type": "Number"},
+			"numFailed":       types.M{"type": "Number"},
+			"pushHash":        types.M{"type": "String"},
+			"errorMessage":    types.M{"type": "Object"},
+			"sentPerType":     types.M{"type": "Object"},
+			"failedPerType":   types.M{"type": "Object"},
+			"count":           types.M{"type": "Number"},
+			"localTimeCursor": types.M{"type": "String"},
+		},
+		"_JobStatus": types.M{
+			"objectId":    types.M{"type": "String"},
+			"updatedAt":   types.M{"type": "Date"},
+			"createdAt":   types.M{"type": "Date"},
+			"ACL":         types.M{"type": "ACL"},
+			"jobName":     types.M{"type": "String"},
+			"source":      types.M{"type": "String"},
+			"status":      types.M{"type": "String"},
+			"message":     types.M{"type": "String"},
+			"params":      types.M{"type": "Object"},
+			"finishedAt":  types.M{"type": "Date"},
+			"progress":    types.M{"type": "Number"},
+			"duration":    types.M{"type": "Number"},
+			"heartbeatAt": types.M{"type": "String"},
+			"deadlineAt":  types.M{"type": "String"},
+		},
+		"_JobSchedule": types.M{
 			"objectId":      types.M{"type": "String"},
 			"updatedAt":     types.M{"type": "Date"},
 			"createdAt":     types.M{"type": "Date"},
 			"ACL":           types.M{"type": "ACL"},
-			"pushTime":      types.M{"type": "String"},
-			"source":        types.M{"type": "String"},
-			"query":         types.M{"type": "String"},
-			"payload":       types.M{"type": "String"},
-			"title":         types.M{"type": "String"},
-			"expiry":        types.M{"type": "Number"},
-			"status":        types.M{"type": "String"},
-			"numSent":       types.M{"type": "Number"},
-			"numFailed":     types.M{"type": "Number"},
-			"pushHash":      types.M{"type": "String"},
-			"errorMessage":  types.M{"type": "Object"},
-			"sentPerType":   types.M{"type": "Object"},
-			"failedPerType": types.M{"type": "Object"},
-			"count":         types.M{"type": "Number"},
+			"jobName":       types.M{"type": "String"},
+			"description":   types.M{"type": "String"},
+			"params":        types.M{"type": "Object"},
+			"cron":          types.M{"type": "String"},
+			"repeatMinutes": types.M{"type": "Number"},
+			"startAfter":    types.M{"type": "String"},
+			"timeZone":      types.M{"type": "String"},
+			"nextRunAt":     types.M{"type": "String"},
+			"lastRunAt":     types.M{"type": "String"},
+		},
+		"_Audience": types.M{
+			"objectId":  types.M{"type": "String"},
+			"updatedAt": types.M{"type": "Date"},
+			"createdAt": types.M{"type": "Date"},
+			"ACL":       types.M{"type": "ACL"},
+			"name":      types.M{"type": "String"},
+			"query":     types.M{"type": "String"},
+			"lastUsed":  types.M{"type": "Date"},
+			"timesUsed": types.M{"type": "Number"},
 		},
-		"_JobStatus": types.M{
+		"_WebhookDelivery": types.M{
+			"objectId":     types.M{"type": "String"},
+			"updatedAt":    types.M{"type": "Date"},
+			"createdAt":    types.M{"type": "Date"},
+			"ACL":          types.M{"type": "ACL"},
+			"url":          types.M{"type": "String"},
+			"payload":      types.M{"type": "String"},
+			"timeout":      types.M{"type": "Number"},
+			"functionName": types.M{"type": "String"},
+			"className":    types.M{"type": "String"},
+			"triggerName":  types.M{"type": "String"},
+			"status":       types.M{"type": "String"},
+			"attempts":     types.M{"type": "Number"},
+			"error":        types.M{"type": "String"},
+		},
+		"_WebhookSubscription": types.M{
 			"objectId":   types.M{"type": "String"},
 			"updatedAt":  types.M{"type": "Date"},
 			"createdAt":  types.M{"type": "Date"},
 			"ACL":        types.M{"type": "ACL"},
-			"jobName":    types.M{"type": "String"},
-			"source":     types.M{"type": "String"},
-			"status":     types.M{"type": "String"},
-			"message":    types.M{"type": "String"},
-			"params":     types.M{"type": "Object"},
-			"finishedAt": types.M{"type": "Date"},
+			"url":        types.M{"type": "String"},
+			"classNames": types.M{"type": "Array"},
+			"events":     types.M{"type": "Array"},
+			"where":      types.M{"type": "String"},
+			"timeout":    types.M{"type": "Number"},
 		},
 		"_Hooks": types.M{
 			"objectId":     types.M{"type": "String"},
@@ -1445,6 +1501,7 @@ func TestPostgres_reloadData(t *testing.T) {
 			"className":    types.M{"type": "String"},
 			"triggerName":  types.M{"type": "String"},
 			"url":          types.M{"type": "String"},
+			"timeout":      types.M{"type": "Number"},
 		},
 		"_GlobalConfig": types.M{
 			"objectId":  types.M{"type": "String"},
@@ -1474,10 +1531,14 @@ func TestPostgres_reloadData(t *testing.T) {
 			"delete":   types.M{"*": true},
 			"addField": types.M{"*": true},
 		},
-		"_PushStatus":   types.M{},
-		"_JobStatus":    types.M{},
-		"_Hooks":        types.M{},
-		"_GlobalConfig": types.M{},
+		"_PushStatus":          types.M{},
+		"_JobStatus":           types.M{},
+		"_JobSchedule":         types.M{},
+		"_Hooks":               types.M{},
+		"_GlobalConfig":        types.M{},
+		"_Audience":            types.M{},
+		"_WebhookDelivery":     types.M{},
+		"_WebhookSubscription": types.M{},
 	}
 	if reflect.DeepEqual(expect, schama.perms) == false {
 		t.Error("expect:", expect, "result:", schama.perms)
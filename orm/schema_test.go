@@ -12,6 +12,7 @@ import (
 	"github.com/lfq7413/tomato/storage/mongo"
 	"github.com/lfq7413/tomato/test"
 	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
 )
 
 func Test_AddClassIfNotExists(t *testing.T) {
@@ -30,7 +31,7 @@ func Test_AddClassIfNotExists(t *testing.T) {
 		"key": types.M{"type": "String"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.AddClassIfNotExists(className, fields, classLevelPermissions)
+	result, err = schama.AddClassIfNotExists(className, fields, classLevelPermissions, nil)
 	expect = types.M{
 		"className": className,
 		"fields": types.M{
@@ -66,7 +67,7 @@ func Test_AddClassIfNotExists(t *testing.T) {
 		"key": types.M{"type": "String"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.AddClassIfNotExists(className, fields, classLevelPermissions)
+	result, err = schama.AddClassIfNotExists(className, fields, classLevelPermissions, nil)
 	expect = errs.E(errs.InvalidClassName, "Class "+className+" already exists.")
 	if err == nil || reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", result, err)
@@ -88,7 +89,7 @@ func Test_UpdateClass(t *testing.T) {
 	className = "user"
 	submittedFields = nil
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = errs.E(errs.InvalidClassName, "Class "+className+" does not exist.")
 	if err == nil || reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", result, err)
@@ -107,7 +108,7 @@ func Test_UpdateClass(t *testing.T) {
 		"key": types.M{"type": "String"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = errs.E(errs.ClassNotEmpty, "Field key exists, cannot update.")
 	if err == nil || reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", result, err)
@@ -126,7 +127,7 @@ func Test_UpdateClass(t *testing.T) {
 		"key1": types.M{"__op": "Delete"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = errs.E(errs.ClassNotEmpty, "Field key1 does not exist, cannot delete.")
 	if err == nil || reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", result, err)
@@ -145,7 +146,7 @@ func Test_UpdateClass(t *testing.T) {
 		"key1": types.M{"type": "String"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = types.M{
 		"className": className,
 		"fields": types.M{
@@ -183,7 +184,7 @@ func Test_UpdateClass(t *testing.T) {
 		"key":  types.M{"__op": "Delete"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = types.M{
 		"className": className,
 		"fields": types.M{
@@ -222,7 +223,7 @@ func Test_UpdateClass(t *testing.T) {
 		"key2": types.M{"__op": "Delete"},
 	}
 	classLevelPermissions = nil
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = types.M{
 		"className": className,
 		"fields": types.M{
@@ -265,7 +266,7 @@ func Test_UpdateClass(t *testing.T) {
 		"update": types.M{"*": true},
 		"delete": types.M{"*": true},
 	}
-	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions)
+	result, err = schama.UpdateClass(className, submittedFields, classLevelPermissions, nil)
 	expect = types.M{
 		"className": className,
 		"fields": types.M{
@@ -289,6 +290,30 @@ func Test_UpdateClass(t *testing.T) {
 	}
 	schama.data = nil
 	adapter.DeleteAllClasses()
+	/************************************************************/
+	class = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+	}
+	adapter.CreateClass(className, class)
+	className = "user"
+	submittedFields = types.M{
+		"key": types.M{"type": "String", "required": true},
+	}
+	result, err = schama.UpdateClass(className, submittedFields, nil, nil)
+	if err != nil || reflect.DeepEqual(types.M{"type": "String", "required": true}, utils.M(result["fields"])["key"]) == false {
+		t.Error("expect:", "required", "result:", result, err)
+	}
+	submittedFields = types.M{
+		"key": types.M{"type": "String"},
+	}
+	result, err = schama.UpdateClass(className, submittedFields, nil, nil)
+	if err != nil || reflect.DeepEqual(types.M{"type": "String"}, utils.M(result["fields"])["key"]) == false {
+		t.Error("expect:", types.M{"type": "String"}, "result:", result, err)
+	}
+	schama.data = nil
+	adapter.DeleteAllClasses()
 }
 
 func Test_deleteField(t *testing.T) {
@@ -1398,36 +1423,92 @@ func Test_reloadData(t *testing.T) {
 			"authData":      types.M{"type": "Object"},
 		},
 		"_PushStatus": types.M{
+			"objectId":        types.M{"type": "String"},
+			"updatedAt":       types.M{"type": "Date"},
+			"createdAt":       types.M{"type": "Date"},
+			"ACL":             types.M{"type": "ACL"},
+			"pushTime":        types.M{"type": "String"},
+			"source":          types.M{"type": "String"},
+			"query":           types.M{"type": "String"},
+			"payload":         types.M{"type": "String"},
+			"title":           types.M{"type": "String"},
+			"expiry":          types.M{"type": "Number"},
+			"status":          types.M{"type": "String"},
+			"numSent":         types.M{"type": "Number"},
+			"numFailed":       types.M{"type": "Number"},
+			"pushHash":        types.M{"type": "String"},
+			"errorMessage":    types.M{"type": "Object"},
+			"sentPerType":     types.M{"type": "Object"},
+			"failedPerType":   types.M{"type": "Object"},
+			"count":           types.M{"type": "Number"},
+			"localTimeCursor": types.M{"type": "String"},
+		},
+		"_JobStatus": types.M{
+			"objectId":    types.M{"type": "String"},
+			"updatedAt":   types.M{"type": "Date"},
+			"createdAt":   types.M{"type": "Date"},
+			"ACL":         types.M{"type": "ACL"},
+			"jobName":     types.M{"type": "String"},
+			"source":      types.M{"type": "String"},
+			"status":      types.M{"type": "String"},
+			"message":     types.M{"type": "String"},
+			"params":      types.M{"type": "Object"},
+			"finishedAt":  types.M{"type": "Date"},
+			"progress":    types.M{"type": "Number"},
+			"duration":    types.M{"type": "Number"},
+			"heartbeatAt": types.M{"type": "String"},
+			"deadlineAt":  types.M{"type": "String"},
+		},
+		"_JobSchedule": types.M{
 			"objectId":      types.M{"type": "String"},
 			"updatedAt":     types.M{"type": "Date"},
 			"createdAt":     types.M{"type": "Date"},
 			"ACL":           types.M{"type": "ACL"},
-			"pushTime":      types.M{"type": "String"},
-			"source":        types.M{"type": "String"},
-			"query":         types.M{"type": "String"},
-			"payload":       types.M{"type": "String"},
-			"title":         types.M{"type": "String"},
-			"expiry":        types.M{"type": "Number"},
-			"status":        types.M{"type": "String"},
-			"numSent":       types.M{"type": "Number"},
-			"numFailed":     types.M{"type": "Number"},
-			"pushHash":      types.M{"type": "String"},
-			"errorMessage":  types.M{"type": "Object"},
-			"sentPerType":   types.M{"type": "Object"},
-			"failedPerType": types.M{"type": "Object"},
-			"count":         types.M{"type": "Number"},
+			"jobName":       types.M{"type": "String"},
+			"description":   types.M{"type": "String"},
+			"params":        types.M{"type": "Object"},
+			"cron":          types.M{"type": "String"},
+			"repeatMinutes": types.M{"type": "Number"},
+			"startAfter":    types.M{"type": "String"},
+			"timeZone":      types.M{"type": "String"},
+			"nextRunAt":     types.M{"type": "String"},
+			"lastRunAt":     types.M{"type": "String"},
+		},
+		"_Audience": types.M{
+			"objectId":  types.M{"type": "String"},
+			"updatedAt": types.M{"type": "Date"},
+			"createdAt": types.M{"type": "Date"},
+			"ACL":       types.M{"type": "ACL"},
+			"name":      types.M{"type": "String"},
+			"query":     types.M{"type": "String"},
+			"lastUsed":  types.M{"type": "Date"},
+			"timesUsed": types.M{"type": "Number"},
 		},
-		"_JobStatus": types.M{
+		"_WebhookDelivery": types.M{
+			"objectId":     types.M{"type": "String"},
+			"updatedAt":    types.M{"type": "Date"},
+			"createdAt":    types.M{"type": "Date"},
+			"ACL":          types.M{"type": "ACL"},
+			"url":          types.M{"type": "String"},
+			"payload":      types.M{"type": "String"},
+			"timeout":      types.M{"type": "Number"},
+			"functionName": types.M{"type": "String"},
+			"className":    types.M{"type": "String"},
+			"triggerName":  types.M{"type": "String"},
+			"status":       types.M{"type": "String"},
+			"attempts":     types.M{"type": "Number"},
+			"error":        types.M{"type": "String"},
+		},
+		"_WebhookSubscription": types.M{
 			"objectId":   types.M{"type": "String"},
 			"updatedAt":  types.M{"type": "Date"},
 			"createdAt":  types.M{"type": "Date"},
 			"ACL":        types.M{"type": "ACL"},
-			"jobName":    types.M{"type": "String"},
-			"source":     types.M{"type": "String"},
-			"status":     types.M{"type": "String"},
-			"message":    types.M{"type": "String"},
-			"params":     types.M{"type": "Object"},
-			"finishedAt": types.M{"type": "Date"},
+			"url":        types.M{"type": "String"},
+			"classNames": types.M{"type": "Array"},
+			"events":     types.M{"type": "Array"},
+			"where":      types.M{"type": "String"},
+			"timeout":    types.M{"type": "Number"},
 		},
 		"_Hooks": types.M{
 			"objectId":     types.M{"type": "String"},
@@ -1438,6 +1519,7 @@ func Test_reloadData(t *testing.T) {
 			"className":    types.M{"type": "String"},
 			"triggerName":  types.M{"type": "String"},
 			"url":          types.M{"type": "String"},
+			"timeout":      types.M{"type": "Number"},
 		},
 		"_GlobalConfig": types.M{
 			"objectId":  types.M{"type": "String"},
@@ -1467,10 +1549,14 @@ func Test_reloadData(t *testing.T) {
 			"delete":   types.M{"*": true},
 			"addField": types.M{"*": true},
 		},
-		"_PushStatus":   types.M{},
-		"_JobStatus":    types.M{},
-		"_Hooks":        types.M{},
-		"_GlobalConfig": types.M{},
+		"_PushStatus":          types.M{},
+		"_JobStatus":           types.M{},
+		"_JobSchedule":         types.M{},
+		"_Hooks":               types.M{},
+		"_GlobalConfig":        types.M{},
+		"_Audience":            types.M{},
+		"_WebhookDelivery":     types.M{},
+		"_WebhookSubscription": types.M{},
 	}
 	if reflect.DeepEqual(expect, schama.perms) == false {
 		t.Error("expect:", expect, "result:", schama.perms)
@@ -2221,6 +2307,123 @@ func Test_fieldNameIsValid(t *testing.T) {
 	}
 }
 
+func Test_buildIndex(t *testing.T) {
+	var name string
+	var value interface{}
+	var fields types.M
+	var result types.M
+	var err error
+	var expect types.M
+	var expectErr error
+	fields = types.M{
+		"objectId":  types.M{"type": "String"},
+		"createdAt": types.M{"type": "Date"},
+		"updatedAt": types.M{"type": "Date"},
+		"ACL":       types.M{"type": "ACL"},
+		"name":      types.M{"type": "String"},
+		"score":     types.M{"type": "Number"},
+		"expiresAt": types.M{"type": "Date"},
+		"owner":     types.M{"type": "Pointer", "targetClass": "_User"},
+		"members":   types.M{"type": "Relation", "targetClass": "_User"},
+	}
+	/************************************************************/
+	name = "name_score"
+	value = types.M{"fields": types.S{"name", "-score", "owner"}, "unique": true, "sparse": false}
+	result, err = buildIndex(name, value, fields)
+	expect = types.M{"fields": types.S{"name", "-score", "owner"}, "unique": true}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	name = "name_text"
+	value = types.M{"fields": types.S{"$text:name"}}
+	result, err = buildIndex(name, value, fields)
+	expect = types.M{"fields": types.S{"$text:name"}}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	name = "ttl"
+	value = types.M{"fields": types.S{"expiresAt"}, "expireAfterSeconds": 3600.0, "sparse": true}
+	result, err = buildIndex(name, value, fields)
+	expect = types.M{"fields": types.S{"expiresAt"}, "expireAfterSeconds": 3600.0, "sparse": true}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	name = "1abc"
+	value = types.M{"fields": types.S{"name"}}
+	result, err = buildIndex(name, value, fields)
+	expectErr = errs.E(errs.InvalidQuery, "invalid index name: 1abc")
+	if result != nil || reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	name = "abc"
+	value = types.M{"fields": types.S{}}
+	result, err = buildIndex(name, value, fields)
+	expectErr = errs.E(errs.InvalidJSON, "fields of index abc should be a non-empty array")
+	if result != nil || reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	name = "abc"
+	value = types.M{"fields": types.S{"name"}, "background": true}
+	result, err = buildIndex(name, value, fields)
+	expectErr = errs.E(errs.InvalidJSON, "invalid option for index abc: background")
+	if result != nil || reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	name = "abc"
+	value = types.M{"fields": types.S{"other"}}
+	result, err = buildIndex(name, value, fields)
+	expectErr = errs.E(errs.InvalidQuery, "Field other does not exist, cannot add index.")
+	if result != nil || reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	name = "abc"
+	value = types.M{"fields": types.S{"members"}}
+	result, err = buildIndex(name, value, fields)
+	expectErr = errs.E(errs.InvalidQuery, "Field members is a Relation, cannot add index.")
+	if result != nil || reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	name = "abc"
+	value = types.M{"fields": types.S{"$text:score"}}
+	result, err = buildIndex(name, value, fields)
+	expectErr = errs.E(errs.InvalidQuery, "Field score is not a String, cannot add text index.")
+	if result != nil || reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	name = "abc"
+	value = types.M{"fields": types.S{"name"}, "unique": "true"}
+	result, err = buildIndex(name, value, fields)
+	expectErr = errs.E(errs.InvalidJSON, "unique of index abc should be a boolean")
+	if result != nil || reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	name = "abc"
+	value = types.M{"fields": types.S{"name"}, "expireAfterSeconds": 3600.0}
+	result, err = buildIndex(name, value, fields)
+	expectErr = errs.E(errs.InvalidQuery, "expireAfterSeconds of index abc can only be used on a single Date field")
+	if result != nil || reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/************************************************************/
+	name = "abc"
+	value = types.M{"fields": types.S{"expiresAt"}, "expireAfterSeconds": 0.5}
+	result, err = buildIndex(name, value, fields)
+	expectErr = errs.E(errs.InvalidJSON, "expireAfterSeconds of index abc should be a positive integer")
+	if result != nil || reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+}
+
 func Test_fieldNameIsValidForClass(t *testing.T) {
 	var fieldName string
 	var className string
@@ -2458,6 +2661,131 @@ func Test_fieldTypeIsInvalid(t *testing.T) {
 	}
 }
 
+func Test_validateFieldRules(t *testing.T) {
+	var fieldType types.M
+	var err error
+	var expect error
+	/************************************************************/
+	fieldType = types.M{"type": "Number", "required": true, "defaultValue": 1.0, "min": 0.0, "max": 10.0, "enum": types.S{1.0, 2.0}}
+	err = validateFieldRules("key", fieldType)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "String", "minLength": 1.0, "maxLength": 10.0, "pattern": "^a", "defaultValue": "abc"}
+	err = validateFieldRules("key", fieldType)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "String", "min": 1.0}
+	err = validateFieldRules("key", fieldType)
+	expect = errs.E(errs.IncorrectType, "min cannot be used on String field key")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "Number", "required": "true"}
+	err = validateFieldRules("key", fieldType)
+	expect = errs.E(errs.InvalidJSON, "required of field key should be a boolean")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "Number", "min": 10.0, "max": 1.0}
+	err = validateFieldRules("key", fieldType)
+	expect = errs.E(errs.InvalidJSON, "min of field key should not be greater than max")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "String", "maxLength": -1.0}
+	err = validateFieldRules("key", fieldType)
+	expect = errs.E(errs.InvalidJSON, "maxLength of field key should be a non-negative integer")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "String", "pattern": "("}
+	err = validateFieldRules("key", fieldType)
+	expect = errs.E(errs.InvalidJSON, "pattern of field key is not a valid regular expression")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "String", "enum": types.S{"a", 1.0}}
+	err = validateFieldRules("key", fieldType)
+	expect = errs.E(errs.IncorrectType, "enum of field key should only contain String values")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "String", "defaultValue": 1.0}
+	err = validateFieldRules("key", fieldType)
+	expect = errs.E(errs.IncorrectType, "schema mismatch for key default value; expected String but got Number")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "Number", "defaultValue": 11.0, "max": 10.0}
+	err = validateFieldRules("key", fieldType)
+	expect = errs.E(errs.ValidationError, "key should be less than or equal to 10.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "Relation", "targetClass": "_User", "defaultValue": types.M{"__type": "Relation", "className": "_User"}}
+	err = validateFieldRules("key", fieldType)
+	expect = errs.E(errs.IncorrectType, "Relation field key cannot have a defaultValue")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+}
+
+func Test_ValidateFieldValue(t *testing.T) {
+	var fieldType types.M
+	var value interface{}
+	var err error
+	var expect error
+	/************************************************************/
+	fieldType = types.M{"type": "Number", "min": 1.0, "max": 10.0}
+	value = types.M{"__op": "Increment", "amount": 100.0}
+	err = ValidateFieldValue("key", fieldType, value)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "Number", "min": 1.5}
+	value = 1.0
+	err = ValidateFieldValue("key", fieldType, value)
+	expect = errs.E(errs.ValidationError, "key should be greater than or equal to 1.5.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "String", "maxLength": 2.0}
+	value = "你好"
+	err = ValidateFieldValue("key", fieldType, value)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "Array", "maxLength": 2.0}
+	value = types.S{1, 2, 3}
+	err = ValidateFieldValue("key", fieldType, value)
+	expect = errs.E(errs.ValidationError, "key should have a length of at most 2.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	fieldType = types.M{"type": "Number", "enum": types.S{1.0, 2.0}}
+	value = 2
+	err = ValidateFieldValue("key", fieldType, value)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+}
+
 func Test_validateCLP(t *testing.T) {
 	var perms types.M
 	var fields types.M
@@ -2835,48 +3163,74 @@ func Test_volatileClassesSchemas(t *testing.T) {
 				"className":    types.M{"type": "String"},
 				"triggerName":  types.M{"type": "String"},
 				"url":          types.M{"type": "String"},
+				"timeout":      types.M{"type": "Number"},
 			},
 			"classLevelPermissions": types.M{},
 		},
 		types.M{
 			"className": "_JobStatus",
 			"fields": types.M{
-				"objectId":   types.M{"type": "String"},
-				"createdAt":  types.M{"type": "Date"},
-				"updatedAt":  types.M{"type": "Date"},
-				"_rperm":     types.M{"type": "Array"},
-				"_wperm":     types.M{"type": "Array"},
-				"jobName":    types.M{"type": "String"},
-				"source":     types.M{"type": "String"},
-				"status":     types.M{"type": "String"},
-				"message":    types.M{"type": "String"},
-				"params":     types.M{"type": "Object"},
-				"finishedAt": types.M{"type": "Date"},
+				"objectId":    types.M{"type": "String"},
+				"createdAt":   types.M{"type": "Date"},
+				"updatedAt":   types.M{"type": "Date"},
+				"_rperm":      types.M{"type": "Array"},
+				"_wperm":      types.M{"type": "Array"},
+				"jobName":     types.M{"type": "String"},
+				"source":      types.M{"type": "String"},
+				"status":      types.M{"type": "String"},
+				"message":     types.M{"type": "String"},
+				"params":      types.M{"type": "Object"},
+				"finishedAt":  types.M{"type": "Date"},
+				"progress":    types.M{"type": "Number"},
+				"duration":    types.M{"type": "Number"},
+				"heartbeatAt": types.M{"type": "String"},
+				"deadlineAt":  types.M{"type": "String"},
 			},
 			"classLevelPermissions": types.M{},
 		},
 		types.M{
-			"className": "_PushStatus",
+			"className": "_JobSchedule",
 			"fields": types.M{
 				"objectId":      types.M{"type": "String"},
 				"createdAt":     types.M{"type": "Date"},
 				"updatedAt":     types.M{"type": "Date"},
 				"_rperm":        types.M{"type": "Array"},
 				"_wperm":        types.M{"type": "Array"},
-				"pushTime":      types.M{"type": "String"},
-				"source":        types.M{"type": "String"},
-				"query":         types.M{"type": "String"},
-				"payload":       types.M{"type": "String"},
-				"title":         types.M{"type": "String"},
-				"expiry":        types.M{"type": "Number"},
-				"status":        types.M{"type": "String"},
-				"numSent":       types.M{"type": "Number"},
-				"numFailed":     types.M{"type": "Number"},
-				"pushHash":      types.M{"type": "String"},
-				"errorMessage":  types.M{"type": "Object"},
-				"sentPerType":   types.M{"type": "Object"},
-				"failedPerType": types.M{"type": "Object"},
-				"count":         types.M{"type": "Number"},
+				"jobName":       types.M{"type": "String"},
+				"description":   types.M{"type": "String"},
+				"params":        types.M{"type": "Object"},
+				"cron":          types.M{"type": "String"},
+				"repeatMinutes": types.M{"type": "Number"},
+				"startAfter":    types.M{"type": "String"},
+				"timeZone":      types.M{"type": "String"},
+				"nextRunAt":     types.M{"type": "String"},
+				"lastRunAt":     types.M{"type": "String"},
+			},
+			"classLevelPermissions": types.M{},
+		},
+		types.M{
+			"className": "_PushStatus",
+			"fields": types.M{
+				"objectId":        types.M{"type": "String"},
+				"createdAt":       types.M{"type": "Date"},
+				"updatedAt":       types.M{"type": "Date"},
+				"_rperm":          types.M{"type": "Array"},
+				"_wperm":          types.M{"type": "Array"},
+				"pushTime":        types.M{"type": "String"},
+				"source":          types.M{"type": "String"},
+				"query":           types.M{"type": "String"},
+				"payload":         types.M{"type": "String"},
+				"title":           types.M{"type": "String"},
+				"expiry":          types.M{"type": "Number"},
+				"status":          types.M{"type": "String"},
+				"numSent":         types.M{"type": "Number"},
+				"numFailed":       types.M{"type": "Number"},
+				"pushHash":        types.M{"type": "String"},
+				"errorMessage":    types.M{"type": "Object"},
+				"sentPerType":     types.M{"type": "Object"},
+				"failedPerType":   types.M{"type": "Object"},
+				"count":           types.M{"type": "Number"},
+				"localTimeCursor": types.M{"type": "String"},
 			},
 			"classLevelPermissions": types.M{},
 		},
@@ -2888,6 +3242,57 @@ func Test_volatileClassesSchemas(t *testing.T) {
 			},
 			"classLevelPermissions": types.M{},
 		},
+		types.M{
+			"className": "_Audience",
+			"fields": types.M{
+				"objectId":  types.M{"type": "String"},
+				"createdAt": types.M{"type": "Date"},
+				"updatedAt": types.M{"type": "Date"},
+				"_rperm":    types.M{"type": "Array"},
+				"_wperm":    types.M{"type": "Array"},
+				"name":      types.M{"type": "String"},
+				"query":     types.M{"type": "String"},
+				"lastUsed":  types.M{"type": "Date"},
+				"timesUsed": types.M{"type": "Number"},
+			},
+			"classLevelPermissions": types.M{},
+		},
+		types.M{
+			"className": "_WebhookDelivery",
+			"fields": types.M{
+				"objectId":     types.M{"type": "String"},
+				"createdAt":    types.M{"type": "Date"},
+				"updatedAt":    types.M{"type": "Date"},
+				"_rperm":       types.M{"type": "Array"},
+				"_wperm":       types.M{"type": "Array"},
+				"url":          types.M{"type": "String"},
+				"payload":      types.M{"type": "String"},
+				"timeout":      types.M{"type": "Number"},
+				"functionName": types.M{"type": "String"},
+				"className":    types.M{"type": "String"},
+				"triggerName":  types.M{"type": "String"},
+				"status":       types.M{"type": "String"},
+				"attempts":     types.M{"type": "Number"},
+				"error":        types.M{"type": "String"},
+			},
+			"classLevelPermissions": types.M{},
+		},
+		types.M{
+			"className": "_WebhookSubscription",
+			"fields": types.M{
+				"objectId":   types.M{"type": "String"},
+				"createdAt":  types.M{"type": "Date"},
+				"updatedAt":  types.M{"type": "Date"},
+				"_rperm":     types.M{"type": "Array"},
+				"_wperm":     types.M{"type": "Array"},
+				"url":        types.M{"type": "String"},
+				"classNames": types.M{"type": "Array"},
+				"events":     types.M{"type": "Array"},
+				"where":      types.M{"type": "String"},
+				"timeout":    types.M{"type": "Number"},
+			},
+			"classLevelPermissions": types.M{},
+		},
 	}
 	if reflect.DeepEqual(expect, result) == false {
 		t.Error("expect:", expect, "result:", result)
@@ -3233,36 +3638,92 @@ func Test_Load(t *testing.T) {
 	schama = Load(adapter, schemaCache, nil)
 	expectData = types.M{
 		"_PushStatus": types.M{
+			"objectId":        types.M{"type": "String"},
+			"updatedAt":       types.M{"type": "Date"},
+			"createdAt":       types.M{"type": "Date"},
+			"ACL":             types.M{"type": "ACL"},
+			"pushTime":        types.M{"type": "String"},
+			"source":          types.M{"type": "String"},
+			"query":           types.M{"type": "String"},
+			"payload":         types.M{"type": "String"},
+			"title":           types.M{"type": "String"},
+			"expiry":          types.M{"type": "Number"},
+			"status":          types.M{"type": "String"},
+			"numSent":         types.M{"type": "Number"},
+			"numFailed":       types.M{"type": "Number"},
+			"pushHash":        types.M{"type": "String"},
+			"errorMessage":    types.M{"type": "Object"},
+			"sentPerType":     types.M{"type": "Object"},
+			"failedPerType":   types.M{"type": "Object"},
+			"count":           types.M{"type": "Number"},
+			"localTimeCursor": types.M{"type": "String"},
+		},
+		"_JobStatus": types.M{
+			"objectId":    types.M{"type": "String"},
+			"updatedAt":   types.M{"type": "Date"},
+			"createdAt":   types.M{"type": "Date"},
+			"ACL":         types.M{"type": "ACL"},
+			"jobName":     types.M{"type": "String"},
+			"source":      types.M{"type": "String"},
+			"status":      types.M{"type": "String"},
+			"message":     types.M{"type": "String"},
+			"params":      types.M{"type": "Object"},
+			"finishedAt":  types.M{"type": "Date"},
+			"progress":    types.M{"type": "Number"},
+			"duration":    types.M{"type": "Number"},
+			"heartbeatAt": types.M{"type": "String"},
+			"deadlineAt":  types.M{"type": "String"},
+		},
+		"_JobSchedule": types.M{
 			"objectId":      types.M{"type": "String"},
 			"updatedAt":     types.M{"type": "Date"},
 			"createdAt":     types.M{"type": "Date"},
 			"ACL":           types.M{"type": "ACL"},
-			"pushTime":      types.M{"type": "String"},
-			"source":        types.M{"type": "String"},
-			"query":         types.M{"type": "String"},
-			"payload":       types.M{"type": "String"},
-			"title":         types.M{"type": "String"},
-			"expiry":        types.M{"type": "Number"},
-			"status":        types.M{"type": "String"},
-			"numSent":       types.M{"type": "Number"},
-			"numFailed":     types.M{"type": "Number"},
-			"pushHash":      types.M{"type": "String"},
-			"errorMessage":  types.M{"type": "Object"},
-			"sentPerType":   types.M{"type": "Object"},
-			"failedPerType": types.M{"type": "Object"},
-			"count":         types.M{"type": "Number"},
+			"jobName":       types.M{"type": "String"},
+			"description":   types.M{"type": "String"},
+			"params":        types.M{"type": "Object"},
+			"cron":          types.M{"type": "String"},
+			"repeatMinutes": types.M{"type": "Number"},
+			"startAfter":    types.M{"type": "String"},
+			"timeZone":      types.M{"type": "String"},
+			"nextRunAt":     types.M{"type": "String"},
+			"lastRunAt":     types.M{"type": "String"},
+		},
+		"_Audience": types.M{
+			"objectId":  types.M{"type": "String"},
+			"updatedAt": types.M{"type": "Date"},
+			"createdAt": types.M{"type": "Date"},
+			"ACL":       types.M{"type": "ACL"},
+			"name":      types.M{"type": "String"},
+			"query":     types.M{"type": "String"},
+			"lastUsed":  types.M{"type": "Date"},
+			"timesUsed": types.M{"type": "Number"},
 		},
-		"_JobStatus": types.M{
+		"_WebhookDelivery": types.M{
+			"objectId":     types.M{"type": "String"},
+			"updatedAt":    types.M{"type": "Date"},
+			"createdAt":    types.M{"type": "Date"},
+			"ACL":          types.M{"type": "ACL"},
+			"url":          types.M{"type": "String"},
+			"payload":      types.M{"type": "String"},
+			"timeout":      types.M{"type": "Number"},
+			"functionName": types.M{"type": "String"},
+			"className":    types.M{"type": "String"},
+			"triggerName":  types.M{"type": "String"},
+			"status":       types.M{"type": "String"},
+			"attempts":     types.M{"type": "Number"},
+			"error":        types.M{"type": "String"},
+		},
+		"_WebhookSubscription": types.M{
 			"objectId":   types.M{"type": "String"},
 			"updatedAt":  types.M{"type": "Date"},
 			"createdAt":  types.M{"type": "Date"},
 			"ACL":        types.M{"type": "ACL"},
-			"jobName":    types.M{"type": "String"},
-			"source":     types.M{"type": "String"},
-			"status":     types.M{"type": "String"},
-			"message":    types.M{"type": "String"},
-			"params":     types.M{"type": "Object"},
-			"finishedAt": types.M{"type": "Date"},
+			"url":        types.M{"type": "String"},
+			"classNames": types.M{"type": "Array"},
+			"events":     types.M{"type": "Array"},
+			"where":      types.M{"type": "String"},
+			"timeout":    types.M{"type": "Number"},
 		},
 		"_Hooks": types.M{
 			"objectId":     types.M{"type": "String"},
@@ -3273,6 +3734,7 @@ func Test_Load(t *testing.T) {
 			"className":    types.M{"type": "String"},
 			"triggerName":  types.M{"type": "String"},
 			"url":          types.M{"type": "String"},
+			"timeout":      types.M{"type": "Number"},
 		},
 		"_GlobalConfig": types.M{
 			"objectId":  types.M{"type": "String"},
@@ -3283,10 +3745,14 @@ func Test_Load(t *testing.T) {
 		},
 	}
 	expectPerms = types.M{
-		"_PushStatus":   types.M{},
-		"_JobStatus":    types.M{},
-		"_Hooks":        types.M{},
-		"_GlobalConfig": types.M{},
+		"_PushStatus":          types.M{},
+		"_JobStatus":           types.M{},
+		"_JobSchedule":         types.M{},
+		"_Hooks":               types.M{},
+		"_GlobalConfig":        types.M{},
+		"_Audience":            types.M{},
+		"_WebhookDelivery":     types.M{},
+		"_WebhookSubscription": types.M{},
 	}
 	if reflect.DeepEqual(expectData, schama.data) == false {
 		t.Error("expect:", expectData, "result:", schama.data)
@@ -3312,36 +3778,92 @@ func Test_Load(t *testing.T) {
 			"ACL":       types.M{"type": "ACL"},
 		},
 		"_PushStatus": types.M{
+			"objectId":        types.M{"type": "String"},
+			"updatedAt":       types.M{"type": "Date"},
+			"createdAt":       types.M{"type": "Date"},
+			"ACL":             types.M{"type": "ACL"},
+			"pushTime":        types.M{"type": "String"},
+			"source":          types.M{"type": "String"},
+			"query":           types.M{"type": "String"},
+			"payload":         types.M{"type": "String"},
+			"title":           types.M{"type": "String"},
+			"expiry":          types.M{"type": "Number"},
+			"status":          types.M{"type": "String"},
+			"numSent":         types.M{"type": "Number"},
+			"numFailed":       types.M{"type": "Number"},
+			"pushHash":        types.M{"type": "String"},
+			"errorMessage":    types.M{"type": "Object"},
+			"sentPerType":     types.M{"type": "Object"},
+			"failedPerType":   types.M{"type": "Object"},
+			"count":           types.M{"type": "Number"},
+			"localTimeCursor": types.M{"type": "String"},
+		},
+		"_JobStatus": types.M{
+			"objectId":    types.M{"type": "String"},
+			"updatedAt":   types.M{"type": "Date"},
+			"createdAt":   types.M{"type": "Date"},
+			"ACL":         types.M{"type": "ACL"},
+			"jobName":     types.M{"type": "String"},
+			"source":      types.M{"type": "String"},
+			"status":      types.M{"type": "String"},
+			"message":     types.M{"type": "String"},
+			"params":      types.M{"type": "Object"},
+			"finishedAt":  types.M{"type": "Date"},
+			"progress":    types.M{"type": "Number"},
+			"duration":    types.M{"type": "Number"},
+			"heartbeatAt": types.M{"type": "String"},
+			"deadlineAt":  types.M{"type": "String"},
+		},
+		"_JobSchedule": types.M{
 			"objectId":      types.M{"type": "String"},
 			"updatedAt":     types.M{"type": "Date"},
 			"createdAt":     types.M{"type": "Date"},
 			"ACL":           types.M{"type": "ACL"},
-			"pushTime":      types.M{"type": "String"},
-			"source":        types.M{"type": "String"},
-			"query":         types.M{"type": "String"},
-			"payload":       types.M{"type": "String"},
-			"title":         types.M{"type": "String"},
-			"expiry":        types.M{"type": "Number"},
-			"status":        types.M{"type": "String"},
-			"numSent":       types.M{"type": "Number"},
-			"numFailed":     types.M{"type": "Number"},
-			"pushHash":      types.M{"type": "String"},
-			"errorMessage":  types.M{"type": "Object"},
-			"sentPerType":   types.M{"type": "Object"},
-			"failedPerType": types.M{"type": "Object"},
-			"count":         types.M{"type": "Number"},
+			"jobName":       types.M{"type": "String"},
+			"description":   types.M{"type": "String"},
+			"params":        types.M{"type": "Object"},
+			"cron":          types.M{"type": "String"},
+			"repeatMinutes": types.M{"type": "Number"},
+			"startAfter":    types.M{"type": "String"},
+			"timeZone":      types.M{"type": "String"},
+			"nextRunAt":     types.M{"type": "String"},
+			"lastRunAt":     types.M{"type": "String"},
+		},
+		"_Audience": types.M{
+			"objectId":  types.M{"type": "String"},
+			"updatedAt": types.M{"type": "Date"},
+			"createdAt": types.M{"type": "Date"},
+			"ACL":       types.M{"type": "ACL"},
+			"name":      types.M{"type": "String"},
+			"query":     types.M{"type": "String"},
+			"lastUsed":  types.M{"type": "Date"},
+			"timesUsed": types.M{"type": "Number"},
 		},
-		"_JobStatus": types.M{
+		"_WebhookDelivery": types.M{
+			"objectId":     types.M{"type": "String"},
+			"updatedAt":    types.M{"type": "Date"},
+			"createdAt":    types.M{"type": "Date"},
+			"ACL":          types.M{"type": "ACL"},
+			"url":          types.M{"type": "String"},
+			"payload":      types.M{"type": "String"},
+			"timeout":      types.M{"type": "Number"},
+			"functionName": types.M{"type": "String"},
+			"className":    types.M{"type": "String"},
+			"triggerName":  types.M{"type": "String"},
+			"status":       types.M{"type": "String"},
+			"attempts":     types.M{"type": "Number"},
+			"error":        types.M{"type": "String"},
+		},
+		"_WebhookSubscription": types.M{
 			"objectId":   types.M{"type": "String"},
 			"updatedAt":  types.M{"type": "Date"},
 			"createdAt":  types.M{"type": "Date"},
 			"ACL":        types.M{"type": "ACL"},
-			"jobName":    types.M{"type": "String"},
-			"source":     types.M{"type": "String"},
-			"status":     types.M{"type": "String"},
-			"message":    types.M{"type": "String"},
-			"params":     types.M{"type": "Object"},
-			"finishedAt": types.M{"type": "Date"},
+			"url":        types.M{"type": "String"},
+			"classNames": types.M{"type": "Array"},
+			"events":     types.M{"type": "Array"},
+			"where":      types.M{"type": "String"},
+			"timeout":    types.M{"type": "Number"},
 		},
 		"_Hooks": types.M{
 			"objectId":     types.M{"type": "String"},
@@ -3352,6 +3874,7 @@ func Test_Load(t *testing.T) {
 			"className":    types.M{"type": "String"},
 			"triggerName":  types.M{"type": "String"},
 			"url":          types.M{"type": "String"},
+			"timeout":      types.M{"type": "Number"},
 		},
 		"_GlobalConfig": types.M{
 			"objectId":  types.M{"type": "String"},
@@ -3370,10 +3893,14 @@ func Test_Load(t *testing.T) {
 			"delete":   types.M{"*": true},
 			"addField": types.M{"*": true},
 		},
-		"_PushStatus":   types.M{},
-		"_JobStatus":    types.M{},
-		"_Hooks":        types.M{},
-		"_GlobalConfig": types.M{},
+		"_PushStatus":          types.M{},
+		"_JobStatus":           types.M{},
+		"_JobSchedule":         types.M{},
+		"_Hooks":               types.M{},
+		"_GlobalConfig":        types.M{},
+		"_Audience":            types.M{},
+		"_WebhookDelivery":     types.M{},
+		"_WebhookSubscription": types.M{},
 	}
 	if reflect.DeepEqual(expectData, schama.data) == false {
 		t.Error("expect:", expectData, "result:", schama.data)
@@ -37,38 +37,30 @@ func newPushQueue(channel string, batchSize int) *pushQueue {
 }
 
 func (q *pushQueue) enqueue(body, where types.M, auth *rest.Auth, status *pushStatus) error {
-	limit := q.batchSize
-	order := ""
-	if isPushIncrementing(body) {
-		order = "badge,createdAt"
-	} else {
-		order = "createdAt"
-	}
-
-	where = utils.CopyMapM(where)
-	if _, ok := where["deviceToken"]; !ok {
-		where["deviceToken"] = types.M{"$exists": true}
-	}
-
-	options := types.M{
-		"limit": 0,
-		"count": true,
-	}
-	result, err := rest.Find(auth, "_Installation", where, options, nil)
+	where = installationsWhere(where)
+	count, err := countInstallations(auth, where)
 	if err != nil {
 		return err
 	}
 
-	count := 0
-	if c, ok := result["count"].(int); ok {
-		count = c
-	}
-
 	if count == 0 {
 		return errors.New("PushController: no results in query")
 	}
 	status.setRunning(count)
 
+	return q.publish(body, where, count, status)
+}
+
+// publish 把符合条件的设备按 batchSize 分批，发送到推送通道
+func (q *pushQueue) publish(body, where types.M, count int, status *pushStatus) error {
+	limit := q.batchSize
+	order := ""
+	if isPushIncrementing(body) {
+		order = "badge,createdAt"
+	} else {
+		order = "createdAt"
+	}
+
 	for skip := 0; skip < count; skip += limit {
 		query := types.M{
 			"where": where,
@@ -90,3 +82,30 @@ func (q *pushQueue) enqueue(body, where types.M, auth *rest.Auth, status *pushSt
 
 	return nil
 }
+
+// installationsWhere 仅推送给设置了 deviceToken 的设备
+func installationsWhere(where types.M) types.M {
+	where = utils.CopyMapM(where)
+	if _, ok := where["deviceToken"]; !ok {
+		where["deviceToken"] = types.M{"$exists": true}
+	}
+	return where
+}
+
+// countInstallations 统计符合条件的设备数量
+func countInstallations(auth *rest.Auth, where types.M) (int, error) {
+	options := types.M{
+		"limit": 0,
+		"count": true,
+	}
+	result, err := rest.Find(auth, "_Installation", where, options, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	if c, ok := result["count"].(int); ok {
+		count = c
+	}
+	return count, nil
+}
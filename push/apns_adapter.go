@@ -0,0 +1,375 @@
+package push
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lfq7413/tomato/config"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+const (
+	apnsProductionHost  = "https://api.push.apple.com"
+	apnsDevelopmentHost = "https://api.sandbox.push.apple.com"
+	// apnsTokenLifetime APNs 拒绝签发超过 1 小时的 token ，且不允许 20 分钟内频繁更新
+	apnsTokenLifetime = 50 * time.Minute
+	// apnsConcurrency 同时发送的请求数，请求复用同一个 HTTP/2 连接
+	apnsConcurrency = 20
+)
+
+// apnsPushAdapter 通过 HTTP/2 接口直接向 APNs 发送推送
+// 支持 .p8 私钥的 token 鉴权，以及证书鉴权
+type apnsPushAdapter struct {
+	validPushTypes []string
+	host           string
+	topic          string
+	client         *http.Client
+	signer         *apnsTokenSigner
+}
+
+func newAPNsPush() (*apnsPushAdapter, error) {
+	a := &apnsPushAdapter{
+		validPushTypes: []string{"ios", "osx", "tvos"},
+		host:           apnsDevelopmentHost,
+		topic:          config.TConfig.APNsTopic,
+	}
+	if config.TConfig.APNsProduction {
+		a.host = apnsProductionHost
+	}
+
+	transport := &http.Transport{
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{},
+	}
+	if config.TConfig.APNsKeyFile != "" {
+		key, err := ioutil.ReadFile(config.TConfig.APNsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		a.signer, err = newAPNsTokenSigner(key, config.TConfig.APNsKeyID, config.TConfig.APNsTeamID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cert, err := tls.LoadX509KeyPair(config.TConfig.APNsCertFile, config.TConfig.APNsCertKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	a.client = &http.Client{
+		Transport: transport,
+		Timeout:   30 * time.Second,
+	}
+	return a, nil
+}
+
+func (a *apnsPushAdapter) send(body types.M, installations types.S, pushStatus string) []types.M {
+	deviceMap := classifyInstallations(installations, a.validPushTypes)
+	devices := []types.M{}
+	for _, pushType := range a.validPushTypes {
+		devices = append(devices, deviceMap[pushType]...)
+	}
+
+	results := make([]types.M, len(devices))
+	payload, err := json.Marshal(apnsPayload(body))
+	if err != nil {
+		for i, device := range devices {
+			results[i] = types.M{
+				"device":      device,
+				"transmitted": false,
+				"response":    map[string]string{"error": err.Error()},
+			}
+		}
+		return results
+	}
+	header := apnsHeader(body)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, apnsConcurrency)
+	for i, device := range devices {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, device types.M) {
+			defer wg.Done()
+			results[i] = a.sendToDevice(device, payload, header)
+			<-sem
+		}(i, device)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (a *apnsPushAdapter) getValidPushTypes() []string {
+	return a.validPushTypes
+}
+
+// sendToDevice 向单个设备发送推送，返回发送结果
+// 发送失败时 response 中的 error 为 APNs 返回的错误原因，例如 BadDeviceToken 、 Unregistered
+func (a *apnsPushAdapter) sendToDevice(device types.M, payload []byte, header http.Header) types.M {
+	result := types.M{
+		"device":      device,
+		"transmitted": false,
+	}
+
+	req, err := http.NewRequest("POST", a.host+"/3/device/"+utils.S(device["deviceToken"]), bytes.NewReader(payload))
+	if err != nil {
+		result["response"] = map[string]string{"error": err.Error()}
+		return result
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if req.Header.Get("apns-topic") == "" {
+		// 优先使用设备的 appIdentifier ，证书鉴权时 topic 可以省略
+		if topic := utils.S(device["appIdentifier"]); topic != "" {
+			req.Header.Set("apns-topic", topic)
+		} else if a.topic != "" {
+			req.Header.Set("apns-topic", a.topic)
+		}
+	}
+	if a.signer != nil {
+		token, err := a.signer.token()
+		if err != nil {
+			result["response"] = map[string]string{"error": err.Error()}
+			return result
+		}
+		req.Header.Set("authorization", "bearer "+token)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		result["response"] = map[string]string{"error": err.Error()}
+		return result
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		result["transmitted"] = true
+		result["response"] = map[string]string{"id": resp.Header.Get("apns-id")}
+		return result
+	}
+
+	var apnsError struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(resp.Body).Decode(&apnsError)
+	if apnsError.Reason == "" {
+		apnsError.Reason = http.StatusText(resp.StatusCode)
+	}
+	if apnsError.Reason == "ExpiredProviderToken" && a.signer != nil {
+		a.signer.expire()
+	}
+	result["response"] = map[string]string{
+		"error":  apnsError.Reason,
+		"status": strconv.Itoa(resp.StatusCode),
+	}
+	return result
+}
+
+// apnsPayload 把推送消息转换为 APNs 的消息格式
+// data 中的 alert 、 title 、 badge 、 sound 、 content-available 、 mutable-content 、
+// category 、 threadId 放入 aps 中，其他字段作为自定义字段
+func apnsPayload(body types.M) types.M {
+	data := utils.M(body["data"])
+	if data == nil {
+		data = types.M{}
+	}
+	payload := types.M{}
+	aps := types.M{}
+	var alert, title interface{}
+	for key, v := range data {
+		switch key {
+		case "alert":
+			alert = v
+		case "title":
+			title = v
+		case "badge":
+			if _, ok := v.(string); ok == false {
+				aps["badge"] = v
+			}
+		case "sound":
+			aps["sound"] = v
+		case "content-available":
+			if isTrue(v) {
+				aps["content-available"] = 1
+			}
+		case "mutable-content":
+			if isTrue(v) {
+				aps["mutable-content"] = 1
+			}
+		case "category":
+			aps["category"] = v
+		case "threadId", "thread-id":
+			aps["thread-id"] = v
+		default:
+			payload[key] = v
+		}
+	}
+	if title != nil {
+		a := types.M{"title": title}
+		if alert != nil {
+			a["body"] = alert
+		}
+		aps["alert"] = a
+	} else if alert != nil {
+		aps["alert"] = alert
+	}
+	payload["aps"] = aps
+	return payload
+}
+
+// apnsHeader 根据推送消息生成请求头
+// topic 、 push_type 、 priority 、 collapse_id 可以在推送消息中指定，
+// expiration_time 转换为 apns-expiration
+func apnsHeader(body types.M) http.Header {
+	header := http.Header{}
+	header.Set("content-type", "application/json")
+
+	aps := utils.M(apnsPayload(body)["aps"])
+	background := aps["content-available"] != nil && aps["alert"] == nil && aps["badge"] == nil && aps["sound"] == nil
+
+	if topic := utils.S(body["topic"]); topic != "" {
+		header.Set("apns-topic", topic)
+	}
+
+	if pushType := utils.S(body["push_type"]); pushType != "" {
+		header.Set("apns-push-type", pushType)
+	} else if background {
+		header.Set("apns-push-type", "background")
+	} else {
+		header.Set("apns-push-type", "alert")
+	}
+
+	// 静默推送只能使用低优先级
+	if priority, ok := toInt64(body["priority"]); ok {
+		header.Set("apns-priority", strconv.FormatInt(priority, 10))
+	} else if background {
+		header.Set("apns-priority", "5")
+	} else {
+		header.Set("apns-priority", "10")
+	}
+
+	// expiration_time 在 SendPush 中已转换为毫秒
+	if expiration, ok := toInt64(body["expiration_time"]); ok {
+		header.Set("apns-expiration", strconv.FormatInt(expiration/1000, 10))
+	}
+
+	if collapseID := utils.S(body["collapse_id"]); collapseID != "" {
+		header.Set("apns-collapse-id", collapseID)
+	}
+
+	return header
+}
+
+func isTrue(v interface{}) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case string:
+		return t == "1" || t == "true"
+	}
+	i, ok := toInt64(v)
+	return ok && i != 0
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return int64(t), true
+	case int:
+		return int64(t), true
+	case int64:
+		return t, true
+	}
+	return 0, false
+}
+
+// apnsTokenSigner 使用 .p8 私钥签发 APNs 鉴权使用的 JWT
+// 签发的 token 在 apnsTokenLifetime 内重复使用
+type apnsTokenSigner struct {
+	mu       sync.Mutex
+	key      *ecdsa.PrivateKey
+	keyID    string
+	teamID   string
+	bearer   string
+	issuedAt time.Time
+}
+
+func newAPNsTokenSigner(keyPEM []byte, keyID, teamID string) (*apnsTokenSigner, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("APNs key is not a valid PEM file")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+	if ok == false {
+		return nil, errors.New("APNs key must be an ECDSA private key")
+	}
+	s := &apnsTokenSigner{
+		key:    ecdsaKey,
+		keyID:  keyID,
+		teamID: teamID,
+	}
+	return s, nil
+}
+
+// token 返回有效的 token ，过期时重新签发
+func (s *apnsTokenSigner) token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.bearer != "" && now.Sub(s.issuedAt) < apnsTokenLifetime {
+		return s.bearer, nil
+	}
+
+	header, _ := json.Marshal(types.M{"alg": "ES256", "kid": s.keyID})
+	claims, _ := json.Marshal(types.M{"iss": s.teamID, "iat": now.Unix()})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	r, ss, err := ecdsa.Sign(rand.Reader, s.key, hash[:])
+	if err != nil {
+		return "", err
+	}
+	// ES256 的签名为定长的 r 与 s 拼接
+	signature := make([]byte, 64)
+	copyPadded(signature[:32], r)
+	copyPadded(signature[32:], ss)
+
+	s.bearer = unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	s.issuedAt = now
+	return s.bearer, nil
+}
+
+// expire 使当前 token 失效，下次发送时重新签发
+func (s *apnsTokenSigner) expire() {
+	s.mu.Lock()
+	s.bearer = ""
+	s.mu.Unlock()
+}
+
+func copyPadded(dst []byte, n *big.Int) {
+	b := n.Bytes()
+	copy(dst[len(dst)-len(b):], b)
+}
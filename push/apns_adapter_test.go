@@ -0,0 +1,260 @@
+package push
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lfq7413/tomato/types"
+)
+
+func Test_apnsPayload(t *testing.T) {
+	var body types.M
+	var result types.M
+	var expect types.M
+	/************************************************************/
+	body = types.M{
+		"data": types.M{
+			"alert":    "hello",
+			"badge":    1.0,
+			"sound":    "default",
+			"category": "message",
+			"threadId": "chat",
+			"key":      "value",
+		},
+	}
+	result = apnsPayload(body)
+	expect = types.M{
+		"aps": types.M{
+			"alert":     "hello",
+			"badge":     1.0,
+			"sound":     "default",
+			"category":  "message",
+			"thread-id": "chat",
+		},
+		"key": "value",
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	body = types.M{
+		"data": types.M{
+			"alert":           "hello",
+			"title":           "title",
+			"badge":           "Increment",
+			"mutable-content": 1.0,
+		},
+	}
+	result = apnsPayload(body)
+	expect = types.M{
+		"aps": types.M{
+			"alert": types.M{
+				"title": "title",
+				"body":  "hello",
+			},
+			"mutable-content": 1,
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func Test_apnsHeader(t *testing.T) {
+	var body types.M
+	var result http.Header
+	/************************************************************/
+	body = types.M{
+		"data": types.M{
+			"alert": "hello",
+		},
+		"expiration_time": 1489442708000.0,
+		"collapse_id":     "news",
+		"topic":           "com.example.app",
+	}
+	result = apnsHeader(body)
+	if result.Get("apns-priority") != "10" || result.Get("apns-push-type") != "alert" {
+		t.Error("unexpected header:", result)
+	}
+	if result.Get("apns-expiration") != "1489442708" {
+		t.Error("expect:", "1489442708", "result:", result.Get("apns-expiration"))
+	}
+	if result.Get("apns-collapse-id") != "news" || result.Get("apns-topic") != "com.example.app" {
+		t.Error("unexpected header:", result)
+	}
+	/************************************************************/
+	body = types.M{
+		"data": types.M{
+			"content-available": 1.0,
+		},
+	}
+	result = apnsHeader(body)
+	if result.Get("apns-priority") != "5" || result.Get("apns-push-type") != "background" {
+		t.Error("unexpected header:", result)
+	}
+	if result.Get("apns-expiration") != "" || result.Get("apns-topic") != "" {
+		t.Error("unexpected header:", result)
+	}
+	/************************************************************/
+	body = types.M{
+		"data": types.M{
+			"content-available": 1.0,
+		},
+		"priority": 10.0,
+	}
+	result = apnsHeader(body)
+	if result.Get("apns-priority") != "10" {
+		t.Error("expect:", "10", "result:", result.Get("apns-priority"))
+	}
+}
+
+func Test_apnsSend(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	signer, err := newAPNsTokenSigner(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "KEYID", "TEAMID")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	requests := map[string]*http.Request{}
+	payloads := map[string]types.M{}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			w.WriteHeader(http.StatusHTTPVersionNotSupported)
+			return
+		}
+		if verifyAPNsToken(r.Header.Get("authorization"), &key.PublicKey) == false {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"reason":"InvalidProviderToken"}`))
+			return
+		}
+		token := strings.TrimPrefix(r.URL.Path, "/3/device/")
+		var payload types.M
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &payload)
+		mu.Lock()
+		requests[token] = r
+		payloads[token] = payload
+		mu.Unlock()
+
+		switch token {
+		case "bad":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+		case "gone":
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered","timestamp":1489442708000}`))
+		default:
+			w.Header().Set("apns-id", "id-"+token)
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	a := &apnsPushAdapter{
+		validPushTypes: []string{"ios", "osx", "tvos"},
+		host:           server.URL,
+		topic:          "com.example.default",
+		client:         server.Client(),
+		signer:         signer,
+	}
+	body := types.M{
+		"data": types.M{
+			"alert": "hello",
+		},
+		"collapse_id": "news",
+	}
+	installations := types.S{
+		types.M{"deviceType": "ios", "deviceToken": "good", "appIdentifier": "com.example.app"},
+		types.M{"deviceType": "ios", "deviceToken": "bad"},
+		types.M{"deviceType": "tvos", "deviceToken": "gone"},
+		types.M{"deviceType": "android", "deviceToken": "android"},
+	}
+	results := a.send(body, installations, "pushStatusID")
+	if len(results) != 3 {
+		t.Fatal("expect:", 3, "result:", len(results))
+	}
+
+	expect := map[string]types.M{
+		"good": types.M{"transmitted": true, "response": map[string]string{"id": "id-good"}},
+		"bad":  types.M{"transmitted": false, "response": map[string]string{"error": "BadDeviceToken", "status": "400"}},
+		"gone": types.M{"transmitted": false, "response": map[string]string{"error": "Unregistered", "status": "410"}},
+	}
+	for _, result := range results {
+		token := result["device"].(types.M)["deviceToken"].(string)
+		delete(result, "device")
+		if reflect.DeepEqual(expect[token], result) == false {
+			t.Error("expect:", expect[token], "result:", result)
+		}
+	}
+
+	if topic := requests["good"].Header.Get("apns-topic"); topic != "com.example.app" {
+		t.Error("expect:", "com.example.app", "result:", topic)
+	}
+	if topic := requests["bad"].Header.Get("apns-topic"); topic != "com.example.default" {
+		t.Error("expect:", "com.example.default", "result:", topic)
+	}
+	if collapseID := requests["good"].Header.Get("apns-collapse-id"); collapseID != "news" {
+		t.Error("expect:", "news", "result:", collapseID)
+	}
+	expectPayload := types.M{"aps": map[string]interface{}{"alert": "hello"}}
+	if reflect.DeepEqual(expectPayload, payloads["good"]) == false {
+		t.Error("expect:", expectPayload, "result:", payloads["good"])
+	}
+}
+
+func Test_responseError(t *testing.T) {
+	if r := responseError(map[string]string{"error": "BadDeviceToken"}); r != "BadDeviceToken" {
+		t.Error("expect:", "BadDeviceToken", "result:", r)
+	}
+	if r := responseError(types.M{"error": "NotRegistered"}); r != "NotRegistered" {
+		t.Error("expect:", "NotRegistered", "result:", r)
+	}
+	if r := responseError(nil); r != "" {
+		t.Error("expect:", "", "result:", r)
+	}
+}
+
+// verifyAPNsToken 校验 ES256 签名的 JWT
+func verifyAPNsToken(authorization string, key *ecdsa.PublicKey) bool {
+	parts := strings.Split(strings.TrimPrefix(authorization, "bearer "), ".")
+	if len(parts) != 3 {
+		return false
+	}
+	var header, claims types.M
+	b, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	json.Unmarshal(b, &header)
+	b, _ = base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(b, &claims)
+	if header["alg"] != "ES256" || header["kid"] != "KEYID" || claims["iss"] != "TEAMID" || claims["iat"] == nil {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return false
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	return ecdsa.Verify(key, hash[:], r, s)
+}
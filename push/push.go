@@ -8,6 +8,7 @@ import (
 
 	"github.com/lfq7413/tomato/config"
 	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/logger"
 	"github.com/lfq7413/tomato/rest"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
@@ -18,14 +19,21 @@ var queue *pushQueue
 var worker *pushWorker
 
 // init 初始化推送模块
-// 当前仅有模拟的推送模块，
-// 后续添加 APNS、GCM、以及其他第三方推送模块
+// 支持 FCM 与 APNs 推送模块，默认为模拟的推送模块
 func init() {
 	a := config.TConfig.PushAdapter
 	if a == "tomato" {
 		adapter = newTomatoPush()
 	} else if a == "FCM" {
 		adapter = newFCMPush()
+	} else if a == "APNs" {
+		apns, err := newAPNsPush()
+		if err != nil {
+			logger.Error("APNs push adapter:", err)
+			adapter = nil
+		} else {
+			adapter = apns
+		}
 	} else {
 		adapter = nil
 	}
@@ -51,13 +59,11 @@ func SendPush(body types.M, where types.M, auth *rest.Auth, onPushStatusSaved fu
 	}
 
 	if body["push_time"] != nil {
-		pushTime, err := getPushTime(body)
+		pushTime, isLocalTime, err := getPushTime(body)
 		if err != nil {
 			return err
 		}
-		if pushTime != nil {
-			body["push_time"] = pushTime
-		}
+		body["push_time"] = formatPushTime(pushTime, isLocalTime)
 	}
 
 	badgeUpdate := func() error { return nil }
@@ -115,8 +121,8 @@ func SendPush(body types.M, where types.M, auth *rest.Auth, onPushStatusSaved fu
 		return err
 	}
 
-	if _, ok := body["push_time"]; ok && config.TConfig.ScheduledPush {
-
+	if _, ok := body["push_time"].(string); ok && config.TConfig.ScheduledPush {
+		// 定时推送由推送调度器在推送时间到达时发送，参考 scheduler.go
 	} else {
 		err = queue.enqueue(body, where, auth, status)
 	}
@@ -160,30 +166,50 @@ func getExpirationTime(body types.M) (interface{}, error) {
 }
 
 // getPushTime 获取推送时间
-func getPushTime(body types.M) (interface{}, error) {
+// 支持 Unix 时间与 ISO 8601 格式的字符串
+// 字符串中不包含时区信息时，表示该时间为设备所在时区的本地时间，此时 isLocalTime 为 true
+func getPushTime(body types.M) (pushTime time.Time, isLocalTime bool, err error) {
 	pushTimeParam := body["push_time"]
-	if pushTimeParam == nil {
-		return nil, nil
-	}
-
-	var pushTime time.Time
-	var err error
 
 	if v, ok := pushTimeParam.(float64); ok {
-		pushTime = time.Unix(int64(v), 0)
+		pushTime = time.Unix(int64(v), 0).UTC()
 	} else if v, ok := pushTimeParam.(int); ok {
-		pushTime = time.Unix(int64(v), 0)
+		pushTime = time.Unix(int64(v), 0).UTC()
 	} else if v, ok := pushTimeParam.(string); ok {
-		pushTime, err = utils.StringtoTime(v)
+		pushTime, isLocalTime, err = parsePushTime(v)
 		if err != nil {
-			return nil, errs.E(errs.PushMisconfigured, fmt.Sprint(pushTimeParam, "is not valid time."))
+			return pushTime, false, errs.E(errs.PushMisconfigured, fmt.Sprint(pushTimeParam, "is not valid time."))
 		}
 	} else {
 		// 时间格式错误
-		return nil, errs.E(errs.PushMisconfigured, fmt.Sprint(pushTimeParam, "is not valid time."))
+		return pushTime, false, errs.E(errs.PushMisconfigured, fmt.Sprint(pushTimeParam, "is not valid time."))
 	}
 
-	return pushTime, nil
+	return pushTime, isLocalTime, nil
+}
+
+// parsePushTime 解析字符串格式的推送时间
+// 本地时间的时区为 UTC ，仅用于表示时钟读数
+func parsePushTime(s string) (time.Time, bool, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), false, nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, errors.New("invalid push time")
+}
+
+// formatPushTime 格式化推送时间，本地时间不包含末尾的时区标识 Z
+func formatPushTime(pushTime time.Time, isLocalTime bool) string {
+	if isLocalTime {
+		return pushTime.Format(localPushTimeLayout)
+	}
+	return utils.TimetoString(pushTime)
 }
 
 // pushAdapter 推送模块要实现的接口
@@ -197,7 +223,7 @@ type pushAdapter interface {
 			"where":{
 				"key":"v"
 			},
-			"push_time":time.Time("2015-03-13T22:05:08Z"),
+			"push_time":"2015-03-13T22:05:08.000Z",
 			"expiration_interval": 518400,
 			"expiration_time": 14xxxxxxxxx,
 			"data":{
@@ -38,10 +38,10 @@ func (p *pushStatus) setInitial(body, where, options types.M) error {
 	}
 
 	now := time.Now().UTC()
-	pushTime := time.Now().UTC()
+	pushTime := utils.TimetoString(now)
 	status := "pending"
 
-	if t, ok := body["push_time"].(time.Time); ok {
+	if t, ok := body["push_time"].(string); ok {
 		if config.TConfig.ScheduledPush {
 			pushTime = t
 			status = "scheduled"
@@ -66,7 +66,7 @@ func (p *pushStatus) setInitial(body, where, options types.M) error {
 	object := types.M{
 		"objectId":  p.objectID,
 		"createdAt": utils.TimetoString(now),
-		"pushTime":  types.M{"__type": "Date", "iso": utils.TimetoString(pushTime)},
+		"pushTime":  pushTime,
 		"query":     string(whereString),
 		"payload":   string(payloadString),
 		"source":    utils.S(options["source"]),
@@ -96,6 +96,15 @@ func (p *pushStatus) setRunning(count int) {
 	p.db.Update(pushStatusCollection, where, update, types.M{}, false)
 }
 
+// claim 更新推送状态，查询条件中包含推送的当前状态
+// 多个 tomato 实例同时处理同一个推送时，仅有一个实例能够更新成功，更新成功时返回 true
+func (p *pushStatus) claim(where, update types.M) bool {
+	where["objectId"] = p.objectID
+	update["updatedAt"] = utils.TimetoString(time.Now().UTC())
+	_, err := p.db.Update(pushStatusCollection, where, update, types.M{}, false)
+	return err == nil
+}
+
 // trackSent 推送完成，传入数据格式如下
 // {
 // 	"device":{
@@ -107,6 +116,7 @@ func (p *pushStatus) trackSent(results []types.M) error {
 	update := types.M{}
 	numSent := 0
 	numFailed := 0
+	invalidTokens := types.S{}
 
 	for _, result := range results {
 		if result == nil {
@@ -127,8 +137,14 @@ func (p *pushStatus) trackSent(results []types.M) error {
 		} else {
 			numFailed++
 			incrementOp(update, `failedPerType.`+deviceType, 1)
+			if invalidDeviceTokenErrors[responseError(result["response"])] {
+				invalidTokens = append(invalidTokens, device["deviceToken"])
+			}
 		}
 	}
+	if len(invalidTokens) > 0 {
+		p.removeDeviceTokens(invalidTokens)
+	}
 	incrementOp(update, "count", -len(results))
 
 	if numSent > 0 {
@@ -163,6 +179,38 @@ func (p *pushStatus) trackSent(results []types.M) error {
 	return nil
 }
 
+// invalidDeviceTokenErrors 表示设备 token 已失效的错误，包括 APNs 与 FCM 返回的错误
+var invalidDeviceTokenErrors = map[string]bool{
+	"BadDeviceToken":      true,
+	"Unregistered":        true,
+	"NotRegistered":       true,
+	"InvalidRegistration": true,
+}
+
+// responseError 获取推送模块返回结果中的错误原因
+func responseError(response interface{}) string {
+	switch r := response.(type) {
+	case map[string]string:
+		return r["error"]
+	case types.M:
+		return utils.S(r["error"])
+	case map[string]interface{}:
+		return utils.S(r["error"])
+	}
+	return ""
+}
+
+// removeDeviceTokens 从 _Installation 中移除已失效的设备 token ，避免再次向其推送
+func (p *pushStatus) removeDeviceTokens(deviceTokens types.S) {
+	where := types.M{
+		"deviceToken": types.M{"$in": deviceTokens},
+	}
+	update := types.M{
+		"deviceToken": types.M{"__op": "Delete"},
+	}
+	p.db.Update("_Installation", where, update, types.M{"many": true}, false)
+}
+
 // complete 推送完成
 func (p *pushStatus) complete() {
 	where := types.M{
@@ -0,0 +1,258 @@
+package push
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lfq7413/tomato/config"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/rest"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+// localPushTimeLayout 本地时间推送的时间格式，不包含时区标识
+const localPushTimeLayout = "2006-01-02T15:04:05.000"
+
+// maxTimeZoneOffset 时区与 UTC 的最大时差
+// 本地时间推送从最早的时区到达推送时间开始发送，到最晚的时区到达推送时间为止
+const maxTimeZoneOffset = 14 * time.Hour
+
+// RunScheduler 启动推送调度器
+// 调度器定时检查 _PushStatus 中的定时推送，在推送时间到达时通过 pushQueue 发送
+// 推送状态的更新以推送的当前状态为条件，多个 tomato 实例同时运行调度器时，同一推送仅会被发送一次
+func RunScheduler() {
+	if config.TConfig.ScheduledPush == false || adapter == nil {
+		return
+	}
+	interval := time.Duration(config.TConfig.ScheduledPushInterval) * time.Second
+	go func() {
+		for {
+			runScheduledPushes(time.Now().UTC())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// runScheduledPushes 发送所有已到达推送时间的定时推送
+// 正在发送中的本地时间推送，会继续发送给已到达推送时间的时区中的设备
+func runScheduledPushes(now time.Time) {
+	where := types.M{
+		"status": types.M{"$in": types.S{"scheduled", "running"}},
+	}
+	results, err := orm.TomatoDBController.Find(pushStatusCollection, where, types.M{})
+	if err != nil {
+		return
+	}
+	for _, v := range results {
+		object := utils.M(v)
+		if object == nil {
+			continue
+		}
+		if utils.S(object["status"]) == "running" && utils.S(object["localTimeCursor"]) == "" {
+			continue
+		}
+		sendScheduledPush(object, now)
+	}
+}
+
+// sendScheduledPush 发送定时推送
+func sendScheduledPush(object types.M, now time.Time) {
+	status := newPushStatus(utils.S(object["objectId"]))
+	pushTime, isLocalTime, err := parsePushTime(utils.S(object["pushTime"]))
+	if err != nil {
+		status.fail(err)
+		return
+	}
+	body, where, err := scheduledPushRequest(object)
+	if err != nil {
+		status.fail(err)
+		return
+	}
+
+	if isLocalTime {
+		err = sendLocalTimePush(object, status, body, where, pushTime, now)
+		if err != nil {
+			status.fail(err)
+		}
+		return
+	}
+
+	if pushTime.After(now) {
+		return
+	}
+	if isPushExpired(body, now) {
+		status.claim(types.M{"status": "scheduled"}, types.M{"status": "failed", "errorMessage": "Push expired before it was sent"})
+		return
+	}
+	// 把推送状态修改为 pending ，修改成功的实例负责发送该推送
+	if status.claim(types.M{"status": "scheduled"}, types.M{"status": "pending"}) == false {
+		return
+	}
+	err = queue.enqueue(body, where, rest.Master(), status)
+	if err != nil {
+		status.fail(err)
+	}
+}
+
+// sendLocalTimePush 发送本地时间推送
+// 推送时间为设备所在时区的本地时间，每次调度时，发送给在 localTimeCursor 之后到达推送时间的时区中的设备
+// 未设置时区或者时区无效的设备，以 UTC 时间为准
+func sendLocalTimePush(object types.M, status *pushStatus, body, where types.M, pushTime, now time.Time) error {
+	if now.Before(pushTime.Add(-maxTimeZoneOffset)) {
+		return nil
+	}
+	if isPushExpired(body, now) {
+		return errors.New("Push expired before it was sent")
+	}
+
+	where = installationsWhere(where)
+	cursor := utils.S(object["localTimeCursor"])
+	final := now.After(pushTime.Add(maxTimeZoneOffset))
+	update := types.M{"localTimeCursor": utils.TimetoString(now)}
+	if final {
+		update["localTimeCursor"] = types.M{"__op": "Delete"}
+	}
+
+	var from time.Time
+	if cursor == "" {
+		// 首次发送时，统计所有需要发送的设备数量，所有设备发送完成后推送状态为 succeeded
+		count, err := countInstallations(rest.Master(), where)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("PushController: no results in query")
+		}
+		update["status"] = "running"
+		update["count"] = count
+		if status.claim(types.M{"status": "scheduled"}, update) == false {
+			return nil
+		}
+	} else {
+		var err error
+		from, err = utils.StringtoTime(cursor)
+		if err != nil {
+			return err
+		}
+		if status.claim(types.M{"status": "running", "localTimeCursor": cursor}, update) == false {
+			return nil
+		}
+	}
+
+	to := now
+	if final {
+		to = pushTime.Add(maxTimeZoneOffset)
+	}
+	response, err := rest.Find(rest.Master(), "_Installation", where, types.M{"distinct": "timeZone"}, nil)
+	if err != nil {
+		return err
+	}
+	timeZones := []string{}
+	for _, v := range utils.A(response["results"]) {
+		if timeZone, ok := v.(string); ok {
+			timeZones = append(timeZones, timeZone)
+		}
+	}
+	dueZones, includeMissing := dueTimeZones(pushTime, timeZones, from, to)
+	where = timeZoneWhere(where, dueZones, includeMissing)
+	if where == nil {
+		return nil
+	}
+
+	count, err := countInstallations(rest.Master(), where)
+	if err != nil || count == 0 {
+		return err
+	}
+	return queue.publish(body, where, count, status)
+}
+
+// scheduledPushRequest 从推送状态中恢复推送内容与查询条件
+func scheduledPushRequest(object types.M) (types.M, types.M, error) {
+	var data types.M
+	err := json.Unmarshal([]byte(utils.S(object["payload"])), &data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var where types.M
+	err = json.Unmarshal([]byte(utils.S(object["query"])), &where)
+	if err != nil {
+		return nil, nil, err
+	}
+	if where == nil {
+		where = types.M{}
+	}
+
+	body := types.M{"data": data}
+	if expiry, ok := object["expiry"].(float64); ok {
+		body["expiration_time"] = int64(expiry)
+	} else if expiry, ok := object["expiry"].(int64); ok {
+		body["expiration_time"] = expiry
+	} else if expiry, ok := object["expiry"].(int); ok {
+		body["expiration_time"] = int64(expiry)
+	}
+	return body, where, nil
+}
+
+// isPushExpired 判断推送是否已过期，expiration_time 为以毫秒为单位的 Unix 时间
+func isPushExpired(body types.M, now time.Time) bool {
+	expirationTime, ok := body["expiration_time"].(int64)
+	if ok == false {
+		return false
+	}
+	return now.UnixNano()/int64(time.Millisecond) > expirationTime
+}
+
+// dueTimeZones 返回本地推送时间处于 (from, to] 之间的时区
+// includeMissing 表示未设置时区的设备是否需要发送
+func dueTimeZones(pushTime time.Time, timeZones []string, from, to time.Time) (due []string, includeMissing bool) {
+	isDue := func(t time.Time) bool {
+		return t.After(from) && t.After(to) == false
+	}
+	due = []string{}
+	for _, name := range timeZones {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			location = time.UTC
+		}
+		if isDue(localTime(pushTime, location)) {
+			due = append(due, name)
+		}
+	}
+	return due, isDue(pushTime)
+}
+
+// localTime 返回指定时区中时钟读数为 t 的时间
+func localTime(t time.Time, location *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), location)
+}
+
+// timeZoneWhere 在查询条件中添加时区限制，没有需要发送的时区时返回 nil
+func timeZoneWhere(where types.M, timeZones []string, includeMissing bool) types.M {
+	conditions := types.S{}
+	if len(timeZones) > 0 {
+		in := types.S{}
+		for _, timeZone := range timeZones {
+			in = append(in, timeZone)
+		}
+		conditions = append(conditions, types.M{"timeZone": types.M{"$in": in}})
+	}
+	if includeMissing {
+		conditions = append(conditions, types.M{"timeZone": types.M{"$exists": false}})
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	var condition types.M
+	if len(conditions) == 1 {
+		condition = utils.M(conditions[0])
+	} else {
+		condition = types.M{"$or": conditions}
+	}
+	if len(where) == 0 {
+		return condition
+	}
+	return types.M{"$and": types.S{where, condition}}
+}
@@ -0,0 +1,126 @@
+package push
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lfq7413/tomato/types"
+)
+
+func Test_parsePushTime(t *testing.T) {
+	var s string
+	var pushTime time.Time
+	var isLocalTime bool
+	var err error
+	var expect time.Time
+	/************************************************************/
+	s = "2017-03-13T22:05:08.000Z"
+	pushTime, isLocalTime, err = parsePushTime(s)
+	expect = time.Date(2017, 3, 13, 22, 5, 8, 0, time.UTC)
+	if err != nil || isLocalTime || pushTime.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", pushTime, isLocalTime, err)
+	}
+	if formatPushTime(pushTime, isLocalTime) != s {
+		t.Error("expect:", s, "result:", formatPushTime(pushTime, isLocalTime))
+	}
+	/************************************************************/
+	s = "2017-03-14T06:05:08+08:00"
+	pushTime, isLocalTime, err = parsePushTime(s)
+	expect = time.Date(2017, 3, 13, 22, 5, 8, 0, time.UTC)
+	if err != nil || isLocalTime || pushTime.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", pushTime, isLocalTime, err)
+	}
+	/************************************************************/
+	s = "2017-03-13T22:05:08.000"
+	pushTime, isLocalTime, err = parsePushTime(s)
+	expect = time.Date(2017, 3, 13, 22, 5, 8, 0, time.UTC)
+	if err != nil || isLocalTime == false || pushTime.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", pushTime, isLocalTime, err)
+	}
+	if formatPushTime(pushTime, isLocalTime) != s {
+		t.Error("expect:", s, "result:", formatPushTime(pushTime, isLocalTime))
+	}
+	/************************************************************/
+	s = "2017-03-13"
+	pushTime, isLocalTime, err = parsePushTime(s)
+	expect = time.Date(2017, 3, 13, 0, 0, 0, 0, time.UTC)
+	if err != nil || isLocalTime == false || pushTime.Equal(expect) == false {
+		t.Error("expect:", expect, "result:", pushTime, isLocalTime, err)
+	}
+	/************************************************************/
+	s = "hello"
+	_, _, err = parsePushTime(s)
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
+
+func Test_dueTimeZones(t *testing.T) {
+	var pushTime, from, to time.Time
+	var timeZones []string
+	var due []string
+	var includeMissing bool
+	var expect []string
+	/************************************************************/
+	pushTime = time.Date(2017, 3, 13, 10, 0, 0, 0, time.UTC)
+	timeZones = []string{"Asia/Shanghai", "UTC", "America/New_York", "invalid"}
+	to = time.Date(2017, 3, 13, 2, 0, 0, 0, time.UTC)
+	due, includeMissing = dueTimeZones(pushTime, timeZones, from, to)
+	expect = []string{"Asia/Shanghai"}
+	if reflect.DeepEqual(expect, due) == false || includeMissing {
+		t.Error("expect:", expect, "result:", due, includeMissing)
+	}
+	/************************************************************/
+	from = to
+	to = time.Date(2017, 3, 13, 10, 0, 0, 0, time.UTC)
+	due, includeMissing = dueTimeZones(pushTime, timeZones, from, to)
+	expect = []string{"UTC", "invalid"}
+	if reflect.DeepEqual(expect, due) == false || includeMissing == false {
+		t.Error("expect:", expect, "result:", due, includeMissing)
+	}
+	/************************************************************/
+	from = to
+	to = time.Date(2017, 3, 14, 0, 0, 0, 0, time.UTC)
+	due, includeMissing = dueTimeZones(pushTime, timeZones, from, to)
+	expect = []string{"America/New_York"}
+	if reflect.DeepEqual(expect, due) == false || includeMissing {
+		t.Error("expect:", expect, "result:", due, includeMissing)
+	}
+}
+
+func Test_timeZoneWhere(t *testing.T) {
+	var where types.M
+	var result types.M
+	var expect types.M
+	/************************************************************/
+	where = types.M{"channels": "news"}
+	result = timeZoneWhere(where, []string{}, false)
+	if result != nil {
+		t.Error("expect:", nil, "result:", result)
+	}
+	/************************************************************/
+	where = types.M{"channels": "news"}
+	result = timeZoneWhere(where, []string{"UTC"}, false)
+	expect = types.M{
+		"$and": types.S{
+			types.M{"channels": "news"},
+			types.M{"timeZone": types.M{"$in": types.S{"UTC"}}},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	where = types.M{}
+	result = timeZoneWhere(where, []string{"UTC"}, true)
+	expect = types.M{
+		"$or": types.S{
+			types.M{"timeZone": types.M{"$in": types.S{"UTC"}}},
+			types.M{"timeZone": types.M{"$exists": false}},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
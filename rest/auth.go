@@ -5,6 +5,7 @@ import (
 
 	"github.com/lfq7413/tomato/cache"
 	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/orm"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
 )
@@ -17,6 +18,14 @@ type Auth struct {
 	UserRoles      []string
 	FetchedRoles   bool
 	RolePromise    []string
+	// DB 为空时使用 orm.TomatoDBController ，批量请求开启事务时，所有操作都在该 DBController 的事务中执行
+	DB *orm.DBController
+	// IP Headers 为当前 http 请求的客户端地址与请求头，传递给云代码回调
+	IP      string
+	Headers map[string]string
+	// Context 在同一个请求的各个云代码回调之间共享，例如 beforeSave 中写入的数据可以在 afterSave 中读取
+	// 客户端可以通过 X-Parse-Cloud-Context 请求头设置初始值
+	Context types.M
 }
 
 // Master 生成 Master 级别用户
@@ -24,6 +33,23 @@ func Master() *Auth {
 	return &Auth{IsMaster: true}
 }
 
+// db 返回当前请求使用的 DBController
+func (a *Auth) db() *orm.DBController {
+	if a == nil || a.DB == nil {
+		return orm.TomatoDBController
+	}
+	return a.DB
+}
+
+// master 生成 Master 级别用户，与当前用户使用同一个 DBController
+func (a *Auth) master() *Auth {
+	auth := Master()
+	if a != nil {
+		auth.DB = a.DB
+	}
+	return auth
+}
+
 // Nobody 生成空用户
 func Nobody() *Auth {
 	return &Auth{IsMaster: false}
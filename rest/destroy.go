@@ -4,9 +4,9 @@ import (
 	"github.com/lfq7413/tomato/cache"
 	"github.com/lfq7413/tomato/cloud"
 	"github.com/lfq7413/tomato/livequery"
-	"github.com/lfq7413/tomato/orm"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
+	"github.com/lfq7413/tomato/webhook"
 )
 
 // Destroy 删除对象
@@ -75,9 +75,6 @@ func (d *Destroy) runBeforeTrigger() error {
 	if d.originalData == nil {
 		return nil
 	}
-	if livequery.TLiveQuery != nil {
-		livequery.TLiveQuery.OnAfterDelete(d.className, d.originalData, nil)
-	}
 
 	d.originalData["className"] = d.className
 	maybeRunTrigger(cloud.TypeBeforeDelete, d.auth, d.originalData, nil)
@@ -105,11 +102,21 @@ func (d *Destroy) runDestroy() error {
 		}
 		options["acl"] = acl
 	}
-	return orm.TomatoDBController.Destroy(d.className, d.query, options)
+	return d.auth.db().Destroy(d.className, d.query, options)
 }
 
 // runAfterTrigger 执行删后回调
+// 批量请求的事务中，等待事务提交之后再通知 LiveQueryServer 、发送数据删除事件与执行删后回调
 func (d *Destroy) runAfterTrigger() error {
-	maybeRunTrigger(cloud.TypeAfterDelete, d.auth, d.originalData, nil)
+	d.auth.db().RunAfterCommit(func() {
+		if d.originalData != nil && webhook.HasSubscriptions(d.className) {
+			// 发送数据删除事件到订阅地址
+			webhook.Publish(webhook.EventDelete, d.className, d.originalData, nil)
+		}
+		if d.originalData != nil && livequery.TLiveQuery != nil {
+			livequery.TLiveQuery.OnAfterDelete(d.className, d.originalData, nil)
+		}
+		maybeRunTrigger(cloud.TypeAfterDelete, d.auth, d.originalData, nil)
+	})
 	return nil
 }
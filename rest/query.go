@@ -1,6 +1,9 @@
 package rest
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
 	"sort"
 	"strings"
 
@@ -27,6 +30,10 @@ type Query struct {
 	redirectKey       string
 	redirectClassName string
 	clientSDK         map[string]string
+	// useCursor 为 true 时使用游标分页，返回结果中包含 nextCursor
+	useCursor bool
+	// cursor 上一页返回的游标，为 nil 时从第一页开始查询
+	cursor *queryCursor
 }
 
 var alwaysSelectedKeys = []string{"objectId", "createdAt", "updatedAt"}
@@ -124,6 +131,8 @@ func NewQuery(
 			}
 		case "count":
 			query.doCount = true
+		case "distinct":
+			query.findOptions["distinct"] = v
 		case "skip":
 			query.findOptions["skip"] = v
 		case "limit":
@@ -172,14 +181,55 @@ func NewQuery(
 				query.redirectKey = s
 				query.redirectClassName = ""
 			}
+		case "cursor":
+			// cursor 为空字符串时表示查询第一页
+			s, ok := v.(string)
+			if ok == false {
+				return nil, errs.E(errs.InvalidQuery, "cursor should be a string")
+			}
+			query.useCursor = true
+			if s != "" {
+				cursor, err := decodeCursor(s)
+				if err != nil {
+					return nil, err
+				}
+				query.cursor = cursor
+			}
 		default:
 			return nil, errs.E(errs.InvalidJSON, "bad option: "+k)
 		}
 	}
 
+	if query.useCursor {
+		err := query.prepareCursor()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return query, nil
 }
 
+// prepareCursor 检查游标分页的参数，并在排序字段中加入 objectId ，保证排序结果唯一
+func (q *Query) prepareCursor() error {
+	if q.findOptions["skip"] != nil {
+		return errs.E(errs.InvalidQuery, "Cannot use skip with cursor.")
+	}
+	if q.findOptions["distinct"] != nil {
+		return errs.E(errs.InvalidQuery, "Cannot use distinct with cursor.")
+	}
+	order, _ := q.findOptions["sort"].([]string)
+	sortKeys, err := cursorSort(order)
+	if err != nil {
+		return err
+	}
+	if q.cursor != nil && reflect.DeepEqual(q.cursor.Sort, sortKeys) == false {
+		return errs.E(errs.InvalidQuery, "cursor does not match the order of the query.")
+	}
+	q.findOptions["sort"] = sortKeys
+	return nil
+}
+
 // Execute 执行查询请求，返回的数据包含 results count 两个字段
 func (q *Query) Execute(executeOptions ...types.M) (types.M, error) {
 
@@ -258,7 +308,7 @@ func (q *Query) redirectClassNameForKey() error {
 		return nil
 	}
 
-	newClassName := orm.TomatoDBController.RedirectClassNameForKey(q.className, q.redirectKey)
+	newClassName := q.auth.db().RedirectClassNameForKey(q.className, q.redirectKey)
 	q.className = newClassName
 	q.redirectClassName = newClassName
 
@@ -281,7 +331,7 @@ func (q *Query) validateClientClassCreation() error {
 		}
 	}
 	// 允许操作已存在的表
-	schema := orm.TomatoDBController.LoadSchema(nil)
+	schema := q.auth.db().LoadSchema(nil)
 	hasClass := schema.HasClass(q.className)
 	if hasClass {
 		return nil
@@ -576,6 +626,70 @@ func (q *Query) runFind(executeOptions ...types.M) error {
 		}
 	}
 
+	findOptions := q.buildFindOptions(options)
+	where := q.Where
+	// injectedKeys 为生成游标而额外查询的字段，生成游标之后从结果中删除
+	injectedKeys := []string{}
+	if q.useCursor {
+		sortKeys, _ := findOptions["sort"].([]string)
+		// 需要取出排序字段的值以生成下一页的游标
+		if keys, ok := findOptions["keys"].([]string); ok {
+			selected := map[string]bool{}
+			for _, key := range keys {
+				selected[key] = true
+			}
+			for _, key := range sortKeys {
+				key = strings.Split(strings.TrimPrefix(key, "-"), ".")[0]
+				if selected[key] == false {
+					selected[key] = true
+					keys = append(keys, key)
+					injectedKeys = append(injectedKeys, key)
+				}
+			}
+			findOptions["keys"] = keys
+		}
+		if q.cursor != nil {
+			where = types.M{
+				"$and": types.S{q.Where, cursorConstraint(q.cursor.Sort, q.cursor.Values, cursorNullsLargest())},
+			}
+		}
+	}
+	response, err := q.auth.db().Find(q.className, where, findOptions)
+	if err != nil {
+		return err
+	}
+	// distinct 查询返回的是字段值，不需要处理对象
+	if findOptions["distinct"] != nil {
+		q.response["results"] = response
+		return nil
+	}
+	q.cleanResults(response)
+
+	// 返回结果达到 limit 时，可能还有下一页
+	if q.useCursor && len(response) > 0 && len(response) == queryLimit(findOptions) {
+		sortKeys, _ := findOptions["sort"].([]string)
+		nextCursor, err := encodeCursor(sortKeys, utils.M(response[len(response)-1]))
+		if err != nil {
+			return err
+		}
+		q.response["nextCursor"] = nextCursor
+	}
+	if len(injectedKeys) > 0 {
+		for _, v := range response {
+			if object := utils.M(v); object != nil {
+				for _, key := range injectedKeys {
+					delete(object, key)
+				}
+			}
+		}
+	}
+
+	q.response["results"] = response
+	return nil
+}
+
+// buildFindOptions 组装传递给数据库的查询选项
+func (q *Query) buildFindOptions(options types.M) types.M {
 	findOptions := types.M{}
 	for k, v := range q.findOptions {
 		findOptions[k] = v
@@ -591,10 +705,11 @@ func (q *Query) runFind(executeOptions ...types.M) error {
 	if v, ok := options["op"].(string); ok && v != "" {
 		findOptions["op"] = v
 	}
-	response, err := orm.TomatoDBController.Find(q.className, q.Where, findOptions)
-	if err != nil {
-		return err
-	}
+	return findOptions
+}
+
+// cleanResults 处理数据库返回的结果：删除敏感字段、展开文件类型、设置 redirectClassName
+func (q *Query) cleanResults(response types.S) {
 	// 从 _User 表中删除敏感字段
 	if q.className == "_User" {
 		for _, v := range response {
@@ -615,9 +730,77 @@ func (q *Query) runFind(executeOptions ...types.M) error {
 			}
 		}
 	}
+}
 
-	q.response["results"] = response
-	return nil
+// Stream 以流的形式执行查询请求，从数据库中逐个读取对象，避免一次加载全部结果
+// 每读取 chunkSize 个对象，展开 include 并运行 afterFind 回调后交给 handle 处理
+// 不支持 count distinct cursor 选项
+func (q *Query) Stream(chunkSize int, handle func(results types.S) error) error {
+	if q.doCount {
+		return errs.E(errs.InvalidQuery, "Cannot use count with streaming.")
+	}
+	if q.useCursor {
+		return errs.E(errs.InvalidQuery, "Cannot use cursor with streaming.")
+	}
+	if q.findOptions["distinct"] != nil {
+		return errs.E(errs.InvalidQuery, "Cannot use distinct with streaming.")
+	}
+	if chunkSize <= 0 {
+		chunkSize = 100
+	}
+
+	err := q.BuildRestWhere()
+	if err != nil {
+		return err
+	}
+	if queryLimit(q.findOptions) == 0 {
+		return nil
+	}
+
+	iter, err := q.auth.db().FindIterator(q.className, q.Where, q.buildFindOptions(types.M{}))
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	// handleInclude 会修改 q.include ，每次处理前需要恢复
+	include := q.include
+	for {
+		results := types.S{}
+		for len(results) < chunkSize {
+			object, err := iter.Next()
+			if err != nil {
+				return err
+			}
+			if object == nil {
+				break
+			}
+			results = append(results, object)
+		}
+		if len(results) == 0 {
+			return nil
+		}
+		done := len(results) < chunkSize
+
+		q.cleanResults(results)
+		q.response = types.M{"results": results}
+		q.include = include
+		err = q.handleInclude()
+		if err != nil {
+			return err
+		}
+		err = q.runAfterFindTrigger()
+		if err != nil {
+			return err
+		}
+		err = handle(utils.A(q.response["results"]))
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
 }
 
 // runCount 查询符合条件的结果数量
@@ -629,7 +812,7 @@ func (q *Query) runCount() error {
 	delete(q.findOptions, "skip")
 	delete(q.findOptions, "limit")
 	// 当需要取 count 时，数据库返回结果的第一个即为 count
-	result, err := orm.TomatoDBController.Find(q.className, q.Where, q.findOptions)
+	result, err := q.auth.db().Find(q.className, q.Where, q.findOptions)
 	if err != nil {
 		return err
 	}
@@ -665,6 +848,9 @@ func (q *Query) runAfterFindTrigger() error {
 	if q.response == nil {
 		return nil
 	}
+	if q.findOptions["distinct"] != nil {
+		return nil
+	}
 	results := utils.A(q.response["results"])
 	hasAfterFindHook := cloud.TriggerExists(cloud.TypeAfterFind, q.className)
 	if hasAfterFindHook == false {
@@ -1014,6 +1200,156 @@ func cleanResultAuthData(result types.M) {
 	}
 }
 
+// queryCursor 游标分页中的游标，保存排序字段与上一页最后一个对象中排序字段的值
+type queryCursor struct {
+	Sort   []string      `json:"sort"`
+	Values []interface{} `json:"values"`
+}
+
+// cursorSort 生成游标分页使用的排序字段，以 objectId 作为最后一个排序字段，保证排序结果唯一
+func cursorSort(order []string) ([]string, error) {
+	sortKeys := []string{}
+	for _, key := range order {
+		if key == "" {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimPrefix(key, "-"), "$") {
+			return nil, errs.E(errs.InvalidQuery, "Cannot use cursor with order: "+key)
+		}
+		sortKeys = append(sortKeys, key)
+		// objectId 之后的排序字段不影响结果
+		if key == "objectId" || key == "-objectId" {
+			return sortKeys, nil
+		}
+	}
+	return append(sortKeys, "objectId"), nil
+}
+
+// encodeCursor 从对象中取出排序字段的值，编码为游标
+// 可选的排序字段没有值时编码为 null ，objectId 必须有值
+func encodeCursor(sortKeys []string, object types.M) (string, error) {
+	values := []interface{}{}
+	for _, key := range sortKeys {
+		key = strings.TrimPrefix(key, "-")
+		value := getCursorValue(object, key)
+		if value == nil && key == "objectId" {
+			return "", errs.E(errs.InvalidQuery, "Cannot create cursor, missing value of key: "+key)
+		}
+		values = append(values, value)
+	}
+	b, err := json.Marshal(queryCursor{Sort: sortKeys, Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor 解析 encodeCursor 生成的游标
+func decodeCursor(s string) (*queryCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errs.E(errs.InvalidQuery, "invalid cursor")
+	}
+	var cursor queryCursor
+	err = json.Unmarshal(b, &cursor)
+	if err != nil || len(cursor.Sort) == 0 || len(cursor.Sort) != len(cursor.Values) {
+		return nil, errs.E(errs.InvalidQuery, "invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// getCursorValue 获取对象中 key 对应的值， key 可以是 a.b 的形式
+func getCursorValue(object types.M, key string) interface{} {
+	var value interface{} = object
+	for _, k := range strings.Split(key, ".") {
+		m := utils.M(value)
+		if m == nil {
+			return nil
+		}
+		value = m[k]
+	}
+	return value
+}
+
+// cursorConstraint 把游标转换为范围查询条件，查询排在游标之后的对象
+// 如排序字段为 -score,objectId 时，转换为：
+// {"$or":[{"score":{"$lt":v1}},{"score":v1,"objectId":{"$gt":v2}}]}
+// 字段值为 null 或者不存在时， $gt $lt 都不会匹配，需要按照数据库中 null 的排序位置单独处理，
+// 使用 {key: null} 匹配 null ，使用 {key: {"$ne": null}} 匹配非 null ，
+// MongoDB 中 $exists 不区分值为 null 的字段，所以不使用 $exists
+// nullsLargest 为 true 时 null 大于所有值（ PostgreSQL ），为 false 时 null 小于所有值（ MongoDB ）
+func cursorConstraint(sortKeys []string, values []interface{}, nullsLargest bool) types.M {
+	or := types.S{}
+	equal := types.M{}
+	for i, key := range sortKeys {
+		op := "$gt"
+		desc := strings.HasPrefix(key, "-")
+		if desc {
+			op = "$lt"
+			key = key[1:]
+		}
+		// nullsAfter 为 true 时，按当前排序方向 null 排在非 null 值之后
+		nullsAfter := nullsLargest != desc
+		value := values[i]
+		// 返回结果中的 createdAt updatedAt 为字符串，查询时需要使用 Date 类型
+		if s, ok := value.(string); ok && (key == "createdAt" || key == "updatedAt") {
+			value = types.M{"__type": "Date", "iso": s}
+		}
+		var after types.M
+		if value == nil {
+			// 排在 null 之后的是所有非 null 值
+			if nullsAfter == false {
+				after = types.M{key: types.M{"$ne": nil}}
+			}
+		} else if nullsAfter && alwaysHasValue(key) == false {
+			after = types.M{"$or": types.S{
+				types.M{key: types.M{op: value}},
+				types.M{key: nil},
+			}}
+		} else {
+			after = types.M{key: types.M{op: value}}
+		}
+		if after != nil {
+			condition := types.M{}
+			for k, v := range equal {
+				condition[k] = v
+			}
+			for k, v := range after {
+				condition[k] = v
+			}
+			or = append(or, condition)
+		}
+		equal[key] = value
+	}
+	return types.M{"$or": or}
+}
+
+// alwaysHasValue 判断字段是否在所有对象中都有值
+func alwaysHasValue(key string) bool {
+	for _, k := range alwaysSelectedKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// cursorNullsLargest 当前数据库排序时 null 是否大于所有值
+func cursorNullsLargest() bool {
+	return config.TConfig.DatabaseType == "PostgreSQL"
+}
+
+// queryLimit 获取查询选项中的 limit ，未设置时返回 -1
+func queryLimit(findOptions types.M) int {
+	switch l := findOptions["limit"].(type) {
+	case int:
+		return l
+	case float64:
+		return int(l)
+	}
+	return -1
+}
+
 func replaceEqualityConstraint(constraint interface{}) interface{} {
 	object := utils.M(constraint)
 	if object == nil {
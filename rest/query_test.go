@@ -12,6 +12,7 @@ import (
 	"github.com/lfq7413/tomato/storage/mongo"
 	"github.com/lfq7413/tomato/test"
 	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
 )
 
 func Test_Execute(t *testing.T) {
@@ -2138,6 +2139,171 @@ func Test_runFind(t *testing.T) {
 		t.Error("expect:", expect, "result:", q.response["results"], err)
 	}
 	orm.TomatoDBController.DeleteEverything()
+	/**********************************************************/
+	// 游标分页时，排序字段没有值的对象不会被跳过，排序字段不在 keys 中时不返回
+	initEnv()
+	className = "user"
+	object = types.M{
+		"fields": types.M{
+			"key":  types.M{"type": "String"},
+			"name": types.M{"type": "String"},
+		},
+	}
+	orm.Adapter.CreateClass(className, object)
+	orm.Adapter.CreateObject(className, types.M{}, types.M{"objectId": "01", "key": "a", "name": "n1"})
+	orm.Adapter.CreateObject(className, types.M{}, types.M{"objectId": "02", "name": "n2"})
+	orm.Adapter.CreateObject(className, types.M{}, types.M{"objectId": "03", "key": "b", "name": "n3"})
+	initEnv()
+	results := types.S{}
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		where = types.M{}
+		options = types.M{"order": "key", "keys": "name", "limit": 1, "cursor": cursor}
+		q, err = NewQuery(Master(), className, where, options, nil)
+		if err != nil {
+			t.Error("expect:", nil, "result:", err)
+			break
+		}
+		err = q.runFind()
+		if err != nil {
+			t.Error("expect:", nil, "result:", err)
+			break
+		}
+		results = append(results, utils.A(q.response["results"])...)
+		if q.response["nextCursor"] == nil {
+			break
+		}
+		cursor = utils.S(q.response["nextCursor"])
+	}
+	expect = types.S{
+		types.M{"objectId": "02", "name": "n2"},
+		types.M{"objectId": "01", "name": "n1"},
+		types.M{"objectId": "03", "name": "n3"},
+	}
+	if reflect.DeepEqual(expect, results) == false {
+		t.Error("expect:", expect, "result:", results)
+	}
+	orm.TomatoDBController.DeleteEverything()
+}
+
+func Test_Stream(t *testing.T) {
+	var object types.M
+	var className string
+	var q *Query
+	var err error
+	var chunks []types.S
+	var expect []types.S
+	handle := func(results types.S) error {
+		chunks = append(chunks, results)
+		return nil
+	}
+	/**********************************************************/
+	initEnv()
+	className = "user"
+	q, _ = NewQuery(Master(), className, types.M{}, types.M{"count": true}, nil)
+	err = q.Stream(2, handle)
+	if reflect.DeepEqual(errs.E(errs.InvalidQuery, "Cannot use count with streaming."), err) == false {
+		t.Error("expect:", "Cannot use count with streaming.", "result:", err)
+	}
+	orm.TomatoDBController.DeleteEverything()
+	/**********************************************************/
+	initEnv()
+	className = "user"
+	object = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+	}
+	orm.Adapter.CreateClass(className, object)
+	for _, id := range []string{"01", "02", "03"} {
+		orm.Adapter.CreateObject(className, types.M{}, types.M{"objectId": id, "key": "hello"})
+	}
+	initEnv()
+	chunks = nil
+	q, _ = NewQuery(Master(), className, types.M{}, types.M{}, nil)
+	err = q.Stream(2, handle)
+	expect = []types.S{
+		types.S{
+			types.M{"objectId": "01", "key": "hello"},
+			types.M{"objectId": "02", "key": "hello"},
+		},
+		types.S{
+			types.M{"objectId": "03", "key": "hello"},
+		},
+	}
+	if err != nil || reflect.DeepEqual(expect, chunks) == false {
+		t.Error("expect:", expect, "result:", chunks, err)
+	}
+	orm.TomatoDBController.DeleteEverything()
+	/**********************************************************/
+	initEnv()
+	className = "user"
+	object = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+	}
+	orm.Adapter.CreateClass(className, object)
+	for _, id := range []string{"01", "02", "03"} {
+		orm.Adapter.CreateObject(className, types.M{}, types.M{"objectId": id, "key": "hello"})
+	}
+	initEnv()
+	chunks = nil
+	q, _ = NewQuery(Master(), className, types.M{}, types.M{"limit": 0}, nil)
+	err = q.Stream(2, handle)
+	if err != nil || chunks != nil {
+		t.Error("expect:", nil, "result:", chunks, err)
+	}
+	orm.TomatoDBController.DeleteEverything()
+	/**********************************************************/
+	initEnv()
+	className = "_User"
+	object = types.M{
+		"fields": types.M{
+			"username": types.M{"type": "String"},
+			"email":    types.M{"type": "String"},
+		},
+	}
+	orm.Adapter.CreateClass(className, object)
+	orm.Adapter.CreateObject(className, object, types.M{"objectId": "01", "username": "joe", "email": "joe@g.cn"})
+	orm.Adapter.CreateObject(className, object, types.M{"objectId": "02", "username": "jack", "email": "jack@g.cn"})
+	initEnv()
+	chunks = nil
+	q, _ = NewQuery(Nobody(), className, types.M{}, types.M{}, nil)
+	err = q.Stream(2, handle)
+	expect = []types.S{
+		types.S{
+			types.M{"objectId": "01", "username": "joe"},
+			types.M{"objectId": "02", "username": "jack"},
+		},
+	}
+	if err != nil || reflect.DeepEqual(expect, chunks) == false {
+		t.Error("expect:", expect, "result:", chunks, err)
+	}
+	orm.TomatoDBController.DeleteEverything()
+	/**********************************************************/
+	initEnv()
+	className = "user"
+	object = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+	}
+	orm.Adapter.CreateClass(className, object)
+	for _, id := range []string{"01", "02", "03"} {
+		orm.Adapter.CreateObject(className, types.M{}, types.M{"objectId": id, "key": "hello"})
+	}
+	initEnv()
+	chunks = nil
+	q, _ = NewQuery(Master(), className, types.M{}, types.M{}, nil)
+	err = q.Stream(2, func(results types.S) error {
+		chunks = append(chunks, results)
+		return errs.E(errs.InternalServerError, "write failed")
+	})
+	if reflect.DeepEqual(errs.E(errs.InternalServerError, "write failed"), err) == false || len(chunks) != 1 {
+		t.Error("expect:", "write failed", "result:", err, len(chunks))
+	}
+	orm.TomatoDBController.DeleteEverything()
 }
 
 func Test_runCount(t *testing.T) {
@@ -4432,6 +4598,203 @@ func Test_replaceEqualityConstraint(t *testing.T) {
 	}
 }
 
+func Test_cursorSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   []string
+		want    []string
+		wantErr error
+	}{
+		{name: "1", order: nil, want: []string{"objectId"}},
+		{name: "2", order: []string{"-score", "name"}, want: []string{"-score", "name", "objectId"}},
+		{name: "3", order: []string{"-objectId", "score"}, want: []string{"-objectId"}},
+		{name: "4", order: []string{"$score"}, wantErr: errs.E(errs.InvalidQuery, "Cannot use cursor with order: $score")},
+	}
+	for _, tt := range tests {
+		got, err := cursorSort(tt.order)
+		if !reflect.DeepEqual(err, tt.wantErr) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. cursorSort() = %v, %v, want %v, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func Test_encodeCursor(t *testing.T) {
+	var sortKeys []string
+	var object types.M
+	var cursor string
+	var result *queryCursor
+	var expect *queryCursor
+	var err error
+	/*****************************************************************/
+	sortKeys = []string{"-score", "player.name", "objectId"}
+	object = types.M{
+		"objectId": "1001",
+		"score":    10.0,
+		"player":   types.M{"name": "joe"},
+	}
+	cursor, err = encodeCursor(sortKeys, object)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	result, err = decodeCursor(cursor)
+	expect = &queryCursor{
+		Sort:   []string{"-score", "player.name", "objectId"},
+		Values: []interface{}{10.0, "joe", "1001"},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*****************************************************************/
+	sortKeys = []string{"score", "objectId"}
+	object = types.M{
+		"objectId": "1001",
+	}
+	cursor, err = encodeCursor(sortKeys, object)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	result, err = decodeCursor(cursor)
+	expect = &queryCursor{
+		Sort:   []string{"score", "objectId"},
+		Values: []interface{}{nil, "1001"},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*****************************************************************/
+	sortKeys = []string{"score", "objectId"}
+	object = types.M{
+		"score": 10.0,
+	}
+	_, err = encodeCursor(sortKeys, object)
+	if reflect.DeepEqual(errs.E(errs.InvalidQuery, "Cannot create cursor, missing value of key: objectId"), err) == false {
+		t.Error("expect:", "missing value of key", "result:", err)
+	}
+	/*****************************************************************/
+	_, err = decodeCursor("hello")
+	if reflect.DeepEqual(errs.E(errs.InvalidQuery, "invalid cursor"), err) == false {
+		t.Error("expect:", "invalid cursor", "result:", err)
+	}
+}
+
+func Test_cursorConstraint(t *testing.T) {
+	var sortKeys []string
+	var values []interface{}
+	var result types.M
+	var expect types.M
+	/*****************************************************************/
+	sortKeys = []string{"objectId"}
+	values = []interface{}{"1001"}
+	result = cursorConstraint(sortKeys, values, false)
+	expect = types.M{
+		"$or": types.S{
+			types.M{"objectId": types.M{"$gt": "1001"}},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************************/
+	sortKeys = []string{"-createdAt", "score", "objectId"}
+	values = []interface{}{"2006-01-02T15:04:05.000Z", 10.0, "1001"}
+	result = cursorConstraint(sortKeys, values, false)
+	date := types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"}
+	expect = types.M{
+		"$or": types.S{
+			types.M{"createdAt": types.M{"$lt": date}},
+			types.M{"createdAt": date, "score": types.M{"$gt": 10.0}},
+			types.M{"createdAt": date, "score": 10.0, "objectId": types.M{"$gt": "1001"}},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************************/
+	// MongoDB 中 null 最小，升序时排在最前，降序时排在最后
+	sortKeys = []string{"score", "objectId"}
+	values = []interface{}{nil, "1001"}
+	result = cursorConstraint(sortKeys, values, false)
+	expect = types.M{
+		"$or": types.S{
+			types.M{"score": types.M{"$ne": nil}},
+			types.M{"score": nil, "objectId": types.M{"$gt": "1001"}},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************************/
+	sortKeys = []string{"-score", "objectId"}
+	values = []interface{}{nil, "1001"}
+	result = cursorConstraint(sortKeys, values, false)
+	expect = types.M{
+		"$or": types.S{
+			types.M{"score": nil, "objectId": types.M{"$gt": "1001"}},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************************/
+	sortKeys = []string{"-score", "objectId"}
+	values = []interface{}{10.0, "1001"}
+	result = cursorConstraint(sortKeys, values, false)
+	expect = types.M{
+		"$or": types.S{
+			types.M{"$or": types.S{
+				types.M{"score": types.M{"$lt": 10.0}},
+				types.M{"score": nil},
+			}},
+			types.M{"score": 10.0, "objectId": types.M{"$gt": "1001"}},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************************/
+	// PostgreSQL 中 null 最大，升序时排在最后，降序时排在最前
+	sortKeys = []string{"score", "objectId"}
+	values = []interface{}{10.0, "1001"}
+	result = cursorConstraint(sortKeys, values, true)
+	expect = types.M{
+		"$or": types.S{
+			types.M{"$or": types.S{
+				types.M{"score": types.M{"$gt": 10.0}},
+				types.M{"score": nil},
+			}},
+			types.M{"score": 10.0, "objectId": types.M{"$gt": "1001"}},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************************/
+	sortKeys = []string{"score", "objectId"}
+	values = []interface{}{nil, "1001"}
+	result = cursorConstraint(sortKeys, values, true)
+	expect = types.M{
+		"$or": types.S{
+			types.M{"score": nil, "objectId": types.M{"$gt": "1001"}},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************************/
+	sortKeys = []string{"-score", "objectId"}
+	values = []interface{}{nil, "1001"}
+	result = cursorConstraint(sortKeys, values, true)
+	expect = types.M{
+		"$or": types.S{
+			types.M{"score": types.M{"$ne": nil}},
+			types.M{"score": nil, "objectId": types.M{"$gt": "1001"}},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
 func initEnv() {
 	orm.InitOrm(getAdapter())
 }
@@ -40,6 +40,32 @@ func Find(auth *Auth, className string, where, options types.M, clientSDK map[st
 	return query.Execute()
 }
 
+// FindStream 以流的形式查询对象，适用于结果集很大的查询，每 chunkSize 个对象调用一次 handle
+// 查询前同样会运行 beforeFind 回调，结果中的每一批对象会分别展开 include 并运行 afterFind 回调
+func FindStream(auth *Auth, className string, where, options types.M, clientSDK map[string]string, chunkSize int, handle func(results types.S) error) error {
+
+	err := enforceRoleSecurity("find", className, auth)
+	if err != nil {
+		return err
+	}
+	w, o, err := maybeRunQueryTrigger(cloud.TypeBeforeFind, className, where, options, auth)
+	if err != nil {
+		return err
+	}
+	if w != nil {
+		where = w
+	}
+	if o != nil {
+		options = o
+	}
+	query, err := NewQuery(auth, className, where, options, clientSDK)
+	if err != nil {
+		return err
+	}
+
+	return query.Stream(chunkSize, handle)
+}
+
 // Get ...
 func Get(auth *Auth, className, objectID string, options types.M, clientSDK map[string]string) (types.M, error) {
 
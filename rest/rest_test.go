@@ -138,6 +138,81 @@ func Test_Find(t *testing.T) {
 	orm.TomatoDBController.DeleteEverything()
 }
 
+func Test_FindStream(t *testing.T) {
+	var schema types.M
+	var className string
+	var chunks []types.S
+	var expect []types.S
+	var err error
+	handle := func(results types.S) error {
+		chunks = append(chunks, results)
+		return nil
+	}
+	/********************************************************/
+	initEnv()
+	className = "user"
+	schema = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+	}
+	orm.Adapter.CreateClass(className, schema)
+	orm.Adapter.CreateObject(className, schema, types.M{"objectId": "01", "key": "hello"})
+	orm.Adapter.CreateObject(className, schema, types.M{"objectId": "02", "key": "world"})
+	orm.Adapter.CreateObject(className, schema, types.M{"objectId": "03", "key": "hello"})
+	chunks = nil
+	err = FindStream(Master(), className, types.M{"key": "hello"}, types.M{}, nil, 1, handle)
+	expect = []types.S{
+		types.S{types.M{"objectId": "01", "key": "hello"}},
+		types.S{types.M{"objectId": "03", "key": "hello"}},
+	}
+	if err != nil || reflect.DeepEqual(expect, chunks) == false {
+		t.Error("expect:", expect, "result:", chunks, err)
+	}
+	orm.TomatoDBController.DeleteEverything()
+	/********************************************************/
+	initEnv()
+	className = "user"
+	schema = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+	}
+	orm.Adapter.CreateClass(className, schema)
+	orm.Adapter.CreateObject(className, schema, types.M{"objectId": "01", "key": "hello"})
+	orm.Adapter.CreateObject(className, schema, types.M{"objectId": "02", "key": "world"})
+	orm.Adapter.CreateObject(className, schema, types.M{"objectId": "03", "key": "hello"})
+	cloud.BeforeFind(className, func(request cloud.TriggerRequest, response cloud.Response) {
+		response.Success(types.M{"limit": 2})
+	})
+	cloud.AfterFind(className, func(request cloud.TriggerRequest, response cloud.Response) {
+		for _, v := range request.Objects {
+			utils.M(v)["key"] = "changed"
+		}
+		response.Success(request.Objects)
+	})
+	chunks = nil
+	err = FindStream(Master(), className, types.M{}, types.M{}, nil, 1, handle)
+	expect = []types.S{
+		types.S{types.M{"objectId": "01", "key": "changed"}},
+		types.S{types.M{"objectId": "02", "key": "changed"}},
+	}
+	if err != nil || reflect.DeepEqual(expect, chunks) == false {
+		t.Error("expect:", expect, "result:", chunks, err)
+	}
+	cloud.UnregisterAll()
+	orm.TomatoDBController.DeleteEverything()
+	/********************************************************/
+	initEnv()
+	className = "_Installation"
+	chunks = nil
+	err = FindStream(Nobody(), className, types.M{}, types.M{}, nil, 1, handle)
+	if reflect.DeepEqual(errs.E(errs.OperationForbidden, "Clients aren't allowed to perform the find operation on the installation collection."), err) == false || chunks != nil {
+		t.Error("expect:", "OperationForbidden", "result:", chunks, err)
+	}
+	orm.TomatoDBController.DeleteEverything()
+}
+
 func Test_Get(t *testing.T) {
 	var object, schema types.M
 	var className string
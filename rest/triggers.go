@@ -27,10 +27,21 @@ func getRequest(triggerType string, auth *Auth, parseObject, originalParseObject
 	if auth.InstallationID != "" {
 		request.InstallationID = auth.InstallationID
 	}
+	setRequestInfo(&request, auth)
 
 	return request
 }
 
+// setRequestInfo 写入当前请求的客户端地址、请求头与 Context ，同一个 auth 的各个回调使用同一个 Context
+func setRequestInfo(request *cloud.TriggerRequest, auth *Auth) {
+	if auth.Context == nil {
+		auth.Context = types.M{}
+	}
+	request.IP = auth.IP
+	request.Headers = auth.Headers
+	request.Context = auth.Context
+}
+
 func getResponse(request cloud.TriggerRequest) *cloud.TriggerResponse {
 	response := &cloud.TriggerResponse{
 		Request: request,
@@ -56,6 +67,7 @@ func getRequestQuery(triggerType string, auth *Auth, query types.M, count bool)
 	if auth.InstallationID != "" {
 		request.InstallationID = auth.InstallationID
 	}
+	setRequestInfo(&request, auth)
 
 	return request
 }
@@ -75,6 +87,44 @@ func maybeRunTrigger(triggerType string, auth *Auth, parseObject, originalParseO
 	return response.Response, response.Err
 }
 
+// MaybeRunLoginTrigger 执行 beforeLogin afterLogin afterLogout 回调
+// beforeLogin afterLogin 的 object 为 _User 对象， afterLogout 的 object 为 _Session 对象
+// beforeLogin 返回错误时应拒绝登录
+func MaybeRunLoginTrigger(triggerType string, auth *Auth, object types.M) error {
+	className := "_User"
+	if triggerType == cloud.TypeAfterLogout {
+		className = "_Session"
+	}
+	if object == nil || cloud.TriggerExists(triggerType, className) == false {
+		return nil
+	}
+	parseObject := utils.CopyMapM(object)
+	parseObject["className"] = className
+	delete(parseObject, "password")
+	_, err := maybeRunTrigger(triggerType, auth, parseObject, nil)
+	return err
+}
+
+// MaybeRunFileTrigger 执行 beforeSaveFile afterSaveFile beforeDeleteFile 回调
+// beforeSaveFile 中可以修改 file 的文件名、类型与内容，返回错误时应拒绝保存或删除文件
+func MaybeRunFileTrigger(triggerType string, auth *Auth, file *cloud.File) error {
+	trigger := cloud.GetTrigger(triggerType, cloud.FileClassName)
+	if trigger == nil || file == nil {
+		return nil
+	}
+	request := getRequest(triggerType, auth, nil, nil)
+	request.File = file
+	response := getResponse(request)
+	trigger(request, response)
+	if response.Err != nil {
+		return response.Err
+	}
+	if triggerType == cloud.TypeBeforeSaveFile {
+		file.Size = len(file.Data)
+	}
+	return nil
+}
+
 func maybeRunQueryTrigger(triggerType, className string, restWhere, restOptions types.M, auth *Auth) (types.M, types.M, error) {
 	trigger := cloud.GetTrigger(triggerType, className)
 	if trigger == nil {
@@ -42,3 +42,137 @@ func Test_maybeRunTrigger(t *testing.T) {
 	}
 	cloud.UnregisterAll()
 }
+
+func Test_MaybeRunLoginTrigger(t *testing.T) {
+	var err error
+	var expectErr error
+	var object types.M
+	/****************************************************************************************/
+	err = MaybeRunLoginTrigger(cloud.TypeBeforeLogin, Nobody(), types.M{"username": "joe"})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	/****************************************************************************************/
+	cloud.BeforeLogin(func(request cloud.TriggerRequest, response cloud.Response) {
+		object = request.Object
+		if request.Object["banned"] == true {
+			response.Error(errs.ObjectNotFound, "user is banned")
+			return
+		}
+		response.Success(nil)
+	})
+	err = MaybeRunLoginTrigger(cloud.TypeBeforeLogin, Nobody(), types.M{"username": "joe", "password": "123456"})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	if reflect.DeepEqual(types.M{"className": "_User", "username": "joe"}, object) == false {
+		t.Error("expect:", types.M{"className": "_User", "username": "joe"}, "result:", object)
+	}
+	err = MaybeRunLoginTrigger(cloud.TypeBeforeLogin, Nobody(), types.M{"username": "joe", "banned": true})
+	expectErr = errs.E(errs.ObjectNotFound, "user is banned")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/****************************************************************************************/
+	cloud.AfterLogout(func(request cloud.TriggerRequest, response cloud.Response) {
+		object = request.Object
+		response.Success(nil)
+	})
+	err = MaybeRunLoginTrigger(cloud.TypeAfterLogout, Nobody(), types.M{"sessionToken": "r:abc"})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	if reflect.DeepEqual(types.M{"className": "_Session", "sessionToken": "r:abc"}, object) == false {
+		t.Error("expect:", types.M{"className": "_Session", "sessionToken": "r:abc"}, "result:", object)
+	}
+	cloud.UnregisterAll()
+}
+
+func Test_MaybeRunFileTrigger(t *testing.T) {
+	var err error
+	var expectErr error
+	var file *cloud.File
+	var expect *cloud.File
+	/****************************************************************************************/
+	file = &cloud.File{Name: "a.txt", ContentType: "text/plain", Size: 5, Data: []byte("hello")}
+	err = MaybeRunFileTrigger(cloud.TypeBeforeSaveFile, Nobody(), file)
+	expect = &cloud.File{Name: "a.txt", ContentType: "text/plain", Size: 5, Data: []byte("hello")}
+	if err != nil || reflect.DeepEqual(expect, file) == false {
+		t.Error("expect:", expect, "result:", file, err)
+	}
+	/****************************************************************************************/
+	cloud.BeforeSaveFile(func(request cloud.TriggerRequest, response cloud.Response) {
+		if request.File.ContentType == "application/x-msdownload" {
+			response.Error(errs.FileSaveError, "file type is not allowed")
+			return
+		}
+		request.File.Name = "b.txt"
+		request.File.Data = []byte("hello world")
+		response.Success(nil)
+	})
+	file = &cloud.File{Name: "a.txt", ContentType: "text/plain", Size: 5, Data: []byte("hello")}
+	err = MaybeRunFileTrigger(cloud.TypeBeforeSaveFile, Nobody(), file)
+	expect = &cloud.File{Name: "b.txt", ContentType: "text/plain", Size: 11, Data: []byte("hello world")}
+	if err != nil || reflect.DeepEqual(expect, file) == false {
+		t.Error("expect:", expect, "result:", file, err)
+	}
+	file = &cloud.File{Name: "a.exe", ContentType: "application/x-msdownload", Size: 5, Data: []byte("hello")}
+	err = MaybeRunFileTrigger(cloud.TypeBeforeSaveFile, Nobody(), file)
+	expectErr = errs.E(errs.FileSaveError, "file type is not allowed")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/****************************************************************************************/
+	cloud.BeforeDeleteFile(func(request cloud.TriggerRequest, response cloud.Response) {
+		if request.Master == false {
+			response.Error(errs.FileDeleteError, "permission denied")
+			return
+		}
+		response.Success(nil)
+	})
+	err = MaybeRunFileTrigger(cloud.TypeBeforeDeleteFile, Master(), &cloud.File{Name: "a.txt"})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	err = MaybeRunFileTrigger(cloud.TypeBeforeDeleteFile, Nobody(), &cloud.File{Name: "a.txt"})
+	expectErr = errs.E(errs.FileDeleteError, "permission denied")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	cloud.UnregisterAll()
+}
+
+func Test_triggerContext(t *testing.T) {
+	var auth *Auth
+	var result types.M
+	var expect types.M
+	/****************************************************************************************/
+	cloud.BeforeSave("post", func(request cloud.TriggerRequest, response cloud.Response) {
+		request.Context["checked"] = request.IP + " " + request.Headers["User-Agent"]
+		response.Success(nil)
+	})
+	cloud.AfterSave("post", func(request cloud.TriggerRequest, response cloud.Response) {
+		result = request.Context
+		response.Success(nil)
+	})
+	auth = &Auth{
+		IP:      "127.0.0.1",
+		Headers: map[string]string{"User-Agent": "tomato"},
+		Context: types.M{"source": "import"},
+	}
+	maybeRunTrigger(cloud.TypeBeforeSave, auth, types.M{"className": "post"}, nil)
+	maybeRunTrigger(cloud.TypeAfterSave, auth, types.M{"className": "post"}, nil)
+	expect = types.M{"source": "import", "checked": "127.0.0.1 tomato"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/****************************************************************************************/
+	auth = Master()
+	maybeRunTrigger(cloud.TypeBeforeSave, auth, types.M{"className": "post"}, nil)
+	maybeRunTrigger(cloud.TypeAfterSave, auth, types.M{"className": "post"}, nil)
+	expect = types.M{"checked": " "}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	cloud.UnregisterAll()
+}
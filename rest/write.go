@@ -19,6 +19,7 @@ import (
 	"github.com/lfq7413/tomato/orm"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
+	"github.com/lfq7413/tomato/webhook"
 )
 
 // Write ...
@@ -116,6 +117,10 @@ func (w *Write) Execute() (types.M, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = w.validateFieldRules()
+	if err != nil {
+		return nil, err
+	}
 	err = w.transformUser()
 	if err != nil {
 		return nil, err
@@ -132,6 +137,10 @@ func (w *Write) Execute() (types.M, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = w.runAfterLoginTrigger()
+	if err != nil {
+		return nil, err
+	}
 	err = w.handleFollowup()
 	if err != nil {
 		return nil, err
@@ -174,7 +183,7 @@ func (w *Write) validateClientClassCreation() error {
 		}
 	}
 	// 允许操作已存在的表
-	schema := orm.TomatoDBController.LoadSchema(nil)
+	schema := w.auth.db().LoadSchema(nil)
 	hasClass := schema.HasClass(w.className)
 	if hasClass {
 		return nil
@@ -185,7 +194,7 @@ func (w *Write) validateClientClassCreation() error {
 
 // validateSchema 校验数据与权限是否允许进行当前操作
 func (w *Write) validateSchema() error {
-	return orm.TomatoDBController.ValidateObject(w.className, w.data, w.query, w.RunOptions)
+	return w.auth.db().ValidateObject(w.className, w.data, w.query, w.RunOptions)
 }
 
 // handleInstallation 处理 _Installation 表的操作
@@ -245,7 +254,7 @@ func (w *Write) handleInstallation() error {
 	}
 
 	// 查找跟提交的 objectId installationId deviceToken 相同的记录
-	results, err := orm.TomatoDBController.Find("_Installation", types.M{"$or": orQueries}, types.M{})
+	results, err := w.auth.db().Find("_Installation", types.M{"$or": orQueries}, types.M{})
 	if err != nil {
 		return err
 	}
@@ -332,7 +341,7 @@ func (w *Write) handleInstallation() error {
 			if w.data["appIdentifier"] != nil {
 				delQuery["appIdentifier"] = w.data["appIdentifier"]
 			}
-			err := orm.TomatoDBController.Destroy("_Installation", delQuery, types.M{})
+			err := w.auth.db().Destroy("_Installation", delQuery, types.M{})
 			if err != nil {
 				if errs.GetErrorCode(err) == errs.ObjectNotFound {
 
@@ -355,7 +364,7 @@ func (w *Write) handleInstallation() error {
 			delQuery := types.M{
 				"objectId": idMatch["objectId"],
 			}
-			err := orm.TomatoDBController.Destroy("_Installation", delQuery, nil)
+			err := w.auth.db().Destroy("_Installation", delQuery, nil)
 			if err != nil {
 				if errs.GetErrorCode(err) == errs.ObjectNotFound {
 
@@ -389,7 +398,7 @@ func (w *Write) handleInstallation() error {
 					if w.data["appIdentifier"] != nil {
 						delQuery["appIdentifier"] = w.data["appIdentifier"]
 					}
-					err := orm.TomatoDBController.Destroy("_Installation", delQuery, nil)
+					err := w.auth.db().Destroy("_Installation", delQuery, nil)
 					if err != nil {
 						if errs.GetErrorCode(err) == errs.ObjectNotFound {
 
@@ -460,7 +469,7 @@ func (w *Write) handleSession() error {
 			sessionData[k] = v
 		}
 		// 以 Master 权限去创建 session
-		write, err := NewWrite(Master(), "_Session", nil, sessionData, types.M{}, w.clientSDK)
+		write, err := NewWrite(w.auth.master(), "_Session", nil, sessionData, types.M{}, w.clientSDK)
 		if err != nil {
 			return err
 		}
@@ -568,6 +577,13 @@ func (w *Write) handleAuthData(authData types.M) error {
 				"location": w.location(),
 			}
 
+			// 登录前回调，在更新 authData 之前执行，返回错误时拒绝登录
+			err = MaybeRunLoginTrigger(cloud.TypeBeforeLogin, w.auth, userResult)
+			if err != nil {
+				return err
+			}
+			w.storage["authDataLogin"] = true
+
 			// 未修改任何数据，直接返回
 			if len(mutatedAuthData) == 0 {
 				return nil
@@ -591,7 +607,7 @@ func (w *Write) handleAuthData(authData types.M) error {
 			w.response["response"] = userResult
 
 			// 更新数据库中的 authData 字段
-			_, err = orm.TomatoDBController.Update(w.className, types.M{"objectId": w.data["objectId"]}, types.M{"authData": mutatedAuthData}, types.M{}, false)
+			_, err = w.auth.db().Update(w.className, types.M{"objectId": w.data["objectId"]}, types.M{"authData": mutatedAuthData}, types.M{}, false)
 			return err
 		} else if w.query != nil && w.query["objectId"] != nil {
 			// 存在一个用户，并且当前为 update 请求，校验 objectId 是否一致
@@ -647,7 +663,7 @@ func (w *Write) findUsersWithAuthData(authData types.M) (types.S, error) {
 			"$or": query,
 		}
 		var err error
-		findPromise, err = orm.TomatoDBController.Find(w.className, where, types.M{})
+		findPromise, err = w.auth.db().Find(w.className, where, types.M{})
 		if err != nil {
 			return nil, err
 		}
@@ -726,6 +742,58 @@ func (w *Write) setRequiredFieldsIfNeeded() error {
 	return nil
 }
 
+// validateFieldRules 按照类定义中字段的校验规则处理写入数据
+// create 请求时，未设置的字段使用 defaultValue ； update 请求时，删除设置了 defaultValue 的字段会恢复为默认值
+// 设置了 required 的字段不能缺少、不能删除，其他规则参考 orm.ValidateFieldValue
+func (w *Write) validateFieldRules() error {
+	if w.data == nil {
+		return nil
+	}
+	schema, err := w.auth.db().LoadSchema(nil).GetOneSchema(w.className, false, nil)
+	if err != nil {
+		return err
+	}
+	fields := utils.M(schema["fields"])
+	for fieldName, v := range fields {
+		fieldType := utils.M(v)
+		if fieldType == nil {
+			continue
+		}
+		value, exists := w.data[fieldName]
+		isDelete := false
+		if op := utils.M(value); op != nil && utils.S(op["__op"]) == "Delete" {
+			isDelete = true
+		}
+
+		if defaultValue, ok := fieldType["defaultValue"]; ok && defaultValue != nil {
+			if (w.query == nil && value == nil) || isDelete {
+				value = utils.DeepCopy(defaultValue)
+				w.data[fieldName] = value
+				exists = true
+				isDelete = false
+				// 默认值需要返回给客户端
+				changed, _ := w.storage["fieldsChangedByTrigger"].([]string)
+				w.storage["fieldsChangedByTrigger"] = append(changed, fieldName)
+			}
+		}
+
+		if orm.FieldIsRequired(fieldType) {
+			if w.query == nil && value == nil {
+				return errs.E(errs.MissingRequiredFieldError, fieldName+" is required.")
+			}
+			if w.query != nil && exists && (value == nil || isDelete) {
+				return errs.E(errs.MissingRequiredFieldError, fieldName+" is required.")
+			}
+		}
+
+		err = orm.ValidateFieldValue(fieldName, fieldType, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // transformUser 转换用户数据，仅处理 _User 表
 func (w *Write) transformUser() error {
 	if w.className != "_User" {
@@ -747,7 +815,7 @@ func (w *Write) transformUser() error {
 				"objectId":  w.objectID(),
 			},
 		}
-		query, err := NewQuery(Master(), "_Session", where, types.M{}, w.clientSDK)
+		query, err := NewQuery(w.auth.master(), "_Session", where, types.M{}, w.clientSDK)
 		if err != nil {
 			return err
 		}
@@ -765,8 +833,7 @@ func (w *Write) transformUser() error {
 		}
 	}
 
-	// 处理密码，计算 sha256
-	// TODO 后续需要加盐提高安全性
+	// 处理密码，使用 bcrypt 计算加盐哈希
 	if w.data["password"] != nil {
 		// 检测密码
 		err := w.validatePasswordPolicy()
@@ -781,7 +848,11 @@ func (w *Write) transformUser() error {
 				w.storage["generateNewSession"] = true
 			}
 		}
-		w.data["_hashed_password"] = utils.Hash(utils.S(w.data["password"]))
+		hashedPassword, err := utils.Hash(utils.S(w.data["password"]), config.TConfig.PasswordHashCost)
+		if err != nil {
+			return errs.E(errs.ValidationError, "Password is invalid: "+err.Error())
+		}
+		w.data["_hashed_password"] = hashedPassword
 		delete(w.data, "password")
 	}
 
@@ -820,7 +891,7 @@ func (w *Write) validateUserName() error {
 	option := types.M{
 		"limit": 1,
 	}
-	results, err := orm.TomatoDBController.Find(w.className, where, option)
+	results, err := w.auth.db().Find(w.className, where, option)
 	if err != nil {
 		return err
 	}
@@ -855,7 +926,7 @@ func (w *Write) validateEmail() error {
 	option := types.M{
 		"limit": 1,
 	}
-	results, err := orm.TomatoDBController.Find(w.className, where, option)
+	results, err := w.auth.db().Find(w.className, where, option)
 	if err != nil {
 		return err
 	}
@@ -902,7 +973,7 @@ func (w *Write) validatePasswordRequirements() error {
 		} else {
 			// username 不存在时，从数据库中取出再去检测
 			query := types.M{"objectId": w.objectID()}
-			results, err := orm.TomatoDBController.Find("_User", query, types.M{})
+			results, err := w.auth.db().Find("_User", query, types.M{})
 			if err != nil {
 				return err
 			}
@@ -929,7 +1000,7 @@ func (w *Write) validatePasswordHistory() error {
 	options := types.M{
 		"keys": []string{"_password_history", "_hashed_password"},
 	}
-	results, err := orm.TomatoDBController.Find("_User", query, options)
+	results, err := w.auth.db().Find("_User", query, options)
 	if err != nil {
 		return err
 	}
@@ -948,6 +1019,7 @@ func (w *Write) validatePasswordHistory() error {
 	}
 	oldPasswords = append(oldPasswords, utils.S(user["password"]))
 	newPassword := utils.S(w.data["password"])
+	// 历史密码中可能同时存在 bcrypt 哈希与旧版 sha256 哈希， Compare 可同时校验两种格式
 	for _, hash := range oldPasswords {
 		if utils.Compare(newPassword, hash) {
 			return errs.E(errs.ValidationError, "New password should not be the same as last "+strconv.Itoa(config.TConfig.MaxPasswordHistory)+" passwords.")
@@ -1019,7 +1091,7 @@ func (w *Write) runDatabaseOperation() error {
 			options := types.M{
 				"keys": []string{"_password_history", "_hashed_password"},
 			}
-			results, err := orm.TomatoDBController.Find("_User", query, options)
+			results, err := w.auth.db().Find("_User", query, options)
 			if err != nil {
 				return err
 			}
@@ -1040,7 +1112,7 @@ func (w *Write) runDatabaseOperation() error {
 			w.data["_password_history"] = oldPasswords
 		}
 		// 执行更新
-		response, err := orm.TomatoDBController.Update(w.className, w.query, w.data, w.RunOptions, false)
+		response, err := w.auth.db().Update(w.className, w.query, w.data, w.RunOptions, false)
 		if err != nil {
 			return err
 		}
@@ -1078,7 +1150,7 @@ func (w *Write) runDatabaseOperation() error {
 		}
 
 		// 创建对象
-		err := orm.TomatoDBController.Create(w.className, w.data, w.RunOptions)
+		err := w.auth.db().Create(w.className, w.data, w.RunOptions)
 		if err != nil {
 			if w.className != "_User" {
 				return err
@@ -1092,7 +1164,7 @@ func (w *Write) runDatabaseOperation() error {
 					"username": w.data["username"],
 					"objectId": types.M{"$ne": w.objectID()},
 				}
-				results, err := orm.TomatoDBController.Find(w.className, where, types.M{"limit": 1})
+				results, err := w.auth.db().Find(w.className, where, types.M{"limit": 1})
 				if err != nil {
 					return err
 				}
@@ -1106,7 +1178,7 @@ func (w *Write) runDatabaseOperation() error {
 					"email":    w.data["email"],
 					"objectId": types.M{"$ne": w.objectID()},
 				}
-				results, err := orm.TomatoDBController.Find(w.className, where, types.M{"limit": 1})
+				results, err := w.auth.db().Find(w.className, where, types.M{"limit": 1})
 				if err != nil {
 					return err
 				}
@@ -1183,7 +1255,7 @@ func (w *Write) createSessionToken() error {
 		}
 	}
 
-	create, err := NewWrite(Master(), "_Session", nil, sessionData, types.M{}, w.clientSDK)
+	create, err := NewWrite(w.auth.master(), "_Session", nil, sessionData, types.M{}, w.clientSDK)
 	if err != nil {
 		return err
 	}
@@ -1205,7 +1277,7 @@ func (w *Write) handleFollowup() error {
 			"user": user,
 		}
 		delete(w.storage, "clearSessions")
-		err := orm.TomatoDBController.Destroy("_Session", sessionQuery, types.M{})
+		err := w.auth.db().Destroy("_Session", sessionQuery, types.M{})
 		if err != nil {
 			return err
 		}
@@ -1228,6 +1300,15 @@ func (w *Write) handleFollowup() error {
 	return nil
 }
 
+// runAfterLoginTrigger 第三方登录成功并创建 session 后，运行登录后回调，回调结果不影响登录
+func (w *Write) runAfterLoginTrigger() error {
+	if w.storage["authDataLogin"] == nil || w.response == nil {
+		return nil
+	}
+	MaybeRunLoginTrigger(cloud.TypeAfterLogin, w.auth, utils.M(w.response["response"]))
+	return nil
+}
+
 // runAfterTrigger 运行数据修改后的回调函数
 func (w *Write) runAfterTrigger() error {
 	if w.response == nil || w.response["response"] == nil {
@@ -1239,7 +1320,8 @@ func (w *Write) runAfterTrigger() error {
 	if livequery.TLiveQuery != nil {
 		hasLiveQuery = livequery.TLiveQuery.HasLiveQuery(w.className)
 	}
-	if hasAfterSaveHook == false && hasLiveQuery == false {
+	hasSubscriptions := webhook.HasSubscriptions(w.className)
+	if hasAfterSaveHook == false && hasLiveQuery == false && hasSubscriptions == false {
 		return nil
 	}
 
@@ -1259,15 +1341,27 @@ func (w *Write) runAfterTrigger() error {
 		updatedObject[k] = v
 	}
 
-	if hasLiveQuery {
-		// 尝试通知 LiveQueryServer
-		livequery.TLiveQuery.OnAfterSave(w.className, updatedObject, originalObject)
-	}
+	// 批量请求的事务中，等待事务提交之后再通知
+	w.auth.db().RunAfterCommit(func() {
+		if hasLiveQuery {
+			// 尝试通知 LiveQueryServer
+			livequery.TLiveQuery.OnAfterSave(w.className, updatedObject, originalObject)
+		}
 
-	if hasAfterSaveHook {
-		// TODO 不等待回调返回
-		maybeRunTrigger(cloud.TypeAfterSave, w.auth, updatedObject, originalObject)
-	}
+		if hasSubscriptions {
+			// 发送数据变更事件到订阅地址
+			if originalObject == nil {
+				webhook.Publish(webhook.EventCreate, w.className, updatedObject, nil)
+			} else {
+				webhook.Publish(webhook.EventUpdate, w.className, updatedObject, originalObject)
+			}
+		}
+
+		if hasAfterSaveHook {
+			// TODO 不等待回调返回
+			maybeRunTrigger(cloud.TypeAfterSave, w.auth, updatedObject, originalObject)
+		}
+	})
 
 	return nil
 }
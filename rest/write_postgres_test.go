@@ -1306,7 +1306,7 @@ func TestPostgres_transformUser(t *testing.T) {
 	w, _ = NewWrite(Master(), "_User", query, data, originalData, nil)
 	w.transformUser()
 	expect = types.M{
-		"_hashed_password": utils.Hash("123456"),
+		"_hashed_password": hashedPasswordOf(w.data, "123456"),
 	}
 	if v, ok := w.data["username"]; ok == false {
 		t.Error("expect:", "username", "result:", v)
@@ -1329,7 +1329,7 @@ func TestPostgres_transformUser(t *testing.T) {
 	expect = types.M{
 		"objectId":         "1001",
 		"username":         "joe",
-		"_hashed_password": utils.Hash("123456"),
+		"_hashed_password": hashedPasswordOf(w.data, "123456"),
 	}
 	if reflect.DeepEqual(expect, w.data) == false {
 		t.Error("expect:", expect, "result:", w.data)
@@ -1420,7 +1420,7 @@ func TestPostgres_transformUser(t *testing.T) {
 	err = w.transformUser()
 	expect = types.M{
 		"objectId":         "1002",
-		"_hashed_password": utils.Hash("123456"),
+		"_hashed_password": hashedPasswordOf(w.data, "123456"),
 	}
 	if err != nil || reflect.DeepEqual(expect, w.data) == false {
 		t.Error("expect:", expect, "result:", w.data, "err:", err)
@@ -1560,7 +1560,7 @@ func TestPostgres_transformUser(t *testing.T) {
 	expect = types.M{
 		"objectId":         "1001",
 		"username":         "joe",
-		"_hashed_password": utils.Hash("123456"),
+		"_hashed_password": hashedPasswordOf(w.data, "123456"),
 		"email":            "a@g.cn",
 	}
 	if reflect.DeepEqual(true, w.storage["sendVerificationEmail"]) == false {
@@ -1587,7 +1587,7 @@ func TestPostgres_transformUser(t *testing.T) {
 	expect = types.M{
 		"objectId":                       "1001",
 		"username":                       "joe",
-		"_hashed_password":               utils.Hash("123456"),
+		"_hashed_password":               hashedPasswordOf(w.data, "123456"),
 		"email":                          "a@g.cn",
 		"emailVerified":                  false,
 		"_email_verify_token_expires_at": utils.TimetoString(time.Now().UTC().Add(180 * time.Second)),
@@ -1632,7 +1632,7 @@ func TestPostgres_transformUser(t *testing.T) {
 	w, _ = NewWrite(&Auth{IsMaster: false, User: types.M{"objectId": "1001"}}, "_User", query, data, originalData, nil)
 	err = w.transformUser()
 	expect = types.M{
-		"_hashed_password": utils.Hash("123456"),
+		"_hashed_password": hashedPasswordOf(w.data, "123456"),
 	}
 	if cache.User.Get("aaaaa") != nil {
 		t.Error("expect:", nil, "result:", cache.User.Get("aaaaa"))
@@ -1254,6 +1254,95 @@ func Test_setRequiredFieldsIfNeeded(t *testing.T) {
 	}
 }
 
+func Test_validateFieldRules(t *testing.T) {
+	var w *Write
+	var className string
+	var query, data types.M
+	var err, expectErr error
+	var expect types.M
+	/***************************************************************/
+	initEnv()
+	className = "post"
+	orm.TomatoDBController.LoadSchema(nil).AddClassIfNotExists(className, types.M{
+		"title":  types.M{"type": "String", "required": true, "minLength": 2.0, "maxLength": 5.0},
+		"code":   types.M{"type": "String", "pattern": "^[a-z]+$"},
+		"status": types.M{"type": "String", "enum": types.S{"draft", "published"}, "defaultValue": "draft"},
+		"score":  types.M{"type": "Number", "min": 0.0, "max": 10.0},
+	}, nil, nil)
+	query = nil
+	data = types.M{"title": "hello"}
+	w, _ = NewWrite(Master(), className, query, data, nil, nil)
+	err = w.validateFieldRules()
+	expect = types.M{"title": "hello", "status": "draft"}
+	if err != nil || reflect.DeepEqual(expect, w.data) == false {
+		t.Error("expect:", expect, "result:", w.data, err)
+	}
+	/***************************************************************/
+	query = nil
+	data = types.M{"score": 1.0}
+	w, _ = NewWrite(Master(), className, query, data, nil, nil)
+	err = w.validateFieldRules()
+	expectErr = errs.E(errs.MissingRequiredFieldError, "title is required.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/***************************************************************/
+	query = types.M{"objectId": "1001"}
+	data = types.M{"title": types.M{"__op": "Delete"}}
+	w, _ = NewWrite(Master(), className, query, data, nil, nil)
+	err = w.validateFieldRules()
+	expectErr = errs.E(errs.MissingRequiredFieldError, "title is required.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/***************************************************************/
+	query = types.M{"objectId": "1001"}
+	data = types.M{"status": types.M{"__op": "Delete"}, "score": 5.0}
+	w, _ = NewWrite(Master(), className, query, data, nil, nil)
+	err = w.validateFieldRules()
+	expect = types.M{"status": "draft", "score": 5.0}
+	if err != nil || reflect.DeepEqual(expect, w.data) == false {
+		t.Error("expect:", expect, "result:", w.data, err)
+	}
+	/***************************************************************/
+	query = types.M{"objectId": "1001"}
+	data = types.M{"score": 11.0}
+	w, _ = NewWrite(Master(), className, query, data, nil, nil)
+	err = w.validateFieldRules()
+	expectErr = errs.E(errs.ValidationError, "score should be less than or equal to 10.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/***************************************************************/
+	query = types.M{"objectId": "1001"}
+	data = types.M{"title": "a"}
+	w, _ = NewWrite(Master(), className, query, data, nil, nil)
+	err = w.validateFieldRules()
+	expectErr = errs.E(errs.ValidationError, "title should have a length of at least 2.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/***************************************************************/
+	query = types.M{"objectId": "1001"}
+	data = types.M{"code": "ABC"}
+	w, _ = NewWrite(Master(), className, query, data, nil, nil)
+	err = w.validateFieldRules()
+	expectErr = errs.E(errs.ValidationError, "code does not match the pattern ^[a-z]+$.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/***************************************************************/
+	query = types.M{"objectId": "1001"}
+	data = types.M{"status": "deleted"}
+	w, _ = NewWrite(Master(), className, query, data, nil, nil)
+	err = w.validateFieldRules()
+	expectErr = errs.E(errs.ValidationError, "status should be one of the allowed values.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	orm.TomatoDBController.DeleteEverything()
+}
+
 func Test_transformUser(t *testing.T) {
 	var schema, object types.M
 	var w *Write
@@ -1293,7 +1382,7 @@ func Test_transformUser(t *testing.T) {
 	w, _ = NewWrite(Master(), "_User", query, data, originalData, nil)
 	w.transformUser()
 	expect = types.M{
-		"_hashed_password": utils.Hash("123456"),
+		"_hashed_password": hashedPasswordOf(w.data, "123456"),
 	}
 	if v, ok := w.data["username"]; ok == false {
 		t.Error("expect:", "username", "result:", v)
@@ -1316,7 +1405,7 @@ func Test_transformUser(t *testing.T) {
 	expect = types.M{
 		"objectId":         "1001",
 		"username":         "joe",
-		"_hashed_password": utils.Hash("123456"),
+		"_hashed_password": hashedPasswordOf(w.data, "123456"),
 	}
 	if reflect.DeepEqual(expect, w.data) == false {
 		t.Error("expect:", expect, "result:", w.data)
@@ -1404,7 +1493,7 @@ func Test_transformUser(t *testing.T) {
 	err = w.transformUser()
 	expect = types.M{
 		"objectId":         "1002",
-		"_hashed_password": utils.Hash("123456"),
+		"_hashed_password": hashedPasswordOf(w.data, "123456"),
 	}
 	if err != nil || reflect.DeepEqual(expect, w.data) == false {
 		t.Error("expect:", expect, "result:", w.data, "err:", err)
@@ -1540,7 +1629,7 @@ func Test_transformUser(t *testing.T) {
 	expect = types.M{
 		"objectId":         "1001",
 		"username":         "joe",
-		"_hashed_password": utils.Hash("123456"),
+		"_hashed_password": hashedPasswordOf(w.data, "123456"),
 		"email":            "a@g.cn",
 	}
 	if reflect.DeepEqual(true, w.storage["sendVerificationEmail"]) == false {
@@ -1567,7 +1656,7 @@ func Test_transformUser(t *testing.T) {
 	expect = types.M{
 		"objectId":                       "1001",
 		"username":                       "joe",
-		"_hashed_password":               utils.Hash("123456"),
+		"_hashed_password":               hashedPasswordOf(w.data, "123456"),
 		"email":                          "a@g.cn",
 		"emailVerified":                  false,
 		"_email_verify_token_expires_at": utils.TimetoString(time.Now().UTC().Add(180 * time.Second)),
@@ -1611,7 +1700,7 @@ func Test_transformUser(t *testing.T) {
 	w, _ = NewWrite(&Auth{IsMaster: false, User: types.M{"objectId": "1001"}}, "_User", query, data, originalData, nil)
 	err = w.transformUser()
 	expect = types.M{
-		"_hashed_password": utils.Hash("123456"),
+		"_hashed_password": hashedPasswordOf(w.data, "123456"),
 	}
 	if cache.User.Get("aaaaa") != nil {
 		t.Error("expect:", nil, "result:", cache.User.Get("aaaaa"))
@@ -2866,3 +2955,12 @@ func Test_getLastItems(t *testing.T) {
 		}
 	}
 }
+
+// hashedPasswordOf 校验 data 中的 _hashed_password 是否为 password 的哈希，校验通过时返回该哈希以便比较对象
+func hashedPasswordOf(data types.M, password string) string {
+	hashedPassword := utils.S(data["_hashed_password"])
+	if utils.Compare(password, hashedPassword) {
+		return hashedPassword
+	}
+	return ""
+}
@@ -77,6 +77,11 @@ func init() {
 				&controllers.JobsController{},
 			),
 		),
+		beego.NSNamespace("/aggregate",
+			beego.NSInclude(
+				&controllers.AggregateController{},
+			),
+		),
 		beego.NSNamespace("/schemas",
 			beego.NSInclude(
 				&controllers.SchemasController{},
@@ -92,6 +97,16 @@ func init() {
 				&controllers.PurgeController{},
 			),
 		),
+		beego.NSNamespace("/export",
+			beego.NSInclude(
+				&controllers.ExportController{},
+			),
+		),
+		beego.NSNamespace("/import",
+			beego.NSInclude(
+				&controllers.ImportController{},
+			),
+		),
 		beego.NSNamespace("/config",
 			beego.NSInclude(
 				&controllers.GlobalConfigController{},
@@ -117,6 +132,11 @@ func init() {
 				&controllers.HooksController{},
 			),
 		),
+		beego.NSNamespace("/push_audiences",
+			beego.NSInclude(
+				&controllers.AudiencesController{},
+			),
+		),
 		beego.NSNamespace("/cloud_code",
 			beego.NSInclude(
 				&controllers.CloudCodeController{},
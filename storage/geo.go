@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/utils"
+)
+
+// PolygonCoordinates 校验并转换 Polygon 的坐标 [[latitude, longitude], ...]
+// 至少需要三个不同的点，坐标需要在经纬度范围内，各数据库适配器共用
+func PolygonCoordinates(object interface{}) ([][2]float64, error) {
+	points := utils.A(object)
+	if len(points) < 3 {
+		return nil, errs.E(errs.InvalidJSON, "Polygon must have at least 3 values")
+	}
+	coordinates := [][2]float64{}
+	unique := map[[2]float64]bool{}
+	for _, v := range points {
+		point := utils.A(v)
+		if len(point) != 2 {
+			return nil, errs.E(errs.InvalidJSON, "bad Polygon value; coordinates should be [latitude, longitude]")
+		}
+		var coordinate [2]float64
+		for i := range point {
+			switch n := point[i].(type) {
+			case float64:
+				coordinate[i] = n
+			case int:
+				coordinate[i] = float64(n)
+			default:
+				return nil, errs.E(errs.InvalidJSON, "bad Polygon value; coordinates should be numbers")
+			}
+		}
+		if coordinate[0] < -90 || coordinate[0] > 90 || coordinate[1] < -180 || coordinate[1] > 180 {
+			return nil, errs.E(errs.InvalidJSON, "bad Polygon value; coordinates out of bounds")
+		}
+		coordinates = append(coordinates, coordinate)
+		unique[coordinate] = true
+	}
+	if len(unique) < 3 {
+		return nil, errs.E(errs.InvalidJSON, "GeoJSON: Loop must have at least 3 different vertices")
+	}
+	return coordinates, nil
+}
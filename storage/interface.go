@@ -8,6 +8,7 @@ type Adapter interface {
 	SetClassLevelPermissions(className string, CLPs types.M) error
 	CreateClass(className string, schema types.M) (types.M, error)
 	AddFieldIfNotExists(className, fieldName string, fieldType types.M) error
+	UpdateFieldOptions(className, fieldName string, fieldType types.M) error
 	DeleteClass(className string) (types.M, error)
 	DeleteAllClasses() error
 	DeleteFields(className string, schema types.M, fieldNames []string) error
@@ -16,11 +17,28 @@ type Adapter interface {
 	GetClass(className string) (types.M, error)
 	DeleteObjectsByQuery(className string, schema, query types.M) error
 	Find(className string, schema, query, options types.M) ([]types.M, error)
+	FindIterator(className string, schema, query, options types.M) (Iterator, error)
 	Count(className string, schema, query types.M) (int, error)
+	Distinct(className string, schema, query types.M, fieldName string) (types.S, error)
+	Aggregate(className string, schema types.M, pipeline types.S) ([]types.M, error)
 	UpdateObjectsByQuery(className string, schema, query, update types.M) error
 	FindOneAndUpdate(className string, schema, query, update types.M) (types.M, error)
 	UpsertOneObject(className string, schema, query, update types.M) error
 	EnsureUniqueness(className string, schema types.M, fieldNames []string) error
+	CreateIndexes(className string, schema, indexes types.M) error
+	DropIndexes(className string, indexNames []string) error
+	GetIndexes(className string) (types.M, error)
 	PerformInitialization(options types.M) error
+	StartTransaction() (Adapter, error)
+	CommitTransaction() error
+	AbortTransaction() error
 	HandleShutdown()
 }
+
+// Iterator 查询结果的迭代器，用于逐个读取大量数据，避免一次加载全部结果
+type Iterator interface {
+	// Next 读取下一个对象，没有更多数据时返回 nil, nil
+	Next() (types.M, error)
+	// Close 释放数据库游标，读取结束或中途放弃时都需要调用
+	Close() error
+}
@@ -65,8 +65,29 @@ func (m *MongoCollection) find(query interface{}, options types.M) ([]types.M, e
 	return result, nil
 }
 
+// ensureTextIndex 为指定字段创建文本索引，索引已存在时直接返回
+func (m *MongoCollection) ensureTextIndex(key string) error {
+	index := mgo.Index{
+		Key:  []string{"$text:" + key},
+		Name: key + "_text",
+	}
+	return m.collection.EnsureIndex(index)
+}
+
 // rawFind 执行原始查找操作，查找选项包括 sort、skip、limit、keys、maxTimeMS
 func (m *MongoCollection) rawFind(query interface{}, options types.M) ([]types.M, error) {
+	var result []types.M
+	err := m.buildFind(query, options).All(&result)
+	return result, err
+}
+
+// iter 执行查找操作，返回结果的游标，查找选项与 rawFind 相同
+func (m *MongoCollection) iter(query interface{}, options types.M) *mgo.Iter {
+	return m.buildFind(query, options).Iter()
+}
+
+// buildFind 组装查找操作
+func (m *MongoCollection) buildFind(query interface{}, options types.M) *mgo.Query {
 	if options == nil {
 		options = types.M{}
 	}
@@ -100,9 +121,7 @@ func (m *MongoCollection) rawFind(query interface{}, options types.M) ([]types.M
 			q = q.SetMaxTime(time.Duration(limit) * time.Millisecond)
 		}
 	}
-	var result []types.M
-	err := q.All(&result)
-	return result, err
+	return q
 }
 
 // count 执行 count 操作，查找选项包括 sort、skip、limit、maxTimeMS
@@ -144,6 +163,32 @@ func (m *MongoCollection) count(query interface{}, options types.M) int {
 	return n
 }
 
+// aggregate 执行聚合操作
+func (m *MongoCollection) aggregate(pipeline interface{}) ([]types.M, error) {
+	var result []types.M
+	err := m.collection.Pipe(pipeline).AllowDiskUse().All(&result)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return []types.M{}, nil
+	}
+	return result, nil
+}
+
+// distinct 查找指定字段的不重复值
+func (m *MongoCollection) distinct(query interface{}, key string) ([]interface{}, error) {
+	var result []interface{}
+	err := m.collection.Find(query).Distinct(key, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return []interface{}{}, nil
+	}
+	return result, nil
+}
+
 // findOneAndUpdate 查找并更新一个对象，返回更新后的对象
 func (m *MongoCollection) findOneAndUpdate(selector interface{}, update interface{}) types.M {
 
@@ -220,3 +265,53 @@ func (m *MongoCollection) ensureSparseUniqueIndexInBackground(indexRequest []str
 	}
 	return m.collection.EnsureIndex(index)
 }
+
+// createIndex 创建指定名称的索引， options 中可设置 unique 、 sparse 、 expireAfterSeconds
+func (m *MongoCollection) createIndex(name string, keys []string, options types.M) error {
+	index := mgo.Index{
+		Key:        keys,
+		Name:       name,
+		Background: true,
+	}
+	if unique, ok := options["unique"].(bool); ok {
+		index.Unique = unique
+	}
+	if sparse, ok := options["sparse"].(bool); ok {
+		index.Sparse = sparse
+	}
+	switch seconds := options["expireAfterSeconds"].(type) {
+	case float64:
+		index.ExpireAfter = time.Duration(seconds) * time.Second
+	case int:
+		index.ExpireAfter = time.Duration(seconds) * time.Second
+	}
+	err := m.collection.EnsureIndex(index)
+	if err != nil && strings.Index(err.Error(), "duplicate key error") > -1 {
+		return errs.E(errs.DuplicateValue, "Tried to ensure field uniqueness for a class that already has duplicates.")
+	}
+	return err
+}
+
+// dropIndex 删除指定名称的索引，索引不存在时直接返回
+func (m *MongoCollection) dropIndex(name string) error {
+	err := m.collection.DropIndexName(name)
+	if err != nil && strings.Index(err.Error(), "index not found") > -1 {
+		return nil
+	}
+	return err
+}
+
+// indexes 获取表中的所有索引，表不存在时返回空列表
+func (m *MongoCollection) indexes() ([]mgo.Index, error) {
+	indexes, err := m.collection.Indexes()
+	if err != nil {
+		if qerr, ok := err.(*mgo.QueryError); ok && qerr.Code == 26 {
+			return []mgo.Index{}, nil
+		}
+		if strings.Index(err.Error(), "ns does not exist") > -1 {
+			return []mgo.Index{}, nil
+		}
+		return nil, err
+	}
+	return indexes, nil
+}
@@ -128,6 +128,9 @@ func (m *MongoSchemaCollection) addFieldIfNotExists(className string, fieldName
 	date := types.M{
 		fieldName: parseFieldTypeToMongoFieldType(fieldType),
 	}
+	if options := parseFieldTypeToMongoFieldOptions(fieldType); options != nil {
+		date["_metadata.fields_options."+fieldName] = options
+	}
 	update := types.M{
 		"$set": date,
 	}
@@ -199,6 +202,10 @@ func mongoFieldToParseSchemaField(t string) types.M {
 		return types.M{
 			"type": "GeoPoint",
 		}
+	case "polygon":
+		return types.M{
+			"type": "Polygon",
+		}
 	case "file":
 		return types.M{
 			"type": "File",
@@ -291,11 +298,48 @@ func mongoSchemaToParseSchema(schema types.M) types.M {
 		}
 	}
 
-	return types.M{
+	// 合并 schema["_metadata"]["fields_options"] 中的字段校验规则
+	fields := mongoSchemaFieldsToParseSchemaFields(schema)
+	if metadata := utils.M(schema["_metadata"]); metadata != nil {
+		if fieldsOptions := utils.M(metadata["fields_options"]); fieldsOptions != nil {
+			for fieldName, v := range fieldsOptions {
+				field := utils.M(fields[fieldName])
+				if field == nil {
+					continue
+				}
+				for k, option := range utils.M(v) {
+					field[k] = option
+				}
+			}
+		}
+	}
+
+	result := types.M{
 		"className":             schema["_id"],
-		"fields":                mongoSchemaFieldsToParseSchemaFields(schema),
+		"fields":                fields,
 		"classLevelPermissions": clps,
 	}
+	// 复制 schema["_metadata"]["indexes"] 到 indexes 中
+	if metadata := utils.M(schema["_metadata"]); metadata != nil {
+		if indexes := utils.M(metadata["indexes"]); len(indexes) > 0 {
+			result["indexes"] = types.M(indexes)
+		}
+	}
+	return result
+}
+
+// parseFieldTypeToMongoFieldOptions 返回字段定义中 type 与 targetClass 以外的校验规则，没有规则时返回 nil
+func parseFieldTypeToMongoFieldOptions(t types.M) types.M {
+	options := types.M{}
+	for k, v := range t {
+		if k != "type" && k != "targetClass" {
+			options[k] = v
+		}
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
 }
 
 // parseFieldTypeToMongoFieldType 返回数据库中存储的字段类型
@@ -327,6 +371,8 @@ func parseFieldTypeToMongoFieldType(t types.M) string {
 		return "array"
 	case "GeoPoint":
 		return "geopoint"
+	case "Polygon":
+		return "polygon"
 	case "File":
 		return "file"
 	default:
@@ -1124,6 +1124,74 @@ func Test_mongoSchemaToParseSchema(t *testing.T) {
 	if reflect.DeepEqual(expect, result) == false {
 		t.Error("expect:", expect, "result:", result)
 	}
+	/*****************************************************/
+	schema = types.M{
+		"_id":  "user",
+		"key1": "string",
+		"_metadata": types.M{
+			"indexes": types.M{
+				"key1_index": types.M{"fields": types.S{"key1", "-createdAt"}, "unique": true},
+			},
+		},
+	}
+	result = mongoSchemaToParseSchema(schema)
+	expect = types.M{
+		"className": "user",
+		"fields": types.M{
+			"key1":      types.M{"type": "String"},
+			"ACL":       types.M{"type": "ACL"},
+			"createdAt": types.M{"type": "Date"},
+			"updatedAt": types.M{"type": "Date"},
+			"objectId":  types.M{"type": "String"},
+		},
+		"classLevelPermissions": types.M{
+			"find":     types.M{"*": true},
+			"get":      types.M{"*": true},
+			"create":   types.M{"*": true},
+			"update":   types.M{"*": true},
+			"delete":   types.M{"*": true},
+			"addField": types.M{"*": true},
+		},
+		"indexes": types.M{
+			"key1_index": types.M{"fields": types.S{"key1", "-createdAt"}, "unique": true},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	schema = types.M{
+		"_id":  "user",
+		"key1": "string",
+		"_metadata": types.M{
+			"fields_options": types.M{
+				"key1": types.M{"required": true, "defaultValue": "a"},
+				"key2": types.M{"required": true},
+			},
+		},
+	}
+	result = mongoSchemaToParseSchema(schema)
+	expect = types.M{
+		"className": "user",
+		"fields": types.M{
+			"key1":      types.M{"type": "String", "required": true, "defaultValue": "a"},
+			"ACL":       types.M{"type": "ACL"},
+			"createdAt": types.M{"type": "Date"},
+			"updatedAt": types.M{"type": "Date"},
+			"objectId":  types.M{"type": "String"},
+		},
+		"classLevelPermissions": types.M{
+			"find":     types.M{"*": true},
+			"get":      types.M{"*": true},
+			"create":   types.M{"*": true},
+			"update":   types.M{"*": true},
+			"delete":   types.M{"*": true},
+			"addField": types.M{"*": true},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
 }
 
 func Test_parseFieldTypeToMongoFieldType(t *testing.T) {
@@ -5,10 +5,12 @@ import (
 	"strings"
 
 	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/storage"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
 
 	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 const mongoSchemaCollectionName = "_SCHEMA"
@@ -74,11 +76,10 @@ func (m *MongoAdapter) ClassExists(name string) bool {
 // SetClassLevelPermissions 设置类级别权限
 func (m *MongoAdapter) SetClassLevelPermissions(className string, CLPs types.M) error {
 	schemaCollection := m.schemaCollection()
+	// 仅更新 _metadata.class_permissions ，保留 _metadata 中的 indexes
 	update := types.M{
 		"$set": types.M{
-			"_metadata": types.M{
-				"class_permissions": CLPs,
-			},
+			"_metadata.class_permissions": CLPs,
 		},
 	}
 	return schemaCollection.updateSchema(className, update)
@@ -113,6 +114,16 @@ func (m *MongoAdapter) AddFieldIfNotExists(className, fieldName string, fieldTyp
 	return schemaCollection.addFieldIfNotExists(className, fieldName, fieldType)
 }
 
+// UpdateFieldOptions 更新已存在字段的校验规则，规则保存在 _metadata.fields_options 中
+func (m *MongoAdapter) UpdateFieldOptions(className, fieldName string, fieldType types.M) error {
+	schemaCollection := m.schemaCollection()
+	options := parseFieldTypeToMongoFieldOptions(fieldType)
+	if options == nil {
+		return schemaCollection.updateSchema(className, types.M{"$unset": types.M{"_metadata.fields_options." + fieldName: nil}})
+	}
+	return schemaCollection.updateSchema(className, types.M{"$set": types.M{"_metadata.fields_options." + fieldName: options}})
+}
+
 // DeleteClass 删除指定表
 func (m *MongoAdapter) DeleteClass(className string) (types.M, error) {
 	coll := m.adaptiveCollection(className)
@@ -172,6 +183,7 @@ func (m *MongoAdapter) DeleteFields(className string, schema types.M, fieldNames
 	unset2 := types.M{}
 	for _, name := range fieldNames {
 		unset2[name] = nil
+		unset2["_metadata.fields_options."+name] = nil
 	}
 	schemaUpdate := types.M{"$unset": unset2}
 
@@ -303,10 +315,63 @@ func (m *MongoAdapter) Find(className string, schema, query, options types.M) ([
 		options = types.M{}
 	}
 	schema = convertParseSchemaToMongoSchema(schema)
+	mongoWhere, err := m.transformFind(className, schema, query, options)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := m.adaptiveCollection(className)
+	err = m.createTextIndexesIfNeeded(coll, className, query, schema)
+	if err != nil {
+		return nil, err
+	}
+	results, err := coll.find(mongoWhere, options)
+	if err != nil {
+		return nil, err
+	}
+	objects := []types.M{}
+	for _, result := range results {
+		r, err := m.transform.mongoObjectToParseObject(className, result, schema)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, utils.M(r))
+	}
+	return objects, nil
+}
+
+// FindIterator 查找对象，以迭代器的形式返回结果，查找选项与 Find 相同
+func (m *MongoAdapter) FindIterator(className string, schema, query, options types.M) (storage.Iterator, error) {
+	if options == nil {
+		options = types.M{}
+	}
+	schema = convertParseSchemaToMongoSchema(schema)
+	mongoWhere, err := m.transformFind(className, schema, query, options)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := m.adaptiveCollection(className)
+	err = m.createTextIndexesIfNeeded(coll, className, query, schema)
+	if err != nil {
+		return nil, err
+	}
+	return &mongoIterator{
+		iter:      coll.iter(mongoWhere, options),
+		transform: m.transform,
+		className: className,
+		schema:    schema,
+	}, nil
+}
+
+// transformFind 转换查询条件，并把查找选项中的 sort keys 转换为 mongo 格式
+func (m *MongoAdapter) transformFind(className string, schema, query, options types.M) (types.M, error) {
 	mongoWhere, err := m.transform.transformWhere(className, query, schema)
 	if err != nil {
 		return nil, err
 	}
+	// 按照 $score 排序或者选择 $score 时，需要在返回结果中添加检索的相关度 score
+	selectScore := false
 	if _, ok := options["sort"]; ok {
 		if keys, ok := options["sort"].([]string); ok {
 			mongoSort := []string{}
@@ -319,6 +384,13 @@ func (m *MongoAdapter) Find(className string, schema, query, options types.M) ([
 					key = key[1:]
 				}
 
+				if key == "$score" {
+					// 相关度只能按照倒序排列
+					mongoSort = append(mongoSort, "$textScore:score")
+					selectScore = true
+					continue
+				}
+
 				mongoKey = prefix + m.transform.transformKey(className, key, schema)
 				mongoSort = append(mongoSort, mongoKey)
 			}
@@ -331,6 +403,10 @@ func (m *MongoAdapter) Find(className string, schema, query, options types.M) ([
 		if keys, ok := options["keys"].([]string); ok {
 			mongoKeys := types.M{}
 			for _, key := range keys {
+				if key == "$score" {
+					selectScore = true
+					continue
+				}
 				mongoKey := m.transform.transformKey(className, key, schema)
 				mongoKeys[mongoKey] = 1
 			}
@@ -339,24 +415,44 @@ func (m *MongoAdapter) Find(className string, schema, query, options types.M) ([
 			delete(options, "keys")
 		}
 	}
+	if selectScore {
+		mongoKeys := utils.M(options["keys"])
+		if mongoKeys == nil {
+			mongoKeys = types.M{}
+		}
+		mongoKeys["score"] = types.M{"$meta": "textScore"}
+		options["keys"] = mongoKeys
+	}
 	if m.maxTimeMS != 0 {
 		options["maxTimeMS"] = m.maxTimeMS
 	}
+	return mongoWhere, nil
+}
 
-	coll := m.adaptiveCollection(className)
-	results, err := coll.find(mongoWhere, options)
+// mongoIterator mongo 查询结果的迭代器
+type mongoIterator struct {
+	iter      *mgo.Iter
+	transform *Transform
+	className string
+	schema    types.M
+}
+
+// Next 读取下一个对象，并转换为 Parse 格式
+func (i *mongoIterator) Next() (types.M, error) {
+	var result types.M
+	if i.iter.Next(&result) == false {
+		return nil, i.iter.Err()
+	}
+	r, err := i.transform.mongoObjectToParseObject(i.className, result, i.schema)
 	if err != nil {
 		return nil, err
 	}
-	objects := []types.M{}
-	for _, result := range results {
-		r, err := m.transform.mongoObjectToParseObject(className, result, schema)
-		if err != nil {
-			return nil, err
-		}
-		objects = append(objects, utils.M(r))
-	}
-	return objects, nil
+	return utils.M(r), nil
+}
+
+// Close ...
+func (i *mongoIterator) Close() error {
+	return i.iter.Close()
 }
 
 // rawFind 仅用于测试
@@ -381,10 +477,290 @@ func (m *MongoAdapter) Count(className string, schema, query types.M) (int, erro
 	if m.maxTimeMS != 0 {
 		options["maxTimeMS"] = m.maxTimeMS
 	}
+	err = m.createTextIndexesIfNeeded(coll, className, query, schema)
+	if err != nil {
+		return 0, err
+	}
 	c := coll.count(mongoWhere, options)
 	return c, nil
 }
 
+// createTextIndexesIfNeeded 查询中包含 $text 时，为检索的字段创建文本索引
+// MongoDB 中每个表只能有一个文本索引， $text 查询检索该索引中的字段，
+// 表中已有的文本索引不包含检索的字段时返回错误，避免检索其他字段
+func (m *MongoAdapter) createTextIndexesIfNeeded(coll *MongoCollection, className string, query, schema types.M) error {
+	fields := textFields(query)
+	if len(fields) == 0 {
+		return nil
+	}
+	indexes, err := coll.indexes()
+	if err != nil {
+		return err
+	}
+	indexed := map[string]bool{}
+	for _, index := range indexes {
+		for _, key := range index.Key {
+			if strings.HasPrefix(key, "$text:") {
+				indexed[key[len("$text:"):]] = true
+			}
+		}
+	}
+	for _, fieldName := range fields {
+		key := m.transform.transformKey(className, fieldName, schema)
+		if indexed[key] {
+			continue
+		}
+		if len(indexed) > 0 {
+			return errs.E(errs.InvalidQuery, "bad $text: "+className+" already has a text index that does not include "+fieldName)
+		}
+		err = coll.ensureTextIndex(key)
+		if err != nil {
+			return err
+		}
+		indexed[key] = true
+	}
+	return nil
+}
+
+// textFields 获取查询中使用 $text 的字段
+func textFields(query types.M) []string {
+	fields := []string{}
+	for key, value := range query {
+		if key == "$or" || key == "$and" {
+			for _, subQuery := range utils.A(value) {
+				fields = append(fields, textFields(utils.M(subQuery))...)
+			}
+			continue
+		}
+		if constraint := utils.M(value); constraint != nil && constraint["$text"] != nil {
+			fields = append(fields, key)
+		}
+	}
+	return fields
+}
+
+// Distinct 查找指定字段的不重复值，指针字段返回指针对象
+func (m *MongoAdapter) Distinct(className string, schema, query types.M, fieldName string) (types.S, error) {
+	schema = convertParseSchemaToMongoSchema(schema)
+	path := strings.SplitN(fieldName, ".", 2)
+	var tp types.M
+	if fields := utils.M(schema["fields"]); fields != nil {
+		tp = utils.M(fields[path[0]])
+	}
+	isPointer := len(path) == 1 && tp != nil && utils.S(tp["type"]) == "Pointer"
+	isFile := len(path) == 1 && tp != nil && utils.S(tp["type"]) == "File"
+	path[0] = m.transform.transformKey(className, path[0], schema)
+
+	coll := m.adaptiveCollection(className)
+	mongoWhere, err := m.transform.transformWhere(className, query, schema)
+	if err != nil {
+		return nil, err
+	}
+	values, err := coll.distinct(mongoWhere, strings.Join(path, "."))
+	if err != nil {
+		return nil, err
+	}
+
+	results := types.S{}
+	for _, v := range values {
+		if isPointer {
+			// 指针字段在数据库中保存为 className$objectId
+			objData := strings.Split(utils.S(v), "$")
+			if len(objData) != 2 {
+				continue
+			}
+			results = append(results, types.M{
+				"__type":    "Pointer",
+				"className": objData[0],
+				"objectId":  objData[1],
+			})
+			continue
+		}
+		if isFile {
+			if name, ok := v.(string); ok {
+				results = append(results, types.M{
+					"__type": "File",
+					"name":   name,
+				})
+				continue
+			}
+		}
+		r, err := m.transform.nestedMongoObjectToNestedParseObject(v)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// Aggregate 执行聚合查询
+// pipeline 已由 orm 规范化，$sort 为 []string ，$unwind 为 "$field" 格式的字符串
+func (m *MongoAdapter) Aggregate(className string, schema types.M, pipeline types.S) ([]types.M, error) {
+	schema = convertParseSchemaToMongoSchema(schema)
+	// grouped 表示已经执行过 $group ，之后的字段不再对应 schema 中的字段
+	grouped := false
+	// pointerGroupKey 表示 $group 的 _id 为指针字段，结果中需要转换为指针对象
+	var pointerGroupKey types.M
+	mongoPipeline := []interface{}{}
+	for _, s := range pipeline {
+		stage := utils.M(s)
+		for key, value := range stage {
+			switch key {
+			case "$match":
+				var matchSchema types.M
+				if grouped == false {
+					matchSchema = schema
+				}
+				where, err := m.transform.transformWhere(className, utils.M(value), matchSchema)
+				if err != nil {
+					return nil, err
+				}
+				mongoPipeline = append(mongoPipeline, bson.M{"$match": where})
+
+			case "$group":
+				group := bson.M{}
+				for field, v := range utils.M(value) {
+					if field == "_id" && grouped == false {
+						pointerGroupKey = nil
+						if path, ok := v.(string); ok && strings.HasPrefix(path, "$") {
+							if fields := utils.M(schema["fields"]); fields != nil {
+								if tp := utils.M(fields[path[1:]]); tp != nil && utils.S(tp["type"]) == "Pointer" {
+									pointerGroupKey = tp
+								}
+							}
+						}
+					}
+					group[field] = m.transformAggregateExpression(className, v, schema, grouped)
+				}
+				grouped = true
+				mongoPipeline = append(mongoPipeline, bson.M{"$group": group})
+
+			case "$project":
+				project := bson.M{}
+				for field, v := range utils.M(value) {
+					project[m.aggregateKey(className, field, schema, grouped)] = m.transformAggregateExpression(className, v, schema, grouped)
+				}
+				mongoPipeline = append(mongoPipeline, bson.M{"$project": project})
+
+			case "$sort":
+				sort := bson.D{}
+				if keys, ok := value.([]string); ok {
+					for _, k := range keys {
+						order := 1
+						if strings.HasPrefix(k, "-") {
+							order = -1
+							k = k[1:]
+						}
+						sort = append(sort, bson.DocElem{Name: m.aggregateKey(className, k, schema, grouped), Value: order})
+					}
+				}
+				mongoPipeline = append(mongoPipeline, bson.M{"$sort": sort})
+
+			case "$limit", "$skip":
+				mongoPipeline = append(mongoPipeline, bson.M{key: value})
+
+			case "$unwind":
+				path := m.transformAggregateExpression(className, value, schema, grouped)
+				mongoPipeline = append(mongoPipeline, bson.M{"$unwind": path})
+			}
+		}
+	}
+
+	coll := m.adaptiveCollection(className)
+	results, err := coll.aggregate(mongoPipeline)
+	if err != nil {
+		return nil, err
+	}
+	objects := []types.M{}
+	for _, result := range results {
+		if grouped == false {
+			r, err := m.transform.mongoObjectToParseObject(className, result, schema)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, utils.M(r))
+			continue
+		}
+		object := types.M{}
+		for k, v := range result {
+			if k == "_id" {
+				k = "objectId"
+				if pointerGroupKey != nil {
+					if objData := strings.Split(utils.S(v), "$"); len(objData) == 2 {
+						v = types.M{
+							"__type":    "Pointer",
+							"className": objData[0],
+							"objectId":  objData[1],
+						}
+					}
+				}
+			}
+			r, err := m.transform.nestedMongoObjectToNestedParseObject(v)
+			if err != nil {
+				return nil, err
+			}
+			object[k] = r
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// aggregateKey 转换聚合查询中使用的字段名
+func (m *MongoAdapter) aggregateKey(className, key string, schema types.M, grouped bool) string {
+	if grouped {
+		if key == "objectId" {
+			return "_id"
+		}
+		return key
+	}
+	return m.transform.transformKey(className, key, schema)
+}
+
+// transformAggregateExpression 转换聚合表达式中引用的字段，如 "$createdAt" 转换为 "$_created_at"
+func (m *MongoAdapter) transformAggregateExpression(className string, expression interface{}, schema types.M, grouped bool) interface{} {
+	if str, ok := expression.(string); ok {
+		if strings.HasPrefix(str, "$") && strings.HasPrefix(str, "$$") == false {
+			path := strings.SplitN(str[1:], ".", 2)
+			path[0] = m.aggregateKey(className, path[0], schema, grouped)
+			return "$" + strings.Join(path, ".")
+		}
+		return str
+	}
+	if arr := utils.A(expression); arr != nil {
+		result := types.S{}
+		for _, v := range arr {
+			result = append(result, m.transformAggregateExpression(className, v, schema, grouped))
+		}
+		return result
+	}
+	if object := utils.M(expression); object != nil {
+		result := bson.M{}
+		for k, v := range object {
+			result[k] = m.transformAggregateExpression(className, v, schema, grouped)
+		}
+		return result
+	}
+	return expression
+}
+
+// StartTransaction 开启事务
+// mgo.v2 不支持 MongoDB 4.0 的多文档事务，无法保证原子性，所以 MongoDB 中不支持事务
+func (m *MongoAdapter) StartTransaction() (storage.Adapter, error) {
+	return nil, errs.E(errs.OperationForbidden, "MongoDB adapter does not support transactions")
+}
+
+// CommitTransaction 提交事务
+func (m *MongoAdapter) CommitTransaction() error {
+	return errs.E(errs.OperationForbidden, "MongoDB adapter does not support transactions")
+}
+
+// AbortTransaction 回滚事务
+func (m *MongoAdapter) AbortTransaction() error {
+	return errs.E(errs.OperationForbidden, "MongoDB adapter does not support transactions")
+}
+
 // EnsureUniqueness 创建索引
 func (m *MongoAdapter) EnsureUniqueness(className string, schema types.M, fieldNames []string) error {
 	schema = convertParseSchemaToMongoSchema(schema)
@@ -401,6 +777,108 @@ func (m *MongoAdapter) EnsureUniqueness(className string, schema types.M, fieldN
 	return err
 }
 
+// CreateIndexes 创建索引，并把索引定义保存到 _SCHEMA 的 _metadata.indexes 中
+// indexes 的格式为 {"name":{"fields":["a","-b"],"unique":true,"sparse":true,"expireAfterSeconds":3600}}
+func (m *MongoAdapter) CreateIndexes(className string, schema, indexes types.M) error {
+	schema = convertParseSchemaToMongoSchema(schema)
+	coll := m.adaptiveCollection(className)
+	set := types.M{}
+	for name, v := range indexes {
+		index := utils.M(v)
+		if index == nil {
+			continue
+		}
+		keys := []string{}
+		for _, k := range utils.A(index["fields"]) {
+			keys = append(keys, m.transformIndexKey(className, utils.S(k), schema))
+		}
+		err := coll.createIndex(name, keys, index)
+		if err != nil {
+			return err
+		}
+		set["_metadata.indexes."+name] = index
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return m.schemaCollection().updateSchema(className, types.M{"$set": set})
+}
+
+// transformIndexKey 转换索引中的字段名，保留表示倒序与文本索引的前缀
+func (m *MongoAdapter) transformIndexKey(className, key string, schema types.M) string {
+	prefix := ""
+	if strings.HasPrefix(key, "$text:") {
+		prefix = "$text:"
+	} else if strings.HasPrefix(key, "-") {
+		prefix = "-"
+	}
+	return prefix + m.transform.transformKey(className, key[len(prefix):], schema)
+}
+
+// DropIndexes 删除索引，并从 _SCHEMA 中删除索引定义
+func (m *MongoAdapter) DropIndexes(className string, indexNames []string) error {
+	coll := m.adaptiveCollection(className)
+	unset := types.M{}
+	for _, name := range indexNames {
+		err := coll.dropIndex(name)
+		if err != nil {
+			return err
+		}
+		unset["_metadata.indexes."+name] = ""
+	}
+	if len(unset) == 0 {
+		return nil
+	}
+	return m.schemaCollection().updateSchema(className, types.M{"$unset": unset})
+}
+
+// GetIndexes 获取数据库中实际存在的索引，格式与 CreateIndexes 中的相同
+func (m *MongoAdapter) GetIndexes(className string) (types.M, error) {
+	coll := m.adaptiveCollection(className)
+	indexes, err := coll.indexes()
+	if err != nil {
+		return nil, err
+	}
+	result := types.M{}
+	for _, index := range indexes {
+		fields := types.S{}
+		for _, key := range index.Key {
+			prefix := ""
+			if strings.HasPrefix(key, "$text:") {
+				prefix = "$text:"
+			} else if strings.HasPrefix(key, "-") {
+				prefix = "-"
+			}
+			fields = append(fields, prefix+mongoIndexKeyToFieldName(key[len(prefix):]))
+		}
+		definition := types.M{"fields": fields}
+		if index.Unique {
+			definition["unique"] = true
+		}
+		if index.Sparse {
+			definition["sparse"] = true
+		}
+		if index.ExpireAfter > 0 {
+			definition["expireAfterSeconds"] = index.ExpireAfter.Seconds()
+		}
+		result[index.Name] = definition
+	}
+	return result, nil
+}
+
+// mongoIndexKeyToFieldName 把索引中数据库的字段名转换为 Parse 格式的字段名
+func mongoIndexKeyToFieldName(key string) string {
+	switch key {
+	case "_id":
+		return "objectId"
+	case "_created_at":
+		return "createdAt"
+	case "_updated_at":
+		return "updatedAt"
+	}
+	return strings.TrimPrefix(key, "_p_")
+}
+
 // PerformInitialization 性能优化初始化
 func (m *MongoAdapter) PerformInitialization(options types.M) error {
 	return nil
@@ -459,16 +937,27 @@ func mongoSchemaFromFieldsAndClassNameAndCLP(fields types.M, className string, c
 		"createdAt": "string",
 	}
 
-	// 添加其他字段
+	// 添加其他字段，字段的校验规则保存在 _metadata.fields_options 中
+	fieldsOptions := types.M{}
 	if fields != nil {
 		for fieldName, v := range fields {
 			mongoObject[fieldName] = parseFieldTypeToMongoFieldType(utils.M(v))
+			if options := parseFieldTypeToMongoFieldOptions(utils.M(v)); options != nil {
+				fieldsOptions[fieldName] = options
+			}
 		}
 	}
 
 	// 添加 CLP
+	metadata := types.M{}
 	if classLevelPermissions != nil {
-		mongoObject["_metadata"] = types.M{"class_permissions": classLevelPermissions}
+		metadata["class_permissions"] = classLevelPermissions
+	}
+	if len(fieldsOptions) > 0 {
+		metadata["fields_options"] = fieldsOptions
+	}
+	if len(metadata) > 0 {
+		mongoObject["_metadata"] = metadata
 	}
 
 	return mongoObject
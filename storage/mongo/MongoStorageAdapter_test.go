@@ -1378,6 +1378,164 @@ func Test_Count(t *testing.T) {
 	adapter.DeleteAllClasses()
 }
 
+func Test_Distinct(t *testing.T) {
+	adapter := getAdapter()
+	var className string
+	var schema types.M
+	var query types.M
+	var results types.S
+	var err error
+	var object types.M
+	var expect types.S
+	tmpTimeStr := utils.TimetoString(time.Now().UTC())
+	/*****************************************************/
+	className = "post"
+	schema = types.M{
+		"fields": types.M{
+			"key":   types.M{"type": "String"},
+			"owner": types.M{"type": "Pointer", "targetClass": "_User"},
+		},
+	}
+	object = types.M{
+		"objectId":  "01",
+		"updatedAt": tmpTimeStr,
+		"createdAt": tmpTimeStr,
+		"key":       "hello",
+		"owner":     types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+	}
+	adapter.CreateObject(className, schema, object)
+	object = types.M{
+		"objectId":  "02",
+		"updatedAt": tmpTimeStr,
+		"createdAt": tmpTimeStr,
+		"key":       "hello",
+		"owner":     types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+	}
+	adapter.CreateObject(className, schema, object)
+	object = types.M{
+		"objectId":  "03",
+		"updatedAt": tmpTimeStr,
+		"createdAt": tmpTimeStr,
+		"key":       "hi",
+		"owner":     types.M{"__type": "Pointer", "className": "_User", "objectId": "u2"},
+	}
+	adapter.CreateObject(className, schema, object)
+	/*****************************************************/
+	query = types.M{}
+	results, err = adapter.Distinct(className, schema, query, "key")
+	expect = types.S{"hello", "hi"}
+	if err != nil || reflect.DeepEqual(expect, results) == false {
+		t.Error("expect:", expect, "result:", results, err)
+	}
+	/*****************************************************/
+	query = types.M{"objectId": "01"}
+	results, err = adapter.Distinct(className, schema, query, "key")
+	expect = types.S{"hello"}
+	if err != nil || reflect.DeepEqual(expect, results) == false {
+		t.Error("expect:", expect, "result:", results, err)
+	}
+	/*****************************************************/
+	query = types.M{}
+	results, err = adapter.Distinct(className, schema, query, "owner")
+	expect = types.S{
+		types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+		types.M{"__type": "Pointer", "className": "_User", "objectId": "u2"},
+	}
+	if err != nil || reflect.DeepEqual(expect, results) == false {
+		t.Error("expect:", expect, "result:", results, err)
+	}
+	/*****************************************************/
+	query = types.M{}
+	results, err = adapter.Distinct("post1", schema, query, "key")
+	expect = types.S{}
+	if err != nil || reflect.DeepEqual(expect, results) == false {
+		t.Error("expect:", expect, "result:", results, err)
+	}
+
+	adapter.DeleteAllClasses()
+}
+
+func Test_Aggregate(t *testing.T) {
+	adapter := getAdapter()
+	var className string
+	var schema types.M
+	var pipeline types.S
+	var results []types.M
+	var err error
+	var object types.M
+	var expect []types.M
+	tmpTimeStr := utils.TimetoString(time.Now().UTC())
+	/*****************************************************/
+	className = "user"
+	schema = nil
+	object = types.M{
+		"objectId":  "01",
+		"updatedAt": tmpTimeStr,
+		"createdAt": tmpTimeStr,
+		"key":       "hello",
+		"score":     1.0,
+	}
+	adapter.CreateObject(className, schema, object)
+	object = types.M{
+		"objectId":  "02",
+		"updatedAt": tmpTimeStr,
+		"createdAt": tmpTimeStr,
+		"key":       "hello",
+		"score":     2.0,
+	}
+	adapter.CreateObject(className, schema, object)
+	object = types.M{
+		"objectId":  "03",
+		"updatedAt": tmpTimeStr,
+		"createdAt": tmpTimeStr,
+		"key":       "hi",
+		"score":     4.0,
+	}
+	adapter.CreateObject(className, schema, object)
+	/*****************************************************/
+	className = "user"
+	schema = nil
+	pipeline = types.S{
+		types.M{"$group": types.M{"_id": "$key", "total": types.M{"$sum": "$score"}, "count": types.M{"$sum": 1}}},
+		types.M{"$sort": []string{"objectId"}},
+	}
+	results, err = adapter.Aggregate(className, schema, pipeline)
+	expect = []types.M{
+		types.M{"objectId": "hello", "total": 3.0, "count": 2},
+		types.M{"objectId": "hi", "total": 4.0, "count": 1},
+	}
+	if err != nil || reflect.DeepEqual(expect, results) == false {
+		t.Error("expect:", expect, "result:", results, err)
+	}
+	/*****************************************************/
+	className = "user"
+	schema = nil
+	pipeline = types.S{
+		types.M{"$match": types.M{"score": types.M{"$gt": 1}}},
+		types.M{"$project": types.M{"key": 1}},
+		types.M{"$sort": []string{"-objectId"}},
+		types.M{"$limit": 1},
+	}
+	results, err = adapter.Aggregate(className, schema, pipeline)
+	expect = []types.M{
+		types.M{"objectId": "03", "key": "hi"},
+	}
+	if err != nil || reflect.DeepEqual(expect, results) == false {
+		t.Error("expect:", expect, "result:", results, err)
+	}
+	/*****************************************************/
+	className = "user1"
+	schema = nil
+	pipeline = types.S{}
+	results, err = adapter.Aggregate(className, schema, pipeline)
+	expect = []types.M{}
+	if err != nil || reflect.DeepEqual(expect, results) == false {
+		t.Error("expect:", expect, "result:", results, err)
+	}
+
+	adapter.DeleteAllClasses()
+}
+
 func Test_EnsureUniqueness(t *testing.T) {
 	adapter := getAdapter()
 	var className string
@@ -1419,6 +1577,49 @@ func Test_EnsureUniqueness(t *testing.T) {
 	adapter.DeleteAllClasses()
 }
 
+func Test_createTextIndexesIfNeeded(t *testing.T) {
+	adapter := getAdapter()
+	var query types.M
+	var results []types.M
+	var err error
+	var expect error
+	schema := types.M{
+		"fields": types.M{
+			"title": types.M{"type": "String"},
+			"body":  types.M{"type": "String"},
+		},
+	}
+	adapter.CreateObject("post", schema, types.M{"objectId": "01", "title": "coffee", "body": "tea"})
+	/*****************************************************/
+	query = types.M{"title": types.M{"$text": types.M{"$search": types.M{"$term": "coffee"}}}}
+	results, err = adapter.Find("post", schema, query, types.M{})
+	if err != nil || len(results) != 1 {
+		t.Error("expect:", 1, "result:", results, err)
+	}
+	/*****************************************************/
+	// 已有 title 的文本索引，不能检索 body
+	query = types.M{"body": types.M{"$text": types.M{"$search": types.M{"$term": "coffee"}}}}
+	_, err = adapter.Find("post", schema, query, types.M{})
+	expect = errs.E(errs.InvalidQuery, "bad $text: post already has a text index that does not include body")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	_, err = adapter.Count("post", schema, query)
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	adapter.DeleteAllClasses()
+}
+
+func Test_StartTransaction(t *testing.T) {
+	adapter := getAdapter()
+	_, err := adapter.StartTransaction()
+	expect := errs.E(errs.OperationForbidden, "MongoDB adapter does not support transactions")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+}
+
 func Test_storageAdapterAllCollections(t *testing.T) {
 	adapter := getAdapter()
 	var result []*MongoCollection
@@ -1463,6 +1664,40 @@ func Test_storageAdapterAllCollections(t *testing.T) {
 	adapter.adaptiveCollection("ser.system.id").drop()
 }
 
+func Test_mongoIndexKeyToFieldName(t *testing.T) {
+	var key string
+	var result string
+	var expect string
+	/*****************************************************/
+	key = "_id"
+	result = mongoIndexKeyToFieldName(key)
+	expect = "objectId"
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	key = "_created_at"
+	result = mongoIndexKeyToFieldName(key)
+	expect = "createdAt"
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	key = "_p_owner"
+	result = mongoIndexKeyToFieldName(key)
+	expect = "owner"
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	key = "name"
+	result = mongoIndexKeyToFieldName(key)
+	expect = "name"
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
 func Test_convertParseSchemaToMongoSchema(t *testing.T) {
 	var schema types.M
 	var result types.M
@@ -1619,6 +1854,39 @@ func Test_mongoSchemaFromFieldsAndClassNameAndCLP(t *testing.T) {
 	if reflect.DeepEqual(expect, result) == false {
 		t.Error("expect:", expect, "result:", result)
 	}
+	/*****************************************************/
+	fields = types.M{
+		"key1": types.M{
+			"type":        "Pointer",
+			"targetClass": "_User",
+			"required":    true,
+		},
+		"key2": types.M{
+			"type":         "Number",
+			"defaultValue": 1.0,
+			"min":          0.0,
+		},
+	}
+	className = "user"
+	classLevelPermissions = nil
+	result = mongoSchemaFromFieldsAndClassNameAndCLP(fields, className, classLevelPermissions)
+	expect = types.M{
+		"_id":       className,
+		"objectId":  "string",
+		"updatedAt": "string",
+		"createdAt": "string",
+		"key1":      "*_User",
+		"key2":      "number",
+		"_metadata": types.M{
+			"fields_options": types.M{
+				"key1": types.M{"required": true},
+				"key2": types.M{"defaultValue": 1.0, "min": 0.0},
+			},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
 }
 
 func getAdapter() *MongoAdapter {
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/storage"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
 )
@@ -334,6 +335,12 @@ func (t *Transform) transformQueryKeyValue(className, key string, value interfac
 		return "", nil, err
 	}
 	if cValue != cannotTransform() {
+		if c := utils.M(cValue); c != nil && c["$text"] != nil {
+			if len(c) > 1 {
+				return "", nil, errs.E(errs.InvalidJSON, "bad $text: cannot be combined with other constraints on "+key)
+			}
+			return "$text", c["$text"], nil
+		}
 		return key, cValue, nil
 	}
 
@@ -539,6 +546,15 @@ func (t *Transform) transformConstraint(constraint interface{}, inArray bool) (i
 			nearSphere["$maxDistance"] = distance
 			answer["$nearSphere"] = nearSphere
 
+		// 转换 全文检索 操作符，MongoDB 中 $text 只能用于查询的顶层，
+		// 在 transformQueryKeyValue 中提取到顶层
+		case "$text":
+			text, err := transformText(object[key])
+			if err != nil {
+				return nil, err
+			}
+			answer[key] = text
+
 		case "$select", "$dontSelect":
 			// 暂时不支持该参数
 			return nil, errs.E(errs.CommandUnavailable, "the "+key+" constraint is not supported yet")
@@ -592,6 +608,29 @@ func (t *Transform) transformConstraint(constraint interface{}, inArray bool) (i
 				"$polygon": points,
 			}
 
+		case "$geoIntersects":
+			// 查找包含指定点的多边形
+			// {"$geoIntersects": {"$point": {"__type": "GeoPoint", "longitude": 30, "latitude": 40}}}
+			geoIntersects := utils.M(object[key])
+			if geoIntersects == nil {
+				return nil, errs.E(errs.InvalidJSON, "bad $geoIntersect value; $point should be GeoPoint")
+			}
+			point := utils.M(geoIntersects["$point"])
+			g := geoPointCoder{}
+			if g.isValidJSON(point) == false {
+				return nil, errs.E(errs.InvalidJSON, "bad $geoIntersect value; $point should be GeoPoint")
+			}
+			coordinates, err := g.jsonToDatabase(utils.CopyMap(point))
+			if err != nil {
+				return nil, err
+			}
+			answer["$geoIntersects"] = types.M{
+				"$geometry": types.M{
+					"type":        "Point",
+					"coordinates": coordinates,
+				},
+			}
+
 		default:
 			b, _ := regexp.MatchString(`^\$+`, key)
 			if b {
@@ -685,6 +724,17 @@ func (t *Transform) transformTopLevelAtom(atom interface{}) (interface{}, error)
 			return g.jsonToDatabase(object)
 		}
 
+		// Polygon 类型，坐标的格式为 [latitude, longitude]
+		// {
+		// 	"__type": "Polygon",
+		// 	"coordinates": [[0,0],[0,1],[1,1]]
+		// }
+		// ==> {"type": "Polygon", "coordinates": [[[0,0],[1,0],[1,1],[0,0]]]}
+		p := polygonCoder{}
+		if p.isValidJSON(object) {
+			return p.jsonToDatabase(object)
+		}
+
 		// File 类型
 		// {
 		// 	"__type": "File",
@@ -1081,12 +1131,57 @@ func (t *Transform) transformWhere(className string, where, schema types.M) (typ
 		if err != nil {
 			return nil, err
 		}
+		if _, ok := mongoWhere["$text"]; ok && key == "$text" {
+			return nil, errs.E(errs.InvalidJSON, "bad $text: only one $text constraint is allowed in a query")
+		}
 		mongoWhere[key] = value
 	}
 
 	return mongoWhere, nil
 }
 
+// transformText 转换全文检索条件
+// {"$search":{"$term":"coffee","$language":"en","$caseSensitive":false,"$diacriticSensitive":false}}
+// ==>
+// {"$search":"coffee","$language":"en","$caseSensitive":false,"$diacriticSensitive":false}
+func transformText(value interface{}) (types.M, error) {
+	text := utils.M(value)
+	if text == nil {
+		return nil, errs.E(errs.InvalidJSON, "bad $text: should be object")
+	}
+	search := utils.M(text["$search"])
+	if search == nil {
+		return nil, errs.E(errs.InvalidJSON, "bad $text: $search, should be object")
+	}
+	term, ok := search["$term"].(string)
+	if ok == false {
+		return nil, errs.E(errs.InvalidJSON, "bad $text: $term, should be string")
+	}
+	answer := types.M{"$search": term}
+	if v, ok := search["$language"]; ok {
+		language, ok := v.(string)
+		if ok == false {
+			return nil, errs.E(errs.InvalidJSON, "bad $text: $language, should be string")
+		}
+		answer["$language"] = language
+	}
+	if v, ok := search["$caseSensitive"]; ok {
+		caseSensitive, ok := v.(bool)
+		if ok == false {
+			return nil, errs.E(errs.InvalidJSON, "bad $text: $caseSensitive, should be boolean")
+		}
+		answer["$caseSensitive"] = caseSensitive
+	}
+	if v, ok := search["$diacriticSensitive"]; ok {
+		diacriticSensitive, ok := v.(bool)
+		if ok == false {
+			return nil, errs.E(errs.InvalidJSON, "bad $text: $diacriticSensitive, should be boolean")
+		}
+		answer["$diacriticSensitive"] = diacriticSensitive
+	}
+	return answer, nil
+}
+
 // transformUpdate 转换 update 数据
 func (t *Transform) transformUpdate(className string, update types.M, parseFormatSchema types.M) (types.M, error) {
 	if update == nil {
@@ -1175,7 +1270,7 @@ func (t *Transform) nestedMongoObjectToNestedParseObject(mongoObject interface{}
 
 	// 转换基本类型
 	switch mongoObject.(type) {
-	case string, float64, int, bool:
+	case string, float64, int, int64, bool:
 		return mongoObject, nil
 
 	}
@@ -1439,6 +1534,16 @@ func (t *Transform) mongoObjectToParseObject(className string, mongoObject inter
 						restObject[key] = g.databaseToJSON(value)
 						break
 					}
+					// polygon 类型
+					// {
+					// 	"__type":      "Polygon",
+					// 	"coordinates": [[0,0],[0,1],[1,1],[0,0]]
+					// }
+					p := polygonCoder{}
+					if expectedType != nil && utils.S(expectedType["type"]) == "Polygon" && p.isValidDatabaseObject(value) {
+						restObject[key] = p.databaseToJSON(value)
+						break
+					}
 					// bytesCoder 类型
 					// {
 					// 	"__type": "Bytes",
@@ -1660,6 +1765,66 @@ func (g geoPointCoder) isValidJSON(value types.M) bool {
 	return value != nil && utils.S(value["__type"]) == "GeoPoint" && value["longitude"] != nil && value["latitude"] != nil
 }
 
+// polygonCoder Polygon 类型处理
+// 数据库中保存为 GeoJSON 格式，坐标为 [longitude, latitude] ，并且首尾坐标相同
+type polygonCoder struct{}
+
+func (p polygonCoder) databaseToJSON(object interface{}) types.M {
+	coordinates := types.S{}
+	if rings := utils.A(utils.M(object)["coordinates"]); len(rings) > 0 {
+		for _, v := range utils.A(rings[0]) {
+			if point := utils.A(v); len(point) == 2 {
+				coordinates = append(coordinates, types.S{point[1], point[0]})
+			}
+		}
+	}
+	return types.M{
+		"__type":      "Polygon",
+		"coordinates": coordinates,
+	}
+}
+
+func (p polygonCoder) isValidDatabaseObject(object interface{}) bool {
+	polygon := utils.M(object)
+	if polygon == nil || utils.S(polygon["type"]) != "Polygon" {
+		return false
+	}
+	rings := utils.A(polygon["coordinates"])
+	if len(rings) == 0 {
+		return false
+	}
+	g := geoPointCoder{}
+	for _, point := range utils.A(rings[0]) {
+		if g.isValidDatabaseObject(point) == false {
+			return false
+		}
+	}
+	return true
+}
+
+func (p polygonCoder) jsonToDatabase(json types.M) (interface{}, error) {
+	coordinates, err := storage.PolygonCoordinates(json["coordinates"])
+	if err != nil {
+		return nil, err
+	}
+	ring := types.S{}
+	for _, point := range coordinates {
+		ring = append(ring, types.S{point[1], point[0]})
+	}
+	// GeoJSON 中多边形需要闭合
+	if first, last := coordinates[0], coordinates[len(coordinates)-1]; first[0] != last[0] || first[1] != last[1] {
+		ring = append(ring, types.S{first[1], first[0]})
+	}
+	return types.M{
+		"type":        "Polygon",
+		"coordinates": types.S{ring},
+	}, nil
+}
+
+func (p polygonCoder) isValidJSON(value types.M) bool {
+	return value != nil && utils.S(value["__type"]) == "Polygon" && value["coordinates"] != nil
+}
+
 // fileCoder File 类型处理
 type fileCoder struct{}
 
@@ -1134,6 +1134,73 @@ func Test_transformConstraint(t *testing.T) {
 		t.Error("expect:", expect, "get result:", result, err)
 	}
 	/*************************************************/
+	constraint = types.M{
+		"$text": types.M{
+			"$search": types.M{
+				"$term":               "coffee",
+				"$language":           "en",
+				"$caseSensitive":      true,
+				"$diacriticSensitive": false,
+			},
+		},
+	}
+	inArray = false
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = types.M{
+		"$text": types.M{
+			"$search":             "coffee",
+			"$language":           "en",
+			"$caseSensitive":      true,
+			"$diacriticSensitive": false,
+		},
+	}
+	if err != nil || reflect.DeepEqual(result, expect) == false {
+		t.Error("expect:", expect, "get result:", result, err)
+	}
+	/*************************************************/
+	constraint = types.M{"$text": types.M{"$search": "coffee"}}
+	inArray = false
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = errs.E(errs.InvalidJSON, "bad $text: $search, should be object")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", err)
+	}
+	/*************************************************/
+	constraint = types.M{"$text": types.M{"$search": types.M{"$term": "coffee", "$caseSensitive": "true"}}}
+	inArray = false
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = errs.E(errs.InvalidJSON, "bad $text: $caseSensitive, should be boolean")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", err)
+	}
+	/*************************************************/
+	constraint = types.M{
+		"$geoIntersects": types.M{
+			"$point": types.M{"__type": "GeoPoint", "longitude": 30.0, "latitude": 40.0},
+		},
+	}
+	inArray = false
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = types.M{
+		"$geoIntersects": types.M{
+			"$geometry": types.M{
+				"type":        "Point",
+				"coordinates": types.S{30.0, 40.0},
+			},
+		},
+	}
+	if err != nil || reflect.DeepEqual(result, expect) == false {
+		t.Error("expect:", expect, "get result:", result, err)
+	}
+	/*************************************************/
+	constraint = types.M{"$geoIntersects": types.M{"$point": types.M{"longitude": 30.0}}}
+	inArray = false
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = errs.E(errs.InvalidJSON, "bad $geoIntersect value; $point should be GeoPoint")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", err)
+	}
+	/*************************************************/
 	constraint = types.M{"$other": "hello"}
 	inArray = true
 	result, err = tf.transformConstraint(constraint, inArray)
@@ -2128,6 +2195,37 @@ func Test_transformWhere(t *testing.T) {
 	if err != nil || reflect.DeepEqual(expect, result) == false {
 		t.Error("expect:", expect, "get result:", result)
 	}
+	/*************************************************/
+	where = types.M{
+		"title": types.M{
+			"$text": types.M{
+				"$search": types.M{"$term": "coffee"},
+			},
+		},
+		"key": "value",
+	}
+	schema = types.M{}
+	result, err = tf.transformWhere("", where, schema)
+	expect = types.M{
+		"$text": types.M{"$search": "coffee"},
+		"key":   "value",
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "get result:", result)
+	}
+	/*************************************************/
+	where = types.M{
+		"title": types.M{
+			"$text":   types.M{"$search": types.M{"$term": "coffee"}},
+			"$exists": true,
+		},
+	}
+	schema = types.M{}
+	result, err = tf.transformWhere("", where, schema)
+	expectErr = errs.E(errs.InvalidJSON, "bad $text: cannot be combined with other constraints on title")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "get result:", err)
+	}
 }
 
 func Test_transformUpdate(t *testing.T) {
@@ -3362,6 +3460,86 @@ func Test_geoPointCoder(t *testing.T) {
 	}
 }
 
+func Test_polygonCoder(t *testing.T) {
+	pc := polygonCoder{}
+	var databaseObject interface{}
+	var jsonObject types.M
+	var ok bool
+	var result interface{}
+	var expect interface{}
+	var err error
+	/*************************************************/
+	databaseObject = types.M{
+		"type":        "Polygon",
+		"coordinates": types.S{types.S{types.S{0.0, 0.0}, types.S{1.0, 0.0}, types.S{1.0, 1.0}, types.S{0.0, 0.0}}},
+	}
+	ok = pc.isValidDatabaseObject(databaseObject)
+	if !ok {
+		t.Error("expect:", "true", "get:", ok)
+	}
+	jsonObject = pc.databaseToJSON(databaseObject)
+	expect = types.M{
+		"__type":      "Polygon",
+		"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 1.0}, types.S{1.0, 1.0}, types.S{0.0, 0.0}},
+	}
+	if reflect.DeepEqual(jsonObject, expect) == false {
+		t.Error("expect:", expect, "get jsonObject:", jsonObject)
+	}
+	/*************************************************/
+	databaseObject = types.M{"type": "Point", "coordinates": types.S{0.0, 0.0}}
+	ok = pc.isValidDatabaseObject(databaseObject)
+	if ok {
+		t.Error("expect:", "false", "get:", ok)
+	}
+	/*************************************************/
+	jsonObject = types.M{
+		"__type":      "Polygon",
+		"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 1.0}, types.S{1.0, 1.0}},
+	}
+	ok = pc.isValidJSON(jsonObject)
+	if !ok {
+		t.Error("expect:", "true", "get:", ok)
+	}
+	result, err = pc.jsonToDatabase(jsonObject)
+	expect = types.M{
+		"type":        "Polygon",
+		"coordinates": types.S{types.S{types.S{0.0, 0.0}, types.S{1.0, 0.0}, types.S{1.0, 1.0}, types.S{0.0, 0.0}}},
+	}
+	if err != nil || reflect.DeepEqual(result, expect) == false {
+		t.Error("expect:", expect, "get result:", result, err)
+	}
+	/*************************************************/
+	jsonObject = types.M{
+		"__type":      "Polygon",
+		"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 1.0}},
+	}
+	result, err = pc.jsonToDatabase(jsonObject)
+	expect = errs.E(errs.InvalidJSON, "Polygon must have at least 3 values")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", result, err)
+	}
+	/*************************************************/
+	jsonObject = types.M{
+		"__type":      "Polygon",
+		"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 1.0}, types.S{0.0, 0.0}},
+	}
+	result, err = pc.jsonToDatabase(jsonObject)
+	expect = errs.E(errs.InvalidJSON, "GeoJSON: Loop must have at least 3 different vertices")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", result, err)
+	}
+	/*************************************************/
+	jsonObject = types.M{
+		"__type":      "Polygon",
+		"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 1.0}, types.S{91.0, 1.0}},
+	}
+	result, err = pc.jsonToDatabase(jsonObject)
+	expect = errs.E(errs.InvalidJSON, "bad Polygon value; coordinates out of bounds")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", result, err)
+	}
+}
+
 func Test_fileCoder(t *testing.T) {
 	fc := fileCoder{}
 	var databaseObject interface{}
@@ -0,0 +1,15 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/lfq7413/tomato/storage/storagetest"
+)
+
+func Test_UpdateConformance(t *testing.T) {
+	storagetest.RunUpdateTests(t, getAdapter())
+}
+
+func Test_FindIteratorConformance(t *testing.T) {
+	storagetest.RunFindIteratorTests(t, getAdapter())
+}
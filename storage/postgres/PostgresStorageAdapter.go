@@ -7,11 +7,13 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"regexp"
 
 	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/storage"
 	"github.com/lfq7413/tomato/types"
 	"github.com/lfq7413/tomato/utils"
 	"github.com/lib/pq"
@@ -26,11 +28,20 @@ const postgresDuplicateObjectError = "42710"
 const postgresUniqueIndexViolationError = "23505"
 const postgresTransactionAbortedError = "25P02"
 
+// textIndexes 已创建或者正在创建的全文检索索引，避免每次查询都执行 CREATE INDEX
+// 删除表或者字段时，从中移除对应表的索引
+var textIndexes sync.Map
+
 // PostgresAdapter postgres 数据库适配器
 type PostgresAdapter struct {
 	collectionPrefix string
 	collectionList   []string
 	db               *sql.DB
+	// tx 不为空时，表示适配器处于事务中，所有语句都在该事务中执行
+	tx *sql.Tx
+	// statementSavepoint 为 true 时，表示上一次查询的 savepoint 还未释放
+	// 查询结果关闭之后才能执行下一条语句，所以在执行下一条语句之前释放
+	statementSavepoint bool
 }
 
 // NewPostgresAdapter ...
@@ -42,9 +53,182 @@ func NewPostgresAdapter(collectionPrefix string, db *sql.DB) *PostgresAdapter {
 	}
 }
 
+// StartTransaction 开启事务，返回在事务中执行操作的适配器
+func (p *PostgresAdapter) StartTransaction() (storage.Adapter, error) {
+	if p.tx != nil {
+		return nil, errs.E(errs.InternalServerError, "Transaction has already been started.")
+	}
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresAdapter{
+		collectionPrefix: p.collectionPrefix,
+		collectionList:   p.collectionList,
+		db:               p.db,
+		tx:               tx,
+	}, nil
+}
+
+// CommitTransaction 提交事务，提交时会释放所有 savepoint
+func (p *PostgresAdapter) CommitTransaction() error {
+	if p.tx == nil {
+		return errs.E(errs.InternalServerError, "Transaction has not been started.")
+	}
+	p.statementSavepoint = false
+	return p.tx.Commit()
+}
+
+// AbortTransaction 回滚事务
+func (p *PostgresAdapter) AbortTransaction() error {
+	if p.tx == nil {
+		return errs.E(errs.InternalServerError, "Transaction has not been started.")
+	}
+	p.statementSavepoint = false
+	return p.tx.Rollback()
+}
+
+// postgresTx 在一个事务中执行多条语句
+// 适配器处于事务中时，使用 savepoint 实现，Commit 释放 savepoint ，Rollback 回滚到 savepoint 并释放
+type postgresTx struct {
+	tx        *sql.Tx
+	savepoint bool
+	adapter   *PostgresAdapter
+}
+
+func (t *postgresTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if t.savepoint {
+		err := t.adapter.releaseStatementSavepoint()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t.tx.Exec(query, args...)
+}
+
+func (t *postgresTx) Commit() error {
+	if t.savepoint {
+		err := t.adapter.releaseStatementSavepoint()
+		if err != nil {
+			return err
+		}
+		_, err = t.tx.Exec(`RELEASE SAVEPOINT "tomato_tx"`)
+		return err
+	}
+	return t.tx.Commit()
+}
+
+func (t *postgresTx) Rollback() error {
+	if t.savepoint {
+		// 回滚到 tomato_tx 时，其后的 savepoint 都会被销毁
+		t.adapter.statementSavepoint = false
+		_, err := t.tx.Exec(`ROLLBACK TO SAVEPOINT "tomato_tx"`)
+		if err != nil {
+			return err
+		}
+		_, err = t.tx.Exec(`RELEASE SAVEPOINT "tomato_tx"`)
+		return err
+	}
+	return t.tx.Rollback()
+}
+
+// begin 开始执行一组需要在同一事务中执行的语句
+func (p *PostgresAdapter) begin() (*postgresTx, error) {
+	if p.tx == nil {
+		tx, err := p.db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		return &postgresTx{tx: tx}, nil
+	}
+	err := p.releaseStatementSavepoint()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.tx.Exec(`SAVEPOINT "tomato_tx"`)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresTx{tx: p.tx, savepoint: true, adapter: p}, nil
+}
+
+// releaseStatementSavepoint 释放上一次查询的 savepoint
+func (p *PostgresAdapter) releaseStatementSavepoint() error {
+	if p.statementSavepoint == false {
+		return nil
+	}
+	p.statementSavepoint = false
+	_, err := p.tx.Exec(`RELEASE SAVEPOINT "tomato_statement"`)
+	return err
+}
+
+// rollbackStatementSavepoint 语句出错时回滚到 savepoint 并释放，事务可以继续执行其他语句
+func (p *PostgresAdapter) rollbackStatementSavepoint() {
+	p.tx.Exec(`ROLLBACK TO SAVEPOINT "tomato_statement"`)
+	p.tx.Exec(`RELEASE SAVEPOINT "tomato_statement"`)
+}
+
+// exec 执行语句，适配器处于事务中时，语句出错只回滚该语句，避免整个事务中止
+func (p *PostgresAdapter) exec(query string, args ...interface{}) (sql.Result, error) {
+	if p.tx == nil {
+		return p.db.Exec(query, args...)
+	}
+	err := p.releaseStatementSavepoint()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.tx.Exec(`SAVEPOINT "tomato_statement"`)
+	if err != nil {
+		return nil, err
+	}
+	result, err := p.tx.Exec(query, args...)
+	if err != nil {
+		p.rollbackStatementSavepoint()
+		return nil, err
+	}
+	_, err = p.tx.Exec(`RELEASE SAVEPOINT "tomato_statement"`)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// query 执行查询，适配器处于事务中时，查询出错只回滚该语句，避免整个事务中止
+// 事务中的结果需要读取完毕或者关闭之后，才能执行下一条语句
+func (p *PostgresAdapter) query(query string, args ...interface{}) (*sql.Rows, error) {
+	if p.tx == nil {
+		return p.db.Query(query, args...)
+	}
+	err := p.releaseStatementSavepoint()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.tx.Exec(`SAVEPOINT "tomato_statement"`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := p.tx.Query(query, args...)
+	if err != nil {
+		p.rollbackStatementSavepoint()
+		return nil, err
+	}
+	// 结果读取完毕之前不能释放 savepoint ，在执行下一条语句之前释放
+	p.statementSavepoint = true
+	return rows, nil
+}
+
+// queryRow 执行查询并返回一行结果
+func (p *PostgresAdapter) queryRow(query string, args ...interface{}) *sql.Row {
+	if p.tx == nil {
+		return p.db.QueryRow(query, args...)
+	}
+	p.releaseStatementSavepoint()
+	return p.tx.QueryRow(query, args...)
+}
+
 // ensureSchemaCollectionExists 确保 _SCHEMA 表存在，不存在则创建表
 func (p *PostgresAdapter) ensureSchemaCollectionExists() error {
-	_, err := p.db.Exec(`CREATE TABLE IF NOT EXISTS "_SCHEMA" ( "className" varChar(120), "schema" jsonb, "isParseClass" bool, PRIMARY KEY ("className") )`)
+	_, err := p.exec(`CREATE TABLE IF NOT EXISTS "_SCHEMA" ( "className" varChar(120), "schema" jsonb, "isParseClass" bool, PRIMARY KEY ("className") )`)
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok {
 			if e.Code == postgresDuplicateRelationError || e.Code == postgresUniqueIndexViolationError || e.Code == postgresDuplicateObjectError {
@@ -61,7 +245,7 @@ func (p *PostgresAdapter) ensureSchemaCollectionExists() error {
 // ClassExists 检测数据库中是否存在指定类
 func (p *PostgresAdapter) ClassExists(name string) bool {
 	var result bool
-	err := p.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM   information_schema.tables WHERE table_name = $1)`, name).Scan(&result)
+	err := p.queryRow(`SELECT EXISTS (SELECT 1 FROM   information_schema.tables WHERE table_name = $1)`, name).Scan(&result)
 	if err != nil {
 		return false
 	}
@@ -83,7 +267,7 @@ func (p *PostgresAdapter) SetClassLevelPermissions(className string, CLPs types.
 	}
 
 	qs := `UPDATE "_SCHEMA" SET "schema" = json_object_set_key("schema", $1::text, $2::jsonb) WHERE "className"=$3 `
-	_, err = p.db.Exec(qs, "classLevelPermissions", string(b), className)
+	_, err = p.exec(qs, "classLevelPermissions", string(b), className)
 	if err != nil {
 		return err
 	}
@@ -102,7 +286,7 @@ func (p *PostgresAdapter) CreateClass(className string, schema types.M) (types.M
 		return nil, err
 	}
 
-	tx, err := p.db.Begin()
+	tx, err := p.begin()
 	if err != nil {
 		return nil, err
 	}
@@ -131,7 +315,7 @@ func (p *PostgresAdapter) CreateClass(className string, schema types.M) (types.M
 }
 
 // createTable 仅创建表，不加入 schema 中
-func (p *PostgresAdapter) createTable(className string, schema types.M, tx *sql.Tx) error {
+func (p *PostgresAdapter) createTable(className string, schema types.M, tx *postgresTx) error {
 	if schema == nil {
 		schema = types.M{}
 	}
@@ -196,7 +380,7 @@ func (p *PostgresAdapter) createTable(className string, schema types.M, tx *sql.
 	if tx != nil {
 		_, err = tx.Exec(qs)
 	} else {
-		_, err = p.db.Exec(qs)
+		_, err = p.exec(qs)
 	}
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok {
@@ -219,7 +403,7 @@ func (p *PostgresAdapter) createTable(className string, schema types.M, tx *sql.
 		if tx != nil {
 			_, err = tx.Exec(qs)
 		} else {
-			_, err = p.db.Exec(qs)
+			_, err = p.exec(qs)
 		}
 		if err != nil {
 			return err
@@ -235,7 +419,7 @@ func (p *PostgresAdapter) AddFieldIfNotExists(className, fieldName string, field
 		fieldType = types.M{}
 	}
 
-	tx, err := p.db.Begin()
+	tx, err := p.begin()
 	if err != nil {
 		return err
 	}
@@ -248,6 +432,8 @@ func (p *PostgresAdapter) AddFieldIfNotExists(className, fieldName string, field
 		qs := fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" %s`, className, fieldName, tp)
 		_, err = tx.Exec(qs)
 		if err != nil {
+			// 发生错误之后 tx 异常中止，需要回滚并重新获取
+			tx.Rollback()
 			if e, ok := err.(*pq.Error); ok {
 				if e.Code == postgresRelationDoesNotExistError {
 					// TODO 添加默认字段
@@ -263,8 +449,7 @@ func (p *PostgresAdapter) AddFieldIfNotExists(className, fieldName string, field
 			} else {
 				return err
 			}
-			// 发生错误之后 tx 异常中止，需要重新获取
-			tx, err = p.db.Begin()
+			tx, err = p.begin()
 			if err != nil {
 				return err
 			}
@@ -279,13 +464,15 @@ func (p *PostgresAdapter) AddFieldIfNotExists(className, fieldName string, field
 	}
 
 	qs := `SELECT "schema" FROM "_SCHEMA" WHERE "className" = $1 and ("schema"::json->'fields'->$2) is not null`
-	rows, err := p.db.Query(qs, className, fieldName)
+	rows, err := p.query(qs, className, fieldName)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
-	if rows.Next() {
-		return nil
+	// 事务中同一连接上不能同时存在未读取完的结果，需要先关闭
+	exists := rows.Next()
+	rows.Close()
+	if exists {
+		return tx.Commit()
 	}
 
 	path := fmt.Sprintf(`{fields,%s}`, fieldName)
@@ -299,9 +486,21 @@ func (p *PostgresAdapter) AddFieldIfNotExists(className, fieldName string, field
 	return tx.Commit()
 }
 
+// UpdateFieldOptions 更新已存在字段的校验规则，规则与字段类型一同保存在 _SCHEMA 的 fields 中
+func (p *PostgresAdapter) UpdateFieldOptions(className, fieldName string, fieldType types.M) error {
+	path := fmt.Sprintf(`{fields,%s}`, fieldName)
+	qs := `UPDATE "_SCHEMA" SET "schema"=jsonb_set("schema", $1, $2)  WHERE "className"=$3`
+	b, err := json.Marshal(fieldType)
+	if err != nil {
+		return err
+	}
+	_, err = p.exec(qs, path, string(b), className)
+	return err
+}
+
 // DeleteClass 删除指定表
 func (p *PostgresAdapter) DeleteClass(className string) (types.M, error) {
-	tx, err := p.db.Begin()
+	tx, err := p.begin()
 
 	if err != nil {
 		return nil, err
@@ -322,6 +521,7 @@ func (p *PostgresAdapter) DeleteClass(className string) (types.M, error) {
 	if err != nil {
 		return nil, err
 	}
+	forgetTextIndexes(className)
 
 	return types.M{}, nil
 }
@@ -329,7 +529,7 @@ func (p *PostgresAdapter) DeleteClass(className string) (types.M, error) {
 // DeleteAllClasses 删除所有表，仅用于测试
 func (p *PostgresAdapter) DeleteAllClasses() error {
 	qs := `SELECT "className","schema" FROM "_SCHEMA"`
-	rows, err := p.db.Query(qs)
+	rows, err := p.query(qs)
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok && e.Code == postgresRelationDoesNotExistError {
 			// _SCHEMA 不存在，则不删除
@@ -363,11 +563,11 @@ func (p *PostgresAdapter) DeleteAllClasses() error {
 		joins = append(joins, joinTablesForSchema(sch)...)
 	}
 
-	classes := []string{"_SCHEMA", "_PushStatus", "_JobStatus", "_Hooks", "_GlobalConfig"}
+	classes := []string{"_SCHEMA", "_PushStatus", "_JobStatus", "_JobSchedule", "_Hooks", "_GlobalConfig", "_Audience", "_WebhookDelivery", "_WebhookSubscription"}
 	classes = append(classes, classNames...)
 	classes = append(classes, joins...)
 
-	tx, err := p.db.Begin()
+	tx, err := p.begin()
 	if err != nil {
 		return err
 	}
@@ -379,6 +579,7 @@ func (p *PostgresAdapter) DeleteAllClasses() error {
 			return err
 		}
 	}
+	forgetTextIndexes("")
 
 	return tx.Commit()
 }
@@ -417,7 +618,7 @@ func (p *PostgresAdapter) DeleteFields(className string, schema types.M, fieldNa
 		return err
 	}
 
-	tx, err := p.db.Begin()
+	tx, err := p.begin()
 	if err != nil {
 		return err
 	}
@@ -436,6 +637,7 @@ func (p *PostgresAdapter) DeleteFields(className string, schema types.M, fieldNa
 			return err
 		}
 	}
+	forgetTextIndexes(className)
 
 	return tx.Commit()
 }
@@ -561,6 +763,12 @@ func (p *PostgresAdapter) CreateObject(className string, schema, object types.M)
 		case "GeoPoint":
 			geoPoints[fieldName] = object[fieldName]
 			columnsArray = columnsArray[:len(columnsArray)-1]
+		case "Polygon":
+			polygon, err := toPostgresPolygon(object[fieldName])
+			if err != nil {
+				return err
+			}
+			valuesArray = append(valuesArray, polygon)
 		default:
 			return errs.E(errs.OtherCause, "Type "+utils.S(tp["type"])+" not supported yet")
 		}
@@ -589,6 +797,9 @@ func (p *PostgresAdapter) CreateObject(className string, schema, object types.M)
 			if utils.S(tp["type"]) == "Array" {
 				termination = "::jsonb"
 			}
+			if utils.S(tp["type"]) == "Polygon" {
+				termination = "::polygon"
+			}
 		}
 		initialValues = append(initialValues, fmt.Sprintf(`$%d%s`, index+1, termination))
 	}
@@ -614,7 +825,7 @@ func (p *PostgresAdapter) CreateObject(className string, schema, object types.M)
 	valuesPattern := strings.Join(initialValues, ",")
 
 	qs := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, className, columnsPattern, valuesPattern)
-	_, err = p.db.Exec(qs, valuesArray...)
+	_, err = p.exec(qs, valuesArray...)
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok {
 			if e.Code == postgresUniqueIndexViolationError {
@@ -634,7 +845,7 @@ func (p *PostgresAdapter) GetAllClasses() ([]types.M, error) {
 		return nil, err
 	}
 	qs := `SELECT "className","schema" FROM "_SCHEMA"`
-	rows, err := p.db.Query(qs)
+	rows, err := p.query(qs)
 	if err != nil {
 		return nil, err
 	}
@@ -668,7 +879,7 @@ func (p *PostgresAdapter) GetClass(className string) (types.M, error) {
 		return nil, err
 	}
 	qs := `SELECT "schema" FROM "_SCHEMA" WHERE "className"=$1`
-	rows, err := p.db.Query(qs, className)
+	rows, err := p.query(qs, className)
 	if err != nil {
 		return nil, err
 	}
@@ -704,7 +915,7 @@ func (p *PostgresAdapter) DeleteObjectsByQuery(className string, schema, query t
 	}
 
 	qs := fmt.Sprintf(`WITH deleted AS (DELETE FROM "%s" WHERE %s RETURNING *) SELECT count(*) FROM deleted`, className, where.pattern)
-	row := p.db.QueryRow(qs, where.values...)
+	row := p.queryRow(qs, where.values...)
 	var count int
 	err = row.Scan(&count)
 	if err != nil {
@@ -726,6 +937,28 @@ func (p *PostgresAdapter) DeleteObjectsByQuery(className string, schema, query t
 
 // Find ...
 func (p *PostgresAdapter) Find(className string, schema, query, options types.M) ([]types.M, error) {
+	iter, err := p.FindIterator(className, schema, query, options)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	results := []types.M{}
+	for {
+		object, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if object == nil {
+			break
+		}
+		results = append(results, object)
+	}
+	return results, nil
+}
+
+// FindIterator 查找对象，以迭代器的形式返回结果，查找选项与 Find 相同
+func (p *PostgresAdapter) FindIterator(className string, schema, query, options types.M) (storage.Iterator, error) {
 	if schema == nil {
 		schema = types.M{}
 	}
@@ -764,12 +997,22 @@ func (p *PostgresAdapter) Find(className string, schema, query, options types.M)
 		values = append(values, options["skip"])
 	}
 
+	// 按照 $score 排序或者选择 $score 时，在结果中添加检索的相关度 score
+	selectScore := false
 	var sortPattern string
 	if _, ok := options["sort"]; ok {
 		if keys, ok := options["sort"].([]string); ok {
 			postgresSort := []string{}
 			for _, key := range keys {
 				var postgresKey string
+				if strings.TrimPrefix(key, "-") == "$score" {
+					// 相关度只能按照倒序排列
+					if where.score != "" {
+						postgresSort = append(postgresSort, where.score+` DESC`)
+						selectScore = true
+					}
+					continue
+				}
 				if strings.HasPrefix(key, "-") {
 					key = key[1:]
 					postgresKey = fmt.Sprintf(`"%s" DESC`, key)
@@ -793,7 +1036,9 @@ func (p *PostgresAdapter) Find(className string, schema, query, options types.M)
 		if keys, ok := options["keys"].([]string); ok {
 			postgresKeys := []string{}
 			for _, key := range keys {
-				if key != "" {
+				if key == "$score" {
+					selectScore = where.score != ""
+				} else if key != "" {
 					postgresKeys = append(postgresKeys, fmt.Sprintf(`"%s"`, key))
 				}
 			}
@@ -802,59 +1047,108 @@ func (p *PostgresAdapter) Find(className string, schema, query, options types.M)
 			}
 		}
 	}
+	if selectScore {
+		columns = fmt.Sprintf(`%s, %s AS "score"`, columns, where.score)
+	}
 
+	p.createTextIndexesIfNeeded(className, where.texts)
 	qs := fmt.Sprintf(`SELECT %s FROM "%s" %s %s %s %s`, columns, className, wherePattern, sortPattern, limitPattern, skipPattern)
-	rows, err := p.db.Query(qs, values...)
+	rows, err := p.query(qs, values...)
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok {
 			// 表不存在返回空
 			if e.Code == postgresRelationDoesNotExistError {
-				return []types.M{}, nil
+				return &postgresIterator{}, nil
 			}
 		}
 		return nil, err
 	}
-	defer rows.Close()
 
 	fields := utils.M(schema["fields"])
 	if fields == nil {
 		fields = types.M{}
 	}
+	return &postgresIterator{rows: rows, fields: fields}, nil
+}
 
-	results := []types.M{}
-	var resultColumns []string
-	for rows.Next() {
-		if resultColumns == nil {
-			resultColumns, err = rows.Columns()
-			if err != nil {
-				return nil, err
-			}
-		}
-		resultValues := []*interface{}{}
-		values := types.S{}
-		for i := 0; i < len(resultColumns); i++ {
-			var v interface{}
-			resultValues = append(resultValues, &v)
-			values = append(values, &v)
-		}
-		err = rows.Scan(values...)
+// postgresIterator postgres 查询结果的迭代器
+type postgresIterator struct {
+	rows    *sql.Rows
+	fields  types.M
+	columns []string
+}
+
+// Next 读取下一行数据，并转换为 Parse 格式
+func (i *postgresIterator) Next() (types.M, error) {
+	if i.rows == nil {
+		return nil, nil
+	}
+	if i.rows.Next() == false {
+		return nil, i.rows.Err()
+	}
+	var err error
+	if i.columns == nil {
+		i.columns, err = i.rows.Columns()
 		if err != nil {
 			return nil, err
 		}
-		object := types.M{}
-		for i, field := range resultColumns {
-			object[field] = *resultValues[i]
-		}
+	}
+	resultValues := []*interface{}{}
+	values := types.S{}
+	for j := 0; j < len(i.columns); j++ {
+		var v interface{}
+		resultValues = append(resultValues, &v)
+		values = append(values, &v)
+	}
+	err = i.rows.Scan(values...)
+	if err != nil {
+		return nil, err
+	}
+	object := types.M{}
+	for j, field := range i.columns {
+		object[field] = *resultValues[j]
+	}
+	return postgresObjectToParseObject(object, i.fields)
+}
 
-		object, err = postgresObjectToParseObject(object, fields)
-		if err != nil {
-			return nil, err
+// Close ...
+func (i *postgresIterator) Close() error {
+	if i.rows == nil {
+		return nil
+	}
+	return i.rows.Close()
+}
+
+// createTextIndexesIfNeeded 为全文检索的字段创建 GIN 索引，索引表达式与查询条件中的 to_tsvector 相同
+// 索引在后台通过 createTextIndex 创建，不阻塞当前查询，索引创建完成之前的查询不使用该索引
+func (p *PostgresAdapter) createTextIndexesIfNeeded(className string, texts []textSearch) {
+	for _, text := range texts {
+		name := fmt.Sprintf("%s_%s_%s_text", className, text.fieldName, text.language)
+		if _, loaded := textIndexes.LoadOrStore(name, className); loaded {
+			continue
 		}
+		go p.createTextIndex(name, className, text)
+	}
+}
 
-		results = append(results, object)
+// createTextIndex 使用 CREATE INDEX CONCURRENTLY 创建全文检索索引，创建期间不锁定表的写入
+// CONCURRENTLY 不能在事务中执行，所以不使用 p.tx ，事务回滚时也不会撤销索引
+// 创建失败时移除索引记录，之后的查询会重新尝试创建
+func (p *PostgresAdapter) createTextIndex(name, className string, text textSearch) {
+	qs := fmt.Sprintf(`CREATE INDEX CONCURRENTLY IF NOT EXISTS "%s" ON "%s" USING GIN (to_tsvector('%s'::regconfig, "%s"))`, name, className, text.language, text.fieldName)
+	if _, err := p.db.Exec(qs); err != nil {
+		textIndexes.Delete(name)
 	}
+}
 
-	return results, nil
+// forgetTextIndexes 移除指定表已创建的全文检索索引记录，className 为空时移除所有记录
+func forgetTextIndexes(className string) {
+	textIndexes.Range(func(key, value interface{}) bool {
+		if className == "" || value == className {
+			textIndexes.Delete(key)
+		}
+		return true
+	})
 }
 
 // Count ...
@@ -869,8 +1163,9 @@ func (p *PostgresAdapter) Count(className string, schema, query types.M) (int, e
 		wherePattern = `WHERE ` + where.pattern
 	}
 
+	p.createTextIndexesIfNeeded(className, where.texts)
 	qs := fmt.Sprintf(`SELECT count(*) FROM "%s" %s`, className, wherePattern)
-	rows, err := p.db.Query(qs, where.values...)
+	rows, err := p.query(qs, where.values...)
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok {
 			if e.Code == postgresRelationDoesNotExistError {
@@ -891,18 +1186,401 @@ func (p *PostgresAdapter) Count(className string, schema, query types.M) (int, e
 	return count, nil
 }
 
-// UpdateObjectsByQuery ...
-func (p *PostgresAdapter) UpdateObjectsByQuery(className string, schema, query, update types.M) error {
-	_, err := p.FindOneAndUpdate(className, schema, query, update)
-	return err
+// Distinct 查找指定字段的不重复值，指针字段返回指针对象，数组字段返回数组中的元素
+func (p *PostgresAdapter) Distinct(className string, schema, query types.M, fieldName string) (types.S, error) {
+	if schema == nil {
+		schema = types.M{}
+	}
+	path := strings.Split(fieldName, ".")
+	fields := utils.M(schema["fields"])
+	if fields == nil || fields[path[0]] == nil {
+		return types.S{}, nil
+	}
+	tp := utils.M(fields[path[0]])
+	if utils.S(tp["type"]) == "Relation" {
+		return nil, errs.E(errs.InvalidQuery, "Cannot use distinct on Relation field: "+fieldName)
+	}
+
+	where, err := buildWhereClause(schema, query, 1)
+	if err != nil {
+		return nil, err
+	}
+	wherePattern := ""
+	if len(where.pattern) > 0 {
+		wherePattern = `WHERE ` + where.pattern
+	}
+
+	var column string
+	var valueType types.M
+	if len(path) > 1 {
+		// 嵌套字段，路径通过参数传入
+		column = fmt.Sprintf(`"%s"#>$%d::text[]`, path[0], len(where.values)+1)
+		where.values = append(where.values, pq.Array(path[1:]))
+	} else if utils.S(tp["type"]) == "Array" {
+		if contents := utils.M(tp["contents"]); contents != nil && utils.S(contents["type"]) == "String" {
+			column = fmt.Sprintf(`unnest("%s")`, path[0])
+			valueType = types.M{"type": "String"}
+		} else {
+			column = fmt.Sprintf(`jsonb_array_elements("%s")`, path[0])
+		}
+	} else {
+		column = fmt.Sprintf(`"%s"`, path[0])
+		valueType = tp
+	}
+
+	qs := fmt.Sprintf(`SELECT DISTINCT %s AS "value" FROM "%s" %s`, column, className, wherePattern)
+	rows, err := p.query(qs, where.values...)
+	if err != nil {
+		if e, ok := err.(*pq.Error); ok {
+			// 表不存在返回空
+			if e.Code == postgresRelationDoesNotExistError {
+				return types.S{}, nil
+			}
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	valueFields := types.M{}
+	if valueType != nil {
+		valueFields["value"] = valueType
+	}
+	results := types.S{}
+	for rows.Next() {
+		var value interface{}
+		err = rows.Scan(&value)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		object, err := postgresObjectToParseObject(types.M{"value": value}, valueFields)
+		if err != nil {
+			return nil, err
+		}
+		value = object["value"]
+		if b, ok := value.([]byte); ok {
+			var r interface{}
+			if err := json.Unmarshal(b, &r); err == nil {
+				value = r
+			} else {
+				value = string(b)
+			}
+		}
+		if value == nil {
+			continue
+		}
+		results = append(results, value)
+	}
+
+	return results, nil
 }
 
-// FindOneAndUpdate ...
-func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, update types.M) (types.M, error) {
-	updatePatterns := []string{}
+// Aggregate 执行聚合查询，把 pipeline 转换为 SQL 语句
+// 每个阶段尽量合并到当前层的 SELECT 语句中，无法合并时把当前层作为子查询，开始新的一层
+func (p *PostgresAdapter) Aggregate(className string, schema types.M, pipeline types.S) ([]types.M, error) {
+	if schema == nil {
+		schema = types.M{}
+	}
+	schema = toPostgresSchema(types.M{
+		"className": schema["className"],
+		"fields":    utils.CopyMap(utils.M(schema["fields"])),
+	})
+	fields := utils.M(schema["fields"])
+
+	// 当前层输出的列，用于 $project 与 $unwind
+	columns := []string{}
+	for fieldName, v := range fields {
+		if tp := utils.M(v); tp != nil && utils.S(tp["type"]) == "Relation" {
+			delete(fields, fieldName)
+			continue
+		}
+		columns = append(columns, fieldName)
+	}
+	sort.Strings(columns)
+
 	values := types.S{}
-	index := 1
+	layer := &aggregateLayer{from: fmt.Sprintf(`"%s"`, className)}
+	depth := 0
+	wrap := func() {
+		depth++
+		layer = &aggregateLayer{from: fmt.Sprintf(`(%s) AS "_t%d"`, layer.sql(), depth)}
+	}
+
+	for _, s := range pipeline {
+		stage := utils.M(s)
+		for key, value := range stage {
+			switch key {
+			case "$match":
+				if layer.selected() || len(layer.groups) > 0 || len(layer.sorts) > 0 || layer.limit != "" || layer.offset != "" {
+					wrap()
+				}
+				where, err := buildWhereClause(types.M{"fields": utils.CopyMap(fields)}, utils.M(value), len(values)+1)
+				if err != nil {
+					return nil, err
+				}
+				if where.pattern != "" {
+					layer.wheres = append(layer.wheres, where.pattern)
+					values = append(values, where.values...)
+				}
+				layer.sorts = append(layer.sorts, where.sorts...)
+
+			case "$group":
+				if layer.selected() || len(layer.groups) > 0 || len(layer.sorts) > 0 || layer.limit != "" || layer.offset != "" {
+					wrap()
+				}
+				newFields := types.M{}
+				newColumns := []string{"objectId"}
+				group := utils.M(value)
+				id := group["_id"]
+				if path, ok := id.(string); ok && strings.HasPrefix(path, "$") {
+					name := aggregateGroupKeyToSQL(path[1:])
+					layer.groups = append(layer.groups, name)
+					layer.columns = append(layer.columns, name+` AS "objectId"`)
+					if fields[path[1:]] != nil {
+						newFields["objectId"] = fields[path[1:]]
+					}
+				} else if object := utils.M(id); object != nil {
+					keys := []string{}
+					for k := range object {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					pairs := []string{}
+					for _, k := range keys {
+						path, ok := object[k].(string)
+						if ok == false || strings.HasPrefix(path, "$") == false {
+							return nil, errs.E(errs.OperationForbidden, "Postgres doesn't support this $group _id yet")
+						}
+						name := aggregateGroupKeyToSQL(path[1:])
+						layer.groups = append(layer.groups, name)
+						pairs = append(pairs, quoteLiteral(k)+", "+name)
+					}
+					layer.columns = append(layer.columns, fmt.Sprintf(`json_build_object(%s) AS "objectId"`, strings.Join(pairs, ", ")))
+					newFields["objectId"] = types.M{"type": "Object"}
+				} else if id == nil {
+					layer.columns = append(layer.columns, `NULL AS "objectId"`)
+				} else {
+					layer.columns = append(layer.columns, fmt.Sprintf(`$%d AS "objectId"`, len(values)+1))
+					values = append(values, id)
+				}
+
+				aliases := []string{}
+				for alias := range group {
+					if alias != "_id" {
+						aliases = append(aliases, alias)
+					}
+				}
+				sort.Strings(aliases)
+				for _, alias := range aliases {
+					accumulator := utils.M(group[alias])
+					for op, arg := range accumulator {
+						expression, err := aggregateAccumulatorToSQL(op, arg)
+						if err != nil {
+							return nil, err
+						}
+						layer.columns = append(layer.columns, expression+" AS "+quoteIdentifier(alias))
+						if op == "$push" || op == "$addToSet" {
+							newFields[alias] = types.M{"type": "Array"}
+						}
+					}
+					newColumns = append(newColumns, alias)
+				}
+				fields = newFields
+				columns = newColumns
+
+			case "$project":
+				if layer.selected() || len(layer.groups) > 0 || layer.limit != "" || layer.offset != "" {
+					wrap()
+				}
+				project := utils.M(value)
+				include := false
+				for k, v := range project {
+					if k == "_id" || k == "objectId" {
+						continue
+					}
+					if aggregateProjectionIsTrue(v) {
+						include = true
+					} else if aggregateProjectionIsFalse(v) == false {
+						return nil, errs.E(errs.OperationForbidden, "Postgres doesn't support expression in $project yet")
+					}
+				}
+				newFields := types.M{}
+				newColumns := []string{}
+				for _, column := range columns {
+					v, ok := project[column]
+					if column == "objectId" {
+						if id, ok := project["_id"]; ok {
+							v = id
+						}
+						if aggregateProjectionIsFalse(v) {
+							continue
+						}
+					} else if include && (ok == false || aggregateProjectionIsFalse(v)) {
+						continue
+					} else if include == false && ok && aggregateProjectionIsFalse(v) {
+						continue
+					}
+					newColumns = append(newColumns, column)
+					layer.columns = append(layer.columns, fmt.Sprintf(`"%s"`, column))
+					if fields[column] != nil {
+						newFields[column] = fields[column]
+					}
+				}
+				if len(newColumns) == 0 {
+					return nil, errs.E(errs.InvalidQuery, "Invalid aggregate $project, no field is selected.")
+				}
+				fields = newFields
+				columns = newColumns
+
+			case "$sort":
+				if len(layer.sorts) > 0 || layer.limit != "" || layer.offset != "" {
+					wrap()
+				}
+				if keys, ok := value.([]string); ok {
+					for _, k := range keys {
+						if strings.HasPrefix(k, "-") {
+							layer.sorts = append(layer.sorts, quoteIdentifier(k[1:])+" DESC")
+						} else {
+							layer.sorts = append(layer.sorts, quoteIdentifier(k)+" ASC")
+						}
+					}
+				}
+
+			case "$skip":
+				if layer.limit != "" || layer.offset != "" {
+					wrap()
+				}
+				layer.offset = fmt.Sprintf(`OFFSET $%d`, len(values)+1)
+				values = append(values, value)
+
+			case "$limit":
+				if layer.limit != "" {
+					wrap()
+				}
+				layer.limit = fmt.Sprintf(`LIMIT $%d`, len(values)+1)
+				values = append(values, value)
+
+			case "$unwind":
+				if layer.selected() || len(layer.groups) > 0 || len(layer.sorts) > 0 || layer.limit != "" || layer.offset != "" {
+					wrap()
+				}
+				path := utils.S(value)[1:]
+				found := false
+				for _, column := range columns {
+					if column != path {
+						layer.columns = append(layer.columns, fmt.Sprintf(`"%s"`, column))
+						continue
+					}
+					found = true
+					tp := utils.M(fields[column])
+					if contents := utils.M(tp["contents"]); tp != nil && contents != nil && utils.S(contents["type"]) == "String" {
+						layer.columns = append(layer.columns, fmt.Sprintf(`unnest("%s") AS "%s"`, column, column))
+						fields[column] = types.M{"type": "String"}
+					} else {
+						layer.columns = append(layer.columns, fmt.Sprintf(`jsonb_array_elements("%s") AS "%s"`, column, column))
+						delete(fields, column)
+					}
+				}
+				if found == false {
+					return nil, errs.E(errs.InvalidQuery, "Invalid aggregate $unwind, field not found: "+path)
+				}
+			}
+		}
+	}
+
+	rows, err := p.query(layer.sql(), values...)
+	if err != nil {
+		if e, ok := err.(*pq.Error); ok {
+			// 表不存在返回空
+			if e.Code == postgresRelationDoesNotExistError {
+				return []types.M{}, nil
+			}
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []types.M{}
+	resultColumns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		resultValues := make([]interface{}, len(resultColumns))
+		pointers := make([]interface{}, len(resultColumns))
+		for i := range resultValues {
+			pointers[i] = &resultValues[i]
+		}
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return nil, err
+		}
+		object := types.M{}
+		for i, field := range resultColumns {
+			object[field] = resultValues[i]
+		}
 
+		object, err = postgresObjectToParseObject(object, fields)
+		if err != nil {
+			return nil, err
+		}
+		// 聚合产生的字段没有类型信息，尝试按 json 解析
+		for k, v := range object {
+			if b, ok := v.([]byte); ok {
+				var r interface{}
+				if err := json.Unmarshal(b, &r); err == nil {
+					object[k] = r
+				} else {
+					object[k] = string(b)
+				}
+			}
+		}
+
+		results = append(results, object)
+	}
+
+	return results, nil
+}
+
+// UpdateObjectsByQuery 更新所有符合条件的对象
+func (p *PostgresAdapter) UpdateObjectsByQuery(className string, schema, query, update types.M) error {
+	if schema == nil {
+		schema = types.M{}
+	}
+	schema = toPostgresSchema(schema)
+
+	set, err := buildUpdateClause(schema, update, 1)
+	if err != nil {
+		return err
+	}
+	where, err := buildWhereClause(schema, query, set.index)
+	if err != nil {
+		return err
+	}
+	if where.pattern == "" {
+		where.pattern = "TRUE"
+	}
+	values := append(set.values, where.values...)
+
+	qs := fmt.Sprintf(`UPDATE "%s" SET %s WHERE %s`, className, set.pattern, where.pattern)
+	_, err = p.exec(qs, values...)
+	if err != nil {
+		if e, ok := err.(*pq.Error); ok {
+			// 表不存在时没有需要更新的对象
+			if e.Code == postgresRelationDoesNotExistError {
+				return nil
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// FindOneAndUpdate 更新第一个符合条件的对象，并返回更新后的对象
+// 更新时重新校验查询条件，可以作为条件更新使用
+func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, update types.M) (types.M, error) {
 	if schema == nil {
 		schema = types.M{}
 	}
@@ -913,9 +1591,81 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 		fields = types.M{}
 	}
 
-	originalUpdate := utils.CopyMapM(update)
-	update = handleDotFields(update)
+	set, err := buildUpdateClause(schema, update, 1)
+	if err != nil {
+		return nil, err
+	}
+	where, err := buildWhereClause(schema, query, set.index)
+	if err != nil {
+		return nil, err
+	}
+	if where.pattern == "" {
+		where.pattern = "TRUE"
+	}
+	values := append(set.values, where.values...)
+
+	qs := fmt.Sprintf(`UPDATE "%s" SET %s WHERE "objectId" IN (SELECT "objectId" FROM "%s" WHERE %s LIMIT 1) AND %s RETURNING *`, className, set.pattern, className, where.pattern, where.pattern)
+	rows, err := p.query(qs, values...)
+	if err != nil {
+		if e, ok := err.(*pq.Error); ok {
+			// 表不存在返回空
+			if e.Code == postgresRelationDoesNotExistError {
+				return nil, errs.E(errs.ObjectNotFound, "Object not found.")
+			}
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	object := types.M{}
+	if rows.Next() {
+		resultColumns, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+
+		resultValues := []*interface{}{}
+		values := types.S{}
+		for i := 0; i < len(resultColumns); i++ {
+			var v interface{}
+			resultValues = append(resultValues, &v)
+			values = append(values, &v)
+		}
+		err = rows.Scan(values...)
+		if err != nil {
+			return nil, err
+		}
+		for i, field := range resultColumns {
+			object[field] = *resultValues[i]
+		}
+
+		object, err = postgresObjectToParseObject(object, fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return object, nil
+}
+
+type updateClause struct {
+	pattern string
+	values  types.S
+	index   int
+}
+
+// buildUpdateClause 把更新数据转换为 SET 语句，支持的更新操作与 MongoTransform 相同
+// 带 . 的字段表示更新 Object 字段中的嵌套字段，对应 Mongo 中的 $set 、 $inc 、 $unset 等
+func buildUpdateClause(schema, update types.M, index int) (*updateClause, error) {
+	updatePatterns := []string{}
+	values := types.S{}
+
+	fields := utils.M(schema["fields"])
+	if fields == nil {
+		fields = types.M{}
+	}
 
+	update = utils.CopyMapM(update)
 	for fieldName, v := range update {
 		re := regexp.MustCompile(`^_auth_data_([a-zA-Z0-9_]+)$`)
 		authDataMatch := re.FindStringSubmatch(fieldName)
@@ -931,7 +1681,78 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 		}
 	}
 
-	for fieldName, fieldValue := range update {
+	// 嵌套字段的更新按照所属字段分组，在所属字段的更新之后执行
+	dotFields := map[string][]string{}
+	for fieldName := range update {
+		if i := strings.Index(fieldName, "."); i > -1 {
+			dotFields[fieldName[:i]] = append(dotFields[fieldName[:i]], fieldName)
+		}
+	}
+	for fieldName, keys := range dotFields {
+		if _, ok := update[fieldName]; ok == false {
+			update[fieldName] = nil
+		}
+		sort.Strings(keys)
+	}
+
+	fieldNames := []string{}
+	for fieldName := range update {
+		if strings.Index(fieldName, ".") == -1 {
+			fieldNames = append(fieldNames, fieldName)
+		}
+	}
+	sort.Strings(fieldNames)
+
+	for _, fieldName := range fieldNames {
+		fieldValue := update[fieldName]
+
+		if keys, ok := dotFields[fieldName]; ok {
+			tp := utils.M(fields[fieldName])
+			if postgresType, _ := parseTypeToPostgresType(tp); postgresType != "jsonb" {
+				b, _ := json.Marshal(types.M{keys[0]: update[keys[0]]})
+				return nil, errs.E(errs.OperationForbidden, "Postgres doesn't support update "+string(b)+" yet")
+			}
+			expr := fmt.Sprintf(`COALESCE("%s", '{}'::jsonb)`, fieldName)
+			if fieldValue != nil {
+				b, err := json.Marshal(toPostgresJSONValue(fieldValue))
+				if err != nil {
+					return nil, err
+				}
+				expr = fmt.Sprintf(`$%d::jsonb`, index)
+				values = append(values, string(b))
+				index = index + 1
+			}
+			for _, key := range keys {
+				path := strings.Split(key, ".")[1:]
+				op, value := "Set", update[key]
+				if object := utils.M(value); object != nil {
+					switch utils.S(object["__op"]) {
+					case "Delete":
+						op = "Delete"
+					case "Increment":
+						op, value = "Increment", object["amount"]
+					case "Add", "AddUnique", "Remove":
+						op, value = utils.S(object["__op"]), toPostgresJSONValue(object["objects"])
+					}
+				}
+				if op == "Delete" {
+					expr = fmt.Sprintf(`(%s #- $%d::text[])`, expr, index)
+					values = append(values, pq.Array(path))
+					index = index + 1
+					continue
+				}
+				b, err := json.Marshal(value)
+				if err != nil {
+					return nil, err
+				}
+				expr = fmt.Sprintf(`json_object_update_path(%s, $%d::text[], '%s', $%d::jsonb)`, expr, index, op, index+1)
+				values = append(values, pq.Array(path), string(b))
+				index = index + 2
+			}
+			updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = %s`, fieldName, expr))
+			continue
+		}
+
 		if fieldValue == nil {
 			updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = NULL`, fieldName))
 			continue
@@ -973,52 +1794,60 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 			continue
 		}
 
-		switch fieldValue.(type) {
-		case string, bool, float64, int:
+		switch v := fieldValue.(type) {
+		case string, bool, float64, int, int64, int32, float32:
 			updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d`, fieldName, index))
 			values = append(values, fieldValue)
 			index = index + 1
 			continue
 		case time.Time:
 			updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d`, fieldName, index))
-			values = append(values, utils.TimetoString(fieldValue.(time.Time)))
+			values = append(values, utils.TimetoString(v))
 			index = index + 1
 			continue
 		}
 
+		tp := utils.M(fields[fieldName])
+		postgresType, _ := parseTypeToPostgresType(tp)
+
 		if object := utils.M(fieldValue); object != nil {
-			switch utils.S(object["__op"]) {
+			switch op := utils.S(object["__op"]); op {
 			case "Increment":
 				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = COALESCE("%s", 0) + $%d`, fieldName, fieldName, index))
 				values = append(values, object["amount"])
 				index = index + 1
 				continue
-			case "Add":
-				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = array_add(COALESCE("%s", '[]'::jsonb), $%d::jsonb)`, fieldName, fieldName, index))
-				b, err := json.Marshal(object["objects"])
-				if err != nil {
-					return nil, err
-				}
-				values = append(values, string(b))
-				index = index + 1
-				continue
 			case "Delete":
-				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d`, fieldName, index))
-				values = append(values, nil)
-				index = index + 1
+				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = NULL`, fieldName))
 				continue
-			case "Remove":
-				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = array_remove(COALESCE("%s", '[]'::jsonb), $%d::jsonb)`, fieldName, fieldName, index))
-				b, err := json.Marshal(object["objects"])
-				if err != nil {
-					return nil, err
+			case "Add", "AddUnique", "Remove":
+				if postgresType == "text[]" {
+					items := []string{}
+					for _, item := range utils.A(object["objects"]) {
+						s, ok := item.(string)
+						if ok == false {
+							b, _ := json.Marshal(item)
+							return nil, errs.E(errs.IncorrectType, "expected string but got "+string(b))
+						}
+						items = append(items, s)
+					}
+					var pattern string
+					switch op {
+					case "Add":
+						pattern = `"%[1]s" = array_cat(COALESCE("%[1]s", '{}'::text[]), $%[2]d::text[])`
+					case "AddUnique":
+						pattern = `"%[1]s" = array_cat(COALESCE("%[1]s", '{}'::text[]), ARRAY(SELECT "item" FROM unnest($%[2]d::text[]) WITH ORDINALITY AS t("item", "ord") WHERE "item" <> ALL(COALESCE("%[1]s", '{}'::text[])) GROUP BY "item" ORDER BY min("ord")))`
+					case "Remove":
+						pattern = `"%[1]s" = ARRAY(SELECT "item" FROM unnest(COALESCE("%[1]s", '{}'::text[])) AS "item" WHERE "item" <> ALL($%[2]d::text[]))`
+					}
+					updatePatterns = append(updatePatterns, fmt.Sprintf(pattern, fieldName, index))
+					values = append(values, pq.Array(items))
+					index = index + 1
+					continue
 				}
-				values = append(values, string(b))
-				index = index + 1
-				continue
-			case "AddUnique":
-				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = array_add_unique(COALESCE("%s", '[]'::jsonb), $%d::jsonb)`, fieldName, fieldName, index))
-				b, err := json.Marshal(object["objects"])
+				functions := map[string]string{"Add": "array_add", "AddUnique": "array_add_unique", "Remove": "array_remove"}
+				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = %s(COALESCE("%s", '[]'::jsonb), $%d::jsonb)`, fieldName, functions[op], fieldName, index))
+				b, err := json.Marshal(toPostgresJSONValue(object["objects"]))
 				if err != nil {
 					return nil, err
 				}
@@ -1043,60 +1872,23 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 				values = append(values, object["longitude"], object["latitude"])
 				index = index + 2
 				continue
+			case "Polygon":
+				polygon, err := toPostgresPolygon(object)
+				if err != nil {
+					return nil, err
+				}
+				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d::polygon`, fieldName, index))
+				values = append(values, polygon)
+				index = index + 1
+				continue
 			case "Relation":
 				continue
 			}
 
-			if tp := utils.M(fields[fieldName]); tp != nil && utils.S(tp["type"]) == "Object" {
-				keysToIncrement := []string{}
-				for k, v := range originalUpdate {
-					if o := utils.M(v); o != nil && utils.S(o["__op"]) == "Increment" {
-						if keys := strings.Split(k, "."); len(keys) == 2 && keys[0] == fieldName {
-							keysToIncrement = append(keysToIncrement, keys[1])
-						}
-					}
-				}
-
-				incrementPatterns := ""
-				if len(keysToIncrement) > 0 {
-					for _, key := range keysToIncrement {
-						increment := utils.M(object[key])
-						if increment == nil {
-							continue
-						}
-
-						var amount interface{}
-						switch increment["amount"].(type) {
-						case float64, int:
-							amount = increment["amount"]
-						}
-						if amount == nil {
-							continue
-						}
-						incrementPatterns += " || " + fmt.Sprintf(`CONCAT('{"%s":', COALESCE("%s"->>'%s', '0')::float + %v, '}')::jsonb`, key, fieldName, key, amount)
-					}
-
-					for _, key := range keysToIncrement {
-						delete(object, key)
-					}
-				}
-
-				keysToDelete := []string{}
-				for k, v := range originalUpdate {
-					if o := utils.M(v); o != nil && utils.S(o["__op"]) == "Delete" {
-						if keys := strings.Split(k, "."); len(keys) == 2 && keys[0] == fieldName {
-							keysToDelete = append(keysToDelete, keys[1])
-						}
-					}
-				}
-
-				deletePatterns := ""
-				for _, k := range keysToDelete {
-					deletePatterns = deletePatterns + fmt.Sprintf(` - '%s'`, k)
-				}
-
-				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = ( COALESCE("%s", '{}'::jsonb) %s %s || $%d::jsonb )`, fieldName, fieldName, deletePatterns, incrementPatterns, index))
-				b, err := json.Marshal(object)
+			if utils.S(tp["type"]) == "Object" {
+				// 与 Mongo 相同，设置整个 Object 字段时替换原有的值
+				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d::jsonb`, fieldName, index))
+				b, err := json.Marshal(toPostgresJSONValue(object))
 				if err != nil {
 					return nil, err
 				}
@@ -1107,32 +1899,22 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 		}
 
 		if array := utils.A(fieldValue); array != nil {
-			if tp := utils.M(fields[fieldName]); tp != nil && utils.S(tp["type"]) == "Array" {
-				expectedType, err := parseTypeToPostgresType(tp)
-				if err != nil {
-					return nil, err
-				}
-
-				b, err := json.Marshal(fieldValue)
-				if err != nil {
-					return nil, err
-				}
-
-				if expectedType == "text[]" {
-					// '[' => '{'
-					if b[0] == 91 {
-						b[0] = 123
-					}
-					// ']' => '}'
-					if len(b) > 0 && b[len(b)-1] == 93 {
-						b[len(b)-1] = 125
+			if utils.S(tp["type"]) == "Array" {
+				if postgresType == "text[]" {
+					items := []string{}
+					for _, item := range array {
+						items = append(items, utils.S(item))
 					}
 					updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d::text[]`, fieldName, index))
+					values = append(values, pq.Array(items))
 				} else {
+					b, err := json.Marshal(toPostgresJSONValue(array))
+					if err != nil {
+						return nil, err
+					}
 					updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d::jsonb`, fieldName, index))
+					values = append(values, string(b))
 				}
-
-				values = append(values, string(b))
 				index = index + 1
 				continue
 			}
@@ -1142,55 +1924,44 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 		return nil, errs.E(errs.OperationForbidden, "Postgres doesn't support update "+string(b)+" yet")
 	}
 
-	where, err := buildWhereClause(schema, query, index)
-	if err != nil {
-		return nil, err
+	if len(updatePatterns) == 0 {
+		// 仅包含 Relation 等无需写入的更新时，保持对象不变
+		updatePatterns = append(updatePatterns, `"objectId" = "objectId"`)
 	}
-	values = append(values, where.values...)
 
-	// TODO 需要添加限制，只更新一条，UpdateObjectsByQuery 时更新多条
-	qs := fmt.Sprintf(`UPDATE "%s" SET %s WHERE %s RETURNING *`, className, strings.Join(updatePatterns, ","), where.pattern)
-	rows, err := p.db.Query(qs, values...)
-	if err != nil {
-		if e, ok := err.(*pq.Error); ok {
-			// 表不存在返回空
-			if e.Code == postgresRelationDoesNotExistError {
-				return nil, errs.E(errs.ObjectNotFound, "Object not found.")
-			}
-		}
-		return nil, err
+	clause := &updateClause{
+		pattern: strings.Join(updatePatterns, ","),
+		values:  values,
+		index:   index,
 	}
-	defer rows.Close()
-
-	object := types.M{}
-	if rows.Next() {
-		resultColumns, err := rows.Columns()
-		if err != nil {
-			return nil, err
-		}
+	return clause, nil
+}
 
-		resultValues := []*interface{}{}
-		values := types.S{}
-		for i := 0; i < len(resultColumns); i++ {
-			var v interface{}
-			resultValues = append(resultValues, &v)
-			values = append(values, &v)
-		}
-		err = rows.Scan(values...)
-		if err != nil {
-			return nil, err
+// toPostgresJSONValue 转换保存在 jsonb 中的值，与 MongoTransform 相同，
+// Pointer 仅保留 __type 、 className 、 objectId
+func toPostgresJSONValue(value interface{}) interface{} {
+	if object := utils.M(value); object != nil {
+		if utils.S(object["__type"]) == "Pointer" {
+			return types.M{
+				"__type":    "Pointer",
+				"className": object["className"],
+				"objectId":  object["objectId"],
+			}
 		}
-		for i, field := range resultColumns {
-			object[field] = *resultValues[i]
+		result := types.M{}
+		for k, v := range object {
+			result[k] = toPostgresJSONValue(v)
 		}
-
-		object, err = postgresObjectToParseObject(object, fields)
-		if err != nil {
-			return nil, err
+		return result
+	}
+	if array := utils.A(value); array != nil {
+		result := types.S{}
+		for _, v := range array {
+			result = append(result, toPostgresJSONValue(v))
 		}
+		return result
 	}
-
-	return object, nil
+	return value
 }
 
 // UpsertOneObject 仅用于 config 和 hooks
@@ -1226,7 +1997,7 @@ func (p *PostgresAdapter) EnsureUniqueness(className string, schema types.M, fie
 	}
 
 	qs := fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT "%s" UNIQUE (%s)`, className, constraintName, strings.Join(constraintPatterns, ","))
-	_, err := p.db.Exec(qs)
+	_, err := p.exec(qs)
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok {
 			if e.Code == postgresDuplicateRelationError && strings.Contains(e.Message, constraintName) {
@@ -1241,6 +2012,236 @@ func (p *PostgresAdapter) EnsureUniqueness(className string, schema types.M, fie
 	return nil
 }
 
+// CreateIndexes 创建索引，并把索引定义保存到 _SCHEMA 的 indexes 中
+// 数据库中的索引名称为 className_name ，以免不同类中的同名索引冲突
+// sparse 通过部分索引实现，仅索引字段不为 NULL 的行
+// 文本索引使用 GIN 索引，不支持与普通字段组合，也不支持 unique ，不支持 expireAfterSeconds
+func (p *PostgresAdapter) CreateIndexes(className string, schema, indexes types.M) error {
+	qss := []string{}
+	for name, v := range indexes {
+		qs, err := buildCreateIndexQuery(className, name, utils.M(v))
+		if err != nil {
+			return err
+		}
+		qss = append(qss, qs)
+	}
+	if len(qss) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(indexes)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.begin()
+	if err != nil {
+		return err
+	}
+	for _, qs := range qss {
+		_, err = tx.Exec(qs)
+		if err != nil {
+			if e, ok := err.(*pq.Error); ok && e.Code == postgresUniqueIndexViolationError {
+				err = errs.E(errs.DuplicateValue, "Tried to ensure field uniqueness for a class that already has duplicates.")
+			}
+			tx.Rollback()
+			return err
+		}
+	}
+	qs := `UPDATE "_SCHEMA" SET "schema" = jsonb_set("schema", '{indexes}', COALESCE("schema"->'indexes', '{}'::jsonb) || $1::jsonb) WHERE "className"=$2`
+	_, err = tx.Exec(qs, string(b), className)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// DropIndexes 删除索引，并从 _SCHEMA 中删除索引定义
+func (p *PostgresAdapter) DropIndexes(className string, indexNames []string) error {
+	if len(indexNames) == 0 {
+		return nil
+	}
+	tx, err := p.begin()
+	if err != nil {
+		return err
+	}
+	for _, name := range indexNames {
+		_, err = tx.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS "%s"`, className+"_"+name))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		qs := `UPDATE "_SCHEMA" SET "schema" = jsonb_set("schema", '{indexes}', COALESCE("schema"->'indexes', '{}'::jsonb) - $1::text) WHERE "className"=$2`
+		_, err = tx.Exec(qs, name, className)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetIndexes 获取数据库中实际存在的索引，格式与 CreateIndexes 中的相同
+// 通过 CreateIndexes 创建的索引，返回时去掉名称中的 className_ 前缀
+func (p *PostgresAdapter) GetIndexes(className string) (types.M, error) {
+	rows, err := p.query(`SELECT indexname, indexdef FROM pg_indexes WHERE tablename = $1`, className)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := types.M{}
+	for rows.Next() {
+		var name, definition string
+		err = rows.Scan(&name, &definition)
+		if err != nil {
+			return nil, err
+		}
+		name = strings.TrimPrefix(name, className+"_")
+		result[name] = parseIndexDefinition(definition)
+	}
+	return result, rows.Err()
+}
+
+// buildCreateIndexQuery 生成创建索引的语句
+func buildCreateIndexQuery(className, name string, index types.M) (string, error) {
+	indexName := className + "_" + name
+	if len(indexName) > 63 {
+		return "", errs.E(errs.InvalidQuery, "Index name "+indexName+" is too long for Postgres")
+	}
+	if index == nil {
+		return "", errs.E(errs.InvalidJSON, "index "+name+" should be an object")
+	}
+	if index["expireAfterSeconds"] != nil {
+		return "", errs.E(errs.OperationForbidden, "Postgres doesn't support expireAfterSeconds")
+	}
+	unique, _ := index["unique"].(bool)
+	sparse, _ := index["sparse"].(bool)
+
+	columns := []string{}
+	textColumns := []string{}
+	notNull := []string{}
+	for _, v := range utils.A(index["fields"]) {
+		key := utils.S(v)
+		if strings.HasPrefix(key, "$text:") {
+			key = key[len("$text:"):]
+			textColumns = append(textColumns, fmt.Sprintf(`to_tsvector('english'::regconfig, "%s")`, key))
+		} else if strings.HasPrefix(key, "-") {
+			key = key[1:]
+			columns = append(columns, fmt.Sprintf(`"%s" DESC`, key))
+		} else {
+			columns = append(columns, fmt.Sprintf(`"%s" ASC`, key))
+		}
+		notNull = append(notNull, fmt.Sprintf(`"%s" IS NOT NULL`, key))
+	}
+	if len(columns) == 0 && len(textColumns) == 0 {
+		return "", errs.E(errs.InvalidJSON, "fields of index "+name+" should be a non-empty array")
+	}
+
+	if len(textColumns) > 0 {
+		if len(columns) > 0 {
+			return "", errs.E(errs.OperationForbidden, "Postgres doesn't support text indexes combined with other fields")
+		}
+		if unique {
+			return "", errs.E(errs.OperationForbidden, "Postgres doesn't support unique text indexes")
+		}
+	}
+
+	qs := "CREATE "
+	if unique {
+		qs += "UNIQUE "
+	}
+	if len(textColumns) > 0 {
+		qs += fmt.Sprintf(`INDEX "%s" ON "%s" USING GIN (%s)`, indexName, className, strings.Join(textColumns, ", "))
+	} else {
+		qs += fmt.Sprintf(`INDEX "%s" ON "%s" (%s)`, indexName, className, strings.Join(columns, ", "))
+	}
+	if sparse {
+		qs += " WHERE " + strings.Join(notNull, " OR ")
+	}
+	return qs, nil
+}
+
+// parseIndexDefinition 解析 pg_indexes 中的 indexdef ，转换为 CreateIndexes 中的索引格式
+// CREATE UNIQUE INDEX "Foo_name" ON public."Foo" USING btree (name, "createdAt" DESC) WHERE (name IS NOT NULL)
+func parseIndexDefinition(definition string) types.M {
+	index := types.M{}
+	if strings.HasPrefix(definition, "CREATE UNIQUE INDEX") {
+		index["unique"] = true
+	}
+	fields := types.S{}
+	start := strings.Index(definition, " USING ")
+	if start < 0 {
+		index["fields"] = fields
+		return index
+	}
+	definition = definition[start+len(" USING "):]
+	start = strings.Index(definition, "(")
+	if start < 0 {
+		index["fields"] = fields
+		return index
+	}
+
+	// 按照括号层级拆分字段列表
+	depth := 0
+	end := len(definition)
+	items := []string{}
+	item := ""
+	for i := start + 1; i < len(definition); i++ {
+		c := definition[i]
+		if c == '(' {
+			depth++
+		} else if c == ')' {
+			if depth == 0 {
+				end = i
+				break
+			}
+			depth--
+		} else if c == ',' && depth == 0 {
+			items = append(items, item)
+			item = ""
+			continue
+		}
+		item += string(c)
+	}
+	items = append(items, item)
+
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if strings.HasPrefix(item, "to_tsvector(") {
+			// to_tsvector('english'::regconfig, title)
+			item = strings.TrimSuffix(item, ")")
+			if i := strings.LastIndex(item, ","); i > -1 {
+				item = item[i+1:]
+			}
+			fields = append(fields, "$text:"+unquoteIdentifier(strings.TrimSpace(item)))
+			continue
+		}
+		item = strings.TrimSuffix(item, " NULLS FIRST")
+		item = strings.TrimSuffix(item, " NULLS LAST")
+		prefix := ""
+		if strings.HasSuffix(item, " DESC") {
+			prefix = "-"
+			item = strings.TrimSuffix(item, " DESC")
+		}
+		item = strings.TrimSuffix(item, " ASC")
+		fields = append(fields, prefix+unquoteIdentifier(item))
+	}
+	index["fields"] = fields
+
+	if strings.Contains(definition[end:], " WHERE ") {
+		index["sparse"] = true
+	}
+	return index
+}
+
+// unquoteIdentifier 去掉标识符两边的引号
+func unquoteIdentifier(identifier string) string {
+	if len(identifier) >= 2 && strings.HasPrefix(identifier, `"`) && strings.HasSuffix(identifier, `"`) {
+		return strings.Replace(identifier[1:len(identifier)-1], `""`, `"`, -1)
+	}
+	return identifier
+}
+
 // PerformInitialization ...
 func (p *PostgresAdapter) PerformInitialization(options types.M) error {
 	if options == nil {
@@ -1266,7 +2267,7 @@ func (p *PostgresAdapter) PerformInitialization(options types.M) error {
 		}
 	}
 
-	tx, err := p.db.Begin()
+	tx, err := p.begin()
 	if err != nil {
 		return err
 	}
@@ -1301,6 +2302,16 @@ func (p *PostgresAdapter) PerformInitialization(options types.M) error {
 		return err
 	}
 
+	_, err = tx.Exec(jsonObjectSetPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(jsonObjectUpdatePath)
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -1371,6 +2382,19 @@ func postgresObjectToParseObject(object, fields types.M) (types.M, error) {
 				"longitude": longitude,
 				"latitude":  latitude,
 			}
+		} else if objectType == "Polygon" && object[fieldName] != nil {
+			// object[fieldName] = ((10,20),(30,40),...) (longitude, latitude)
+			resString := ""
+			if v, ok := object[fieldName].([]byte); ok {
+				resString = string(v)
+			} else if v, ok := object[fieldName].(string); ok {
+				resString = v
+			}
+			polygon, err := postgresPolygonToParsePolygon(resString)
+			if err != nil {
+				return nil, err
+			}
+			object[fieldName] = polygon
 		} else if objectType == "File" && object[fieldName] != nil {
 			if v, ok := object[fieldName].([]byte); ok {
 				object[fieldName] = types.M{
@@ -1556,6 +2580,8 @@ func parseTypeToPostgresType(t types.M) (string, error) {
 		return "double precision", nil
 	case "GeoPoint":
 		return "point", nil
+	case "Polygon":
+		return "polygon", nil
 	case "Array":
 		if contents := utils.M(t["contents"]); contents != nil {
 			if utils.S(contents["type"]) == "String" {
@@ -1568,6 +2594,50 @@ func parseTypeToPostgresType(t types.M) (string, error) {
 	}
 }
 
+// toPostgresPolygon 转换 Polygon 为数据库中的格式 ((longitude, latitude), ...)
+// Polygon 的坐标格式为 [latitude, longitude] ，至少需要三个不同的点，首尾坐标不同时自动闭合
+func toPostgresPolygon(value interface{}) (string, error) {
+	coordinates, err := storage.PolygonCoordinates(utils.M(value)["coordinates"])
+	if err != nil {
+		return "", err
+	}
+	if coordinates[0] != coordinates[len(coordinates)-1] {
+		coordinates = append(coordinates, coordinates[0])
+	}
+	pointStrings := []string{}
+	for _, coordinate := range coordinates {
+		pointStrings = append(pointStrings, fmt.Sprintf("(%v, %v)", coordinate[1], coordinate[0]))
+	}
+	return "(" + strings.Join(pointStrings, ", ") + ")", nil
+}
+
+// postgresPolygonToParsePolygon 转换数据库中的 ((longitude,latitude),...) 为 Polygon
+func postgresPolygonToParsePolygon(s string) (types.M, error) {
+	coordinates := types.S{}
+	s = strings.TrimSpace(s)
+	if len(s) > 4 {
+		for _, pointString := range strings.Split(s[2:len(s)-2], "),(") {
+			point := strings.Split(pointString, ",")
+			if len(point) != 2 {
+				continue
+			}
+			longitude, err := strconv.ParseFloat(strings.TrimSpace(point[0]), 64)
+			if err != nil {
+				return nil, err
+			}
+			latitude, err := strconv.ParseFloat(strings.TrimSpace(point[1]), 64)
+			if err != nil {
+				return nil, err
+			}
+			coordinates = append(coordinates, types.S{latitude, longitude})
+		}
+	}
+	return types.M{
+		"__type":      "Polygon",
+		"coordinates": coordinates,
+	}, nil
+}
+
 func toPostgresValue(value interface{}) interface{} {
 	if v := utils.M(value); v != nil {
 		if utils.S(v["__type"]) == "Date" {
@@ -1640,11 +2710,15 @@ func toParseSchema(schema types.M) types.M {
 		}
 	}
 
-	return types.M{
+	result := types.M{
 		"className":             schema["className"],
 		"fields":                fields,
 		"classLevelPermissions": clps,
 	}
+	if indexes := utils.M(schema["indexes"]); len(indexes) > 0 {
+		result["indexes"] = types.M(indexes)
+	}
+	return result
 }
 
 func toPostgresSchema(schema types.M) types.M {
@@ -1744,16 +2818,163 @@ func joinTablesForSchema(schema types.M) []string {
 	return list
 }
 
+// aggregateLayer 聚合查询中的一层 SELECT 语句
+type aggregateLayer struct {
+	from    string
+	columns []string
+	wheres  []string
+	groups  []string
+	sorts   []string
+	limit   string
+	offset  string
+}
+
+// selected 当前层是否已经指定了输出的列
+func (l *aggregateLayer) selected() bool {
+	return len(l.columns) > 0
+}
+
+func (l *aggregateLayer) sql() string {
+	columns := "*"
+	if len(l.columns) > 0 {
+		columns = strings.Join(l.columns, ", ")
+	}
+	qs := fmt.Sprintf(`SELECT %s FROM %s`, columns, l.from)
+	if len(l.wheres) > 0 {
+		qs += ` WHERE ` + strings.Join(l.wheres, " AND ")
+	}
+	if len(l.groups) > 0 {
+		qs += ` GROUP BY ` + strings.Join(l.groups, ", ")
+	}
+	if len(l.sorts) > 0 {
+		qs += ` ORDER BY ` + strings.Join(l.sorts, ", ")
+	}
+	if l.limit != "" {
+		qs += ` ` + l.limit
+	}
+	if l.offset != "" {
+		qs += ` ` + l.offset
+	}
+	return qs
+}
+
+// aggregateGroupKeyToSQL 把 $group 中 _id 引用的字段转换为 SQL 表达式
+// a.b 形式的嵌套字段转换为 jsonb 路径 "a"->'b'
+func aggregateGroupKeyToSQL(path string) string {
+	components := strings.Split(path, ".")
+	name := quoteIdentifier(components[0])
+	for _, cmpt := range components[1:] {
+		name += "->" + quoteLiteral(cmpt)
+	}
+	return name
+}
+
+// aggregateAccumulatorToSQL 把 $group 中的累加器转换为 SQL 聚合函数
+func aggregateAccumulatorToSQL(op string, arg interface{}) (string, error) {
+	var column string
+	if path, ok := arg.(string); ok && strings.HasPrefix(path, "$") {
+		if strings.Contains(path, ".") {
+			return "", errs.E(errs.InvalidQuery, "Postgres doesn't support nested field "+path+" in $group accumulators")
+		}
+		column = quoteIdentifier(path[1:])
+	}
+	switch op {
+	case "$sum":
+		if column != "" {
+			return fmt.Sprintf(`SUM(%s)`, column), nil
+		}
+		var n float64
+		if v, ok := arg.(float64); ok {
+			n = v
+		} else if v, ok := arg.(int); ok {
+			n = float64(v)
+		} else {
+			break
+		}
+		if n == 1 {
+			return `count(*)`, nil
+		}
+		return fmt.Sprintf(`count(*) * %s`, strconv.FormatFloat(n, 'f', -1, 64)), nil
+	case "$avg":
+		if column != "" {
+			return fmt.Sprintf(`AVG(%s)`, column), nil
+		}
+	case "$min":
+		if column != "" {
+			return fmt.Sprintf(`MIN(%s)`, column), nil
+		}
+	case "$max":
+		if column != "" {
+			return fmt.Sprintf(`MAX(%s)`, column), nil
+		}
+	case "$push":
+		if column != "" {
+			return fmt.Sprintf(`json_agg(%s)`, column), nil
+		}
+	case "$addToSet":
+		if column != "" {
+			return fmt.Sprintf(`json_agg(DISTINCT %s)`, column), nil
+		}
+	}
+	return "", errs.E(errs.OperationForbidden, "Postgres doesn't support "+op+" in $group yet")
+}
+
+// quoteIdentifier 转义 SQL 中的标识符，用于拼接客户端传入的字段名
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// quoteLiteral 转义 SQL 中的字符串常量
+func quoteLiteral(value string) string {
+	return `'` + strings.Replace(value, `'`, `''`, -1) + `'`
+}
+
+func aggregateProjectionIsTrue(v interface{}) bool {
+	switch n := v.(type) {
+	case bool:
+		return n
+	case float64:
+		return n != 0
+	case int:
+		return n != 0
+	}
+	return false
+}
+
+func aggregateProjectionIsFalse(v interface{}) bool {
+	switch n := v.(type) {
+	case bool:
+		return n == false
+	case float64:
+		return n == 0
+	case int:
+		return n == 0
+	}
+	return false
+}
+
 type whereClause struct {
 	pattern string
 	values  types.S
 	sorts   []string
+	// score 全文检索的相关度表达式，查询中包含 $text 时有效
+	score string
+	// texts 查询中的全文检索条件，用于创建索引
+	texts []textSearch
+}
+
+// textSearch 字段上的全文检索条件
+type textSearch struct {
+	fieldName string
+	language  string
 }
 
 func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 	patterns := []string{}
 	values := types.S{}
 	sorts := []string{}
+	var score string
+	var texts []textSearch
 
 	schema = toPostgresSchema(schema)
 	if schema == nil {
@@ -1828,6 +3049,10 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 							clauseValues = append(clauseValues, clause.values...)
 							index = index + len(clause.values)
 						}
+						if score == "" {
+							score = clause.score
+						}
+						texts = append(texts, clause.texts...)
 					}
 				}
 			}
@@ -2006,6 +3231,34 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 				}
 			}
 
+			if geoIntersects, ok := value["$geoIntersects"]; ok {
+				// 查找包含指定点的多边形
+				point := utils.M(utils.M(geoIntersects)["$point"])
+				if point == nil || utils.S(point["__type"]) != "GeoPoint" || point["longitude"] == nil || point["latitude"] == nil {
+					return nil, errs.E(errs.InvalidJSON, "bad $geoIntersect value; $point should be GeoPoint")
+				}
+				patterns = append(patterns, fmt.Sprintf(`"%s"::polygon @> $%d::point`, fieldName, index))
+				values = append(values, fmt.Sprintf("(%v, %v)", point["longitude"], point["latitude"]))
+				index = index + 1
+			}
+
+			if text, ok := value["$text"]; ok {
+				language, search, err := buildTextSearch(text)
+				if err != nil {
+					return nil, err
+				}
+				// 相同的表达式用于创建 GIN 索引，参数在查询时确定，可以使用表达式索引
+				vector := fmt.Sprintf(`to_tsvector($%d::regconfig, "%s")`, index, fieldName)
+				tsquery := fmt.Sprintf(`to_tsquery($%d::regconfig, $%d)`, index, index+1)
+				patterns = append(patterns, fmt.Sprintf(`%s @@ %s`, vector, tsquery))
+				if score == "" {
+					score = fmt.Sprintf(`ts_rank_cd(%s, %s, 32)`, vector, tsquery)
+				}
+				texts = append(texts, textSearch{fieldName: fieldName, language: language})
+				values = append(values, language, search)
+				index = index + 2
+			}
+
 			if regex := utils.S(value["$regex"]); regex != "" {
 				operator := "~"
 				opts := utils.S(value["$options"])
@@ -2063,7 +3316,151 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 	for i, v := range values {
 		values[i] = transformValue(v)
 	}
-	return &whereClause{strings.Join(patterns, " AND "), values, sorts}, nil
+	return &whereClause{
+		pattern: strings.Join(patterns, " AND "),
+		values:  values,
+		sorts:   sorts,
+		score:   score,
+		texts:   texts,
+	}, nil
+}
+
+// textSearchLanguages MongoDB 中的语言代码对应的 Postgres 全文检索配置
+var textSearchLanguages = map[string]string{
+	"none": "simple",
+	"da":   "danish",
+	"nl":   "dutch",
+	"en":   "english",
+	"fi":   "finnish",
+	"fr":   "french",
+	"de":   "german",
+	"hu":   "hungarian",
+	"it":   "italian",
+	"nb":   "norwegian",
+	"pt":   "portuguese",
+	"ro":   "romanian",
+	"ru":   "russian",
+	"es":   "spanish",
+	"sv":   "swedish",
+	"tr":   "turkish",
+}
+
+// buildTextSearch 校验全文检索条件，返回检索使用的配置与 tsquery
+// Postgres 的全文检索不区分大小写，且不区分变音符号需要安装 unaccent 扩展，所以不支持对应的选项
+func buildTextSearch(text interface{}) (string, string, error) {
+	search := utils.M(utils.M(text)["$search"])
+	if search == nil {
+		return "", "", errs.E(errs.InvalidJSON, "bad $text: $search, should be object")
+	}
+	term, ok := search["$term"].(string)
+	if ok == false {
+		return "", "", errs.E(errs.InvalidJSON, "bad $text: $term, should be string")
+	}
+
+	language := "english"
+	if v, ok := search["$language"]; ok {
+		l, ok := v.(string)
+		if ok == false {
+			return "", "", errs.E(errs.InvalidJSON, "bad $text: $language, should be string")
+		}
+		if c, ok := textSearchLanguages[l]; ok {
+			l = c
+		}
+		if match, _ := regexp.MatchString(`^[a-z_]+$`, l); match == false {
+			return "", "", errs.E(errs.InvalidJSON, "bad $text: $language, unsupported language "+l)
+		}
+		language = l
+	}
+	if v, ok := search["$caseSensitive"]; ok {
+		caseSensitive, ok := v.(bool)
+		if ok == false {
+			return "", "", errs.E(errs.InvalidJSON, "bad $text: $caseSensitive, should be boolean")
+		}
+		if caseSensitive {
+			return "", "", errs.E(errs.InvalidJSON, "bad $text: $caseSensitive not supported, please use $regex or create a separate lower case column.")
+		}
+	}
+	if v, ok := search["$diacriticSensitive"]; ok {
+		diacriticSensitive, ok := v.(bool)
+		if ok == false {
+			return "", "", errs.E(errs.InvalidJSON, "bad $text: $diacriticSensitive, should be boolean")
+		}
+		if diacriticSensitive == false {
+			return "", "", errs.E(errs.InvalidJSON, "bad $text: $diacriticSensitive - false not supported, install Postgres Unaccent Extension")
+		}
+	}
+
+	return language, textSearchToTsquery(term), nil
+}
+
+// textSearchToTsquery 把 MongoDB 格式的检索词转换为 tsquery ，与 MongoDB 的规则一致：
+// 以空格分隔的词之间为 或 ，双引号中的短语之间为 且 ，包含短语时仅匹配包含所有短语的对象，
+// 以 - 开头的词或短语表示排除。没有可以匹配的词时返回空字符串，此时不匹配任何对象
+func textSearchToTsquery(term string) string {
+	words := []string{}
+	phrases := []string{}
+	negations := []string{}
+
+	for len(term) > 0 {
+		term = strings.TrimLeft(term, " \t\r\n")
+		if term == "" {
+			break
+		}
+		negated := false
+		if strings.HasPrefix(term, "-") {
+			negated = true
+			term = term[1:]
+		}
+		var item string
+		phrase := false
+		if strings.HasPrefix(term, `"`) {
+			phrase = true
+			end := strings.Index(term[1:], `"`)
+			if end < 0 {
+				item = term[1:]
+				term = ""
+			} else {
+				item = term[1 : end+1]
+				term = term[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(term, " \t\r\n")
+			if end < 0 {
+				item = term
+				term = ""
+			} else {
+				item = term[:end]
+				term = term[end:]
+			}
+		}
+
+		lexemes := []string{}
+		for _, word := range strings.Fields(item) {
+			word = strings.Replace(word, `\`, `\\`, -1)
+			lexemes = append(lexemes, `'`+strings.Replace(word, `'`, `''`, -1)+`'`)
+		}
+		if len(lexemes) == 0 {
+			continue
+		}
+		switch {
+		case negated:
+			negations = append(negations, `!(`+strings.Join(lexemes, ` <-> `)+`)`)
+		case phrase:
+			phrases = append(phrases, `(`+strings.Join(lexemes, ` <-> `)+`)`)
+		default:
+			words = append(words, lexemes...)
+		}
+	}
+
+	var positive string
+	if len(phrases) > 0 {
+		positive = strings.Join(phrases, ` & `)
+	} else if len(words) > 0 {
+		positive = `(` + strings.Join(words, ` | `) + `)`
+	} else {
+		return ""
+	}
+	return strings.Join(append([]string{positive}, negations...), ` & `)
 }
 
 func removeWhiteSpace(s string) string {
@@ -178,6 +178,58 @@ func Test_toPostgresValue(t *testing.T) {
 	}
 }
 
+func Test_toPostgresPolygon(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr error
+	}{
+		{
+			name: "1",
+			value: types.M{
+				"__type":      "Polygon",
+				"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 1.0}, types.S{1.0, 1.0}},
+			},
+			want: "((0, 0), (1, 0), (1, 1), (0, 0))",
+		},
+		{
+			name: "2",
+			value: types.M{
+				"__type":      "Polygon",
+				"coordinates": types.S{types.S{10.0, 20.0}, types.S{10.0, 30.0}, types.S{20.0, 30.0}, types.S{10.0, 20.0}},
+			},
+			want: "((20, 10), (30, 10), (30, 20), (20, 10))",
+		},
+		{
+			name: "3",
+			value: types.M{
+				"__type":      "Polygon",
+				"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 1.0}},
+			},
+			wantErr: errs.E(errs.InvalidJSON, "Polygon must have at least 3 values"),
+		},
+		{
+			name: "4",
+			value: types.M{
+				"__type":      "Polygon",
+				"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 1.0}, types.S{91.0, 1.0}},
+			},
+			wantErr: errs.E(errs.InvalidJSON, "bad Polygon value; coordinates out of bounds"),
+		},
+	}
+	for _, tt := range tests {
+		got, err := toPostgresPolygon(tt.value)
+		if !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. toPostgresPolygon() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q. toPostgresPolygon() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 func Test_transformValue(t *testing.T) {
 	type args struct {
 		value interface{}
@@ -1358,6 +1410,50 @@ func Test_buildWhereClause(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "27.2",
+			args: args{
+				schema: types.M{
+					"fields": types.M{},
+				},
+				query: types.M{
+					"key": types.M{
+						"$geoIntersects": types.M{
+							"$point": types.M{
+								"__type":    "GeoPoint",
+								"longitude": 10.0,
+								"latitude":  20.0,
+							},
+						},
+					},
+				},
+				index: 1,
+			},
+			want: &whereClause{
+				pattern: `"key"::polygon @> $1::point`,
+				values:  types.S{"(10, 20)"},
+				sorts:   []string{},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "27.3",
+			args: args{
+				schema: types.M{
+					"fields": types.M{},
+				},
+				query: types.M{
+					"key": types.M{
+						"$geoIntersects": types.M{
+							"$point": types.M{"longitude": 10.0},
+						},
+					},
+				},
+				index: 1,
+			},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "bad $geoIntersect value; $point should be GeoPoint"),
+		},
 		{
 			name: "28",
 			args: args{
@@ -1599,6 +1695,52 @@ func Test_buildWhereClause(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "40",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"title": types.M{"type": "String"},
+					},
+				},
+				query: types.M{
+					"title": types.M{
+						"$text": types.M{
+							"$search": types.M{"$term": "coffee", "$language": "fr"},
+						},
+					},
+				},
+				index: 1,
+			},
+			want: &whereClause{
+				pattern: `to_tsvector($1::regconfig, "title") @@ to_tsquery($1::regconfig, $2)`,
+				values:  types.S{"french", `('coffee')`},
+				sorts:   []string{},
+				score:   `ts_rank_cd(to_tsvector($1::regconfig, "title"), to_tsquery($1::regconfig, $2), 32)`,
+				texts:   []textSearch{{fieldName: "title", language: "french"}},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "41",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"title": types.M{"type": "String"},
+					},
+				},
+				query: types.M{
+					"title": types.M{
+						"$text": types.M{
+							"$search": types.M{"$term": "coffee", "$caseSensitive": true},
+						},
+					},
+				},
+				index: 1,
+			},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "bad $text: $caseSensitive not supported, please use $regex or create a separate lower case column."),
+		},
 	}
 	for _, tt := range tests {
 		got, err := buildWhereClause(tt.args.schema, tt.args.query, tt.args.index)
@@ -1612,6 +1754,157 @@ func Test_buildWhereClause(t *testing.T) {
 	}
 }
 
+func Test_textSearchToTsquery(t *testing.T) {
+	tests := []struct {
+		name string
+		term string
+		want string
+	}{
+		{name: "1", term: "", want: ""},
+		{name: "2", term: "coffee", want: `('coffee')`},
+		{name: "3", term: "coffee  tea ", want: `('coffee' | 'tea')`},
+		{name: "4", term: `coffee "hot tea"`, want: `('hot' <-> 'tea')`},
+		{name: "5", term: `"hot tea" "iced coffee`, want: `('hot' <-> 'tea') & ('iced' <-> 'coffee')`},
+		{name: "6", term: `coffee -tea -"green tea"`, want: `('coffee') & !('tea') & !('green' <-> 'tea')`},
+		{name: "7", term: "-tea", want: ""},
+		{name: "8", term: `it's a\b`, want: `('it''s' | 'a\\b')`},
+	}
+	for _, tt := range tests {
+		if got := textSearchToTsquery(tt.term); got != tt.want {
+			t.Errorf("%q. textSearchToTsquery() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_aggregateAccumulatorToSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      string
+		arg     interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "1", op: "$sum", arg: "$score", want: `SUM("score")`},
+		{name: "2", op: "$sum", arg: 1.0, want: `count(*)`},
+		{name: "3", op: "$sum", arg: 2.0, want: `count(*) * 2`},
+		{name: "4", op: "$max", arg: `$a") FROM "_User" --`, want: `MAX("a"") FROM ""_User"" --")`},
+		{name: "5", op: "$first", arg: "$score", wantErr: true},
+		{name: "6", op: "$sum", arg: "$stats.score", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := aggregateAccumulatorToSQL(tt.op, tt.arg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. aggregateAccumulatorToSQL() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q. aggregateAccumulatorToSQL() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_aggregateGroupKeyToSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "1", path: "score", want: `"score"`},
+		{name: "2", path: "stats.score", want: `"stats"->'score'`},
+		{name: "3", path: "a.b.c", want: `"a"->'b'->'c'`},
+		{name: "4", path: `a".b'c`, want: `"a"""->'b''c'`},
+	}
+	for _, tt := range tests {
+		if got := aggregateGroupKeyToSQL(tt.path); got != tt.want {
+			t.Errorf("%q. aggregateGroupKeyToSQL() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_buildCreateIndexQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		index   types.M
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "name_score",
+			index: types.M{"fields": types.S{"name", "-score"}, "unique": true},
+			want:  `CREATE UNIQUE INDEX "Post_name_score" ON "Post" ("name" ASC, "score" DESC)`,
+		},
+		{
+			name:  "name",
+			index: types.M{"fields": types.S{"name", "owner"}, "sparse": true},
+			want:  `CREATE INDEX "Post_name" ON "Post" ("name" ASC, "owner" ASC) WHERE "name" IS NOT NULL OR "owner" IS NOT NULL`,
+		},
+		{
+			name:  "title",
+			index: types.M{"fields": types.S{"$text:title", "$text:body"}},
+			want:  `CREATE INDEX "Post_title" ON "Post" USING GIN (to_tsvector('english'::regconfig, "title"), to_tsvector('english'::regconfig, "body"))`,
+		},
+		{
+			name:    "title_name",
+			index:   types.M{"fields": types.S{"$text:title", "name"}},
+			wantErr: errs.E(errs.OperationForbidden, "Postgres doesn't support text indexes combined with other fields"),
+		},
+		{
+			name:    "title_unique",
+			index:   types.M{"fields": types.S{"$text:title"}, "unique": true},
+			wantErr: errs.E(errs.OperationForbidden, "Postgres doesn't support unique text indexes"),
+		},
+		{
+			name:    "ttl",
+			index:   types.M{"fields": types.S{"expiresAt"}, "expireAfterSeconds": 3600.0},
+			wantErr: errs.E(errs.OperationForbidden, "Postgres doesn't support expireAfterSeconds"),
+		},
+	}
+	for _, tt := range tests {
+		got, err := buildCreateIndexQuery("Post", tt.name, tt.index)
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. buildCreateIndexQuery() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q. buildCreateIndexQuery() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_parseIndexDefinition(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		want       types.M
+	}{
+		{
+			name:       "1",
+			definition: `CREATE UNIQUE INDEX "Post_pkey" ON public."Post" USING btree ("objectId")`,
+			want:       types.M{"fields": types.S{"objectId"}, "unique": true},
+		},
+		{
+			name:       "2",
+			definition: `CREATE INDEX "Post_name_score" ON public."Post" USING btree (name, score DESC, "createdAt" DESC NULLS LAST)`,
+			want:       types.M{"fields": types.S{"name", "-score", "-createdAt"}},
+		},
+		{
+			name:       "3",
+			definition: `CREATE INDEX "Post_name" ON public."Post" USING btree (name) WHERE ((name IS NOT NULL) OR (owner IS NOT NULL))`,
+			want:       types.M{"fields": types.S{"name"}, "sparse": true},
+		},
+		{
+			name:       "4",
+			definition: `CREATE INDEX "Post_title" ON public."Post" USING gin (to_tsvector('english'::regconfig, title), to_tsvector('english'::regconfig, "subTitle"))`,
+			want:       types.M{"fields": types.S{"$text:title", "$text:subTitle"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := parseIndexDefinition(tt.definition); reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. parseIndexDefinition() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 func Test_removeWhiteSpace(t *testing.T) {
 	type args struct {
 		s string
@@ -3367,6 +3660,41 @@ func TestPostgresAdapter_Find(t *testing.T) {
 			initialize: initialize,
 			clean:      clean,
 		},
+		{
+			name: "13-Polygon",
+			args: args{
+				className: "post",
+				schema: types.M{
+					"className": "post",
+					"fields": types.M{
+						"key": types.M{
+							"type": "Polygon",
+						},
+					},
+				},
+				query:   types.M{},
+				options: types.M{},
+				dataObjects: []types.M{
+					types.M{
+						"key": types.M{
+							"__type":      "Polygon",
+							"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 1.0}, types.S{1.0, 1.0}},
+						},
+					},
+				},
+			},
+			want: []types.M{
+				types.M{
+					"key": types.M{
+						"__type":      "Polygon",
+						"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 1.0}, types.S{1.0, 1.0}, types.S{0.0, 0.0}},
+					},
+				},
+			},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
 		{
 			name: "14-File",
 			args: args{
@@ -4456,6 +4784,53 @@ func TestPostgresAdapter_Find(t *testing.T) {
 			initialize: initialize,
 			clean:      clean,
 		},
+		{
+			name: "46-where-$geoIntersects",
+			args: args{
+				className: "post",
+				schema: types.M{
+					"className": "post",
+					"fields": types.M{
+						"key":  types.M{"type": "String"},
+						"area": types.M{"type": "Polygon"},
+					},
+				},
+				query: types.M{
+					"area": types.M{
+						"$geoIntersects": types.M{
+							"$point": types.M{
+								"__type":    "GeoPoint",
+								"latitude":  5.0,
+								"longitude": 5.0,
+							},
+						},
+					},
+				},
+				options: types.M{"keys": []string{"key"}},
+				dataObjects: []types.M{
+					types.M{
+						"key": "inside",
+						"area": types.M{
+							"__type":      "Polygon",
+							"coordinates": types.S{types.S{0.0, 0.0}, types.S{0.0, 10.0}, types.S{10.0, 10.0}, types.S{10.0, 0.0}},
+						},
+					},
+					types.M{
+						"key": "outside",
+						"area": types.M{
+							"__type":      "Polygon",
+							"coordinates": types.S{types.S{20.0, 20.0}, types.S{20.0, 30.0}, types.S{30.0, 30.0}, types.S{30.0, 20.0}},
+						},
+					},
+				},
+			},
+			want: []types.M{
+				types.M{"key": "inside"},
+			},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
 		{
 			name: "47-where-$regex",
 			args: args{
@@ -5226,6 +5601,280 @@ func TestPostgresAdapter_Count(t *testing.T) {
 	}
 }
 
+func TestPostgresAdapter_Distinct(t *testing.T) {
+	db := openDB()
+	p := NewPostgresAdapter("", db)
+	initialize := func(className string, schema types.M, objects []types.M) {
+		p.CreateClass(className, schema)
+		for _, object := range objects {
+			p.CreateObject(className, schema, object)
+		}
+	}
+	clean := func(className string) {
+		db.Exec(`DROP TABLE "` + className + `"`)
+		db.Exec(`DROP TABLE "_SCHEMA"`)
+	}
+	schema := types.M{
+		"className": "post",
+		"fields": types.M{
+			"objectId": types.M{"type": "String"},
+			"key":      types.M{"type": "String"},
+			"tags":     types.M{"type": "Array", "contents": types.M{"type": "String"}},
+			"owner":    types.M{"type": "Pointer", "targetClass": "_User"},
+		},
+	}
+	dataObjects := []types.M{
+		types.M{
+			"objectId": "01",
+			"key":      "hello",
+			"tags":     types.S{"a", "b"},
+			"owner":    types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+		},
+		types.M{
+			"objectId": "02",
+			"key":      "hello",
+			"tags":     types.S{"b"},
+			"owner":    types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+		},
+		types.M{
+			"objectId": "03",
+			"key":      "hi",
+			"tags":     types.S{"c"},
+			"owner":    types.M{"__type": "Pointer", "className": "_User", "objectId": "u2"},
+		},
+	}
+	type args struct {
+		className   string
+		schema      types.M
+		query       types.M
+		fieldName   string
+		dataObjects []types.M
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       types.S
+		wantErr    error
+		initialize func(className string, schema types.M, objects []types.M)
+		clean      func(className string)
+	}{
+		{
+			name: "1",
+			args: args{
+				className:   "post",
+				schema:      schema,
+				query:       types.M{},
+				fieldName:   "key",
+				dataObjects: []types.M{},
+			},
+			want:    types.S{},
+			wantErr: nil,
+			initialize: func(className string, schema types.M, objects []types.M) {
+				p.ensureSchemaCollectionExists()
+			},
+			clean: func(className string) {
+				db.Exec(`DROP TABLE "_SCHEMA"`)
+			},
+		},
+		{
+			name: "2",
+			args: args{
+				className:   "post",
+				schema:      schema,
+				query:       types.M{"objectId": types.M{"$ne": "03"}},
+				fieldName:   "key",
+				dataObjects: dataObjects,
+			},
+			want:       types.S{"hello"},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
+		{
+			name: "3",
+			args: args{
+				className:   "post",
+				schema:      schema,
+				query:       types.M{"key": "hi"},
+				fieldName:   "owner",
+				dataObjects: dataObjects,
+			},
+			want: types.S{
+				types.M{"__type": "Pointer", "className": "_User", "objectId": "u2"},
+			},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
+		{
+			name: "4",
+			args: args{
+				className:   "post",
+				schema:      schema,
+				query:       types.M{"key": "hi"},
+				fieldName:   "tags",
+				dataObjects: dataObjects,
+			},
+			want:       types.S{"c"},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
+		{
+			name: "5",
+			args: args{
+				className:   "post",
+				schema:      schema,
+				query:       types.M{},
+				fieldName:   "other",
+				dataObjects: dataObjects,
+			},
+			want:       types.S{},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
+	}
+	for _, tt := range tests {
+		tt.initialize(tt.args.className, tt.args.schema, tt.args.dataObjects)
+		got, err := p.Distinct(tt.args.className, tt.args.schema, tt.args.query, tt.args.fieldName)
+		tt.clean(tt.args.className)
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. PostgresAdapter.Distinct() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. PostgresAdapter.Distinct() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPostgresAdapter_Aggregate(t *testing.T) {
+	db := openDB()
+	p := NewPostgresAdapter("", db)
+	initialize := func(className string, schema types.M, objects []types.M) {
+		p.CreateClass(className, schema)
+		for _, object := range objects {
+			p.CreateObject(className, schema, object)
+		}
+	}
+	clean := func(className string) {
+		db.Exec(`DROP TABLE "` + className + `"`)
+		db.Exec(`DROP TABLE "_SCHEMA"`)
+	}
+	schema := types.M{
+		"className": "post",
+		"fields": types.M{
+			"objectId": types.M{"type": "String"},
+			"key":      types.M{"type": "String"},
+			"score":    types.M{"type": "Number"},
+		},
+	}
+	dataObjects := []types.M{
+		types.M{"objectId": "01", "key": "hello", "score": 1.0},
+		types.M{"objectId": "02", "key": "hello", "score": 2.0},
+		types.M{"objectId": "03", "key": "hi", "score": 4.0},
+	}
+	type args struct {
+		className   string
+		schema      types.M
+		pipeline    types.S
+		dataObjects []types.M
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       []types.M
+		wantErr    error
+		initialize func(className string, schema types.M, objects []types.M)
+		clean      func(className string)
+	}{
+		{
+			name: "1",
+			args: args{
+				className:   "post",
+				schema:      schema,
+				pipeline:    types.S{},
+				dataObjects: []types.M{},
+			},
+			want:    []types.M{},
+			wantErr: nil,
+			initialize: func(className string, schema types.M, objects []types.M) {
+				p.ensureSchemaCollectionExists()
+			},
+			clean: func(className string) {
+				db.Exec(`DROP TABLE "_SCHEMA"`)
+			},
+		},
+		{
+			name: "2",
+			args: args{
+				className: "post",
+				schema:    schema,
+				pipeline: types.S{
+					types.M{"$group": types.M{"_id": "$key", "total": types.M{"$sum": "$score"}, "count": types.M{"$sum": 1}}},
+					types.M{"$sort": []string{"objectId"}},
+				},
+				dataObjects: dataObjects,
+			},
+			want: []types.M{
+				types.M{"objectId": "hello", "total": 3.0, "count": int64(2)},
+				types.M{"objectId": "hi", "total": 4.0, "count": int64(1)},
+			},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
+		{
+			name: "3",
+			args: args{
+				className: "post",
+				schema:    schema,
+				pipeline: types.S{
+					types.M{"$match": types.M{"score": types.M{"$gt": 1}}},
+					types.M{"$project": types.M{"key": 1}},
+					types.M{"$sort": []string{"-objectId"}},
+					types.M{"$limit": 1},
+				},
+				dataObjects: dataObjects,
+			},
+			want: []types.M{
+				types.M{"objectId": "03", "key": "hi"},
+			},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
+		{
+			name: "4",
+			args: args{
+				className: "post",
+				schema:    schema,
+				pipeline: types.S{
+					types.M{"$group": types.M{"_id": nil, "score": types.M{"$stdDevPop": "$score"}}},
+				},
+				dataObjects: dataObjects,
+			},
+			want:       nil,
+			wantErr:    errs.E(errs.OperationForbidden, "Postgres doesn't support $stdDevPop in $group yet"),
+			initialize: initialize,
+			clean:      clean,
+		},
+	}
+	for _, tt := range tests {
+		tt.initialize(tt.args.className, tt.args.schema, tt.args.dataObjects)
+		got, err := p.Aggregate(tt.args.className, tt.args.schema, tt.args.pipeline)
+		tt.clean(tt.args.className)
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. PostgresAdapter.Aggregate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. PostgresAdapter.Aggregate() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestPostgresAdapter_FindOneAndUpdate(t *testing.T) {
 	db := openDB()
 	p := NewPostgresAdapter("", db)
@@ -5682,7 +6331,7 @@ func TestPostgresAdapter_FindOneAndUpdate(t *testing.T) {
 					types.M{"key": "hi", "key2": types.M{"key1": "hi", "key2": "world"}},
 				},
 			},
-			want:       types.M{"key": "hi", "key2": types.M{"key1": "hi", "key2": "go"}},
+			want:       types.M{"key": "hi", "key2": types.M{"key2": "go"}},
 			wantErr:    nil,
 			initialize: initialize,
 			clean:      clean,
@@ -6063,3 +6712,108 @@ func TestPostgresAdapter_EnsureUniqueness(t *testing.T) {
 		}
 	}
 }
+
+func TestPostgresAdapter_Transaction(t *testing.T) {
+	db := openDB()
+	p := NewPostgresAdapter("", db)
+	schema := types.M{
+		"className": "post",
+		"fields": types.M{
+			"objectId": types.M{"type": "String"},
+			"key":      types.M{"type": "String"},
+		},
+	}
+	clean := func() {
+		db.Exec(`DROP TABLE "post"`)
+		db.Exec(`DROP TABLE "_SCHEMA"`)
+	}
+	type args struct {
+		commit bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "1",
+			args: args{commit: true},
+			want: 2,
+		},
+		{
+			name: "2",
+			args: args{commit: false},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		p.CreateClass("post", schema)
+		tx, err := p.StartTransaction()
+		if err != nil {
+			t.Errorf("%q. PostgresAdapter.StartTransaction() error = %v", tt.name, err)
+			clean()
+			continue
+		}
+		tx.CreateObject("post", schema, types.M{"objectId": "01", "key": "hello"})
+		// 表不存在时查询出错，不影响事务中的其他语句
+		tx.Find("other", types.M{}, types.M{}, types.M{})
+		// 查询的 savepoint 在下一条语句执行前释放
+		if results, _ := tx.Find("post", schema, types.M{}, types.M{}); len(results) != 1 {
+			t.Errorf("%q. PostgresAdapter.Find() in transaction = %v, want %v", tt.name, len(results), 1)
+		}
+		tx.CreateObject("post", schema, types.M{"objectId": "02", "key": "hi"})
+		if tx.(*PostgresAdapter).statementSavepoint {
+			t.Errorf("%q. PostgresAdapter statement savepoint is not released", tt.name)
+		}
+		if count, _ := p.Count("post", schema, types.M{}); count != 0 {
+			t.Errorf("%q. PostgresAdapter.Count() outside transaction = %v, want %v", tt.name, count, 0)
+		}
+		if tt.args.commit {
+			err = tx.CommitTransaction()
+		} else {
+			err = tx.AbortTransaction()
+		}
+		if err != nil {
+			t.Errorf("%q. PostgresAdapter transaction error = %v", tt.name, err)
+		}
+		got, _ := p.Count("post", schema, types.M{})
+		clean()
+		if got != tt.want {
+			t.Errorf("%q. PostgresAdapter.Count() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPostgresAdapter_createTextIndexesIfNeeded(t *testing.T) {
+	db := openDB()
+	p := NewPostgresAdapter("", db)
+	schema := types.M{
+		"fields": types.M{
+			"subject": types.M{"type": "String"},
+		},
+	}
+	p.CreateClass("post", schema)
+	p.CreateObject("post", schema, types.M{"objectId": "01", "subject": "coffee"})
+	defer func() {
+		db.Exec(`DROP TABLE "post"`)
+		db.Exec(`DROP TABLE "_SCHEMA"`)
+		forgetTextIndexes("post")
+	}()
+
+	query := types.M{"subject": types.M{"$text": types.M{"$search": types.M{"$term": "coffee"}}}}
+	results, err := p.Find("post", schema, query, types.M{})
+	if err != nil || len(results) != 1 {
+		t.Errorf("PostgresAdapter.Find() = %v, %v, want %v", results, err, 1)
+	}
+	// 索引在后台创建，查询不等待索引创建完成
+	exists := false
+	for i := 0; i < 100 && exists == false; i++ {
+		var name string
+		err = db.QueryRow(`SELECT indexname FROM pg_indexes WHERE tablename = 'post' AND indexname = 'post_subject_english_text'`).Scan(&name)
+		exists = err == nil
+		time.Sleep(10 * time.Millisecond)
+	}
+	if exists == false {
+		t.Errorf("text index %v is not created", "post_subject_english_text")
+	}
+}
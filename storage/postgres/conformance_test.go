@@ -0,0 +1,15 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/lfq7413/tomato/storage/storagetest"
+)
+
+func TestPostgresAdapter_UpdateConformance(t *testing.T) {
+	storagetest.RunUpdateTests(t, NewPostgresAdapter("", openDB()))
+}
+
+func TestPostgresAdapter_FindIteratorConformance(t *testing.T) {
+	storagetest.RunFindIteratorTests(t, NewPostgresAdapter("", openDB()))
+}
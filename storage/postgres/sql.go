@@ -19,6 +19,7 @@ SELECT concat('{', string_agg(to_json("key") || ':' || "value", ','), '}')::json
         SELECT "key_to_set", to_json("value_to_set")::jsonb) AS "fields"
 $function$`
 
+// 与 Mongo 的 $push 相同，保留原有元素与顺序
 const arrayAdd = `CREATE OR REPLACE FUNCTION "array_add"(
   "array"   jsonb,
   "values"  jsonb
@@ -28,9 +29,10 @@ const arrayAdd = `CREATE OR REPLACE FUNCTION "array_add"(
   IMMUTABLE 
   STRICT 
 AS $function$ 
-  SELECT array_to_json(ARRAY(SELECT unnest(ARRAY(SELECT DISTINCT jsonb_array_elements("array")) ||  ARRAY(SELECT jsonb_array_elements("values")))))::jsonb;
+  SELECT "array" || "values";
 $function$`
 
+// 与 Mongo 的 $addToSet 相同，仅追加不存在的元素，保留原有顺序
 const arrayAddUnique = `CREATE OR REPLACE FUNCTION "array_add_unique"(
   "array"   jsonb,
   "values"  jsonb
@@ -40,7 +42,14 @@ const arrayAddUnique = `CREATE OR REPLACE FUNCTION "array_add_unique"(
   IMMUTABLE 
   STRICT 
 AS $function$ 
-  SELECT array_to_json(ARRAY(SELECT DISTINCT unnest(ARRAY(SELECT DISTINCT jsonb_array_elements("array")) ||  ARRAY(SELECT DISTINCT jsonb_array_elements("values")))))::jsonb;
+  SELECT "array" || COALESCE((
+    SELECT jsonb_agg("elt" ORDER BY "ord") FROM (
+      SELECT "elt", min("ord") AS "ord"
+        FROM jsonb_array_elements("values") WITH ORDINALITY AS v("elt", "ord")
+       WHERE NOT EXISTS (SELECT 1 FROM jsonb_array_elements("array") AS a("item") WHERE a."item" = v."elt")
+       GROUP BY "elt"
+    ) AS "added"
+  ), '[]'::jsonb);
 $function$`
 
 const arrayRemove = `CREATE OR REPLACE FUNCTION "array_remove"(
@@ -78,3 +87,59 @@ const arrayContains = `CREATE OR REPLACE FUNCTION "array_contains"(
 AS $function$ 
   SELECT RES.CNT >= 1 FROM (SELECT COUNT(*) as CNT FROM jsonb_array_elements("array") as elt WHERE elt IN (SELECT jsonb_array_elements("values"))) as RES ;
 $function$`
+
+// Function to set a value on a nested path of a JSON document, creating the missing objects
+const jsonObjectSetPath = `CREATE OR REPLACE FUNCTION "json_object_set_path"(
+  "json"   jsonb,
+  "path"   text[],
+  "value"  jsonb
+)
+  RETURNS jsonb
+  LANGUAGE plpgsql
+  IMMUTABLE
+AS $function$
+BEGIN
+  IF "json" IS NULL OR jsonb_typeof("json") <> 'object' THEN
+    "json" := '{}'::jsonb;
+  END IF;
+  IF array_length("path", 1) = 1 THEN
+    RETURN jsonb_set("json", "path", COALESCE("value", 'null'::jsonb));
+  END IF;
+  RETURN jsonb_set("json", ARRAY["path"[1]], json_object_set_path("json" -> "path"[1], "path"[2:array_length("path", 1)], "value"));
+END;
+$function$`
+
+// Function to apply an update operation (Set, Increment, Add, AddUnique, Remove) on a nested path of a JSON document
+const jsonObjectUpdatePath = `CREATE OR REPLACE FUNCTION "json_object_update_path"(
+  "json"   jsonb,
+  "path"   text[],
+  "op"     text,
+  "value"  jsonb
+)
+  RETURNS jsonb
+  LANGUAGE plpgsql
+  IMMUTABLE
+AS $function$
+DECLARE
+  "current" jsonb := "json" #> "path";
+  "result"  jsonb;
+BEGIN
+  IF "op" = 'Increment' THEN
+    "result" := to_jsonb(COALESCE(("current" #>> '{}')::double precision, 0) + ("value" #>> '{}')::double precision);
+  ELSIF "op" IN ('Add', 'AddUnique', 'Remove') THEN
+    IF "current" IS NULL OR jsonb_typeof("current") <> 'array' THEN
+      "current" := '[]'::jsonb;
+    END IF;
+    IF "op" = 'Add' THEN
+      "result" := array_add("current", "value");
+    ELSIF "op" = 'AddUnique' THEN
+      "result" := array_add_unique("current", "value");
+    ELSE
+      "result" := array_remove("current", "value");
+    END IF;
+  ELSE
+    "result" := "value";
+  END IF;
+  RETURN json_object_set_path("json", "path", "result");
+END;
+$function$`
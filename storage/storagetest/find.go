@@ -0,0 +1,119 @@
+package storagetest
+
+import (
+	"testing"
+
+	"github.com/lfq7413/tomato/storage"
+	"github.com/lfq7413/tomato/types"
+)
+
+const iteratorClassName = "StorageTestIterator"
+
+var iteratorSchema = types.M{
+	"className": iteratorClassName,
+	"fields": types.M{
+		"objectId": types.M{"type": "String"},
+		"count":    types.M{"type": "Number"},
+	},
+}
+
+type iteratorCase struct {
+	name    string
+	query   types.M
+	options types.M
+	// expect 按顺序读取到的对象的 objectId
+	expect []string
+}
+
+var iteratorCases = []iteratorCase{
+	{
+		name:    "All",
+		query:   types.M{},
+		options: types.M{"sort": []string{"objectId"}},
+		expect:  []string{"01", "02", "03", "04"},
+	},
+	{
+		name:    "Sort limit skip",
+		query:   types.M{},
+		options: types.M{"sort": []string{"-count"}, "limit": 2, "skip": 1},
+		expect:  []string{"03", "02"},
+	},
+	{
+		name:    "Query",
+		query:   types.M{"count": types.M{"$gt": 2.0}},
+		options: types.M{"sort": []string{"count"}},
+		expect:  []string{"03", "04"},
+	},
+	{
+		name:    "Empty",
+		query:   types.M{"count": types.M{"$gt": 10.0}},
+		options: types.M{},
+		expect:  []string{},
+	},
+}
+
+// RunFindIteratorTests 在指定的适配器上执行 FindIterator 的一致性测试，结果需要与 Find 相同
+func RunFindIteratorTests(t *testing.T, adapter storage.Adapter) {
+	if err := adapter.PerformInitialization(types.M{}); err != nil {
+		t.Fatal(err)
+	}
+	adapter.DeleteClass(iteratorClassName)
+	if _, err := adapter.CreateClass(iteratorClassName, iteratorSchema); err != nil {
+		t.Fatal(err)
+	}
+	for i, objectID := range []string{"01", "02", "03", "04"} {
+		object := types.M{"objectId": objectID, "count": float64(i + 1)}
+		if err := adapter.CreateObject(iteratorClassName, iteratorSchema, object); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range iteratorCases {
+		results, err := adapter.Find(iteratorClassName, iteratorSchema, tt.query, copyOptions(tt.options))
+		if err != nil {
+			t.Errorf("%q. Find() error = %v", tt.name, err)
+			continue
+		}
+		iter, err := adapter.FindIterator(iteratorClassName, iteratorSchema, tt.query, copyOptions(tt.options))
+		if err != nil {
+			t.Errorf("%q. FindIterator() error = %v", tt.name, err)
+			continue
+		}
+		objects := []types.M{}
+		for {
+			object, err := iter.Next()
+			if err != nil {
+				t.Errorf("%q. Next() error = %v", tt.name, err)
+				break
+			}
+			if object == nil {
+				break
+			}
+			objects = append(objects, object)
+		}
+		if err := iter.Close(); err != nil {
+			t.Errorf("%q. Close() error = %v", tt.name, err)
+		}
+
+		objectIDs := []string{}
+		for _, object := range objects {
+			objectIDs = append(objectIDs, object["objectId"].(string))
+		}
+		if equal(tt.expect, objectIDs) == false {
+			t.Errorf("%q. FindIterator() = %v, want %v", tt.name, objectIDs, tt.expect)
+		}
+		if equal(results, objects) == false {
+			t.Errorf("%q. FindIterator() = %v, Find() = %v", tt.name, objects, results)
+		}
+	}
+	adapter.DeleteClass(iteratorClassName)
+}
+
+// copyOptions 复制查询选项，适配器会修改传入的选项
+func copyOptions(options types.M) types.M {
+	result := types.M{}
+	for k, v := range options {
+		result[k] = v
+	}
+	return result
+}
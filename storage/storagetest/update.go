@@ -0,0 +1,256 @@
+// Package storagetest 提供数据库适配器的一致性测试
+// 同一组用例分别在 Mongo 与 Postgres 适配器上执行，保证切换数据库时行为一致
+package storagetest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/lfq7413/tomato/storage"
+	"github.com/lfq7413/tomato/types"
+)
+
+const className = "StorageTestUpdate"
+
+var updateSchema = types.M{
+	"className": className,
+	"fields": types.M{
+		"objectId": types.M{"type": "String"},
+		"key":      types.M{"type": "String"},
+		"count":    types.M{"type": "Number"},
+		"profile":  types.M{"type": "Object"},
+		"list":     types.M{"type": "Array"},
+		"owners":   types.M{"type": "Array"},
+	},
+}
+
+type updateCase struct {
+	name    string
+	objects []types.M
+	query   types.M
+	update  types.M
+	many    bool
+	// updated 更新之后设置了 count 字段的对象个数，为 0 时不检查
+	updated int
+	// expect 更新之后按照 objectId 查询到的对象，仅比较其中列出的字段
+	expect map[string]types.M
+}
+
+var updateCases = []updateCase{
+	{
+		name:    "Set",
+		objects: []types.M{{"objectId": "01", "key": "a"}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"key": "b", "count": 1.0},
+		expect:  map[string]types.M{"01": {"key": "b", "count": 1.0}},
+	},
+	{
+		name:    "Increment",
+		objects: []types.M{{"objectId": "01", "count": 1.0}, {"objectId": "02"}},
+		query:   types.M{"key": types.M{"$exists": false}},
+		update:  types.M{"count": types.M{"__op": "Increment", "amount": 2.0}},
+		many:    true,
+		expect:  map[string]types.M{"01": {"count": 3.0}, "02": {"count": 2.0}},
+	},
+	{
+		name:    "Delete",
+		objects: []types.M{{"objectId": "01", "key": "a", "count": 1.0}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"count": types.M{"__op": "Delete"}},
+		expect:  map[string]types.M{"01": {"key": "a", "count": nil}},
+	},
+	{
+		name:    "Set Object",
+		objects: []types.M{{"objectId": "01", "profile": types.M{"name": "a", "age": 1.0}}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"profile": types.M{"name": "b"}},
+		expect:  map[string]types.M{"01": {"profile": types.M{"name": "b"}}},
+	},
+	{
+		name:    "Set nested key",
+		objects: []types.M{{"objectId": "01", "profile": types.M{"name": "a", "address": types.M{"city": "x", "zip": "1"}}}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"profile.address.city": "y", "profile.phone.home": "2"},
+		expect: map[string]types.M{"01": {"profile": types.M{
+			"name":    "a",
+			"address": types.M{"city": "y", "zip": "1"},
+			"phone":   types.M{"home": "2"},
+		}}},
+	},
+	{
+		name:    "Set nested key on empty Object",
+		objects: []types.M{{"objectId": "01"}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"profile.name": "a"},
+		expect:  map[string]types.M{"01": {"profile": types.M{"name": "a"}}},
+	},
+	{
+		name:    "Increment nested key",
+		objects: []types.M{{"objectId": "01", "profile": types.M{"stats": types.M{"views": 1.0}}}},
+		query:   types.M{"objectId": "01"},
+		update: types.M{
+			"profile.stats.views": types.M{"__op": "Increment", "amount": 2.0},
+			"profile.stats.likes": types.M{"__op": "Increment", "amount": 1.0},
+		},
+		expect: map[string]types.M{"01": {"profile": types.M{"stats": types.M{"views": 3.0, "likes": 1.0}}}},
+	},
+	{
+		name:    "Delete nested key",
+		objects: []types.M{{"objectId": "01", "profile": types.M{"name": "a", "address": types.M{"city": "x", "zip": "1"}}}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"profile.address.zip": types.M{"__op": "Delete"}, "profile.name": types.M{"__op": "Delete"}},
+		expect:  map[string]types.M{"01": {"profile": types.M{"address": types.M{"city": "x"}}}},
+	},
+	{
+		name:    "Add objects",
+		objects: []types.M{{"objectId": "01", "list": types.S{types.M{"a": 1.0}, types.M{"a": 1.0}}}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"list": types.M{"__op": "Add", "objects": types.S{types.M{"a": 1.0}, types.M{"b": 2.0}}}},
+		expect:  map[string]types.M{"01": {"list": types.S{types.M{"a": 1.0}, types.M{"a": 1.0}, types.M{"a": 1.0}, types.M{"b": 2.0}}}},
+	},
+	{
+		name:    "AddUnique objects",
+		objects: []types.M{{"objectId": "01", "list": types.S{types.M{"a": 1.0}, "x"}}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"list": types.M{"__op": "AddUnique", "objects": types.S{types.M{"b": 2.0}, types.M{"a": 1.0}, "x", types.M{"b": 2.0}, "y"}}},
+		expect:  map[string]types.M{"01": {"list": types.S{types.M{"a": 1.0}, "x", types.M{"b": 2.0}, "y"}}},
+	},
+	{
+		name:    "Remove objects",
+		objects: []types.M{{"objectId": "01", "list": types.S{types.M{"a": 1.0}, types.M{"a": 1.0, "b": 2.0}, "x", types.M{"a": 1.0}}}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"list": types.M{"__op": "Remove", "objects": types.S{types.M{"a": 1.0}, "y"}}},
+		expect:  map[string]types.M{"01": {"list": types.S{types.M{"a": 1.0, "b": 2.0}, "x"}}},
+	},
+	{
+		name:    "Add Pointers",
+		objects: []types.M{{"objectId": "01", "owners": types.S{pointer("u1")}}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"owners": types.M{"__op": "AddUnique", "objects": types.S{pointer("u1"), pointer("u2")}}},
+		expect:  map[string]types.M{"01": {"owners": types.S{pointer("u1"), pointer("u2")}}},
+	},
+	{
+		name:    "Remove Pointers",
+		objects: []types.M{{"objectId": "01", "owners": types.S{pointer("u1"), pointer("u2")}}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"owners": types.M{"__op": "Remove", "objects": types.S{pointer("u1")}}},
+		expect:  map[string]types.M{"01": {"owners": types.S{pointer("u2")}}},
+	},
+	{
+		name:    "Add to nested array",
+		objects: []types.M{{"objectId": "01", "profile": types.M{"name": "a", "tags": types.S{"x"}}}},
+		query:   types.M{"objectId": "01"},
+		update: types.M{
+			"profile.tags":   types.M{"__op": "AddUnique", "objects": types.S{"x", "y"}},
+			"profile.badges": types.M{"__op": "Add", "objects": types.S{"z"}},
+		},
+		expect: map[string]types.M{"01": {"profile": types.M{"name": "a", "tags": types.S{"x", "y"}, "badges": types.S{"z"}}}},
+	},
+	{
+		name:    "Remove from nested array",
+		objects: []types.M{{"objectId": "01", "profile": types.M{"tags": types.S{"x", "y", "x"}}}},
+		query:   types.M{"objectId": "01"},
+		update:  types.M{"profile.tags": types.M{"__op": "Remove", "objects": types.S{"x"}}},
+		expect:  map[string]types.M{"01": {"profile": types.M{"tags": types.S{"y"}}}},
+	},
+	{
+		name:    "FindOneAndUpdate updates one object",
+		objects: []types.M{{"objectId": "01", "key": "a"}, {"objectId": "02", "key": "a"}},
+		query:   types.M{"key": "a", "count": types.M{"$exists": false}},
+		update:  types.M{"count": 1.0},
+		updated: 1,
+		expect:  map[string]types.M{"01": {"key": "a"}, "02": {"key": "a"}},
+	},
+	{
+		name:    "UpdateObjectsByQuery updates all objects",
+		objects: []types.M{{"objectId": "01", "key": "a"}, {"objectId": "02", "key": "a"}, {"objectId": "03", "key": "b"}},
+		query:   types.M{"key": "a"},
+		update:  types.M{"count": 1.0},
+		many:    true,
+		expect:  map[string]types.M{"01": {"count": 1.0}, "02": {"count": 1.0}, "03": {"count": nil}},
+	},
+}
+
+func pointer(objectID string) types.M {
+	return types.M{"__type": "Pointer", "className": "_User", "objectId": objectID}
+}
+
+// RunUpdateTests 在指定的适配器上执行更新操作的一致性测试
+func RunUpdateTests(t *testing.T, adapter storage.Adapter) {
+	if err := adapter.PerformInitialization(types.M{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range updateCases {
+		adapter.DeleteClass(className)
+		if _, err := adapter.CreateClass(className, updateSchema); err != nil {
+			t.Errorf("%q. CreateClass() error = %v", tt.name, err)
+			continue
+		}
+		for _, object := range tt.objects {
+			if err := adapter.CreateObject(className, updateSchema, copyObject(object)); err != nil {
+				t.Errorf("%q. CreateObject() error = %v", tt.name, err)
+			}
+		}
+
+		var err error
+		if tt.many {
+			err = adapter.UpdateObjectsByQuery(className, updateSchema, tt.query, tt.update)
+		} else {
+			_, err = adapter.FindOneAndUpdate(className, updateSchema, tt.query, tt.update)
+		}
+		if err != nil {
+			t.Errorf("%q. update error = %v", tt.name, err)
+			continue
+		}
+
+		results, err := adapter.Find(className, updateSchema, types.M{}, types.M{})
+		if err != nil {
+			t.Errorf("%q. Find() error = %v", tt.name, err)
+			continue
+		}
+		objects := map[string]types.M{}
+		for _, result := range results {
+			objects[result["objectId"].(string)] = result
+		}
+
+		if tt.updated > 0 {
+			updated := 0
+			for _, object := range objects {
+				if object["count"] != nil {
+					updated++
+				}
+			}
+			if updated != tt.updated {
+				t.Errorf("%q. updated %d objects, want %d", tt.name, updated, tt.updated)
+			}
+		}
+
+		for objectID, expect := range tt.expect {
+			object := objects[objectID]
+			for key, value := range expect {
+				if equal(value, object[key]) == false {
+					t.Errorf("%q. %s.%s = %v, want %v", tt.name, objectID, key, object[key], value)
+				}
+			}
+		}
+	}
+	adapter.DeleteClass(className)
+}
+
+func copyObject(object types.M) types.M {
+	var result types.M
+	b, _ := json.Marshal(object)
+	json.Unmarshal(b, &result)
+	return result
+}
+
+// equal 以 JSON 的形式比较，忽略两个适配器返回的 map 与数字类型的差异
+func equal(expect, result interface{}) bool {
+	var e, r interface{}
+	b, _ := json.Marshal(expect)
+	json.Unmarshal(b, &e)
+	b, _ = json.Marshal(result)
+	json.Unmarshal(b, &r)
+	return reflect.DeepEqual(e, r)
+}
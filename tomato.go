@@ -1,6 +1,8 @@
 package tomato
 
 import (
+	"io/ioutil"
+	"log"
 	"strings"
 
 	"github.com/lfq7413/tomato/config"
@@ -10,8 +12,10 @@ import (
 	"github.com/astaxie/beego/context"
 	"github.com/astaxie/beego/plugins/cors"
 	"github.com/lfq7413/tomato/controllers"
+	"github.com/lfq7413/tomato/job"
 	"github.com/lfq7413/tomato/livequery"
 	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/push"
 )
 
 // Run ...
@@ -22,6 +26,15 @@ func Run() {
 	// 创建必要的索引
 	orm.TomatoDBController.PerformInitialization()
 
+	// 同步类定义文件中的 schema
+	syncSchemas()
+
+	// 启动推送调度器，发送定时推送
+	push.RunScheduler()
+
+	// 启动后台任务调度器，执行 _JobSchedule 中的任务
+	job.RunScheduler()
+
 	if beego.BConfig.RunMode == "dev" {
 		beego.BConfig.WebConfig.DirectoryIndex = true
 		beego.BConfig.WebConfig.StaticDir["/swagger"] = "swagger"
@@ -35,6 +48,39 @@ func Run() {
 	beego.Run()
 }
 
+// syncSchemas 将数据库中的 schema 同步为 SchemaFile 中的定义
+// SchemaDryRun 为 true 时仅打印差异，同步失败时停止启动
+func syncSchemas() {
+	if config.TConfig.SchemaFile == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(config.TConfig.SchemaFile)
+	if err != nil {
+		log.Fatalln("SchemaFile:", err)
+	}
+	definitions, err := orm.ParseSchemaDefinitions(data)
+	if err != nil {
+		log.Fatalln("SchemaFile:", err)
+	}
+	migrations, err := orm.TomatoDBController.PlanSchemaMigrations(definitions, config.TConfig.SchemaStrict)
+	if err != nil {
+		log.Fatalln("SchemaFile:", err)
+	}
+	for _, migration := range migrations {
+		for _, line := range migration.Diff() {
+			log.Println(line)
+		}
+	}
+	if config.TConfig.SchemaDryRun {
+		log.Println("SchemaFile: dry run,", len(migrations), "classes need to be migrated")
+		return
+	}
+	err = orm.TomatoDBController.ApplySchemaMigrations(migrations)
+	if err != nil {
+		log.Fatalln("SchemaFile:", err)
+	}
+}
+
 // RunLiveQueryServer 运行 LiveQuery 服务
 func RunLiveQueryServer(args map[string]string) {
 	// 未设置启动参数时，使用默认参数填充
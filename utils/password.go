@@ -3,28 +3,70 @@ package utils
 import (
 	"crypto/md5"
 	"crypto/sha256"
+	"crypto/subtle"
 	"fmt"
 	"io"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
-// Hash ...
-func Hash(password string) string {
-	h := sha256.New()
-	io.WriteString(h, password)
-	s := fmt.Sprintf("%x", h.Sum(nil))
-	return s
+// Hash 使用 bcrypt 计算密码哈希，每次计算都会生成随机盐
+// cost 为计算强度，取值范围： 4-31 ，超出范围时使用 bcrypt.DefaultCost
+func Hash(password string, cost int) (string, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	h, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
 }
 
-// Compare ...
+// Compare 校验密码，同时支持 bcrypt 哈希与旧版未加盐的 sha256 哈希
 func Compare(password string, hashedPassword string) bool {
 	if password == "" || hashedPassword == "" {
 		return false
 	}
-	s := Hash(password)
-	if s == hashedPassword {
+	if IsLegacyHash(hashedPassword) {
+		s := legacyHash(password)
+		return subtle.ConstantTimeCompare([]byte(s), []byte(hashedPassword)) == 1
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	return err == nil
+}
+
+// NeedsRehash 判断密码哈希是否需要重新计算
+// 旧版 sha256 哈希，或者 bcrypt 计算强度与 cost 不一致时，需要重新计算
+func NeedsRehash(hashedPassword string, cost int) bool {
+	if IsLegacyHash(hashedPassword) {
 		return true
 	}
-	return false
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	c, err := bcrypt.Cost([]byte(hashedPassword))
+	if err != nil {
+		return false
+	}
+	return c != cost
+}
+
+// IsLegacyHash 判断是否为旧版 sha256 哈希，旧版哈希为 64 位十六进制字符串
+func IsLegacyHash(hashedPassword string) bool {
+	if len(hashedPassword) != 64 {
+		return false
+	}
+	return strings.Trim(hashedPassword, "0123456789abcdef") == ""
+}
+
+// legacyHash 旧版未加盐的 sha256 哈希，仅用于校验历史数据
+func legacyHash(password string) string {
+	h := sha256.New()
+	io.WriteString(h, password)
+	s := fmt.Sprintf("%x", h.Sum(nil))
+	return s
 }
 
 // MD5Hash ...
@@ -3,10 +3,20 @@ package utils
 import "testing"
 
 func TestPassword(t *testing.T) {
-	s := Hash("pass")
-	if s != "d74ff0ee8da3b9806b18c877dbf29bbde50b5bd8e4dad7a3a725000feb82e8f1" {
+	s, err := Hash("pass", 4)
+	if err != nil {
+		t.Error("Hash error", err)
+	}
+	if s == "" || IsLegacyHash(s) {
 		t.Error("Hash error", s)
 	}
+	s2, _ := Hash("pass", 4)
+	if s == s2 {
+		t.Error("Hash should be salted", s, s2)
+	}
+	if legacyHash("pass") != "d74ff0ee8da3b9806b18c877dbf29bbde50b5bd8e4dad7a3a725000feb82e8f1" {
+		t.Error("legacyHash error", legacyHash("pass"))
+	}
 }
 
 func TestCompare(t *testing.T) {
@@ -14,4 +24,34 @@ func TestCompare(t *testing.T) {
 	if b == false {
 		t.Error("Compare error", b)
 	}
+	b = Compare("wrong", "d74ff0ee8da3b9806b18c877dbf29bbde50b5bd8e4dad7a3a725000feb82e8f1")
+	if b == true {
+		t.Error("Compare error", b)
+	}
+	s, _ := Hash("pass", 4)
+	b = Compare("pass", s)
+	if b == false {
+		t.Error("Compare error", b)
+	}
+	b = Compare("wrong", s)
+	if b == true {
+		t.Error("Compare error", b)
+	}
+	b = Compare("", s)
+	if b == true {
+		t.Error("Compare error", b)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	if NeedsRehash("d74ff0ee8da3b9806b18c877dbf29bbde50b5bd8e4dad7a3a725000feb82e8f1", 4) == false {
+		t.Error("legacy hash should be rehashed")
+	}
+	s, _ := Hash("pass", 4)
+	if NeedsRehash(s, 4) {
+		t.Error("hash with same cost should not be rehashed")
+	}
+	if NeedsRehash(s, 5) == false {
+		t.Error("hash with different cost should be rehashed")
+	}
 }
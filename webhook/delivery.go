@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lfq7413/tomato/config"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+const deliveryCollection = "_WebhookDelivery"
+
+// Delivery 需要投递的请求
+// FunctionName ClassName TriggerName 用于在 _WebhookDelivery 中标识请求的来源
+type Delivery struct {
+	URL          string
+	Payload      types.M
+	Timeout      time.Duration
+	FunctionName string
+	ClassName    string
+	TriggerName  string
+}
+
+// DeliverAsync 在后台投递请求，失败后按指数退避重试 WebhookRetries 次，全部失败后记录在 _WebhookDelivery 中
+// Payload 在调用时编码，之后调用方继续修改其中的对象或 Context 不影响投递的内容
+func DeliverAsync(d *Delivery) {
+	body, err := json.Marshal(d.Payload)
+	if err != nil {
+		return
+	}
+	go deliver(d, body)
+}
+
+func deliver(d *Delivery, body []byte) {
+	var err error
+	interval := time.Duration(config.TConfig.WebhookRetryInterval) * time.Millisecond
+	attempts := 0
+	for {
+		_, err = Post(d.URL, body, d.Timeout)
+		attempts++
+		if err == nil {
+			return
+		}
+		if attempts > config.TConfig.WebhookRetries {
+			break
+		}
+		time.Sleep(backoff(interval, attempts))
+	}
+	saveFailedDelivery(d, body, attempts, err)
+}
+
+// maxBackoff 重试等待时间的上限
+const maxBackoff = time.Hour
+
+// backoff 第 attempts 次失败后的等待时间，每次翻倍，最长为 maxBackoff
+func backoff(interval time.Duration, attempts int) time.Duration {
+	if interval >= maxBackoff {
+		return maxBackoff
+	}
+	for i := 1; i < attempts; i++ {
+		interval *= 2
+		if interval >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return interval
+}
+
+// saveFailedDelivery 记录投递失败的请求， payload 保存为 JSON 字符串，重新发送时内容不变
+func saveFailedDelivery(d *Delivery, body []byte, attempts int, err error) {
+	delivery := types.M{
+		"objectId":  utils.CreateObjectID(),
+		"url":       d.URL,
+		"payload":   string(body),
+		"timeout":   int(d.Timeout / time.Millisecond),
+		"status":    "failed",
+		"attempts":  attempts,
+		"error":     err.Error(),
+		"createdAt": utils.TimetoString(time.Now().UTC()),
+		// lockdown!
+		"ACL": types.M{},
+	}
+	if d.FunctionName != "" {
+		delivery["functionName"] = d.FunctionName
+	}
+	if d.ClassName != "" {
+		delivery["className"] = d.ClassName
+	}
+	if d.TriggerName != "" {
+		delivery["triggerName"] = d.TriggerName
+	}
+	orm.TomatoDBController.Create(deliveryCollection, delivery, types.M{})
+}
+
+// Replay 重新发送 _WebhookDelivery 中记录的请求，仅发送一次，不再重试
+// 发送成功后状态更新为 succeeded ，失败时记录错误信息并返回错误，返回更新后的记录
+func Replay(objectID string) (types.M, error) {
+	db := orm.TomatoDBController
+	results, err := db.Find(deliveryCollection, types.M{"objectId": objectID}, types.M{})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, errs.E(errs.ObjectNotFound, "Object not found.")
+	}
+	delivery := utils.M(results[0])
+
+	timeout := time.Duration(0)
+	if t, ok := delivery["timeout"].(float64); ok {
+		timeout = time.Duration(t) * time.Millisecond
+	}
+	attempts := 1
+	if a, ok := delivery["attempts"].(float64); ok {
+		attempts = int(a) + 1
+	}
+	_, err = Post(utils.S(delivery["url"]), []byte(utils.S(delivery["payload"])), timeout)
+
+	update := types.M{"attempts": attempts}
+	if err != nil {
+		update["error"] = err.Error()
+	} else {
+		update["status"] = "succeeded"
+		update["error"] = types.M{"__op": "Delete"}
+	}
+	_, updateErr := db.Update(deliveryCollection, types.M{"objectId": objectID}, update, types.M{}, false)
+	if updateErr != nil {
+		return nil, updateErr
+	}
+	if err != nil {
+		return nil, errs.E(errs.WebhookError, err.Error())
+	}
+
+	delivery["attempts"] = attempts
+	delivery["status"] = "succeeded"
+	delete(delivery, "error")
+	return delivery, nil
+}
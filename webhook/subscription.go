@@ -0,0 +1,373 @@
+package webhook
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lfq7413/tomato/config"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/livequery/t"
+	lq "github.com/lfq7413/tomato/livequery/utils"
+	"github.com/lfq7413/tomato/orm"
+	"github.com/lfq7413/tomato/types"
+	"github.com/lfq7413/tomato/utils"
+)
+
+const subscriptionCollection = "_WebhookSubscription"
+
+// 可订阅的数据变更事件
+const (
+	EventCreate = "create"
+	EventUpdate = "update"
+	EventDelete = "delete"
+)
+
+// subscription 缓存在内存中的事件订阅
+type subscription struct {
+	objectID   string
+	url        string
+	classNames map[string]bool
+	events     map[string]bool
+	where      t.M
+	timeout    time.Duration
+}
+
+// matches 检测订阅是否需要接收类 className 的 event 事件
+func (s *subscription) matches(event, className string) bool {
+	return s.events[event] && s.classNames[className]
+}
+
+// matchesObject 检测对象是否符合订阅的 where 条件，匹配规则与 LiveQuery 相同，where 为空时全部符合
+func (s *subscription) matchesObject(object t.M) bool {
+	if len(s.where) == 0 {
+		return true
+	}
+	return lq.MatchesQuery(object, s.where)
+}
+
+var subscriptions = &subscriptionCache{}
+
+// subscriptionCache 订阅的内存缓存，第一次使用时从 _WebhookSubscription 中加载，订阅修改后重新加载
+// 超过 WebhookSubscriptionCacheTTL 后也会重新加载，以同步其他实例中对订阅的修改
+type subscriptionCache struct {
+	mu       sync.RWMutex
+	loaded   bool
+	loadedAt time.Time
+	items    []*subscription
+}
+
+func (c *subscriptionCache) get() []*subscription {
+	c.mu.RLock()
+	if c.loaded && c.expired() == false {
+		items := c.items
+		c.mu.RUnlock()
+		return items
+	}
+	c.mu.RUnlock()
+	c.reload()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.items
+}
+
+// expired 检测缓存是否已过期，调用前需要持有锁
+func (c *subscriptionCache) expired() bool {
+	ttl := config.TConfig.WebhookSubscriptionCacheTTL
+	if ttl <= 0 {
+		return false
+	}
+	return time.Since(c.loadedAt) > time.Duration(ttl)*time.Second
+}
+
+func (c *subscriptionCache) reload() {
+	results, err := orm.TomatoDBController.Find(subscriptionCollection, types.M{}, types.M{})
+	if err != nil {
+		return
+	}
+	items := []*subscription{}
+	for _, v := range results {
+		if item := newSubscription(utils.M(v)); item != nil {
+			items = append(items, item)
+		}
+	}
+	c.mu.Lock()
+	c.items = items
+	c.loaded = true
+	c.loadedAt = time.Now()
+	c.mu.Unlock()
+}
+
+// newSubscription 转换 _WebhookSubscription 中的记录为内存中的订阅，记录无效时返回 nil
+func newSubscription(record types.M) *subscription {
+	if record == nil || utils.S(record["url"]) == "" {
+		return nil
+	}
+	s := &subscription{
+		objectID:   utils.S(record["objectId"]),
+		url:        utils.S(record["url"]),
+		classNames: map[string]bool{},
+		events:     map[string]bool{},
+	}
+	for _, v := range utils.A(record["classNames"]) {
+		s.classNames[utils.S(v)] = true
+	}
+	for _, v := range utils.A(record["events"]) {
+		s.events[utils.S(v)] = true
+	}
+	if where := utils.S(record["where"]); where != "" {
+		if json.Unmarshal([]byte(where), &s.where) != nil {
+			return nil
+		}
+	}
+	if timeout, ok := record["timeout"].(float64); ok {
+		s.timeout = time.Duration(timeout) * time.Millisecond
+	} else if timeout, ok := record["timeout"].(int); ok {
+		s.timeout = time.Duration(timeout) * time.Millisecond
+	}
+	return s
+}
+
+// HasSubscriptions 检测类 className 是否存在事件订阅
+func HasSubscriptions(className string) bool {
+	for _, s := range subscriptions.get() {
+		if s.classNames[className] {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish 发送类 className 的数据变更事件 event 到符合条件的订阅地址，请求在后台投递，不影响当前请求
+// 请求体格式为 {"event":"update","className":"Post","object":{...},"original":{...},"subscriptionId":"xxx","triggeredAt":"xxx"}
+// 仅 update 事件包含 original ，为修改前的对象， where 条件匹配修改后的对象，delete 事件匹配被删除的对象
+func Publish(event, className string, object, original types.M) {
+	if object == nil {
+		return
+	}
+	var current, previous t.M
+	for _, s := range subscriptions.get() {
+		if s.matches(event, className) == false {
+			continue
+		}
+		if current == nil {
+			// 统一转换为 JSON 格式的数据，与 LiveQuery 中收到的对象一致
+			current = toJSONObject(object)
+			if current == nil {
+				return
+			}
+			if event == EventUpdate {
+				previous = toJSONObject(original)
+			}
+		}
+		if s.matchesObject(current) == false {
+			continue
+		}
+		payload := types.M{
+			"event":          event,
+			"className":      className,
+			"object":         current,
+			"subscriptionId": s.objectID,
+			"triggeredAt":    utils.TimetoString(time.Now().UTC()),
+		}
+		if previous != nil {
+			payload["original"] = previous
+		}
+		DeliverAsync(&Delivery{
+			URL:         s.url,
+			Payload:     payload,
+			Timeout:     s.timeout,
+			ClassName:   className,
+			TriggerName: event,
+		})
+	}
+}
+
+// toJSONObject 通过 JSON 编解码复制对象，嵌套的 types.M 等类型转换为 map[string]interface{}
+func toJSONObject(object types.M) t.M {
+	if object == nil {
+		return nil
+	}
+	b, err := json.Marshal(object)
+	if err != nil {
+		return nil
+	}
+	var result t.M
+	if json.Unmarshal(b, &result) != nil {
+		return nil
+	}
+	return result
+}
+
+// GetSubscriptions 获取全部事件订阅
+func GetSubscriptions() (types.S, error) {
+	results, err := orm.TomatoDBController.Find(subscriptionCollection, types.M{}, types.M{"sort": []string{"createdAt"}})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range results {
+		formatSubscription(utils.M(v))
+	}
+	return results, nil
+}
+
+// GetSubscription 获取指定的事件订阅
+func GetSubscription(objectID string) (types.M, error) {
+	results, err := orm.TomatoDBController.Find(subscriptionCollection, types.M{"objectId": objectID}, types.M{"limit": 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, errs.E(errs.ObjectNotFound, "Object not found.")
+	}
+	return formatSubscription(utils.M(results[0])), nil
+}
+
+// CreateSubscription 创建事件订阅，格式如下
+// {
+// 	"url":"https://example.com/events",
+// 	"classNames":["Post","Comment"],
+// 	"events":["create","update","delete"],
+// 	"where":{"published":true},
+// 	"timeout":5000
+// }
+// url classNames events 为必填项， where 与 timeout 可选
+func CreateSubscription(data types.M) (types.M, error) {
+	record, err := parseSubscription(data, true)
+	if err != nil {
+		return nil, err
+	}
+	record["objectId"] = utils.CreateObjectID()
+	record["createdAt"] = utils.TimetoString(time.Now().UTC())
+	// lockdown!
+	record["ACL"] = types.M{}
+	err = orm.TomatoDBController.Create(subscriptionCollection, record, types.M{})
+	if err != nil {
+		return nil, err
+	}
+	subscriptions.reload()
+	return GetSubscription(utils.S(record["objectId"]))
+}
+
+// UpdateSubscription 更新事件订阅，仅更新 data 中包含的字段，格式与 CreateSubscription 相同
+func UpdateSubscription(objectID string, data types.M) (types.M, error) {
+	update, err := parseSubscription(data, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = GetSubscription(objectID); err != nil {
+		return nil, err
+	}
+	_, err = orm.TomatoDBController.Update(subscriptionCollection, types.M{"objectId": objectID}, update, types.M{}, false)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions.reload()
+	return GetSubscription(objectID)
+}
+
+// DeleteSubscription 删除事件订阅
+func DeleteSubscription(objectID string) error {
+	if _, err := GetSubscription(objectID); err != nil {
+		return err
+	}
+	err := orm.TomatoDBController.Destroy(subscriptionCollection, types.M{"objectId": objectID}, types.M{})
+	if err != nil {
+		return err
+	}
+	subscriptions.reload()
+	return nil
+}
+
+// parseSubscription 校验订阅请求，转换为保存在 _WebhookSubscription 中的格式，where 保存为 JSON 字符串
+// required 为 true 时 url classNames events 为必填项
+func parseSubscription(data types.M, required bool) (types.M, error) {
+	if data == nil {
+		return nil, errs.E(errs.WebhookError, "invalid subscription declaration")
+	}
+	record := types.M{}
+
+	if v, ok := data["url"]; ok || required {
+		url, ok := v.(string)
+		if ok == false || url == "" {
+			return nil, errs.E(errs.WebhookError, "url must be a non-empty string")
+		}
+		record["url"] = url
+	}
+
+	if v, ok := data["classNames"]; ok || required {
+		classNames := types.S{}
+		for _, c := range utils.A(v) {
+			className, ok := c.(string)
+			if ok == false || className == "" {
+				return nil, errs.E(errs.WebhookError, "classNames must be an array of class names")
+			}
+			classNames = append(classNames, className)
+		}
+		if len(classNames) == 0 {
+			return nil, errs.E(errs.WebhookError, "classNames must be an array of class names")
+		}
+		record["classNames"] = classNames
+	}
+
+	if v, ok := data["events"]; ok || required {
+		events := types.S{}
+		for _, e := range utils.A(v) {
+			event, _ := e.(string)
+			if event != EventCreate && event != EventUpdate && event != EventDelete {
+				return nil, errs.E(errs.WebhookError, "events must be an array of create, update or delete")
+			}
+			events = append(events, event)
+		}
+		if len(events) == 0 {
+			return nil, errs.E(errs.WebhookError, "events must be an array of create, update or delete")
+		}
+		record["events"] = events
+	}
+
+	if v, ok := data["where"]; ok {
+		if v == nil {
+			record["where"] = types.M{"__op": "Delete"}
+		} else {
+			where := utils.M(v)
+			if where == nil {
+				return nil, errs.E(errs.WebhookError, "where must be an object")
+			}
+			b, err := json.Marshal(where)
+			if err != nil {
+				return nil, errs.E(errs.WebhookError, "where must be an object")
+			}
+			record["where"] = string(b)
+		}
+	}
+
+	if v, ok := data["timeout"]; ok {
+		timeout, ok := v.(float64)
+		if ok == false {
+			if i, isInt := v.(int); isInt {
+				timeout, ok = float64(i), true
+			}
+		}
+		if ok == false || timeout < 0 {
+			return nil, errs.E(errs.WebhookError, "timeout must be a non-negative number")
+		}
+		record["timeout"] = timeout
+	}
+
+	return record, nil
+}
+
+// formatSubscription 转换 _WebhookSubscription 中的记录为返回给客户端的格式，where 解析为对象
+func formatSubscription(record types.M) types.M {
+	if record == nil {
+		return nil
+	}
+	if where := utils.S(record["where"]); where != "" {
+		var w types.M
+		if json.Unmarshal([]byte(where), &w) == nil {
+			record["where"] = w
+		}
+	}
+	delete(record, "ACL")
+	return record
+}
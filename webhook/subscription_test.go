@@ -0,0 +1,230 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lfq7413/tomato/config"
+	"github.com/lfq7413/tomato/errs"
+	"github.com/lfq7413/tomato/types"
+)
+
+func Test_parseSubscription(t *testing.T) {
+	var data types.M
+	var result types.M
+	var err error
+	var expect types.M
+	/*****************************************************************/
+	data = types.M{
+		"url":        "http://example.com",
+		"classNames": []interface{}{"Post", "Comment"},
+		"events":     []interface{}{"create", "delete"},
+		"where":      map[string]interface{}{"published": true},
+		"timeout":    5000.0,
+	}
+	result, err = parseSubscription(data, true)
+	expect = types.M{
+		"url":        "http://example.com",
+		"classNames": types.S{"Post", "Comment"},
+		"events":     types.S{"create", "delete"},
+		"where":      `{"published":true}`,
+		"timeout":    5000.0,
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*****************************************************************/
+	data = types.M{
+		"classNames": []interface{}{"Post"},
+		"events":     []interface{}{"create"},
+	}
+	_, err = parseSubscription(data, true)
+	if reflect.DeepEqual(errs.E(errs.WebhookError, "url must be a non-empty string"), err) == false {
+		t.Error("expect:", "url must be a non-empty string", "result:", err)
+	}
+	/*****************************************************************/
+	data = types.M{
+		"url":        "http://example.com",
+		"classNames": []interface{}{},
+		"events":     []interface{}{"create"},
+	}
+	_, err = parseSubscription(data, true)
+	if reflect.DeepEqual(errs.E(errs.WebhookError, "classNames must be an array of class names"), err) == false {
+		t.Error("expect:", "classNames must be an array of class names", "result:", err)
+	}
+	/*****************************************************************/
+	data = types.M{
+		"url":        "http://example.com",
+		"classNames": []interface{}{"Post"},
+		"events":     []interface{}{"create", "save"},
+	}
+	_, err = parseSubscription(data, true)
+	if reflect.DeepEqual(errs.E(errs.WebhookError, "events must be an array of create, update or delete"), err) == false {
+		t.Error("expect:", "events must be an array of create, update or delete", "result:", err)
+	}
+	/*****************************************************************/
+	data = types.M{
+		"where":   "published",
+		"timeout": 1000.0,
+	}
+	_, err = parseSubscription(data, false)
+	if reflect.DeepEqual(errs.E(errs.WebhookError, "where must be an object"), err) == false {
+		t.Error("expect:", "where must be an object", "result:", err)
+	}
+	/*****************************************************************/
+	data = types.M{
+		"timeout": -1.0,
+	}
+	_, err = parseSubscription(data, false)
+	if reflect.DeepEqual(errs.E(errs.WebhookError, "timeout must be a non-negative number"), err) == false {
+		t.Error("expect:", "timeout must be a non-negative number", "result:", err)
+	}
+	/*****************************************************************/
+	data = types.M{
+		"events": []interface{}{"update"},
+		"where":  nil,
+	}
+	result, err = parseSubscription(data, false)
+	expect = types.M{
+		"events": types.S{"update"},
+		"where":  types.M{"__op": "Delete"},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+}
+
+func Test_newSubscription(t *testing.T) {
+	var record types.M
+	var result *subscription
+	var expect *subscription
+	/*****************************************************************/
+	record = types.M{
+		"objectId":   "1001",
+		"url":        "http://example.com",
+		"classNames": []interface{}{"Post"},
+		"events":     []interface{}{"create", "update"},
+		"where":      `{"published":true}`,
+		"timeout":    5000.0,
+	}
+	result = newSubscription(record)
+	expect = &subscription{
+		objectID:   "1001",
+		url:        "http://example.com",
+		classNames: map[string]bool{"Post": true},
+		events:     map[string]bool{"create": true, "update": true},
+		where:      map[string]interface{}{"published": true},
+		timeout:    5 * time.Second,
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************************/
+	record = types.M{
+		"objectId":   "1001",
+		"classNames": []interface{}{"Post"},
+		"events":     []interface{}{"create"},
+	}
+	result = newSubscription(record)
+	if result != nil {
+		t.Error("expect:", nil, "result:", result)
+	}
+	/*****************************************************************/
+	record = types.M{
+		"objectId":   "1001",
+		"url":        "http://example.com",
+		"classNames": []interface{}{"Post"},
+		"events":     []interface{}{"create"},
+		"where":      `{"published":`,
+	}
+	result = newSubscription(record)
+	if result != nil {
+		t.Error("expect:", nil, "result:", result)
+	}
+}
+
+func Test_subscriptionMatches(t *testing.T) {
+	s := newSubscription(types.M{
+		"objectId":   "1001",
+		"url":        "http://example.com",
+		"classNames": []interface{}{"Post"},
+		"events":     []interface{}{"update"},
+		"where":      `{"score":{"$gt":10},"author.name":"joe"}`,
+	})
+	data := []struct {
+		event     string
+		className string
+		object    types.M
+		expect    bool
+	}{
+		{
+			event:     "update",
+			className: "Post",
+			object:    types.M{"score": 20, "author": types.M{"name": "joe"}},
+			expect:    true,
+		},
+		{
+			event:     "update",
+			className: "Post",
+			object:    types.M{"score": 5, "author": types.M{"name": "joe"}},
+			expect:    false,
+		},
+		{
+			event:     "update",
+			className: "Post",
+			object:    types.M{"score": 20, "author": types.M{"name": "jack"}},
+			expect:    false,
+		},
+		{
+			event:     "create",
+			className: "Post",
+			object:    types.M{"score": 20, "author": types.M{"name": "joe"}},
+			expect:    false,
+		},
+		{
+			event:     "update",
+			className: "Comment",
+			object:    types.M{"score": 20, "author": types.M{"name": "joe"}},
+			expect:    false,
+		},
+	}
+	for _, v := range data {
+		result := s.matches(v.event, v.className) && s.matchesObject(toJSONObject(v.object))
+		if result != v.expect {
+			t.Error(v.event, v.className, v.object, "expect:", v.expect, "result:", result)
+		}
+	}
+	/*****************************************************************/
+	s = newSubscription(types.M{
+		"url":        "http://example.com",
+		"classNames": []interface{}{"Post"},
+		"events":     []interface{}{"delete"},
+	})
+	if s.matchesObject(toJSONObject(types.M{"score": 1})) == false {
+		t.Error("expect:", true, "result:", false)
+	}
+}
+
+func Test_subscriptionCache_expired(t *testing.T) {
+	defer func(ttl int) {
+		config.TConfig.WebhookSubscriptionCacheTTL = ttl
+	}(config.TConfig.WebhookSubscriptionCacheTTL)
+
+	data := []struct {
+		ttl      int
+		loadedAt time.Time
+		expect   bool
+	}{
+		{ttl: 0, loadedAt: time.Now().Add(-time.Hour), expect: false},
+		{ttl: 30, loadedAt: time.Now(), expect: false},
+		{ttl: 30, loadedAt: time.Now().Add(-time.Minute), expect: true},
+	}
+	for i, v := range data {
+		config.TConfig.WebhookSubscriptionCacheTTL = v.ttl
+		c := &subscriptionCache{loaded: true, loadedAt: v.loadedAt}
+		if result := c.expired(); result != v.expect {
+			t.Error(i, "expect:", v.expect, "result:", result)
+		}
+	}
+}
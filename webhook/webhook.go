@@ -0,0 +1,76 @@
+// Package webhook 发送远程回调请求
+// 请求使用 WebhookSigningKey 进行 HMAC-SHA256 签名，异步投递的请求失败后按指数退避重试，
+// 重试全部失败的请求记录在 _WebhookDelivery 中，可通过 Replay 重新发送
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lfq7413/tomato/config"
+)
+
+const (
+	// HeaderKey 兼容旧版本的静态鉴权头
+	HeaderKey = "X-Parse-Webhook-Key"
+	// HeaderTimestamp 请求发送时间，Unix 时间戳，单位为秒
+	HeaderTimestamp = "X-Parse-Webhook-Timestamp"
+	// HeaderSignature 请求签名
+	HeaderSignature = "X-Parse-Webhook-Signature"
+)
+
+// Sign 计算请求签名，签名内容为 timestamp + "." + body ，以 key 进行 HMAC-SHA256 计算，返回十六进制字符串
+// 接收方应使用同样的方法校验签名，并拒绝 timestamp 与当前时间相差过大的请求，防止重放攻击
+func Sign(key string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post 发送签名的 POST 请求，返回响应体
+// timeout 为请求超时时间，为 0 时使用 WebhookTimeout
+// 网络错误、超时或者响应状态码为 5xx 时返回错误
+func Post(url string, body []byte, timeout time.Duration) ([]byte, error) {
+	request, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if key := config.TConfig.WebhookKey; key != "" {
+		request.Header.Set(HeaderKey, key)
+	}
+	// 签名密钥只用于计算签名，不随请求发送，否则截获请求即可伪造签名
+	if key := config.TConfig.WebhookSigningKey; key != "" {
+		timestamp := time.Now().Unix()
+		request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+		request.Header.Set(HeaderSignature, Sign(key, timestamp, body))
+	}
+
+	if timeout <= 0 {
+		timeout = time.Duration(config.TConfig.WebhookTimeout) * time.Millisecond
+	}
+	client := &http.Client{Timeout: timeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	result, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 500 {
+		return nil, fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lfq7413/tomato/config"
+	"github.com/lfq7413/tomato/types"
+)
+
+func Test_Sign(t *testing.T) {
+	result := Sign("key", 1500000000, []byte(`{"a":1}`))
+	if len(result) != 64 {
+		t.Error("expect:", 64, "result:", len(result))
+	}
+	if Sign("key", 1500000000, []byte(`{"a":1}`)) != result {
+		t.Error("expect:", result, "result:", Sign("key", 1500000000, []byte(`{"a":1}`)))
+	}
+	if Sign("key", 1500000001, []byte(`{"a":1}`)) == result {
+		t.Error("expect different signature for different timestamp")
+	}
+	if Sign("other", 1500000000, []byte(`{"a":1}`)) == result {
+		t.Error("expect different signature for different key")
+	}
+}
+
+func Test_Post(t *testing.T) {
+	config.TConfig.WebhookKey = "hello"
+	config.TConfig.WebhookSigningKey = "secret"
+	defer func() {
+		config.TConfig.WebhookKey = ""
+		config.TConfig.WebhookSigningKey = ""
+	}()
+
+	var status int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if r.Header.Get(HeaderSignature) != Sign("secret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// 签名密钥不能随请求发送
+		if r.Header.Get(HeaderKey) != "hello" || strings.Contains(fmt.Sprint(r.Header), "secret") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+			return
+		}
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"success":{}}`))
+	}))
+	defer server.Close()
+	/*****************************************************************/
+	result, err := Post(server.URL, []byte(`{"a":1}`), time.Second)
+	if err != nil || string(result) != `{"success":{}}` {
+		t.Error("expect:", `{"success":{}}`, "result:", string(result), err)
+	}
+	/*****************************************************************/
+	_, err = Post(server.URL+"/slow", []byte(`{"a":1}`), 50*time.Millisecond)
+	if err == nil {
+		t.Error("expect:", "timeout", "result:", nil)
+	}
+	/*****************************************************************/
+	status = http.StatusBadGateway
+	_, err = Post(server.URL, []byte(`{"a":1}`), time.Second)
+	if err == nil || err.Error() != "webhook responded with status 502" {
+		t.Error("expect:", "webhook responded with status 502", "result:", err)
+	}
+}
+
+func Test_DeliverAsync(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- string(body)
+		w.Write([]byte(`{"success":{}}`))
+	}))
+	defer server.Close()
+
+	context := types.M{"step": 1}
+	DeliverAsync(&Delivery{
+		URL:     server.URL,
+		Payload: types.M{"context": context},
+		Timeout: time.Second,
+	})
+	// 投递的内容在调用时已确定，之后修改 Context 不影响投递
+	context["step"] = 2
+	select {
+	case body := <-received:
+		if body != `{"context":{"step":1}}` {
+			t.Error("expect:", `{"context":{"step":1}}`, "result:", body)
+		}
+	case <-time.After(time.Second):
+		t.Error("expect:", "delivered", "result:", "timeout")
+	}
+}
+
+func Test_backoff(t *testing.T) {
+	data := []struct {
+		attempts int
+		expect   time.Duration
+	}{
+		{attempts: 1, expect: time.Second},
+		{attempts: 2, expect: 2 * time.Second},
+		{attempts: 3, expect: 4 * time.Second},
+		{attempts: 13, expect: maxBackoff},
+		{attempts: 100, expect: maxBackoff},
+	}
+	for _, v := range data {
+		result := backoff(time.Second, v.attempts)
+		if result != v.expect {
+			t.Error(v.attempts, "expect:", v.expect, "result:", result)
+		}
+	}
+}